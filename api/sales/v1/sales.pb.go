@@ -0,0 +1,1729 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: sales/v1/sales.proto
+
+// Package sales.v1 is the RPC interface to products, sales and users. It
+// mirrors the REST API: the same operations, the same rules about who may
+// call them and the same errors, reported as gRPC status codes.
+
+package salesv1
+
+import (
+	context "context"
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
+	empty "github.com/golang/protobuf/ptypes/empty"
+	timestamp "github.com/golang/protobuf/ptypes/timestamp"
+	wrappers "github.com/golang/protobuf/ptypes/wrappers"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
+
+// Money is an amount in the minor units of currency, e.g. 1999 USD is $19.99.
+type Money struct {
+	Amount               int64    `protobuf:"varint,1,opt,name=amount,proto3" json:"amount,omitempty"`
+	Currency             string   `protobuf:"bytes,2,opt,name=currency,proto3" json:"currency,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Money) Reset()         { *m = Money{} }
+func (m *Money) String() string { return proto.CompactTextString(m) }
+func (*Money) ProtoMessage()    {}
+func (*Money) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f53d83ec32920531, []int{0}
+}
+
+func (m *Money) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Money.Unmarshal(m, b)
+}
+func (m *Money) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Money.Marshal(b, m, deterministic)
+}
+func (m *Money) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Money.Merge(m, src)
+}
+func (m *Money) XXX_Size() int {
+	return xxx_messageInfo_Money.Size(m)
+}
+func (m *Money) XXX_DiscardUnknown() {
+	xxx_messageInfo_Money.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Money proto.InternalMessageInfo
+
+func (m *Money) GetAmount() int64 {
+	if m != nil {
+		return m.Amount
+	}
+	return 0
+}
+
+func (m *Money) GetCurrency() string {
+	if m != nil {
+		return m.Currency
+	}
+	return ""
+}
+
+type Product struct {
+	Id       string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name     string `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Cost     *Money `protobuf:"bytes,3,opt,name=cost,proto3" json:"cost,omitempty"`
+	Quantity int32  `protobuf:"varint,4,opt,name=quantity,proto3" json:"quantity,omitempty"`
+	Sold     int32  `protobuf:"varint,5,opt,name=sold,proto3" json:"sold,omitempty"`
+	Revenue  *Money `protobuf:"bytes,6,opt,name=revenue,proto3" json:"revenue,omitempty"`
+	// category_id is empty when the product is uncategorised.
+	CategoryId           string               `protobuf:"bytes,7,opt,name=category_id,json=categoryId,proto3" json:"category_id,omitempty"`
+	Tags                 []string             `protobuf:"bytes,8,rep,name=tags,proto3" json:"tags,omitempty"`
+	UserId               string               `protobuf:"bytes,9,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	DateCreated          *timestamp.Timestamp `protobuf:"bytes,10,opt,name=date_created,json=dateCreated,proto3" json:"date_created,omitempty"`
+	DateUpdated          *timestamp.Timestamp `protobuf:"bytes,11,opt,name=date_updated,json=dateUpdated,proto3" json:"date_updated,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
+}
+
+func (m *Product) Reset()         { *m = Product{} }
+func (m *Product) String() string { return proto.CompactTextString(m) }
+func (*Product) ProtoMessage()    {}
+func (*Product) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f53d83ec32920531, []int{1}
+}
+
+func (m *Product) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Product.Unmarshal(m, b)
+}
+func (m *Product) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Product.Marshal(b, m, deterministic)
+}
+func (m *Product) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Product.Merge(m, src)
+}
+func (m *Product) XXX_Size() int {
+	return xxx_messageInfo_Product.Size(m)
+}
+func (m *Product) XXX_DiscardUnknown() {
+	xxx_messageInfo_Product.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Product proto.InternalMessageInfo
+
+func (m *Product) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *Product) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *Product) GetCost() *Money {
+	if m != nil {
+		return m.Cost
+	}
+	return nil
+}
+
+func (m *Product) GetQuantity() int32 {
+	if m != nil {
+		return m.Quantity
+	}
+	return 0
+}
+
+func (m *Product) GetSold() int32 {
+	if m != nil {
+		return m.Sold
+	}
+	return 0
+}
+
+func (m *Product) GetRevenue() *Money {
+	if m != nil {
+		return m.Revenue
+	}
+	return nil
+}
+
+func (m *Product) GetCategoryId() string {
+	if m != nil {
+		return m.CategoryId
+	}
+	return ""
+}
+
+func (m *Product) GetTags() []string {
+	if m != nil {
+		return m.Tags
+	}
+	return nil
+}
+
+func (m *Product) GetUserId() string {
+	if m != nil {
+		return m.UserId
+	}
+	return ""
+}
+
+func (m *Product) GetDateCreated() *timestamp.Timestamp {
+	if m != nil {
+		return m.DateCreated
+	}
+	return nil
+}
+
+func (m *Product) GetDateUpdated() *timestamp.Timestamp {
+	if m != nil {
+		return m.DateUpdated
+	}
+	return nil
+}
+
+type Sale struct {
+	Id                   string               `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	ProductId            string               `protobuf:"bytes,2,opt,name=product_id,json=productId,proto3" json:"product_id,omitempty"`
+	VariantId            string               `protobuf:"bytes,3,opt,name=variant_id,json=variantId,proto3" json:"variant_id,omitempty"`
+	WarehouseId          string               `protobuf:"bytes,4,opt,name=warehouse_id,json=warehouseId,proto3" json:"warehouse_id,omitempty"`
+	Quantity             int32                `protobuf:"varint,5,opt,name=quantity,proto3" json:"quantity,omitempty"`
+	ListPrice            *Money               `protobuf:"bytes,6,opt,name=list_price,json=listPrice,proto3" json:"list_price,omitempty"`
+	Net                  *Money               `protobuf:"bytes,7,opt,name=net,proto3" json:"net,omitempty"`
+	Tax                  *Money               `protobuf:"bytes,8,opt,name=tax,proto3" json:"tax,omitempty"`
+	Paid                 *Money               `protobuf:"bytes,9,opt,name=paid,proto3" json:"paid,omitempty"`
+	Jurisdiction         string               `protobuf:"bytes,10,opt,name=jurisdiction,proto3" json:"jurisdiction,omitempty"`
+	TaxRate              string               `protobuf:"bytes,11,opt,name=tax_rate,json=taxRate,proto3" json:"tax_rate,omitempty"`
+	TaxInclusive         bool                 `protobuf:"varint,12,opt,name=tax_inclusive,json=taxInclusive,proto3" json:"tax_inclusive,omitempty"`
+	CustomerName         string               `protobuf:"bytes,13,opt,name=customer_name,json=customerName,proto3" json:"customer_name,omitempty"`
+	CustomerEmail        string               `protobuf:"bytes,14,opt,name=customer_email,json=customerEmail,proto3" json:"customer_email,omitempty"`
+	ExternalRef          string               `protobuf:"bytes,15,opt,name=external_ref,json=externalRef,proto3" json:"external_ref,omitempty"`
+	OverrideReason       string               `protobuf:"bytes,16,opt,name=override_reason,json=overrideReason,proto3" json:"override_reason,omitempty"`
+	OverriddenBy         string               `protobuf:"bytes,17,opt,name=overridden_by,json=overriddenBy,proto3" json:"overridden_by,omitempty"`
+	DateCreated          *timestamp.Timestamp `protobuf:"bytes,18,opt,name=date_created,json=dateCreated,proto3" json:"date_created,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
+}
+
+func (m *Sale) Reset()         { *m = Sale{} }
+func (m *Sale) String() string { return proto.CompactTextString(m) }
+func (*Sale) ProtoMessage()    {}
+func (*Sale) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f53d83ec32920531, []int{2}
+}
+
+func (m *Sale) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Sale.Unmarshal(m, b)
+}
+func (m *Sale) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Sale.Marshal(b, m, deterministic)
+}
+func (m *Sale) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Sale.Merge(m, src)
+}
+func (m *Sale) XXX_Size() int {
+	return xxx_messageInfo_Sale.Size(m)
+}
+func (m *Sale) XXX_DiscardUnknown() {
+	xxx_messageInfo_Sale.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Sale proto.InternalMessageInfo
+
+func (m *Sale) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *Sale) GetProductId() string {
+	if m != nil {
+		return m.ProductId
+	}
+	return ""
+}
+
+func (m *Sale) GetVariantId() string {
+	if m != nil {
+		return m.VariantId
+	}
+	return ""
+}
+
+func (m *Sale) GetWarehouseId() string {
+	if m != nil {
+		return m.WarehouseId
+	}
+	return ""
+}
+
+func (m *Sale) GetQuantity() int32 {
+	if m != nil {
+		return m.Quantity
+	}
+	return 0
+}
+
+func (m *Sale) GetListPrice() *Money {
+	if m != nil {
+		return m.ListPrice
+	}
+	return nil
+}
+
+func (m *Sale) GetNet() *Money {
+	if m != nil {
+		return m.Net
+	}
+	return nil
+}
+
+func (m *Sale) GetTax() *Money {
+	if m != nil {
+		return m.Tax
+	}
+	return nil
+}
+
+func (m *Sale) GetPaid() *Money {
+	if m != nil {
+		return m.Paid
+	}
+	return nil
+}
+
+func (m *Sale) GetJurisdiction() string {
+	if m != nil {
+		return m.Jurisdiction
+	}
+	return ""
+}
+
+func (m *Sale) GetTaxRate() string {
+	if m != nil {
+		return m.TaxRate
+	}
+	return ""
+}
+
+func (m *Sale) GetTaxInclusive() bool {
+	if m != nil {
+		return m.TaxInclusive
+	}
+	return false
+}
+
+func (m *Sale) GetCustomerName() string {
+	if m != nil {
+		return m.CustomerName
+	}
+	return ""
+}
+
+func (m *Sale) GetCustomerEmail() string {
+	if m != nil {
+		return m.CustomerEmail
+	}
+	return ""
+}
+
+func (m *Sale) GetExternalRef() string {
+	if m != nil {
+		return m.ExternalRef
+	}
+	return ""
+}
+
+func (m *Sale) GetOverrideReason() string {
+	if m != nil {
+		return m.OverrideReason
+	}
+	return ""
+}
+
+func (m *Sale) GetOverriddenBy() string {
+	if m != nil {
+		return m.OverriddenBy
+	}
+	return ""
+}
+
+func (m *Sale) GetDateCreated() *timestamp.Timestamp {
+	if m != nil {
+		return m.DateCreated
+	}
+	return nil
+}
+
+type User struct {
+	Id                   string               `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name                 string               `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Email                string               `protobuf:"bytes,3,opt,name=email,proto3" json:"email,omitempty"`
+	Roles                []string             `protobuf:"bytes,4,rep,name=roles,proto3" json:"roles,omitempty"`
+	DateCreated          *timestamp.Timestamp `protobuf:"bytes,5,opt,name=date_created,json=dateCreated,proto3" json:"date_created,omitempty"`
+	DateUpdated          *timestamp.Timestamp `protobuf:"bytes,6,opt,name=date_updated,json=dateUpdated,proto3" json:"date_updated,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
+}
+
+func (m *User) Reset()         { *m = User{} }
+func (m *User) String() string { return proto.CompactTextString(m) }
+func (*User) ProtoMessage()    {}
+func (*User) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f53d83ec32920531, []int{3}
+}
+
+func (m *User) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_User.Unmarshal(m, b)
+}
+func (m *User) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_User.Marshal(b, m, deterministic)
+}
+func (m *User) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_User.Merge(m, src)
+}
+func (m *User) XXX_Size() int {
+	return xxx_messageInfo_User.Size(m)
+}
+func (m *User) XXX_DiscardUnknown() {
+	xxx_messageInfo_User.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_User proto.InternalMessageInfo
+
+func (m *User) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *User) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *User) GetEmail() string {
+	if m != nil {
+		return m.Email
+	}
+	return ""
+}
+
+func (m *User) GetRoles() []string {
+	if m != nil {
+		return m.Roles
+	}
+	return nil
+}
+
+func (m *User) GetDateCreated() *timestamp.Timestamp {
+	if m != nil {
+		return m.DateCreated
+	}
+	return nil
+}
+
+func (m *User) GetDateUpdated() *timestamp.Timestamp {
+	if m != nil {
+		return m.DateUpdated
+	}
+	return nil
+}
+
+type ListProductsRequest struct {
+	// category_id also matches products in every category below it.
+	CategoryId           string   `protobuf:"bytes,1,opt,name=category_id,json=categoryId,proto3" json:"category_id,omitempty"`
+	Tag                  string   `protobuf:"bytes,2,opt,name=tag,proto3" json:"tag,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListProductsRequest) Reset()         { *m = ListProductsRequest{} }
+func (m *ListProductsRequest) String() string { return proto.CompactTextString(m) }
+func (*ListProductsRequest) ProtoMessage()    {}
+func (*ListProductsRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f53d83ec32920531, []int{4}
+}
+
+func (m *ListProductsRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListProductsRequest.Unmarshal(m, b)
+}
+func (m *ListProductsRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListProductsRequest.Marshal(b, m, deterministic)
+}
+func (m *ListProductsRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListProductsRequest.Merge(m, src)
+}
+func (m *ListProductsRequest) XXX_Size() int {
+	return xxx_messageInfo_ListProductsRequest.Size(m)
+}
+func (m *ListProductsRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListProductsRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListProductsRequest proto.InternalMessageInfo
+
+func (m *ListProductsRequest) GetCategoryId() string {
+	if m != nil {
+		return m.CategoryId
+	}
+	return ""
+}
+
+func (m *ListProductsRequest) GetTag() string {
+	if m != nil {
+		return m.Tag
+	}
+	return ""
+}
+
+type ListProductsResponse struct {
+	Products             []*Product `protobuf:"bytes,1,rep,name=products,proto3" json:"products,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}   `json:"-"`
+	XXX_unrecognized     []byte     `json:"-"`
+	XXX_sizecache        int32      `json:"-"`
+}
+
+func (m *ListProductsResponse) Reset()         { *m = ListProductsResponse{} }
+func (m *ListProductsResponse) String() string { return proto.CompactTextString(m) }
+func (*ListProductsResponse) ProtoMessage()    {}
+func (*ListProductsResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f53d83ec32920531, []int{5}
+}
+
+func (m *ListProductsResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListProductsResponse.Unmarshal(m, b)
+}
+func (m *ListProductsResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListProductsResponse.Marshal(b, m, deterministic)
+}
+func (m *ListProductsResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListProductsResponse.Merge(m, src)
+}
+func (m *ListProductsResponse) XXX_Size() int {
+	return xxx_messageInfo_ListProductsResponse.Size(m)
+}
+func (m *ListProductsResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListProductsResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListProductsResponse proto.InternalMessageInfo
+
+func (m *ListProductsResponse) GetProducts() []*Product {
+	if m != nil {
+		return m.Products
+	}
+	return nil
+}
+
+type GetProductRequest struct {
+	Id                   string   `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetProductRequest) Reset()         { *m = GetProductRequest{} }
+func (m *GetProductRequest) String() string { return proto.CompactTextString(m) }
+func (*GetProductRequest) ProtoMessage()    {}
+func (*GetProductRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f53d83ec32920531, []int{6}
+}
+
+func (m *GetProductRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetProductRequest.Unmarshal(m, b)
+}
+func (m *GetProductRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetProductRequest.Marshal(b, m, deterministic)
+}
+func (m *GetProductRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetProductRequest.Merge(m, src)
+}
+func (m *GetProductRequest) XXX_Size() int {
+	return xxx_messageInfo_GetProductRequest.Size(m)
+}
+func (m *GetProductRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetProductRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetProductRequest proto.InternalMessageInfo
+
+func (m *GetProductRequest) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+type CreateProductRequest struct {
+	Name                 string   `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Sku                  string   `protobuf:"bytes,2,opt,name=sku,proto3" json:"sku,omitempty"`
+	Cost                 *Money   `protobuf:"bytes,3,opt,name=cost,proto3" json:"cost,omitempty"`
+	Quantity             int32    `protobuf:"varint,4,opt,name=quantity,proto3" json:"quantity,omitempty"`
+	WarehouseId          string   `protobuf:"bytes,5,opt,name=warehouse_id,json=warehouseId,proto3" json:"warehouse_id,omitempty"`
+	CategoryId           string   `protobuf:"bytes,6,opt,name=category_id,json=categoryId,proto3" json:"category_id,omitempty"`
+	Tags                 []string `protobuf:"bytes,7,rep,name=tags,proto3" json:"tags,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *CreateProductRequest) Reset()         { *m = CreateProductRequest{} }
+func (m *CreateProductRequest) String() string { return proto.CompactTextString(m) }
+func (*CreateProductRequest) ProtoMessage()    {}
+func (*CreateProductRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f53d83ec32920531, []int{7}
+}
+
+func (m *CreateProductRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CreateProductRequest.Unmarshal(m, b)
+}
+func (m *CreateProductRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CreateProductRequest.Marshal(b, m, deterministic)
+}
+func (m *CreateProductRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CreateProductRequest.Merge(m, src)
+}
+func (m *CreateProductRequest) XXX_Size() int {
+	return xxx_messageInfo_CreateProductRequest.Size(m)
+}
+func (m *CreateProductRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_CreateProductRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CreateProductRequest proto.InternalMessageInfo
+
+func (m *CreateProductRequest) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *CreateProductRequest) GetSku() string {
+	if m != nil {
+		return m.Sku
+	}
+	return ""
+}
+
+func (m *CreateProductRequest) GetCost() *Money {
+	if m != nil {
+		return m.Cost
+	}
+	return nil
+}
+
+func (m *CreateProductRequest) GetQuantity() int32 {
+	if m != nil {
+		return m.Quantity
+	}
+	return 0
+}
+
+func (m *CreateProductRequest) GetWarehouseId() string {
+	if m != nil {
+		return m.WarehouseId
+	}
+	return ""
+}
+
+func (m *CreateProductRequest) GetCategoryId() string {
+	if m != nil {
+		return m.CategoryId
+	}
+	return ""
+}
+
+func (m *CreateProductRequest) GetTags() []string {
+	if m != nil {
+		return m.Tags
+	}
+	return nil
+}
+
+// UpdateProductRequest changes only the fields that are set. An empty
+// category_id uncategorises the product.
+type UpdateProductRequest struct {
+	Id                   string                `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name                 *wrappers.StringValue `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Cost                 *Money                `protobuf:"bytes,3,opt,name=cost,proto3" json:"cost,omitempty"`
+	Quantity             *wrappers.Int32Value  `protobuf:"bytes,4,opt,name=quantity,proto3" json:"quantity,omitempty"`
+	CategoryId           *wrappers.StringValue `protobuf:"bytes,5,opt,name=category_id,json=categoryId,proto3" json:"category_id,omitempty"`
+	Tags                 *Tags                 `protobuf:"bytes,6,opt,name=tags,proto3" json:"tags,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}              `json:"-"`
+	XXX_unrecognized     []byte                `json:"-"`
+	XXX_sizecache        int32                 `json:"-"`
+}
+
+func (m *UpdateProductRequest) Reset()         { *m = UpdateProductRequest{} }
+func (m *UpdateProductRequest) String() string { return proto.CompactTextString(m) }
+func (*UpdateProductRequest) ProtoMessage()    {}
+func (*UpdateProductRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f53d83ec32920531, []int{8}
+}
+
+func (m *UpdateProductRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_UpdateProductRequest.Unmarshal(m, b)
+}
+func (m *UpdateProductRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_UpdateProductRequest.Marshal(b, m, deterministic)
+}
+func (m *UpdateProductRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_UpdateProductRequest.Merge(m, src)
+}
+func (m *UpdateProductRequest) XXX_Size() int {
+	return xxx_messageInfo_UpdateProductRequest.Size(m)
+}
+func (m *UpdateProductRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_UpdateProductRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_UpdateProductRequest proto.InternalMessageInfo
+
+func (m *UpdateProductRequest) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *UpdateProductRequest) GetName() *wrappers.StringValue {
+	if m != nil {
+		return m.Name
+	}
+	return nil
+}
+
+func (m *UpdateProductRequest) GetCost() *Money {
+	if m != nil {
+		return m.Cost
+	}
+	return nil
+}
+
+func (m *UpdateProductRequest) GetQuantity() *wrappers.Int32Value {
+	if m != nil {
+		return m.Quantity
+	}
+	return nil
+}
+
+func (m *UpdateProductRequest) GetCategoryId() *wrappers.StringValue {
+	if m != nil {
+		return m.CategoryId
+	}
+	return nil
+}
+
+func (m *UpdateProductRequest) GetTags() *Tags {
+	if m != nil {
+		return m.Tags
+	}
+	return nil
+}
+
+// Tags replaces every tag of a product, so an empty list clears them.
+type Tags struct {
+	Tags                 []string `protobuf:"bytes,1,rep,name=tags,proto3" json:"tags,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Tags) Reset()         { *m = Tags{} }
+func (m *Tags) String() string { return proto.CompactTextString(m) }
+func (*Tags) ProtoMessage()    {}
+func (*Tags) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f53d83ec32920531, []int{9}
+}
+
+func (m *Tags) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Tags.Unmarshal(m, b)
+}
+func (m *Tags) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Tags.Marshal(b, m, deterministic)
+}
+func (m *Tags) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Tags.Merge(m, src)
+}
+func (m *Tags) XXX_Size() int {
+	return xxx_messageInfo_Tags.Size(m)
+}
+func (m *Tags) XXX_DiscardUnknown() {
+	xxx_messageInfo_Tags.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Tags proto.InternalMessageInfo
+
+func (m *Tags) GetTags() []string {
+	if m != nil {
+		return m.Tags
+	}
+	return nil
+}
+
+type DeleteProductRequest struct {
+	Id                   string   `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DeleteProductRequest) Reset()         { *m = DeleteProductRequest{} }
+func (m *DeleteProductRequest) String() string { return proto.CompactTextString(m) }
+func (*DeleteProductRequest) ProtoMessage()    {}
+func (*DeleteProductRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f53d83ec32920531, []int{10}
+}
+
+func (m *DeleteProductRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DeleteProductRequest.Unmarshal(m, b)
+}
+func (m *DeleteProductRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DeleteProductRequest.Marshal(b, m, deterministic)
+}
+func (m *DeleteProductRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DeleteProductRequest.Merge(m, src)
+}
+func (m *DeleteProductRequest) XXX_Size() int {
+	return xxx_messageInfo_DeleteProductRequest.Size(m)
+}
+func (m *DeleteProductRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_DeleteProductRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DeleteProductRequest proto.InternalMessageInfo
+
+func (m *DeleteProductRequest) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+// AddSaleRequest sells quantity of a product. Setting paid overrides the
+// computed price and needs an override_reason.
+type AddSaleRequest struct {
+	ProductId            string   `protobuf:"bytes,1,opt,name=product_id,json=productId,proto3" json:"product_id,omitempty"`
+	VariantId            string   `protobuf:"bytes,2,opt,name=variant_id,json=variantId,proto3" json:"variant_id,omitempty"`
+	WarehouseId          string   `protobuf:"bytes,3,opt,name=warehouse_id,json=warehouseId,proto3" json:"warehouse_id,omitempty"`
+	Quantity             int32    `protobuf:"varint,4,opt,name=quantity,proto3" json:"quantity,omitempty"`
+	Coupon               string   `protobuf:"bytes,5,opt,name=coupon,proto3" json:"coupon,omitempty"`
+	Jurisdiction         string   `protobuf:"bytes,6,opt,name=jurisdiction,proto3" json:"jurisdiction,omitempty"`
+	CustomerName         string   `protobuf:"bytes,7,opt,name=customer_name,json=customerName,proto3" json:"customer_name,omitempty"`
+	CustomerEmail        string   `protobuf:"bytes,8,opt,name=customer_email,json=customerEmail,proto3" json:"customer_email,omitempty"`
+	ExternalRef          string   `protobuf:"bytes,9,opt,name=external_ref,json=externalRef,proto3" json:"external_ref,omitempty"`
+	Paid                 *Money   `protobuf:"bytes,10,opt,name=paid,proto3" json:"paid,omitempty"`
+	OverrideReason       string   `protobuf:"bytes,11,opt,name=override_reason,json=overrideReason,proto3" json:"override_reason,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *AddSaleRequest) Reset()         { *m = AddSaleRequest{} }
+func (m *AddSaleRequest) String() string { return proto.CompactTextString(m) }
+func (*AddSaleRequest) ProtoMessage()    {}
+func (*AddSaleRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f53d83ec32920531, []int{11}
+}
+
+func (m *AddSaleRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_AddSaleRequest.Unmarshal(m, b)
+}
+func (m *AddSaleRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_AddSaleRequest.Marshal(b, m, deterministic)
+}
+func (m *AddSaleRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_AddSaleRequest.Merge(m, src)
+}
+func (m *AddSaleRequest) XXX_Size() int {
+	return xxx_messageInfo_AddSaleRequest.Size(m)
+}
+func (m *AddSaleRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_AddSaleRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_AddSaleRequest proto.InternalMessageInfo
+
+func (m *AddSaleRequest) GetProductId() string {
+	if m != nil {
+		return m.ProductId
+	}
+	return ""
+}
+
+func (m *AddSaleRequest) GetVariantId() string {
+	if m != nil {
+		return m.VariantId
+	}
+	return ""
+}
+
+func (m *AddSaleRequest) GetWarehouseId() string {
+	if m != nil {
+		return m.WarehouseId
+	}
+	return ""
+}
+
+func (m *AddSaleRequest) GetQuantity() int32 {
+	if m != nil {
+		return m.Quantity
+	}
+	return 0
+}
+
+func (m *AddSaleRequest) GetCoupon() string {
+	if m != nil {
+		return m.Coupon
+	}
+	return ""
+}
+
+func (m *AddSaleRequest) GetJurisdiction() string {
+	if m != nil {
+		return m.Jurisdiction
+	}
+	return ""
+}
+
+func (m *AddSaleRequest) GetCustomerName() string {
+	if m != nil {
+		return m.CustomerName
+	}
+	return ""
+}
+
+func (m *AddSaleRequest) GetCustomerEmail() string {
+	if m != nil {
+		return m.CustomerEmail
+	}
+	return ""
+}
+
+func (m *AddSaleRequest) GetExternalRef() string {
+	if m != nil {
+		return m.ExternalRef
+	}
+	return ""
+}
+
+func (m *AddSaleRequest) GetPaid() *Money {
+	if m != nil {
+		return m.Paid
+	}
+	return nil
+}
+
+func (m *AddSaleRequest) GetOverrideReason() string {
+	if m != nil {
+		return m.OverrideReason
+	}
+	return ""
+}
+
+type ListSalesRequest struct {
+	ProductId            string   `protobuf:"bytes,1,opt,name=product_id,json=productId,proto3" json:"product_id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListSalesRequest) Reset()         { *m = ListSalesRequest{} }
+func (m *ListSalesRequest) String() string { return proto.CompactTextString(m) }
+func (*ListSalesRequest) ProtoMessage()    {}
+func (*ListSalesRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f53d83ec32920531, []int{12}
+}
+
+func (m *ListSalesRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListSalesRequest.Unmarshal(m, b)
+}
+func (m *ListSalesRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListSalesRequest.Marshal(b, m, deterministic)
+}
+func (m *ListSalesRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListSalesRequest.Merge(m, src)
+}
+func (m *ListSalesRequest) XXX_Size() int {
+	return xxx_messageInfo_ListSalesRequest.Size(m)
+}
+func (m *ListSalesRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListSalesRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListSalesRequest proto.InternalMessageInfo
+
+func (m *ListSalesRequest) GetProductId() string {
+	if m != nil {
+		return m.ProductId
+	}
+	return ""
+}
+
+type ListSalesResponse struct {
+	Sales                []*Sale  `protobuf:"bytes,1,rep,name=sales,proto3" json:"sales,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListSalesResponse) Reset()         { *m = ListSalesResponse{} }
+func (m *ListSalesResponse) String() string { return proto.CompactTextString(m) }
+func (*ListSalesResponse) ProtoMessage()    {}
+func (*ListSalesResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f53d83ec32920531, []int{13}
+}
+
+func (m *ListSalesResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListSalesResponse.Unmarshal(m, b)
+}
+func (m *ListSalesResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListSalesResponse.Marshal(b, m, deterministic)
+}
+func (m *ListSalesResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListSalesResponse.Merge(m, src)
+}
+func (m *ListSalesResponse) XXX_Size() int {
+	return xxx_messageInfo_ListSalesResponse.Size(m)
+}
+func (m *ListSalesResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListSalesResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListSalesResponse proto.InternalMessageInfo
+
+func (m *ListSalesResponse) GetSales() []*Sale {
+	if m != nil {
+		return m.Sales
+	}
+	return nil
+}
+
+type TokenRequest struct {
+	Email                string   `protobuf:"bytes,1,opt,name=email,proto3" json:"email,omitempty"`
+	Password             string   `protobuf:"bytes,2,opt,name=password,proto3" json:"password,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *TokenRequest) Reset()         { *m = TokenRequest{} }
+func (m *TokenRequest) String() string { return proto.CompactTextString(m) }
+func (*TokenRequest) ProtoMessage()    {}
+func (*TokenRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f53d83ec32920531, []int{14}
+}
+
+func (m *TokenRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_TokenRequest.Unmarshal(m, b)
+}
+func (m *TokenRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_TokenRequest.Marshal(b, m, deterministic)
+}
+func (m *TokenRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TokenRequest.Merge(m, src)
+}
+func (m *TokenRequest) XXX_Size() int {
+	return xxx_messageInfo_TokenRequest.Size(m)
+}
+func (m *TokenRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_TokenRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TokenRequest proto.InternalMessageInfo
+
+func (m *TokenRequest) GetEmail() string {
+	if m != nil {
+		return m.Email
+	}
+	return ""
+}
+
+func (m *TokenRequest) GetPassword() string {
+	if m != nil {
+		return m.Password
+	}
+	return ""
+}
+
+type TokenResponse struct {
+	Token                string   `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *TokenResponse) Reset()         { *m = TokenResponse{} }
+func (m *TokenResponse) String() string { return proto.CompactTextString(m) }
+func (*TokenResponse) ProtoMessage()    {}
+func (*TokenResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f53d83ec32920531, []int{15}
+}
+
+func (m *TokenResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_TokenResponse.Unmarshal(m, b)
+}
+func (m *TokenResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_TokenResponse.Marshal(b, m, deterministic)
+}
+func (m *TokenResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TokenResponse.Merge(m, src)
+}
+func (m *TokenResponse) XXX_Size() int {
+	return xxx_messageInfo_TokenResponse.Size(m)
+}
+func (m *TokenResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_TokenResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TokenResponse proto.InternalMessageInfo
+
+func (m *TokenResponse) GetToken() string {
+	if m != nil {
+		return m.Token
+	}
+	return ""
+}
+
+type CreateUserRequest struct {
+	Name                 string   `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Email                string   `protobuf:"bytes,2,opt,name=email,proto3" json:"email,omitempty"`
+	Roles                []string `protobuf:"bytes,3,rep,name=roles,proto3" json:"roles,omitempty"`
+	Password             string   `protobuf:"bytes,4,opt,name=password,proto3" json:"password,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *CreateUserRequest) Reset()         { *m = CreateUserRequest{} }
+func (m *CreateUserRequest) String() string { return proto.CompactTextString(m) }
+func (*CreateUserRequest) ProtoMessage()    {}
+func (*CreateUserRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_f53d83ec32920531, []int{16}
+}
+
+func (m *CreateUserRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CreateUserRequest.Unmarshal(m, b)
+}
+func (m *CreateUserRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CreateUserRequest.Marshal(b, m, deterministic)
+}
+func (m *CreateUserRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CreateUserRequest.Merge(m, src)
+}
+func (m *CreateUserRequest) XXX_Size() int {
+	return xxx_messageInfo_CreateUserRequest.Size(m)
+}
+func (m *CreateUserRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_CreateUserRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CreateUserRequest proto.InternalMessageInfo
+
+func (m *CreateUserRequest) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *CreateUserRequest) GetEmail() string {
+	if m != nil {
+		return m.Email
+	}
+	return ""
+}
+
+func (m *CreateUserRequest) GetRoles() []string {
+	if m != nil {
+		return m.Roles
+	}
+	return nil
+}
+
+func (m *CreateUserRequest) GetPassword() string {
+	if m != nil {
+		return m.Password
+	}
+	return ""
+}
+
+func init() {
+	proto.RegisterType((*Money)(nil), "sales.v1.Money")
+	proto.RegisterType((*Product)(nil), "sales.v1.Product")
+	proto.RegisterType((*Sale)(nil), "sales.v1.Sale")
+	proto.RegisterType((*User)(nil), "sales.v1.User")
+	proto.RegisterType((*ListProductsRequest)(nil), "sales.v1.ListProductsRequest")
+	proto.RegisterType((*ListProductsResponse)(nil), "sales.v1.ListProductsResponse")
+	proto.RegisterType((*GetProductRequest)(nil), "sales.v1.GetProductRequest")
+	proto.RegisterType((*CreateProductRequest)(nil), "sales.v1.CreateProductRequest")
+	proto.RegisterType((*UpdateProductRequest)(nil), "sales.v1.UpdateProductRequest")
+	proto.RegisterType((*Tags)(nil), "sales.v1.Tags")
+	proto.RegisterType((*DeleteProductRequest)(nil), "sales.v1.DeleteProductRequest")
+	proto.RegisterType((*AddSaleRequest)(nil), "sales.v1.AddSaleRequest")
+	proto.RegisterType((*ListSalesRequest)(nil), "sales.v1.ListSalesRequest")
+	proto.RegisterType((*ListSalesResponse)(nil), "sales.v1.ListSalesResponse")
+	proto.RegisterType((*TokenRequest)(nil), "sales.v1.TokenRequest")
+	proto.RegisterType((*TokenResponse)(nil), "sales.v1.TokenResponse")
+	proto.RegisterType((*CreateUserRequest)(nil), "sales.v1.CreateUserRequest")
+}
+
+func init() {
+	proto.RegisterFile("sales/v1/sales.proto", fileDescriptor_f53d83ec32920531)
+}
+
+var fileDescriptor_f53d83ec32920531 = []byte{
+	// 1236 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xa4, 0x57, 0xdf, 0x8e, 0xdb, 0xc4,
+	0x17, 0x96, 0x93, 0x38, 0x7f, 0x4e, 0xb2, 0xdb, 0xdd, 0xf9, 0x45, 0x5b, 0xff, 0xbc, 0xb4, 0x4d,
+	0x5d, 0x0a, 0x41, 0xa2, 0x09, 0x9b, 0x5e, 0x40, 0x55, 0x2a, 0x41, 0x69, 0x29, 0x91, 0x28, 0xaa,
+	0xdc, 0x96, 0x0b, 0x6e, 0xa2, 0x59, 0x7b, 0x36, 0x35, 0x75, 0x3c, 0xee, 0xcc, 0x38, 0x4d, 0x5e,
+	0x80, 0x4b, 0xee, 0x79, 0x05, 0x9e, 0x07, 0xde, 0x81, 0x57, 0xe0, 0x0e, 0xcd, 0xf8, 0x4f, 0x1c,
+	0xdb, 0xd9, 0xa6, 0xea, 0x9d, 0xcf, 0x39, 0xdf, 0x9c, 0x39, 0x73, 0xce, 0x37, 0xdf, 0xc8, 0xd0,
+	0xe7, 0xd8, 0x27, 0x7c, 0xbc, 0x3c, 0x1b, 0xab, 0x8f, 0x51, 0xc8, 0xa8, 0xa0, 0xa8, 0x1d, 0x1b,
+	0xcb, 0x33, 0xf3, 0x74, 0x4e, 0xe9, 0xdc, 0x27, 0x63, 0xe5, 0x3f, 0x8f, 0x2e, 0xc6, 0x64, 0x11,
+	0x8a, 0x75, 0x0c, 0x33, 0x6f, 0x14, 0x83, 0xc2, 0x5b, 0x10, 0x2e, 0xf0, 0x22, 0x4c, 0x00, 0xd7,
+	0x8b, 0x80, 0xb7, 0x0c, 0x87, 0x21, 0x61, 0xc9, 0x3e, 0xd6, 0x7d, 0xd0, 0x9f, 0xd2, 0x80, 0xac,
+	0xd1, 0x09, 0x34, 0xf1, 0x82, 0x46, 0x81, 0x30, 0xb4, 0x81, 0x36, 0xac, 0xdb, 0x89, 0x85, 0x4c,
+	0x68, 0x3b, 0x11, 0x63, 0x24, 0x70, 0xd6, 0x46, 0x6d, 0xa0, 0x0d, 0x3b, 0x76, 0x66, 0x5b, 0xff,
+	0xd6, 0xa0, 0xf5, 0x8c, 0x51, 0x37, 0x72, 0x04, 0x3a, 0x84, 0x9a, 0xe7, 0xaa, 0xb5, 0x1d, 0xbb,
+	0xe6, 0xb9, 0x08, 0x41, 0x23, 0xc0, 0x0b, 0x92, 0xac, 0x51, 0xdf, 0xe8, 0x16, 0x34, 0x1c, 0xca,
+	0x85, 0x51, 0x1f, 0x68, 0xc3, 0xee, 0xe4, 0xca, 0x28, 0x3d, 0xe3, 0x48, 0x95, 0x60, 0xab, 0xa0,
+	0xdc, 0xf0, 0x4d, 0x84, 0x03, 0xe1, 0x89, 0xb5, 0xd1, 0x18, 0x68, 0x43, 0xdd, 0xce, 0x6c, 0x99,
+	0x94, 0x53, 0xdf, 0x35, 0x74, 0xe5, 0x57, 0xdf, 0xe8, 0x33, 0x68, 0x31, 0xb2, 0x24, 0x41, 0x44,
+	0x8c, 0x66, 0x75, 0xde, 0x34, 0x8e, 0x6e, 0x40, 0xd7, 0xc1, 0x82, 0xcc, 0x29, 0x5b, 0xcf, 0x3c,
+	0xd7, 0x68, 0xa9, 0xd2, 0x20, 0x75, 0x4d, 0x55, 0xd1, 0x02, 0xcf, 0xb9, 0xd1, 0x1e, 0xd4, 0x65,
+	0xd1, 0xf2, 0x1b, 0x5d, 0x85, 0x56, 0xc4, 0x09, 0x93, 0x0b, 0x3a, 0x6a, 0x41, 0x53, 0x9a, 0x53,
+	0x17, 0x3d, 0x80, 0x9e, 0x8b, 0x05, 0x99, 0x39, 0x8c, 0x60, 0x41, 0x5c, 0x03, 0xd4, 0xee, 0xe6,
+	0x28, 0xee, 0xf8, 0x28, 0xed, 0xf8, 0xe8, 0x45, 0x3a, 0x12, 0xbb, 0x2b, 0xf1, 0xdf, 0xc5, 0xf0,
+	0x6c, 0x79, 0x14, 0xba, 0x6a, 0x79, 0x77, 0xbf, 0xe5, 0x2f, 0x63, 0xb8, 0xf5, 0xbb, 0x0e, 0x8d,
+	0xe7, 0xd8, 0x27, 0xa5, 0xc6, 0x5f, 0x03, 0x08, 0xe3, 0x99, 0xc8, 0x92, 0xe3, 0xf6, 0x77, 0x12,
+	0xcf, 0x54, 0x85, 0x97, 0x98, 0x79, 0x38, 0x50, 0xe1, 0x7a, 0x1c, 0x4e, 0x3c, 0x53, 0x17, 0xdd,
+	0x84, 0xde, 0x5b, 0xcc, 0xc8, 0x2b, 0x1a, 0x71, 0x22, 0x01, 0x0d, 0x05, 0xe8, 0x66, 0xbe, 0xa9,
+	0xbb, 0x35, 0x20, 0xbd, 0x30, 0xa0, 0x11, 0x80, 0xef, 0x71, 0x31, 0x0b, 0x99, 0xe7, 0xec, 0x9c,
+	0x47, 0x47, 0x42, 0x9e, 0x49, 0x04, 0xba, 0x09, 0xf5, 0x80, 0x08, 0xa3, 0x55, 0x0d, 0x94, 0x31,
+	0x09, 0x11, 0x78, 0x65, 0xb4, 0x77, 0x40, 0x04, 0x5e, 0x49, 0x5e, 0x85, 0x38, 0x99, 0x4f, 0x15,
+	0xaf, 0x64, 0x10, 0x59, 0xd0, 0xfb, 0x35, 0x62, 0x1e, 0x77, 0x3d, 0x47, 0x78, 0x34, 0x50, 0xe3,
+	0xea, 0xd8, 0x5b, 0x3e, 0xf4, 0x7f, 0x68, 0x0b, 0xbc, 0x9a, 0x31, 0x2c, 0x88, 0x9a, 0x47, 0xc7,
+	0x6e, 0x09, 0xbc, 0xb2, 0xb1, 0x90, 0xdc, 0x3d, 0x90, 0x21, 0x2f, 0x70, 0xfc, 0x88, 0x7b, 0x4b,
+	0x62, 0xf4, 0x06, 0xda, 0xb0, 0x6d, 0xf7, 0x04, 0x5e, 0x4d, 0x53, 0x9f, 0x04, 0x39, 0x11, 0x17,
+	0x74, 0x41, 0xd8, 0x4c, 0xb1, 0xff, 0x20, 0xde, 0x24, 0x75, 0xfe, 0x24, 0x6f, 0xc1, 0x6d, 0x38,
+	0xcc, 0x40, 0x64, 0x81, 0x3d, 0xdf, 0x38, 0x54, 0xa8, 0x6c, 0xe9, 0x63, 0xe9, 0x94, 0x93, 0x20,
+	0x2b, 0x41, 0x58, 0x80, 0xfd, 0x19, 0x23, 0x17, 0xc6, 0x95, 0x78, 0x12, 0xa9, 0xcf, 0x26, 0x17,
+	0xe8, 0x53, 0xb8, 0x42, 0x97, 0x84, 0x31, 0xcf, 0x25, 0x33, 0x46, 0x30, 0xa7, 0x81, 0x71, 0xa4,
+	0x50, 0x87, 0xa9, 0xdb, 0x56, 0x5e, 0x59, 0x57, 0xe2, 0x71, 0x49, 0x30, 0x3b, 0x5f, 0x1b, 0xc7,
+	0x71, 0x5d, 0x1b, 0xe7, 0xc3, 0x75, 0x89, 0xcf, 0xe8, 0xbd, 0xf8, 0x6c, 0xfd, 0xa5, 0x41, 0xe3,
+	0x25, 0x27, 0x6c, 0x2f, 0x25, 0xe8, 0x83, 0x1e, 0x1f, 0x3d, 0x26, 0x60, 0x6c, 0x48, 0x2f, 0xa3,
+	0x3e, 0xe1, 0x46, 0x43, 0xdd, 0xbf, 0xd8, 0x28, 0xd5, 0xa5, 0x7f, 0xd8, 0x3d, 0x6b, 0xbe, 0xdf,
+	0x3d, 0xfb, 0x01, 0xfe, 0xf7, 0xa3, 0xa2, 0xab, 0xba, 0x40, 0xdc, 0x26, 0x6f, 0x22, 0xc2, 0x45,
+	0x51, 0x4a, 0xb4, 0x92, 0x94, 0x1c, 0x49, 0xda, 0xce, 0x93, 0x43, 0xcb, 0x4f, 0xeb, 0x31, 0xf4,
+	0xb7, 0x33, 0xf1, 0x90, 0x06, 0x9c, 0xa0, 0x3b, 0xd0, 0x4e, 0xae, 0x27, 0x37, 0xb4, 0x41, 0x7d,
+	0xd8, 0x9d, 0x1c, 0x6f, 0x18, 0x9c, 0xa0, 0xed, 0x0c, 0x62, 0xdd, 0x82, 0xe3, 0x27, 0x24, 0xcd,
+	0x92, 0x96, 0x53, 0xe8, 0xb9, 0xf5, 0xb7, 0x06, 0xfd, 0xb8, 0x01, 0x05, 0x60, 0x3a, 0x0c, 0x2d,
+	0x37, 0x8c, 0x23, 0xa8, 0xf3, 0xd7, 0x51, 0x5a, 0x2a, 0x7f, 0x1d, 0x7d, 0xb8, 0x50, 0x17, 0x65,
+	0x44, 0x2f, 0xcb, 0x48, 0xa1, 0x83, 0xcd, 0x9d, 0x62, 0xdc, 0xda, 0x88, 0xb1, 0xf5, 0x47, 0x0d,
+	0xfa, 0xf1, 0x64, 0x2e, 0x6f, 0x00, 0xfa, 0x22, 0x47, 0xba, 0xee, 0xe4, 0xa3, 0xd2, 0xb4, 0x9f,
+	0x0b, 0xe6, 0x05, 0xf3, 0x9f, 0xb1, 0x1f, 0x91, 0xf7, 0x79, 0x9c, 0xbe, 0x2c, 0x9c, 0xb9, 0x3b,
+	0x39, 0x2d, 0xa5, 0x9e, 0x06, 0xe2, 0xee, 0x24, 0xce, 0xbc, 0x69, 0xc8, 0x83, 0xed, 0xd3, 0xea,
+	0x7b, 0x94, 0x95, 0xef, 0x85, 0x95, 0xf4, 0x22, 0x26, 0xef, 0xe1, 0xa6, 0xb8, 0x17, 0x78, 0xce,
+	0x93, 0xde, 0x98, 0xd0, 0x90, 0x56, 0xd6, 0x37, 0x2d, 0xd7, 0xb7, 0x4f, 0xa0, 0xff, 0x88, 0xf8,
+	0xe4, 0x5d, 0x6d, 0xb3, 0x7e, 0xab, 0xc3, 0xe1, 0xb7, 0xae, 0x2b, 0x1f, 0x96, 0x14, 0xb2, 0xfd,
+	0x9e, 0x68, 0x97, 0xbf, 0x27, 0xb5, 0x77, 0xbd, 0x27, 0xf5, 0xcb, 0xdf, 0x93, 0x22, 0x8f, 0x4e,
+	0xa0, 0xe9, 0xd0, 0x28, 0xa4, 0x41, 0xc2, 0xa0, 0xc4, 0x2a, 0x89, 0x79, 0xb3, 0x42, 0xcc, 0x4b,
+	0x62, 0xdc, 0xda, 0x4b, 0x8c, 0xdb, 0xfb, 0x88, 0x71, 0xa7, 0x2c, 0xc6, 0xe9, 0x23, 0x04, 0x97,
+	0x3d, 0x42, 0x15, 0x8a, 0xdd, 0xad, 0x52, 0x6c, 0xeb, 0x0c, 0x8e, 0xa4, 0x58, 0xc8, 0x41, 0xf0,
+	0xfd, 0x26, 0x61, 0xdd, 0x83, 0xe3, 0xdc, 0x92, 0x44, 0x5c, 0x3e, 0x06, 0x5d, 0x15, 0x92, 0x28,
+	0x4b, 0x8e, 0x39, 0x6a, 0xc6, 0x71, 0xd0, 0xfa, 0x06, 0x7a, 0x2f, 0xe8, 0x6b, 0x12, 0xa4, 0x3b,
+	0x65, 0xf2, 0xac, 0xe5, 0xe5, 0xd9, 0x84, 0x76, 0x88, 0x39, 0x7f, 0x4b, 0x59, 0x3a, 0xe8, 0xcc,
+	0xb6, 0x6e, 0xc3, 0x41, 0x92, 0x21, 0xd9, 0xb8, 0x0f, 0xba, 0x90, 0x8e, 0x34, 0x85, 0x32, 0x2c,
+	0x0a, 0xc7, 0xb1, 0x2c, 0xc9, 0x97, 0xe2, 0x32, 0x4d, 0xca, 0x2a, 0xa8, 0x55, 0x3e, 0x10, 0xf5,
+	0xfc, 0x03, 0x91, 0xaf, 0xab, 0xb1, 0x5d, 0xd7, 0xe4, 0x9f, 0x3a, 0xb4, 0x53, 0xc5, 0x45, 0x4f,
+	0xa1, 0x97, 0x57, 0x60, 0x74, 0x6d, 0xd3, 0x8d, 0x0a, 0x8d, 0x37, 0xaf, 0xef, 0x0a, 0x27, 0x47,
+	0xfc, 0x1a, 0x60, 0xa3, 0xc4, 0xe8, 0x74, 0x83, 0x2e, 0xe9, 0xb3, 0x59, 0x56, 0x74, 0xf4, 0x10,
+	0x0e, 0xb6, 0x14, 0x1a, 0xe5, 0xb6, 0xab, 0x92, 0xee, 0xaa, 0x1c, 0x4f, 0xe0, 0x60, 0x4b, 0x0d,
+	0xf3, 0x39, 0xaa, 0x64, 0xd2, 0x3c, 0x29, 0x29, 0xce, 0x63, 0xf9, 0x37, 0x21, 0x13, 0x6d, 0xe9,
+	0x43, 0x3e, 0x51, 0x95, 0x70, 0xec, 0x4c, 0x74, 0x17, 0x5a, 0x89, 0x7e, 0x20, 0x63, 0x93, 0x62,
+	0x5b, 0x52, 0xcc, 0x02, 0x0b, 0xd1, 0x23, 0xe8, 0x64, 0xcc, 0x45, 0xe6, 0x76, 0xd7, 0xf3, 0x37,
+	0xc0, 0x3c, 0xad, 0x8c, 0xc5, 0xe3, 0x98, 0xfc, 0xa9, 0x81, 0x2e, 0x69, 0xc5, 0xd1, 0x57, 0xa0,
+	0x2b, 0x32, 0xa2, 0x93, 0x9c, 0x50, 0xe6, 0xf8, 0x6d, 0x5e, 0x2d, 0xf9, 0x93, 0x91, 0x7e, 0x0e,
+	0xb5, 0xa7, 0x04, 0xed, 0x38, 0x5c, 0xbe, 0x6e, 0xb9, 0x11, 0xba, 0x07, 0xb0, 0x61, 0x73, 0x9e,
+	0x00, 0x25, 0x8e, 0x17, 0x97, 0x3e, 0xfc, 0xfe, 0x97, 0x47, 0x73, 0x4f, 0xf8, 0xf8, 0x7c, 0x74,
+	0x41, 0x82, 0x73, 0x8f, 0xbf, 0x8a, 0xe8, 0xc8, 0xa1, 0x8b, 0x71, 0x66, 0x8d, 0x39, 0x61, 0x4b,
+	0xcf, 0x21, 0x77, 0x04, 0xc3, 0x5e, 0xe0, 0x05, 0xf3, 0x31, 0x0e, 0xbd, 0x71, 0xfa, 0xaf, 0x78,
+	0x5f, 0x7d, 0x2c, 0xcf, 0xce, 0x9b, 0xaa, 0xc4, 0xbb, 0xff, 0x0d, 0x00, 0xe9, 0xa7, 0x03, 0x95,
+	0x46, 0x0e, 0x00, 0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConnInterface
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion6
+
+// ProductsClient is the client API for Products service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type ProductsClient interface {
+	ListProducts(ctx context.Context, in *ListProductsRequest, opts ...grpc.CallOption) (*ListProductsResponse, error)
+	GetProduct(ctx context.Context, in *GetProductRequest, opts ...grpc.CallOption) (*Product, error)
+	CreateProduct(ctx context.Context, in *CreateProductRequest, opts ...grpc.CallOption) (*Product, error)
+	UpdateProduct(ctx context.Context, in *UpdateProductRequest, opts ...grpc.CallOption) (*empty.Empty, error)
+	DeleteProduct(ctx context.Context, in *DeleteProductRequest, opts ...grpc.CallOption) (*empty.Empty, error)
+	// AddSale needs the ADMIN role.
+	AddSale(ctx context.Context, in *AddSaleRequest, opts ...grpc.CallOption) (*Sale, error)
+	ListSales(ctx context.Context, in *ListSalesRequest, opts ...grpc.CallOption) (*ListSalesResponse, error)
+}
+
+type productsClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewProductsClient(cc grpc.ClientConnInterface) ProductsClient {
+	return &productsClient{cc}
+}
+
+func (c *productsClient) ListProducts(ctx context.Context, in *ListProductsRequest, opts ...grpc.CallOption) (*ListProductsResponse, error) {
+	out := new(ListProductsResponse)
+	err := c.cc.Invoke(ctx, "/sales.v1.Products/ListProducts", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *productsClient) GetProduct(ctx context.Context, in *GetProductRequest, opts ...grpc.CallOption) (*Product, error) {
+	out := new(Product)
+	err := c.cc.Invoke(ctx, "/sales.v1.Products/GetProduct", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *productsClient) CreateProduct(ctx context.Context, in *CreateProductRequest, opts ...grpc.CallOption) (*Product, error) {
+	out := new(Product)
+	err := c.cc.Invoke(ctx, "/sales.v1.Products/CreateProduct", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *productsClient) UpdateProduct(ctx context.Context, in *UpdateProductRequest, opts ...grpc.CallOption) (*empty.Empty, error) {
+	out := new(empty.Empty)
+	err := c.cc.Invoke(ctx, "/sales.v1.Products/UpdateProduct", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *productsClient) DeleteProduct(ctx context.Context, in *DeleteProductRequest, opts ...grpc.CallOption) (*empty.Empty, error) {
+	out := new(empty.Empty)
+	err := c.cc.Invoke(ctx, "/sales.v1.Products/DeleteProduct", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *productsClient) AddSale(ctx context.Context, in *AddSaleRequest, opts ...grpc.CallOption) (*Sale, error) {
+	out := new(Sale)
+	err := c.cc.Invoke(ctx, "/sales.v1.Products/AddSale", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *productsClient) ListSales(ctx context.Context, in *ListSalesRequest, opts ...grpc.CallOption) (*ListSalesResponse, error) {
+	out := new(ListSalesResponse)
+	err := c.cc.Invoke(ctx, "/sales.v1.Products/ListSales", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ProductsServer is the server API for Products service.
+type ProductsServer interface {
+	ListProducts(context.Context, *ListProductsRequest) (*ListProductsResponse, error)
+	GetProduct(context.Context, *GetProductRequest) (*Product, error)
+	CreateProduct(context.Context, *CreateProductRequest) (*Product, error)
+	UpdateProduct(context.Context, *UpdateProductRequest) (*empty.Empty, error)
+	DeleteProduct(context.Context, *DeleteProductRequest) (*empty.Empty, error)
+	// AddSale needs the ADMIN role.
+	AddSale(context.Context, *AddSaleRequest) (*Sale, error)
+	ListSales(context.Context, *ListSalesRequest) (*ListSalesResponse, error)
+}
+
+// UnimplementedProductsServer can be embedded to have forward compatible implementations.
+type UnimplementedProductsServer struct {
+}
+
+func (*UnimplementedProductsServer) ListProducts(ctx context.Context, req *ListProductsRequest) (*ListProductsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListProducts not implemented")
+}
+func (*UnimplementedProductsServer) GetProduct(ctx context.Context, req *GetProductRequest) (*Product, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetProduct not implemented")
+}
+func (*UnimplementedProductsServer) CreateProduct(ctx context.Context, req *CreateProductRequest) (*Product, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateProduct not implemented")
+}
+func (*UnimplementedProductsServer) UpdateProduct(ctx context.Context, req *UpdateProductRequest) (*empty.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateProduct not implemented")
+}
+func (*UnimplementedProductsServer) DeleteProduct(ctx context.Context, req *DeleteProductRequest) (*empty.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteProduct not implemented")
+}
+func (*UnimplementedProductsServer) AddSale(ctx context.Context, req *AddSaleRequest) (*Sale, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddSale not implemented")
+}
+func (*UnimplementedProductsServer) ListSales(ctx context.Context, req *ListSalesRequest) (*ListSalesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListSales not implemented")
+}
+
+func RegisterProductsServer(s *grpc.Server, srv ProductsServer) {
+	s.RegisterService(&_Products_serviceDesc, srv)
+}
+
+func _Products_ListProducts_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListProductsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProductsServer).ListProducts(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sales.v1.Products/ListProducts",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProductsServer).ListProducts(ctx, req.(*ListProductsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Products_GetProduct_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetProductRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProductsServer).GetProduct(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sales.v1.Products/GetProduct",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProductsServer).GetProduct(ctx, req.(*GetProductRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Products_CreateProduct_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateProductRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProductsServer).CreateProduct(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sales.v1.Products/CreateProduct",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProductsServer).CreateProduct(ctx, req.(*CreateProductRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Products_UpdateProduct_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateProductRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProductsServer).UpdateProduct(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sales.v1.Products/UpdateProduct",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProductsServer).UpdateProduct(ctx, req.(*UpdateProductRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Products_DeleteProduct_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteProductRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProductsServer).DeleteProduct(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sales.v1.Products/DeleteProduct",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProductsServer).DeleteProduct(ctx, req.(*DeleteProductRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Products_AddSale_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddSaleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProductsServer).AddSale(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sales.v1.Products/AddSale",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProductsServer).AddSale(ctx, req.(*AddSaleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Products_ListSales_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListSalesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProductsServer).ListSales(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sales.v1.Products/ListSales",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProductsServer).ListSales(ctx, req.(*ListSalesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _Products_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "sales.v1.Products",
+	HandlerType: (*ProductsServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListProducts",
+			Handler:    _Products_ListProducts_Handler,
+		},
+		{
+			MethodName: "GetProduct",
+			Handler:    _Products_GetProduct_Handler,
+		},
+		{
+			MethodName: "CreateProduct",
+			Handler:    _Products_CreateProduct_Handler,
+		},
+		{
+			MethodName: "UpdateProduct",
+			Handler:    _Products_UpdateProduct_Handler,
+		},
+		{
+			MethodName: "DeleteProduct",
+			Handler:    _Products_DeleteProduct_Handler,
+		},
+		{
+			MethodName: "AddSale",
+			Handler:    _Products_AddSale_Handler,
+		},
+		{
+			MethodName: "ListSales",
+			Handler:    _Products_ListSales_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "sales/v1/sales.proto",
+}
+
+// UsersClient is the client API for Users service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type UsersClient interface {
+	// Token logs in with a password only. Users enrolled in MFA are refused
+	// with FAILED_PRECONDITION and log in over HTTP, which can take their code.
+	Token(ctx context.Context, in *TokenRequest, opts ...grpc.CallOption) (*TokenResponse, error)
+	Me(ctx context.Context, in *empty.Empty, opts ...grpc.CallOption) (*User, error)
+	// CreateUser needs the ADMIN role.
+	CreateUser(ctx context.Context, in *CreateUserRequest, opts ...grpc.CallOption) (*User, error)
+}
+
+type usersClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewUsersClient(cc grpc.ClientConnInterface) UsersClient {
+	return &usersClient{cc}
+}
+
+func (c *usersClient) Token(ctx context.Context, in *TokenRequest, opts ...grpc.CallOption) (*TokenResponse, error) {
+	out := new(TokenResponse)
+	err := c.cc.Invoke(ctx, "/sales.v1.Users/Token", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *usersClient) Me(ctx context.Context, in *empty.Empty, opts ...grpc.CallOption) (*User, error) {
+	out := new(User)
+	err := c.cc.Invoke(ctx, "/sales.v1.Users/Me", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *usersClient) CreateUser(ctx context.Context, in *CreateUserRequest, opts ...grpc.CallOption) (*User, error) {
+	out := new(User)
+	err := c.cc.Invoke(ctx, "/sales.v1.Users/CreateUser", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// UsersServer is the server API for Users service.
+type UsersServer interface {
+	// Token logs in with a password only. Users enrolled in MFA are refused
+	// with FAILED_PRECONDITION and log in over HTTP, which can take their code.
+	Token(context.Context, *TokenRequest) (*TokenResponse, error)
+	Me(context.Context, *empty.Empty) (*User, error)
+	// CreateUser needs the ADMIN role.
+	CreateUser(context.Context, *CreateUserRequest) (*User, error)
+}
+
+// UnimplementedUsersServer can be embedded to have forward compatible implementations.
+type UnimplementedUsersServer struct {
+}
+
+func (*UnimplementedUsersServer) Token(ctx context.Context, req *TokenRequest) (*TokenResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Token not implemented")
+}
+func (*UnimplementedUsersServer) Me(ctx context.Context, req *empty.Empty) (*User, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Me not implemented")
+}
+func (*UnimplementedUsersServer) CreateUser(ctx context.Context, req *CreateUserRequest) (*User, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateUser not implemented")
+}
+
+func RegisterUsersServer(s *grpc.Server, srv UsersServer) {
+	s.RegisterService(&_Users_serviceDesc, srv)
+}
+
+func _Users_Token_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TokenRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UsersServer).Token(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sales.v1.Users/Token",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UsersServer).Token(ctx, req.(*TokenRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Users_Me_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(empty.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UsersServer).Me(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sales.v1.Users/Me",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UsersServer).Me(ctx, req.(*empty.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Users_CreateUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateUserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UsersServer).CreateUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sales.v1.Users/CreateUser",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UsersServer).CreateUser(ctx, req.(*CreateUserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _Users_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "sales.v1.Users",
+	HandlerType: (*UsersServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Token",
+			Handler:    _Users_Token_Handler,
+		},
+		{
+			MethodName: "Me",
+			Handler:    _Users_Me_Handler,
+		},
+		{
+			MethodName: "CreateUser",
+			Handler:    _Users_CreateUser_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "sales/v1/sales.proto",
+}
@@ -2,16 +2,24 @@ package main
 
 import (
 	"context"
+	"encoding/csv"
 	"errors"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
 	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/conf"
 	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/database"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/pos"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/product"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/role"
 	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/schema"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/tenant"
 	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/user"
 )
 
@@ -31,7 +39,14 @@ func run() error {
 			Name       string `conf:"default:postgres"`
 			DisableTLS bool   `conf:"default:true"`
 		}
-		Args conf.Args
+		Import struct {
+			DryRun bool
+			Atomic bool
+			UserID string `conf:"default:00000000-0000-0000-0000-000000000000"`
+		}
+		// Tenant is the shop useradd, roles-load and the imports work in.
+		Tenant string `conf:"default:1b7c3e5a-2d4f-4a6b-8c9d-0e1f2a3b4c5d"`
+		Args   conf.Args
 	}
 
 	if err := conf.Parse(os.Args[1:], "SALES", &cfg); err != nil {
@@ -61,7 +76,18 @@ func run() error {
 	case "seed":
 		err = seed(dbConfig)
 	case "useradd":
-		err = useradd(dbConfig, cfg.Args.Num(1), cfg.Args.Num(2))
+		err = useradd(dbConfig, cfg.Tenant, cfg.Args.Num(1), cfg.Args.Num(2))
+	case "import-products":
+		opts := product.ImportOptions{DryRun: cfg.Import.DryRun, Atomic: cfg.Import.Atomic}
+		err = importProducts(dbConfig, cfg.Tenant, cfg.Args.Num(1), cfg.Import.UserID, opts)
+	case "import-sales":
+		err = importSales(dbConfig, cfg.Tenant, cfg.Args.Num(1), cfg.Import.UserID)
+	case "roles-load":
+		err = rolesLoad(dbConfig, cfg.Tenant, cfg.Args.Num(1))
+	case "tenant-create":
+		err = tenantCreate(dbConfig, cfg.Args.Num(1))
+	case "rls":
+		err = rls(dbConfig, cfg.Args.Num(1))
 	default:
 		err = errors.New("must specify a command")
 	}
@@ -99,7 +125,8 @@ func seed(cfg database.Config) error {
 	return nil
 }
 
-func useradd(cfg database.Config, email, password string) error {
+// useradd creates an admin of tenantID.
+func useradd(cfg database.Config, tenantID, email, password string) error {
 	db, err := database.Open(cfg)
 	if err != nil {
 		return err
@@ -131,7 +158,13 @@ func useradd(cfg database.Config, email, password string) error {
 		Password:        password,
 		PasswordConfirm: password,
 	}
-	u, err := user.Create(ctx, db, nu, time.Now())
+	// Whoever runs this has the database, so acts as an admin; it is how
+	// the first admin is made.
+	claims := auth.NewClaims("", []string{auth.RoleAdmin}, time.Now(), time.Hour)
+	claims.Permissions = auth.Permissions
+	claims.Tenant = tenantID
+
+	u, err := user.Create(ctx, db, claims, nu, time.Now())
 	if err != nil {
 		return err
 	}
@@ -140,3 +173,191 @@ func useradd(cfg database.Config, email, password string) error {
 
 	return nil
 }
+
+// importProducts creates the products listed in a CSV or NDJSON file, told
+// apart by the file's extension. They are owned by userID of tenantID.
+func importProducts(cfg database.Config, tenantID, path, userID string, opts product.ImportOptions) error {
+	if path == "" {
+		return errors.New("import-products command must be called with the file to import")
+	}
+
+	var format string
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		format = product.FormatCSV
+	case ".ndjson", ".jsonl":
+		format = product.FormatNDJSON
+	default:
+		return errors.New("import file must end in .csv, .ndjson or .jsonl")
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	rows, err := product.ReadImport(f, format)
+	if err != nil {
+		return err
+	}
+
+	db, err := database.Open(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	now := time.Now()
+	claims := auth.NewClaims(userID, []string{auth.RoleAdmin}, now, time.Hour)
+	claims.Permissions = auth.Permissions
+	claims.Tenant = tenantID
+
+	res, err := product.Import(context.Background(), db, claims, rows, opts, now)
+	if err != nil {
+		return err
+	}
+
+	for _, re := range res.Errors {
+		fmt.Printf("line %d: %s\n", re.Line, re.Error)
+		for _, fe := range re.Fields {
+			fmt.Printf("\t%s: %s\n", fe.Field, fe.Error)
+		}
+	}
+
+	switch {
+	case opts.DryRun:
+		fmt.Printf("dry run: %d of %d rows would be imported\n", res.Created, res.Rows)
+	case opts.Atomic && len(res.Errors) > 0:
+		fmt.Printf("nothing imported: %d of %d rows were rejected\n", len(res.Errors), res.Rows)
+	default:
+		fmt.Printf("%d of %d rows imported\n", res.Created, res.Rows)
+	}
+
+	return nil
+}
+
+// importSales records the sales in a point of sale CSV file on behalf of
+// userID of tenantID. The lines that were rejected are written to stdout as CSV so they
+// can be fixed and imported again.
+func importSales(cfg database.Config, tenantID, path, userID string) error {
+	if path == "" {
+		return errors.New("import-sales command must be called with the file to import")
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	db, err := database.Open(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	now := time.Now()
+	claims := auth.NewClaims(userID, []string{auth.RoleAdmin}, now, time.Hour)
+	claims.Permissions = auth.Permissions
+	claims.Tenant = tenantID
+
+	rep, err := pos.Import(context.Background(), db, claims, f, now)
+	if err != nil {
+		return err
+	}
+
+	if len(rep.Rejected) > 0 {
+		w := csv.NewWriter(os.Stdout)
+		w.Write([]string{"line", "reference", "reason"})
+		for _, rj := range rep.Rejected {
+			w.Write([]string{strconv.Itoa(rj.Line), rj.Reference, rj.Reason})
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "%d lines: %d imported, %d duplicates, %d rejected\n",
+		rep.Lines, rep.Imported, rep.Duplicates, len(rep.Rejected))
+
+	return nil
+}
+
+// rolesLoad defines the roles in a JSON file mapping role names to their
+// permissions.
+func rolesLoad(cfg database.Config, tenantID, path string) error {
+	if path == "" {
+		return errors.New("roles-load command must be called with the file to load")
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	db, err := database.Open(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	roles, err := role.Load(context.Background(), db, tenantID, f, time.Now())
+	if err != nil {
+		return err
+	}
+
+	for _, r := range roles {
+		fmt.Printf("%s: %s\n", r.Name, strings.Join(r.Permissions, ", "))
+	}
+	return nil
+}
+
+// tenantCreate adds a shop and prints its ID, which its first admin is then
+// created in with useradd --tenant.
+func tenantCreate(cfg database.Config, name string) error {
+	if name == "" {
+		return errors.New("tenant-create command must be called with the name of the tenant")
+	}
+
+	db, err := database.Open(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	t, err := tenant.Create(context.Background(), db, tenant.NewTenant{Name: name}, time.Now())
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("tenant created with id: ", t.ID)
+	return nil
+}
+
+// rls turns Postgres row level security on the tenant tables on or off.
+func rls(cfg database.Config, mode string) error {
+	var enable bool
+	switch mode {
+	case "enable":
+		enable = true
+	case "disable":
+	default:
+		return errors.New("rls command must be called with enable or disable")
+	}
+
+	db, err := database.Open(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := schema.RowLevelSecurity(db, enable); err != nil {
+		return err
+	}
+
+	fmt.Printf("row level security %sd\n", mode)
+	return nil
+}
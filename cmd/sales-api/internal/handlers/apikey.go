@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/jmoiron/sqlx"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/apikey"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/web"
+	"go.opencensus.io/trace"
+)
+
+// apiKeyAuth checks the API keys given to mid.Authenticate.
+func apiKeyAuth(db *sqlx.DB) auth.APIKeyFunc {
+	f := func(ctx context.Context, key string) (auth.Claims, error) {
+		claims, err := apikey.Authenticate(ctx, db, key, time.Now())
+		if err != nil {
+			switch err {
+			case apikey.ErrInvalidKey:
+				return auth.Claims{}, web.NewRequestError(err, http.StatusUnauthorized)
+			default:
+				return auth.Claims{}, fmt.Errorf("authenticating API key: %w", err)
+			}
+		}
+		return claims, nil
+	}
+	return f
+}
+
+type APIKeys struct {
+	db *sqlx.DB
+}
+
+func (k *APIKeys) List(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(ctx, "handles.APIKey.List")
+	defer span.End()
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	list, err := apikey.List(ctx, k.db, claims.Tenant)
+	if err != nil {
+		return fmt.Errorf("listing API keys: %w", err)
+	}
+
+	return web.Respond(ctx, w, list, http.StatusOK)
+}
+
+func (k *APIKeys) Retrieve(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(ctx, "handles.APIKey.Retrieve")
+	defer span.End()
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	id := chi.URLParam(r, "id")
+
+	key, err := apikey.Retrieve(ctx, k.db, claims.Tenant, id)
+	if err != nil {
+		switch err {
+		case apikey.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case apikey.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("getting API key %q: %w", id, err)
+		}
+	}
+
+	return web.Respond(ctx, w, key, http.StatusOK)
+}
+
+// Create issues an API key. The response holds the key itself; it is not
+// shown again.
+func (k *APIKeys) Create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(ctx, "handles.APIKey.Create")
+	defer span.End()
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	var nk apikey.NewKey
+	if err := web.Decode(r, &nk); err != nil {
+		return fmt.Errorf("decoding new API key: %w", err)
+	}
+
+	key, err := apikey.Create(ctx, k.db, claims.Tenant, nk, time.Now())
+	if err != nil {
+		switch err {
+		case apikey.ErrOwnerNotFound, apikey.ErrRoleNotHeld, apikey.ErrExpired:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("creating API key: %w", err)
+		}
+	}
+
+	return web.Respond(ctx, w, key, http.StatusCreated)
+}
+
+func (k *APIKeys) Revoke(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(ctx, "handles.APIKey.Revoke")
+	defer span.End()
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	id := chi.URLParam(r, "id")
+
+	if err := apikey.Revoke(ctx, k.db, claims.Tenant, id, time.Now()); err != nil {
+		switch err {
+		case apikey.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case apikey.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("revoking API key %q: %w", id, err)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/jmoiron/sqlx"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/category"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/web"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/product"
+	"go.opencensus.io/trace"
+)
+
+type Categories struct {
+	db *sqlx.DB
+}
+
+func (c *Categories) List(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(ctx, "handles.Category.List")
+	defer span.End()
+
+	tenantID, _, err := publicTenant(ctx, r)
+	if err != nil {
+		return err
+	}
+
+	list, err := category.List(ctx, c.db, tenantID)
+	if err != nil {
+		return fmt.Errorf("listing categories: %w", err)
+	}
+
+	return web.Respond(ctx, w, list, http.StatusOK)
+}
+
+func (c *Categories) Retrieve(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(ctx, "handles.Category.Retrieve")
+	defer span.End()
+
+	id := chi.URLParam(r, "id")
+
+	tenantID, _, err := publicTenant(ctx, r)
+	if err != nil {
+		return err
+	}
+
+	cat, err := category.Retrieve(ctx, c.db, tenantID, id)
+	if err != nil {
+		switch err {
+		case category.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case category.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("get category %w", err)
+		}
+	}
+
+	return web.Respond(ctx, w, cat, http.StatusOK)
+}
+
+func (c *Categories) Create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(ctx, "handles.Category.Create")
+	defer span.End()
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	var nc category.NewCategory
+	if err := web.Decode(r, &nc); err != nil {
+		return fmt.Errorf("decoding category %w", err)
+	}
+
+	cat, err := category.Create(ctx, c.db, claims.Tenant, nc, time.Now())
+	if err != nil {
+		switch err {
+		case category.ErrNotFound:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case category.ErrDuplicateName:
+			return web.NewRequestError(err, http.StatusConflict)
+		default:
+			return fmt.Errorf("creating category %w", err)
+		}
+	}
+
+	return web.Respond(ctx, w, cat, http.StatusCreated)
+}
+
+func (c *Categories) Update(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(ctx, "handles.Category.Update")
+	defer span.End()
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	id := chi.URLParam(r, "id")
+
+	var update category.UpdateCategory
+	if err := web.Decode(r, &update); err != nil {
+		return fmt.Errorf("decoding category update %w", err)
+	}
+
+	if err := category.Update(ctx, c.db, claims.Tenant, id, update, time.Now()); err != nil {
+		switch err {
+		case category.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case category.ErrInvalidID, category.ErrCycle:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case category.ErrDuplicateName:
+			return web.NewRequestError(err, http.StatusConflict)
+		default:
+			return fmt.Errorf("updating category %q: %w", id, err)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+func (c *Categories) Delete(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(ctx, "handles.Category.Delete")
+	defer span.End()
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	id := chi.URLParam(r, "id")
+
+	if err := category.Delete(ctx, c.db, claims.Tenant, id); err != nil {
+		switch err {
+		case category.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case category.ErrHasChildren:
+			return web.NewRequestError(err, http.StatusConflict)
+		default:
+			return fmt.Errorf("deleting category %q: %w", id, err)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// Products lists the products in a category and in every category below it.
+func (c *Categories) Products(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(ctx, "handles.Category.Products")
+	defer span.End()
+
+	id := chi.URLParam(r, "id")
+
+	tenantID, signedIn, err := publicTenant(ctx, r)
+	if err != nil {
+		return err
+	}
+
+	if _, err := category.Retrieve(ctx, c.db, tenantID, id); err != nil {
+		switch err {
+		case category.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case category.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("get category %w", err)
+		}
+	}
+
+	f := product.Filter{
+		CategoryID: id,
+		Tag:        r.URL.Query().Get("tag"),
+	}
+
+	list, err := product.List(ctx, c.db, tenantID, f)
+	if err != nil {
+		return fmt.Errorf("listing category products: %w", err)
+	}
+
+	if !signedIn {
+		return web.Respond(ctx, w, product.Listings(list), http.StatusOK)
+	}
+	return web.Respond(ctx, w, list, http.StatusOK)
+}
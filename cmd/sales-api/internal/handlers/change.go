@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/jmoiron/sqlx"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/change"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/web"
+	"go.opencensus.io/trace"
+)
+
+type Changes struct {
+	db *sqlx.DB
+}
+
+// List returns the product and sale changes after the since query parameter,
+// which is the next token of the previous page. Without it the feed starts
+// from the beginning.
+func (c *Changes) List(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(ctx, "handles.Change.List")
+	defer span.End()
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	limit := 100
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > change.MaxLimit {
+			return web.NewRequestError(fmt.Errorf("limit must be between 1 and %d", change.MaxLimit), http.StatusBadRequest)
+		}
+		limit = n
+	}
+
+	page, err := change.Since(ctx, c.db, claims.Tenant, r.URL.Query().Get("since"), limit)
+	if err != nil {
+		switch err {
+		case change.ErrInvalidToken:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("listing changes: %w", err)
+		}
+	}
+
+	return web.Respond(ctx, w, page, http.StatusOK)
+}
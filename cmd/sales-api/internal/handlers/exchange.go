@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/jmoiron/sqlx"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/exchange"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/money"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/web"
+	"go.opencensus.io/trace"
+)
+
+type ExchangeRates struct {
+	db *sqlx.DB
+}
+
+// List returns the rates into the base currency named by the base query
+// parameter, or the default currency.
+func (x *ExchangeRates) List(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(ctx, "handles.ExchangeRate.List")
+	defer span.End()
+
+	list, err := exchange.List(ctx, x.db, r.URL.Query().Get("base"))
+	if err != nil {
+		return fmt.Errorf("listing exchange rates: %w", err)
+	}
+
+	return web.Respond(ctx, w, list, http.StatusOK)
+}
+
+func (x *ExchangeRates) Set(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(ctx, "handles.ExchangeRate.Set")
+	defer span.End()
+
+	var nr exchange.NewRate
+	if err := web.Decode(r, &nr); err != nil {
+		return fmt.Errorf("decoding exchange rate %w", err)
+	}
+
+	rate, err := exchange.Set(ctx, x.db, nr)
+	if err != nil {
+		switch err {
+		case exchange.ErrInvalidRate, money.ErrUnknownCurrency:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("setting exchange rate %w", err)
+		}
+	}
+
+	return web.Respond(ctx, w, rate, http.StatusOK)
+}
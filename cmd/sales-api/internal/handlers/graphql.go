@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/graph"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/web"
+	"go.opencensus.io/trace"
+)
+
+// maxQueryComplexity caps the estimated cost of a GraphQL query.
+const maxQueryComplexity = 1000
+
+type GraphQL struct {
+	exec *graph.Executor
+}
+
+// Query runs a posted GraphQL query. Query errors are part of a successful
+// response, as GraphQL clients expect.
+func (g *GraphQL) Query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(ctx, "handles.GraphQL.Query")
+	defer span.End()
+
+	var req graph.Request
+	if err := web.Decode(r, &req); err != nil {
+		return fmt.Errorf("decoding graphql request: %w", err)
+	}
+
+	return web.Respond(ctx, w, g.exec.Do(ctx, req), http.StatusOK)
+}
@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/web"
+	"go.opencensus.io/trace"
+)
+
+type Keys struct {
+	authenticator *auth.Authenticator
+}
+
+// JWKS publishes the public keys our tokens are signed with so other services
+// can verify them.
+func (k *Keys) JWKS(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(ctx, "handles.Keys.JWKS")
+	defer span.End()
+
+	w.Header().Set("Cache-Control", "public, max-age=300")
+
+	return web.Respond(ctx, w, k.authenticator.JWKS(), http.StatusOK)
+}
@@ -10,9 +10,12 @@ import (
 
 	"github.com/go-chi/chi"
 	"github.com/jmoiron/sqlx"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/money"
 	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
 	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/web"
 	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/product"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/promotion"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/warehouse"
 	"go.opencensus.io/trace"
 )
 
@@ -25,11 +28,29 @@ func (p *Products) List(ctx context.Context, w http.ResponseWriter, r *http.Requ
 	ctx, span := trace.StartSpan(ctx, "handles.Product.List")
 	defer span.End()
 
-	list, err := product.List(ctx, p.db)
+	f := product.Filter{
+		CategoryID: r.URL.Query().Get("category"),
+		Tag:        r.URL.Query().Get("tag"),
+	}
+
+	tenantID, signedIn, err := publicTenant(ctx, r)
+	if err != nil {
+		return err
+	}
+
+	list, err := product.List(ctx, p.db, tenantID, f)
 	if err != nil {
-		return fmt.Errorf("error: listing products: %w", err)
+		switch err {
+		case product.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("error: listing products: %w", err)
+		}
 	}
 
+	if !signedIn {
+		return web.Respond(ctx, w, product.Listings(list), http.StatusOK)
+	}
 	return web.Respond(ctx, w, list, http.StatusOK)
 }
 
@@ -39,7 +60,12 @@ func (p *Products) Retrieve(ctx context.Context, w http.ResponseWriter, r *http.
 
 	id := chi.URLParam(r, "id")
 
-	prod, err := product.Retrieve(ctx, p.db, id)
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	prod, err := product.Retrieve(ctx, p.db, claims.Tenant, id)
 	if err != nil {
 		switch err {
 		case product.ErrNotFound:
@@ -70,7 +96,16 @@ func (p *Products) Create(ctx context.Context, w http.ResponseWriter, r *http.Re
 
 	prod, err := product.Create(ctx, p.db, claims, np, time.Now())
 	if err != nil {
-		return fmt.Errorf("creating product %w", err)
+		switch err {
+		case warehouse.ErrNotFound:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case product.ErrDuplicateSKU:
+			return web.NewRequestError(err, http.StatusConflict)
+		case product.ErrCategoryNotFound, money.ErrUnknownCurrency:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("creating product %w", err)
+		}
 	}
 
 	return web.Respond(ctx, w, prod, http.StatusOK)
@@ -87,9 +122,23 @@ func (p *Products) AddSale(ctx context.Context, w http.ResponseWriter, r *http.R
 
 	productID := chi.URLParam(r, "id")
 
-	sale, err := product.AddSale(ctx, p.db, ns, productID, time.Now())
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	sale, err := product.AddSale(ctx, p.db, claims, ns, productID, time.Now())
 	if err != nil {
-		return fmt.Errorf("adding new sale: %w", err)
+		switch err {
+		case product.ErrInvalidID, product.ErrOverrideReason, money.ErrCurrencyMismatch, promotion.ErrInvalidCoupon:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case product.ErrVariantNotFound, warehouse.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case warehouse.ErrInsufficientStock:
+			return web.NewRequestError(err, http.StatusConflict)
+		default:
+			return fmt.Errorf("adding new sale: %w", err)
+		}
 	}
 
 	return web.Respond(ctx, w, sale, http.StatusCreated)
@@ -101,7 +150,12 @@ func (p *Products) ListSales(ctx context.Context, w http.ResponseWriter, r *http
 
 	id := chi.URLParam(r, "id")
 
-	list, err := product.ListSales(ctx, p.db, id)
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	list, err := product.ListSales(ctx, p.db, claims.Tenant, id)
 	if err != nil {
 		return fmt.Errorf("get sales list %w", err)
 	}
@@ -129,10 +183,10 @@ func (p *Products) Update(ctx context.Context, w http.ResponseWriter, r *http.Re
 		switch err {
 		case product.ErrNotFound:
 			return web.NewRequestError(err, http.StatusNotFound)
-		case product.ErrInvalidID:
+		case product.ErrInvalidID, product.ErrCategoryNotFound, money.ErrUnknownCurrency:
 			return web.NewRequestError(err, http.StatusBadRequest)
 		default:
-			return fmt.Errorf("updating product %q", id)
+			return fmt.Errorf("updating product %q: %w", id, err)
 		}
 	}
 
@@ -145,13 +199,135 @@ func (p *Products) Delete(ctx context.Context, w http.ResponseWriter, r *http.Re
 
 	id := chi.URLParam(r, "id")
 
-	if err := product.Delete(ctx, p.db, id); err != nil {
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	if err := product.Delete(ctx, p.db, claims, id, time.Now()); err != nil {
+		switch err {
+		case product.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case product.ErrInvoiced:
+			return web.NewRequestError(err, http.StatusConflict)
+		default:
+			return fmt.Errorf("deleting product %q: %w", id, err)
+		}
+	}
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+func (p *Products) ListVariants(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(ctx, "handles.Product.ListVariants")
+	defer span.End()
+
+	id := chi.URLParam(r, "id")
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	list, err := product.ListVariants(ctx, p.db, claims.Tenant, id)
+	if err != nil {
 		switch err {
 		case product.ErrInvalidID:
 			return web.NewRequestError(err, http.StatusBadRequest)
 		default:
-			return fmt.Errorf("deleting product %q", id)
+			return fmt.Errorf("get variants list %w", err)
 		}
 	}
+
+	return web.Respond(ctx, w, list, http.StatusOK)
+}
+
+func (p *Products) RetrieveVariant(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(ctx, "handles.Product.RetrieveVariant")
+	defer span.End()
+
+	id := chi.URLParam(r, "id")
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	v, err := product.RetrieveVariant(ctx, p.db, claims.Tenant, id)
+	if err != nil {
+		switch err {
+		case product.ErrVariantNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case product.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("get variant %w", err)
+		}
+	}
+
+	return web.Respond(ctx, w, v, http.StatusOK)
+}
+
+func (p *Products) AddVariant(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(ctx, "handles.Product.AddVariant")
+	defer span.End()
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	var nv product.NewVariant
+	if err := web.Decode(r, &nv); err != nil {
+		return fmt.Errorf("decoding new variant: %w", err)
+	}
+
+	id := chi.URLParam(r, "id")
+
+	v, err := product.AddVariant(ctx, p.db, claims, id, nv, time.Now())
+	if err != nil {
+		switch err {
+		case product.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case product.ErrInvalidID, warehouse.ErrNotFound:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case product.ErrDuplicateSKU:
+			return web.NewRequestError(err, http.StatusConflict)
+		default:
+			return fmt.Errorf("adding variant: %w", err)
+		}
+	}
+
+	return web.Respond(ctx, w, v, http.StatusCreated)
+}
+
+func (p *Products) UpdateVariant(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(ctx, "handles.Product.UpdateVariant")
+	defer span.End()
+
+	id := chi.URLParam(r, "id")
+
+	var update product.UpdateVariant
+	if err := web.Decode(r, &update); err != nil {
+		return fmt.Errorf("decoding variant update %w", err)
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	if err := product.EditVariant(ctx, p.db, claims, id, update, time.Now()); err != nil {
+		switch err {
+		case product.ErrVariantNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case product.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case product.ErrDuplicateSKU:
+			return web.NewRequestError(err, http.StatusConflict)
+		default:
+			return fmt.Errorf("updating variant %q: %w", id, err)
+		}
+	}
+
 	return web.Respond(ctx, w, nil, http.StatusNoContent)
 }
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/web"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/product"
+	"go.opencensus.io/trace"
+)
+
+// maxImportSize caps the size of an uploaded import file.
+const maxImportSize = 10 << 20
+
+// Import creates products from a CSV or NDJSON body. The format comes from
+// the format query parameter or else the Content-Type. The dry_run and
+// atomic query parameters select the import mode.
+func (p *Products) Import(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(ctx, "handles.Product.Import")
+	defer span.End()
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	var opts product.ImportOptions
+	for name, dst := range map[string]*bool{"dry_run": &opts.DryRun, "atomic": &opts.Atomic} {
+		if v := r.URL.Query().Get(name); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return web.NewRequestError(fmt.Errorf("%s must be true or false", name), http.StatusBadRequest)
+			}
+			*dst = b
+		}
+	}
+
+	rows, err := product.ReadImport(http.MaxBytesReader(w, r.Body, maxImportSize), dataFormat(r))
+	if err != nil {
+		switch err {
+		case product.ErrUnknownFormat, product.ErrBadHeader:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return web.NewRequestError(fmt.Errorf("reading import: %w", err), http.StatusBadRequest)
+		}
+	}
+
+	res, err := product.Import(ctx, p.db, claims, rows, opts, time.Now())
+	if err != nil {
+		return fmt.Errorf("importing products: %w", err)
+	}
+
+	return web.Respond(ctx, w, res, http.StatusOK)
+}
+
+// Export writes every product as CSV or NDJSON, chosen by the format query
+// parameter. It defaults to CSV.
+func (p *Products) Export(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(ctx, "handles.Product.Export")
+	defer span.End()
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = product.FormatCSV
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	var buf bytes.Buffer
+	if err := product.Export(ctx, p.db, claims.Tenant, &buf, format); err != nil {
+		switch err {
+		case product.ErrUnknownFormat:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("exporting products: %w", err)
+		}
+	}
+
+	return web.RespondRaw(ctx, w, buf.Bytes(), formatContentType(format), http.StatusOK)
+}
+
+// dataFormat names the format of a request body from the format query
+// parameter or its Content-Type.
+func dataFormat(r *http.Request) string {
+	if f := r.URL.Query().Get("format"); f != "" {
+		return f
+	}
+
+	ct := r.Header.Get("Content-Type")
+	switch {
+	case strings.HasPrefix(ct, "text/csv"):
+		return product.FormatCSV
+	case strings.HasPrefix(ct, "application/x-ndjson"):
+		return product.FormatNDJSON
+	}
+	return ""
+}
+
+func formatContentType(format string) string {
+	if format == product.FormatNDJSON {
+		return "application/x-ndjson"
+	}
+	return "text/csv; charset=utf-8"
+}
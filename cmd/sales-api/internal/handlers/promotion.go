@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/jmoiron/sqlx"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/money"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/web"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/promotion"
+	"go.opencensus.io/trace"
+)
+
+type Promotions struct {
+	db *sqlx.DB
+}
+
+func (pr *Promotions) List(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(ctx, "handles.Promotion.List")
+	defer span.End()
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	list, err := promotion.List(ctx, pr.db, claims.Tenant)
+	if err != nil {
+		return fmt.Errorf("listing promotions: %w", err)
+	}
+
+	return web.Respond(ctx, w, list, http.StatusOK)
+}
+
+func (pr *Promotions) Retrieve(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(ctx, "handles.Promotion.Retrieve")
+	defer span.End()
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	id := chi.URLParam(r, "id")
+
+	p, err := promotion.Retrieve(ctx, pr.db, claims.Tenant, id)
+	if err != nil {
+		switch err {
+		case promotion.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case promotion.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("getting promotion %q: %w", id, err)
+		}
+	}
+
+	return web.Respond(ctx, w, p, http.StatusOK)
+}
+
+func (pr *Promotions) Create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(ctx, "handles.Promotion.Create")
+	defer span.End()
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	var np promotion.NewPromotion
+	if err := web.Decode(r, &np); err != nil {
+		return fmt.Errorf("decoding new promotion: %w", err)
+	}
+
+	p, err := promotion.Create(ctx, pr.db, claims.Tenant, np, time.Now())
+	if err != nil {
+		switch err {
+		case promotion.ErrInvalidTerms, promotion.ErrProductNotFound, money.ErrUnknownCurrency:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case promotion.ErrDuplicateCoupon:
+			return web.NewRequestError(err, http.StatusConflict)
+		default:
+			return fmt.Errorf("creating promotion: %w", err)
+		}
+	}
+
+	return web.Respond(ctx, w, p, http.StatusCreated)
+}
+
+// Deactivate ends a promotion. It stays listed so past sales can refer to it.
+func (pr *Promotions) Deactivate(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(ctx, "handles.Promotion.Deactivate")
+	defer span.End()
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	id := chi.URLParam(r, "id")
+
+	if err := promotion.Deactivate(ctx, pr.db, claims.Tenant, id); err != nil {
+		switch err {
+		case promotion.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case promotion.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("deactivating promotion %q: %w", id, err)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/exchange"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/money"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/web"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/report"
+	"go.opencensus.io/trace"
+)
+
+type Reports struct {
+	db *sqlx.DB
+}
+
+func (rp *Reports) SalesByCategory(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(ctx, "handles.Report.SalesByCategory")
+	defer span.End()
+
+	from, to, err := period(r)
+	if err != nil {
+		return web.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	base := money.NormalizeCurrency(r.URL.Query().Get("currency"))
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	rows, err := report.SalesByCategory(ctx, rp.db, claims.Tenant, base, from, to)
+	if err != nil {
+		switch {
+		case errors.Is(err, money.ErrUnknownCurrency), errors.Is(err, exchange.ErrRateNotFound):
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("reporting sales by category: %w", err)
+		}
+	}
+
+	return web.Respond(ctx, w, rows, http.StatusOK)
+}
+
+func (rp *Reports) Tax(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(ctx, "handles.Report.Tax")
+	defer span.End()
+
+	from, to, err := period(r)
+	if err != nil {
+		return web.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	rows, err := report.Tax(ctx, rp.db, claims.Tenant, from, to)
+	if err != nil {
+		return fmt.Errorf("reporting tax: %w", err)
+	}
+
+	return web.Respond(ctx, w, rows, http.StatusOK)
+}
+
+// period reads the from and to query parameters of a report. Either may be a
+// date or an RFC 3339 timestamp. They default to the beginning of time and now.
+func period(r *http.Request) (time.Time, time.Time, error) {
+	from := time.Time{}
+	to := time.Now()
+
+	parse := func(name string, dst *time.Time) error {
+		v := r.URL.Query().Get(name)
+		if v == "" {
+			return nil
+		}
+		for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+			if t, err := time.Parse(layout, v); err == nil {
+				*dst = t
+				return nil
+			}
+		}
+		return fmt.Errorf("%s must be a date or an RFC 3339 timestamp", name)
+	}
+
+	if err := parse("from", &from); err != nil {
+		return from, to, err
+	}
+	if err := parse("to", &to); err != nil {
+		return from, to, err
+	}
+	if !from.Before(to) {
+		return from, to, errors.New("from must be before to")
+	}
+
+	return from, to, nil
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/jmoiron/sqlx"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/web"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/role"
+	"go.opencensus.io/trace"
+)
+
+type Roles struct {
+	db *sqlx.DB
+}
+
+func (rs *Roles) List(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(ctx, "handles.Role.List")
+	defer span.End()
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	list, err := role.List(ctx, rs.db, claims.Tenant)
+	if err != nil {
+		return fmt.Errorf("listing roles: %w", err)
+	}
+
+	return web.Respond(ctx, w, list, http.StatusOK)
+}
+
+func (rs *Roles) Retrieve(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(ctx, "handles.Role.Retrieve")
+	defer span.End()
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	name := chi.URLParam(r, "name")
+
+	ro, err := role.Retrieve(ctx, rs.db, claims.Tenant, name)
+	if err != nil {
+		switch err {
+		case role.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return fmt.Errorf("getting role %q: %w", name, err)
+		}
+	}
+
+	return web.Respond(ctx, w, ro, http.StatusOK)
+}
+
+// Set defines a role, replacing its permissions if it exists.
+func (rs *Roles) Set(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(ctx, "handles.Role.Set")
+	defer span.End()
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	name := chi.URLParam(r, "name")
+
+	var ur role.UpdateRole
+	if err := web.Decode(r, &ur); err != nil {
+		return fmt.Errorf("decoding role: %w", err)
+	}
+
+	ro, err := role.Set(ctx, rs.db, claims.Tenant, name, ur, time.Now())
+	if err != nil {
+		switch err {
+		case role.ErrInvalidName, role.ErrUnknownPermission:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("setting role %q: %w", name, err)
+		}
+	}
+
+	return web.Respond(ctx, w, ro, http.StatusOK)
+}
+
+func (rs *Roles) Delete(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(ctx, "handles.Role.Delete")
+	defer span.End()
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	name := chi.URLParam(r, "name")
+
+	if err := role.Delete(ctx, rs.db, claims.Tenant, name); err != nil {
+		switch err {
+		case role.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case role.ErrInUse:
+			return web.NewRequestError(err, http.StatusConflict)
+		default:
+			return fmt.Errorf("deleting role %q: %w", name, err)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
@@ -6,12 +6,15 @@ import (
 	"os"
 
 	"github.com/jmoiron/sqlx"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/graph"
 	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/mid"
 	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
 	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/web"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/receipt"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/session"
 )
 
-func API(shutdown chan os.Signal, db *sqlx.DB, log *log.Logger, authenticator *auth.Authenticator) http.Handler {
+func API(shutdown chan os.Signal, db *sqlx.DB, log *log.Logger, authenticator *auth.Authenticator, revocations *session.Revocations, receipts *receipt.Renderer) http.Handler {
 	app := web.NewApp(shutdown, log,
 		mid.Logger(log),
 		mid.Errors(log),
@@ -19,27 +22,161 @@ func API(shutdown chan os.Signal, db *sqlx.DB, log *log.Logger, authenticator *a
 		mid.Panics(log),
 	)
 
+	authenticate := mid.Authenticate(authenticator, revocations, apiKeyAuth(db))
+	public := mid.Optional(authenticate)
+
 	{
 		c := Check{db: db}
 		app.Handle(http.MethodGet, "/v1/health", c.Health)
 	}
 
 	{
-		u := Users{db: db, authenticator: authenticator}
+		k := Keys{authenticator: authenticator}
+		app.Handle(http.MethodGet, "/.well-known/jwks.json", k.JWKS)
+	}
+
+	{
+		u := Users{db: db, authenticator: authenticator, revocations: revocations}
 		app.Handle(http.MethodGet, "/v1/users/token", u.Token)
+		app.Handle(http.MethodPost, "/v1/users/token/mfa", u.TokenMFA)
+		app.Handle(http.MethodPost, "/v1/users/token/refresh", u.Refresh)
+		app.Handle(http.MethodPost, "/v1/users/logout", u.Logout, authenticate)
+		app.Handle(http.MethodPost, "/v1/users/password-reset", u.RequestReset)
+		app.Handle(http.MethodPost, "/v1/users/password-reset/confirm", u.ResetPassword)
+		app.Handle(http.MethodPost, "/v1/users/verify", u.Verify)
+		app.Handle(http.MethodDelete, "/v1/users/{id}/lockout", u.Unlock, authenticate, mid.Require(auth.PermUsersAdmin))
+		app.Handle(http.MethodGet, "/v1/users/{id}/login-events", u.LoginEvents, authenticate, mid.Require(auth.PermUsersAdmin))
+		app.Handle(http.MethodPost, "/v1/users/mfa", u.EnrolMFA, authenticate)
+		app.Handle(http.MethodPost, "/v1/users/mfa/confirm", u.ConfirmMFA, authenticate)
+		app.Handle(http.MethodDelete, "/v1/users/{id}/mfa", u.ResetMFA, authenticate, mid.Require(auth.PermUsersAdmin))
+	}
+
+	{
+		k := APIKeys{db: db}
+
+		app.Handle(http.MethodGet, "/v1/api-keys", k.List, authenticate, mid.Require(auth.PermUsersAdmin))
+		app.Handle(http.MethodPost, "/v1/api-keys", k.Create, authenticate, mid.Require(auth.PermUsersAdmin))
+		app.Handle(http.MethodGet, "/v1/api-keys/{id}", k.Retrieve, authenticate, mid.Require(auth.PermUsersAdmin))
+		app.Handle(http.MethodDelete, "/v1/api-keys/{id}", k.Revoke, authenticate, mid.Require(auth.PermUsersAdmin))
+	}
+
+	{
+		rs := Roles{db: db}
+
+		app.Handle(http.MethodGet, "/v1/roles", rs.List, authenticate, mid.Require(auth.PermUsersAdmin))
+		app.Handle(http.MethodGet, "/v1/roles/{name}", rs.Retrieve, authenticate, mid.Require(auth.PermUsersAdmin))
+		app.Handle(http.MethodPut, "/v1/roles/{name}", rs.Set, authenticate, mid.Require(auth.PermUsersAdmin))
+		app.Handle(http.MethodDelete, "/v1/roles/{name}", rs.Delete, authenticate, mid.Require(auth.PermUsersAdmin))
 	}
 
 	{
 		p := Products{db: db, log: log}
 
-		app.Handle(http.MethodGet, "/v1/products", p.List)
-		app.Handle(http.MethodGet, "/v1/products/{id}", p.Retrieve, mid.Authenticate(authenticator))
-		app.Handle(http.MethodPost, "/v1/products", p.Create, mid.Authenticate(authenticator))
-		app.Handle(http.MethodPut, "/v1/products/{id}", p.Update, mid.Authenticate(authenticator))
-		app.Handle(http.MethodDelete, "/v1/products/{id}", p.Delete, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin))
+		app.Handle(http.MethodGet, "/v1/products", p.List, public)
+		app.Handle(http.MethodPost, "/v1/products/import", p.Import, authenticate, mid.Require(auth.PermProductsAdmin))
+		app.Handle(http.MethodGet, "/v1/products/export", p.Export, authenticate)
+		app.Handle(http.MethodGet, "/v1/products/{id}", p.Retrieve, authenticate)
+		app.Handle(http.MethodPost, "/v1/products", p.Create, authenticate, mid.Require(auth.PermProductsWrite))
+		app.Handle(http.MethodPut, "/v1/products/{id}", p.Update, authenticate, mid.Require(auth.PermProductsWrite))
+		app.Handle(http.MethodDelete, "/v1/products/{id}", p.Delete, authenticate, mid.Require(auth.PermProductsAdmin))
+
+		app.Handle(http.MethodPost, "/v1/products/{id}/sales", p.AddSale, authenticate, mid.Require(auth.PermSalesCreate))
+		app.Handle(http.MethodGet, "/v1/products/{id}/sales", p.ListSales, authenticate)
+
+		app.Handle(http.MethodGet, "/v1/products/{id}/variants", p.ListVariants, authenticate)
+		app.Handle(http.MethodPost, "/v1/products/{id}/variants", p.AddVariant, authenticate, mid.Require(auth.PermProductsWrite))
+		app.Handle(http.MethodGet, "/v1/variants/{id}", p.RetrieveVariant, authenticate)
+		app.Handle(http.MethodPut, "/v1/variants/{id}", p.UpdateVariant, authenticate, mid.Require(auth.PermProductsWrite))
+	}
+
+	{
+		s := Sales{db: db, receipts: receipts}
+
+		app.Handle(http.MethodPost, "/v1/sales/import", s.Import, authenticate, mid.Require(auth.PermSalesAdmin))
+		app.Handle(http.MethodGet, "/v1/sales/export", s.Export, authenticate, mid.Require(auth.PermSalesAdmin))
+		app.Handle(http.MethodGet, "/v1/sales/{id}/receipt", s.Receipt, authenticate)
+	}
+
+	{
+		wh := Warehouses{db: db}
+
+		app.Handle(http.MethodGet, "/v1/warehouses", wh.List, authenticate)
+		app.Handle(http.MethodPost, "/v1/warehouses", wh.Create, authenticate, mid.Require(auth.PermInventoryAdmin))
+		app.Handle(http.MethodGet, "/v1/warehouses/{id}", wh.Retrieve, authenticate)
+		app.Handle(http.MethodGet, "/v1/warehouses/{id}/stock", wh.ListStock, authenticate)
+		app.Handle(http.MethodPut, "/v1/warehouses/{id}/stock/{variant_id}", wh.SetStock, authenticate, mid.Require(auth.PermInventoryAdmin))
+		app.Handle(http.MethodPost, "/v1/transfers", wh.Transfer, authenticate, mid.Require(auth.PermInventoryAdmin))
+	}
+
+	{
+		c := Categories{db: db}
+
+		app.Handle(http.MethodGet, "/v1/categories", c.List, public)
+		app.Handle(http.MethodPost, "/v1/categories", c.Create, authenticate, mid.Require(auth.PermCatalogAdmin))
+		app.Handle(http.MethodGet, "/v1/categories/{id}", c.Retrieve, public)
+		app.Handle(http.MethodPut, "/v1/categories/{id}", c.Update, authenticate, mid.Require(auth.PermCatalogAdmin))
+		app.Handle(http.MethodDelete, "/v1/categories/{id}", c.Delete, authenticate, mid.Require(auth.PermCatalogAdmin))
+		app.Handle(http.MethodGet, "/v1/categories/{id}/products", c.Products, public)
+	}
+
+	{
+		rp := Reports{db: db}
+
+		app.Handle(http.MethodGet, "/v1/reports/sales/categories", rp.SalesByCategory, authenticate, mid.Require(auth.PermReportsRead))
+		app.Handle(http.MethodGet, "/v1/reports/tax", rp.Tax, authenticate, mid.Require(auth.PermReportsRead))
+	}
+
+	{
+		x := ExchangeRates{db: db}
+
+		app.Handle(http.MethodGet, "/v1/exchange-rates", x.List, authenticate)
+		app.Handle(http.MethodPut, "/v1/exchange-rates", x.Set, authenticate, mid.Require(auth.PermPricingAdmin))
+	}
+
+	{
+		pr := Promotions{db: db}
+
+		app.Handle(http.MethodGet, "/v1/promotions", pr.List, authenticate, mid.Require(auth.PermPricingAdmin))
+		app.Handle(http.MethodPost, "/v1/promotions", pr.Create, authenticate, mid.Require(auth.PermPricingAdmin))
+		app.Handle(http.MethodGet, "/v1/promotions/{id}", pr.Retrieve, authenticate, mid.Require(auth.PermPricingAdmin))
+		app.Handle(http.MethodDelete, "/v1/promotions/{id}", pr.Deactivate, authenticate, mid.Require(auth.PermPricingAdmin))
+	}
+
+	{
+		tr := TaxRates{db: db}
+
+		app.Handle(http.MethodGet, "/v1/tax-rates", tr.List, authenticate)
+		app.Handle(http.MethodPut, "/v1/tax-rates", tr.Set, authenticate, mid.Require(auth.PermPricingAdmin))
+		app.Handle(http.MethodDelete, "/v1/tax-rates/{id}", tr.Delete, authenticate, mid.Require(auth.PermPricingAdmin))
+	}
+
+	{
+		// The schema is fixed in code, so building it only fails on a
+		// programming error.
+		exec, err := graph.NewExecutor(db, maxQueryComplexity)
+		if err != nil {
+			panic(err)
+		}
+		g := GraphQL{exec: exec}
+
+		app.Handle(http.MethodPost, "/graphql", g.Query, authenticate)
+	}
+
+	{
+		c := Changes{db: db}
+
+		app.Handle(http.MethodGet, "/v1/changes", c.List, authenticate)
+	}
+
+	{
+		wb := Webhooks{db: db}
 
-		app.Handle(http.MethodPost, "/v1/products/{id}/sales", p.AddSale, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin))
-		app.Handle(http.MethodGet, "/v1/products/{id}/sales", p.ListSales, mid.Authenticate(authenticator))
+		app.Handle(http.MethodGet, "/v1/webhooks", wb.List, authenticate, mid.Require(auth.PermWebhooksAdmin))
+		app.Handle(http.MethodPost, "/v1/webhooks", wb.Create, authenticate, mid.Require(auth.PermWebhooksAdmin))
+		app.Handle(http.MethodGet, "/v1/webhooks/{id}", wb.Retrieve, authenticate, mid.Require(auth.PermWebhooksAdmin))
+		app.Handle(http.MethodDelete, "/v1/webhooks/{id}", wb.Delete, authenticate, mid.Require(auth.PermWebhooksAdmin))
+		app.Handle(http.MethodGet, "/v1/webhooks/{id}/deliveries", wb.Deliveries, authenticate, mid.Require(auth.PermWebhooksAdmin))
+		app.Handle(http.MethodPost, "/v1/webhook-deliveries/{id}/redeliver", wb.Redeliver, authenticate, mid.Require(auth.PermWebhooksAdmin))
 	}
 
 	return app
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/jmoiron/sqlx"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/web"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/pos"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/product"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/receipt"
+	"go.opencensus.io/trace"
+)
+
+type Sales struct {
+	db       *sqlx.DB
+	receipts *receipt.Renderer
+}
+
+// Receipt renders the receipt of a sale in the format named by the format
+// query parameter. Without one, clients asking for text/plain get text and
+// everyone else gets HTML.
+func (s *Sales) Receipt(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(ctx, "handles.Sale.Receipt")
+	defer span.End()
+
+	id := chi.URLParam(r, "id")
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = receipt.FormatHTML
+		if strings.Contains(r.Header.Get("Accept"), "text/plain") {
+			format = receipt.FormatText
+		}
+	}
+	if format != receipt.FormatHTML && format != receipt.FormatText {
+		return web.NewRequestError(receipt.ErrUnknownFormat, http.StatusBadRequest)
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	rc, err := receipt.Build(ctx, s.db, claims.Tenant, id, time.Now())
+	if err != nil {
+		switch err {
+		case product.ErrSaleNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case product.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("building receipt for sale %q: %w", id, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := s.receipts.Render(&buf, format, *rc); err != nil {
+		return fmt.Errorf("rendering receipt: %w", err)
+	}
+
+	return web.RespondRaw(ctx, w, buf.Bytes(), receipt.ContentType(format), http.StatusOK)
+}
+
+// Import records the sales in an uploaded point of sale CSV file and
+// responds with the reconciliation report.
+func (s *Sales) Import(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(ctx, "handles.Sale.Import")
+	defer span.End()
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	rep, err := pos.Import(ctx, s.db, claims, http.MaxBytesReader(w, r.Body, maxImportSize), time.Now())
+	if err != nil {
+		switch err {
+		case pos.ErrBadHeader:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("importing sales: %w", err)
+		}
+	}
+
+	return web.Respond(ctx, w, rep, http.StatusOK)
+}
+
+// Export streams the sales made between the from and to query parameters as
+// CSV or NDJSON, chosen by the format query parameter. Every line carries a
+// cursor; passing the last one received as the cursor query parameter
+// resumes an interrupted export after that sale.
+func (s *Sales) Export(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(ctx, "handles.Sale.Export")
+	defer span.End()
+
+	from, to, err := period(r)
+	if err != nil {
+		return web.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = product.FormatCSV
+	}
+	if format != product.FormatCSV && format != product.FormatNDJSON {
+		return web.NewRequestError(product.ErrUnknownFormat, http.StatusBadRequest)
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	exp, err := product.OpenSalesExport(ctx, s.db, claims.Tenant, from, to, r.URL.Query().Get("cursor"))
+	if err != nil {
+		switch err {
+		case product.ErrInvalidCursor:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("opening sales export: %w", err)
+		}
+	}
+	defer exp.Close()
+
+	write := func(w io.Writer) error {
+		if err := exp.Write(ctx, w, format); err != nil {
+			return fmt.Errorf("exporting sales: %w", err)
+		}
+		return nil
+	}
+
+	return web.RespondStream(ctx, w, formatContentType(format), http.StatusOK, write)
+}
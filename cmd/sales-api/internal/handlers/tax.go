@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/jmoiron/sqlx"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/web"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/tax"
+	"go.opencensus.io/trace"
+)
+
+type TaxRates struct {
+	db *sqlx.DB
+}
+
+func (tr *TaxRates) List(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(ctx, "handles.TaxRate.List")
+	defer span.End()
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	list, err := tax.List(ctx, tr.db, claims.Tenant)
+	if err != nil {
+		return fmt.Errorf("listing tax rates: %w", err)
+	}
+
+	return web.Respond(ctx, w, list, http.StatusOK)
+}
+
+func (tr *TaxRates) Set(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(ctx, "handles.TaxRate.Set")
+	defer span.End()
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	var nr tax.NewRate
+	if err := web.Decode(r, &nr); err != nil {
+		return fmt.Errorf("decoding tax rate %w", err)
+	}
+
+	rate, err := tax.Set(ctx, tr.db, claims.Tenant, nr, time.Now())
+	if err != nil {
+		switch err {
+		case tax.ErrInvalidRate, tax.ErrCategoryNotFound:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("setting tax rate %w", err)
+		}
+	}
+
+	return web.Respond(ctx, w, rate, http.StatusOK)
+}
+
+func (tr *TaxRates) Delete(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(ctx, "handles.TaxRate.Delete")
+	defer span.End()
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	id := chi.URLParam(r, "id")
+
+	if err := tax.Delete(ctx, tr.db, claims.Tenant, id); err != nil {
+		switch err {
+		case tax.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("deleting tax rate %q: %w", id, err)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/web"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/tenant"
+)
+
+// tenantHeader names the shop whose catalogue an anonymous caller browses.
+// Authenticated callers always act in the tenant of their claims.
+const tenantHeader = "X-Tenant-ID"
+
+// publicTenant returns the tenant a public route reads from and whether the
+// caller is signed in to it. Signed in callers read their own tenant whatever
+// the tenant header says; anonymous callers read the one in the header, or
+// the default tenant when there is none.
+func publicTenant(ctx context.Context, r *http.Request) (string, bool, error) {
+	if claims, ok := ctx.Value(auth.Key).(auth.Claims); ok {
+		return claims.Tenant, true, nil
+	}
+
+	id := r.Header.Get(tenantHeader)
+	if id == "" {
+		return tenant.DefaultID, false, nil
+	}
+
+	if _, err := uuid.Parse(id); err != nil {
+		return "", false, web.NewRequestError(tenant.ErrInvalidID, http.StatusBadRequest)
+	}
+	return id, false, nil
+}
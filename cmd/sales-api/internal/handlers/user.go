@@ -4,11 +4,20 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/go-chi/chi"
 	"github.com/jmoiron/sqlx"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/account"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/login"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/mfa"
 	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
 	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/web"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/session"
 	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/user"
 	"go.opencensus.io/trace"
 )
@@ -16,6 +25,13 @@ import (
 type Users struct {
 	db            *sqlx.DB
 	authenticator *auth.Authenticator
+	revocations   *session.Revocations
+}
+
+// tokens is the pair of tokens handed out on login and on every refresh.
+type tokens struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
 }
 
 func (u *Users) Token(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
@@ -33,23 +49,455 @@ func (u *Users) Token(ctx context.Context, w http.ResponseWriter, r *http.Reques
 		return web.NewRequestError(err, http.StatusUnauthorized)
 	}
 
-	claims, err := user.Authenticate(ctx, u.db, v.Start, email, pass)
+	claims, err := login.Authenticate(ctx, u.db, email, pass, clientAddr(r), v.Start)
 	if err != nil {
-		switch err {
-		case user.ErrAuthenticationFailure:
+		var throttled *login.Throttled
+		var required *user.MFARequired
+		switch {
+		case errors.As(err, &throttled):
+			w.Header().Set("Retry-After", retryAfter(throttled.Until, v.Start))
+			return web.NewRequestError(err, http.StatusTooManyRequests)
+		case errors.As(err, &required):
+			// The login is not done until the challenge is answered.
+			return web.Respond(ctx, w, required.Challenge, http.StatusAccepted)
+		case err == user.ErrAuthenticationFailure:
 			return web.NewRequestError(err, http.StatusUnauthorized)
 		default:
 			return fmt.Errorf("authenticating %w", err)
 		}
 	}
 
-	var tkn struct {
-		Token string `json:"token"`
+	return u.respondTokens(ctx, w, claims, v.Start)
+}
+
+// TokenMFA completes a login held for MFA by answering its challenge with a
+// TOTP or recovery code.
+func (u *Users) TokenMFA(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(ctx, "handles.User.TokenMFA")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	var req struct {
+		Challenge string `json:"challenge" validate:"required"`
+		Code      string `json:"code" validate:"required"`
+	}
+	if err := web.Decode(r, &req); err != nil {
+		return fmt.Errorf("decoding MFA answer: %w", err)
+	}
+
+	claims, err := login.AuthenticateMFA(ctx, u.db, req.Challenge, req.Code, clientAddr(r), v.Start)
+	if err != nil {
+		var throttled *login.Throttled
+		switch {
+		case errors.As(err, &throttled):
+			w.Header().Set("Retry-After", retryAfter(throttled.Until, v.Start))
+			return web.NewRequestError(err, http.StatusTooManyRequests)
+		case err == mfa.ErrInvalidChallenge, err == mfa.ErrInvalidCode:
+			return web.NewRequestError(err, http.StatusUnauthorized)
+		default:
+			return fmt.Errorf("authenticating %w", err)
+		}
 	}
 
+	return u.respondTokens(ctx, w, claims, v.Start)
+}
+
+// respondTokens starts a session for claims and responds with its tokens.
+func (u *Users) respondTokens(ctx context.Context, w http.ResponseWriter, claims auth.Claims, now time.Time) error {
+	var tkn tokens
+	var err error
+
 	tkn.Token, err = u.authenticator.GenerateToken(claims)
 	if err != nil {
 		return fmt.Errorf("generating token %w", err)
 	}
+
+	tkn.RefreshToken, err = session.Issue(ctx, u.db, "", claims, now)
+	if err != nil {
+		return fmt.Errorf("issuing refresh token %w", err)
+	}
+
 	return web.Respond(ctx, w, tkn, http.StatusOK)
 }
+
+// Refresh exchanges a refresh token for a new access token and the refresh
+// token to use next time.
+func (u *Users) Refresh(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(ctx, "handles.User.Refresh")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	var req struct {
+		RefreshToken string `json:"refresh_token" validate:"required"`
+	}
+	if err := web.Decode(r, &req); err != nil {
+		return fmt.Errorf("decoding refresh: %w", err)
+	}
+
+	s, err := session.Use(ctx, u.db, req.RefreshToken, v.Start)
+	if err != nil {
+		switch err {
+		case session.ErrInvalidToken:
+			return web.NewRequestError(err, http.StatusUnauthorized)
+		case session.ErrTokenReused:
+			// The session's access tokens were revoked along with it.
+			if err := u.revocations.Refresh(ctx, v.Start); err != nil {
+				return err
+			}
+			return web.NewRequestError(err, http.StatusUnauthorized)
+		default:
+			return fmt.Errorf("refreshing session: %w", err)
+		}
+	}
+
+	claims, err := user.Claims(ctx, u.db, s.UserID, v.Start)
+	if err != nil {
+		switch err {
+		case user.ErrNotFound:
+			return web.NewRequestError(session.ErrInvalidToken, http.StatusUnauthorized)
+		default:
+			return fmt.Errorf("refreshing session: %w", err)
+		}
+	}
+
+	var tkn tokens
+
+	tkn.Token, err = u.authenticator.GenerateToken(claims)
+	if err != nil {
+		return fmt.Errorf("generating token %w", err)
+	}
+
+	tkn.RefreshToken, err = session.Issue(ctx, u.db, s.FamilyID, claims, v.Start)
+	if err != nil {
+		return fmt.Errorf("issuing refresh token %w", err)
+	}
+
+	return web.Respond(ctx, w, tkn, http.StatusOK)
+}
+
+// Logout revokes the caller's access token and ends the session it belongs
+// to. API keys have no session to end, so callers using one are refused;
+// keys are revoked through /v1/api-keys instead.
+func (u *Users) Logout(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(ctx, "handles.User.Logout")
+	defer span.End()
+
+	if scheme := strings.SplitN(r.Header.Get("Authorization"), " ", 2)[0]; strings.EqualFold(scheme, "ApiKey") {
+		err := errors.New("API keys cannot log out: revoke the key instead")
+		return web.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	if err := session.End(ctx, u.db, claims, v.Start); err != nil {
+		return fmt.Errorf("ending session: %w", err)
+	}
+
+	if err := u.revocations.Refresh(ctx, v.Start); err != nil {
+		return err
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// RequestReset has a password reset token mailed to the email given, if a user
+// has it. The response is the same either way.
+func (u *Users) RequestReset(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(ctx, "handles.User.RequestReset")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	var req account.ResetRequest
+	if err := web.Decode(r, &req); err != nil {
+		return fmt.Errorf("decoding reset request: %w", err)
+	}
+
+	if err := account.RequestReset(ctx, u.db, req.Email, v.Start); err != nil {
+		return fmt.Errorf("requesting password reset: %w", err)
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// ResetPassword sets a new password with a mailed reset token.
+func (u *Users) ResetPassword(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(ctx, "handles.User.ResetPassword")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	var req account.Reset
+	if err := web.Decode(r, &req); err != nil {
+		return fmt.Errorf("decoding password reset: %w", err)
+	}
+
+	if err := account.ResetPassword(ctx, u.db, req, v.Start); err != nil {
+		switch err {
+		case account.ErrInvalidToken:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("resetting password: %w", err)
+		}
+	}
+
+	// The user's sessions were ended with the reset.
+	if err := u.revocations.Refresh(ctx, v.Start); err != nil {
+		return err
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// Verify records that a user holds their email with the token mailed to it.
+func (u *Users) Verify(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(ctx, "handles.User.Verify")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	var req struct {
+		Token string `json:"token" validate:"required"`
+	}
+	if err := web.Decode(r, &req); err != nil {
+		return fmt.Errorf("decoding verification: %w", err)
+	}
+
+	if err := account.Verify(ctx, u.db, req.Token, v.Start); err != nil {
+		switch err {
+		case account.ErrInvalidToken:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("verifying email: %w", err)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// Unlock lifts the lockout of a user's account after too many failed
+// logins.
+func (u *Users) Unlock(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(ctx, "handles.User.Unlock")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	id := chi.URLParam(r, "id")
+
+	usr, err := user.Retrieve(ctx, u.db, claims.Tenant, id)
+	if err != nil {
+		switch err {
+		case user.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case user.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("get user %w", err)
+		}
+	}
+
+	if err := login.Unlock(ctx, u.db, claims, *usr, v.Start); err != nil {
+		return fmt.Errorf("unlocking user %q: %w", id, err)
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// LoginEvents lists the lockouts of a user's account and who lifted them.
+func (u *Users) LoginEvents(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(ctx, "handles.User.LoginEvents")
+	defer span.End()
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	id := chi.URLParam(r, "id")
+
+	if _, err := user.Retrieve(ctx, u.db, claims.Tenant, id); err != nil {
+		switch err {
+		case user.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case user.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("get user %w", err)
+		}
+	}
+
+	events, err := login.Events(ctx, u.db, id)
+	if err != nil {
+		return fmt.Errorf("listing login events: %w", err)
+	}
+
+	return web.Respond(ctx, w, events, http.StatusOK)
+}
+
+// EnrolMFA starts the caller's enrolment in MFA, returning the secret to set
+// their authenticator app up with.
+func (u *Users) EnrolMFA(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(ctx, "handles.User.EnrolMFA")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	usr, err := user.Retrieve(ctx, u.db, claims.Tenant, claims.Subject)
+	if err != nil {
+		switch err {
+		case user.ErrNotFound, user.ErrInvalidID:
+			// API keys act for no user.
+			return web.NewRequestError(user.ErrNotFound, http.StatusNotFound)
+		default:
+			return fmt.Errorf("get user %w", err)
+		}
+	}
+
+	e, err := mfa.Enrol(ctx, u.db, usr.ID, usr.Email, v.Start)
+	if err != nil {
+		switch err {
+		case mfa.ErrAlreadyEnrolled:
+			return web.NewRequestError(err, http.StatusConflict)
+		default:
+			return fmt.Errorf("enrolling in MFA: %w", err)
+		}
+	}
+
+	return web.Respond(ctx, w, e, http.StatusCreated)
+}
+
+// ConfirmMFA completes the caller's enrolment with a code from their app and
+// responds with their recovery codes.
+func (u *Users) ConfirmMFA(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(ctx, "handles.User.ConfirmMFA")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	var req struct {
+		Code string `json:"code" validate:"required"`
+	}
+	if err := web.Decode(r, &req); err != nil {
+		return fmt.Errorf("decoding MFA confirmation: %w", err)
+	}
+
+	codes, err := mfa.Confirm(ctx, u.db, claims.Subject, req.Code, v.Start)
+	if err != nil {
+		switch err {
+		case mfa.ErrNotEnrolled:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case mfa.ErrAlreadyEnrolled:
+			return web.NewRequestError(err, http.StatusConflict)
+		case mfa.ErrInvalidCode:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("confirming MFA: %w", err)
+		}
+	}
+
+	resp := struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}{codes}
+
+	return web.Respond(ctx, w, resp, http.StatusOK)
+}
+
+// ResetMFA removes a user's enrolment in MFA, as when they have lost their
+// app, so they can log in with their password and enrol again.
+func (u *Users) ResetMFA(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(ctx, "handles.User.ResetMFA")
+	defer span.End()
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	id := chi.URLParam(r, "id")
+
+	if _, err := user.Retrieve(ctx, u.db, claims.Tenant, id); err != nil {
+		switch err {
+		case user.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case user.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("get user %w", err)
+		}
+	}
+
+	if err := mfa.Reset(ctx, u.db, id); err != nil {
+		return fmt.Errorf("resetting MFA of %q: %w", id, err)
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// clientAddr returns the address a request came from, without its port.
+func clientAddr(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// retryAfter returns the Retry-After header telling a client to wait until
+// until, in whole seconds rounded up.
+func retryAfter(until, now time.Time) string {
+	secs := int((until.Sub(now) + time.Second - 1) / time.Second)
+	if secs < 1 {
+		secs = 1
+	}
+	return strconv.Itoa(secs)
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/jmoiron/sqlx"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/web"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/warehouse"
+	"go.opencensus.io/trace"
+)
+
+type Warehouses struct {
+	db *sqlx.DB
+}
+
+func (wh *Warehouses) List(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(ctx, "handles.Warehouse.List")
+	defer span.End()
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	list, err := warehouse.List(ctx, wh.db, claims.Tenant)
+	if err != nil {
+		return fmt.Errorf("listing warehouses: %w", err)
+	}
+
+	return web.Respond(ctx, w, list, http.StatusOK)
+}
+
+func (wh *Warehouses) Retrieve(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(ctx, "handles.Warehouse.Retrieve")
+	defer span.End()
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	id := chi.URLParam(r, "id")
+
+	wa, err := warehouse.Retrieve(ctx, wh.db, claims.Tenant, id)
+	if err != nil {
+		switch err {
+		case warehouse.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case warehouse.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("get warehouse %w", err)
+		}
+	}
+
+	return web.Respond(ctx, w, wa, http.StatusOK)
+}
+
+func (wh *Warehouses) Create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(ctx, "handles.Warehouse.Create")
+	defer span.End()
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	var nw warehouse.NewWarehouse
+	if err := web.Decode(r, &nw); err != nil {
+		return fmt.Errorf("decoding warehouse %w", err)
+	}
+
+	wa, err := warehouse.Create(ctx, wh.db, claims.Tenant, nw, time.Now())
+	if err != nil {
+		return fmt.Errorf("creating warehouse %w", err)
+	}
+
+	return web.Respond(ctx, w, wa, http.StatusCreated)
+}
+
+func (wh *Warehouses) ListStock(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(ctx, "handles.Warehouse.ListStock")
+	defer span.End()
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	id := chi.URLParam(r, "id")
+
+	stock, err := warehouse.WarehouseStock(ctx, wh.db, claims.Tenant, id)
+	if err != nil {
+		switch err {
+		case warehouse.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("get warehouse stock %w", err)
+		}
+	}
+
+	return web.Respond(ctx, w, stock, http.StatusOK)
+}
+
+func (wh *Warehouses) SetStock(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(ctx, "handles.Warehouse.SetStock")
+	defer span.End()
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	id := chi.URLParam(r, "id")
+	variantID := chi.URLParam(r, "variant_id")
+
+	var us warehouse.UpdateStock
+	if err := web.Decode(r, &us); err != nil {
+		return fmt.Errorf("decoding stock update %w", err)
+	}
+
+	stock, err := warehouse.SetStock(ctx, wh.db, claims.Tenant, id, variantID, us)
+	if err != nil {
+		switch err {
+		case warehouse.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case warehouse.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("setting stock %w", err)
+		}
+	}
+
+	return web.Respond(ctx, w, stock, http.StatusOK)
+}
+
+func (wh *Warehouses) Transfer(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(ctx, "handles.Warehouse.Transfer")
+	defer span.End()
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	var nt warehouse.NewTransfer
+	if err := web.Decode(r, &nt); err != nil {
+		return fmt.Errorf("decoding transfer %w", err)
+	}
+
+	t, err := warehouse.AddTransfer(ctx, wh.db, claims, nt, time.Now())
+	if err != nil {
+		switch err {
+		case warehouse.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case warehouse.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case warehouse.ErrInsufficientStock:
+			return web.NewRequestError(err, http.StatusConflict)
+		default:
+			return fmt.Errorf("transferring stock %w", err)
+		}
+	}
+
+	return web.Respond(ctx, w, t, http.StatusCreated)
+}
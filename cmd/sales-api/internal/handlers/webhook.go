@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/jmoiron/sqlx"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/web"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/webhook"
+	"go.opencensus.io/trace"
+)
+
+type Webhooks struct {
+	db *sqlx.DB
+}
+
+func (wb *Webhooks) List(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(ctx, "handles.Webhook.List")
+	defer span.End()
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	list, err := webhook.List(ctx, wb.db, claims.Tenant)
+	if err != nil {
+		return fmt.Errorf("listing webhook subscriptions: %w", err)
+	}
+
+	return web.Respond(ctx, w, list, http.StatusOK)
+}
+
+func (wb *Webhooks) Retrieve(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(ctx, "handles.Webhook.Retrieve")
+	defer span.End()
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	id := chi.URLParam(r, "id")
+
+	s, err := webhook.Retrieve(ctx, wb.db, claims.Tenant, id)
+	if err != nil {
+		switch err {
+		case webhook.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case webhook.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("getting webhook subscription %q: %w", id, err)
+		}
+	}
+
+	return web.Respond(ctx, w, s, http.StatusOK)
+}
+
+// Create subscribes an endpoint. The response holds the secret deliveries
+// are signed with; it is not shown again.
+func (wb *Webhooks) Create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(ctx, "handles.Webhook.Create")
+	defer span.End()
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	var ns webhook.NewSubscription
+	if err := web.Decode(r, &ns); err != nil {
+		return fmt.Errorf("decoding new webhook subscription: %w", err)
+	}
+
+	s, err := webhook.Create(ctx, wb.db, claims.Tenant, ns, time.Now())
+	if err != nil {
+		switch err {
+		case webhook.ErrUnknownEvent:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("creating webhook subscription: %w", err)
+		}
+	}
+
+	return web.Respond(ctx, w, s, http.StatusCreated)
+}
+
+func (wb *Webhooks) Delete(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(ctx, "handles.Webhook.Delete")
+	defer span.End()
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	id := chi.URLParam(r, "id")
+
+	if err := webhook.Delete(ctx, wb.db, claims.Tenant, id); err != nil {
+		switch err {
+		case webhook.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("deleting webhook subscription %q: %w", id, err)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// Deliveries lists the delivery log of a subscription.
+func (wb *Webhooks) Deliveries(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(ctx, "handles.Webhook.Deliveries")
+	defer span.End()
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	id := chi.URLParam(r, "id")
+
+	list, err := webhook.Deliveries(ctx, wb.db, claims.Tenant, id)
+	if err != nil {
+		switch err {
+		case webhook.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case webhook.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("listing deliveries of webhook subscription %q: %w", id, err)
+		}
+	}
+
+	return web.Respond(ctx, w, list, http.StatusOK)
+}
+
+// Redeliver queues a delivery to be sent again.
+func (wb *Webhooks) Redeliver(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	ctx, span := trace.StartSpan(ctx, "handles.Webhook.Redeliver")
+	defer span.End()
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	id := chi.URLParam(r, "id")
+
+	d, err := webhook.Redeliver(ctx, wb.db, claims.Tenant, id, time.Now())
+	if err != nil {
+		switch err {
+		case webhook.ErrDeliveryNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case webhook.ErrInvalidID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("redelivering webhook delivery %q: %w", id, err)
+		}
+	}
+
+	return web.Respond(ctx, w, d, http.StatusAccepted)
+}
@@ -0,0 +1,87 @@
+package rpc
+
+import (
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	salesv1 "gitlab.fenbishuo.com/fenbishuo/service-training/api/sales/v1"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/money"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/product"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/user"
+)
+
+func toMoney(m money.Money) *salesv1.Money {
+	return &salesv1.Money{Amount: m.Amount, Currency: m.Currency}
+}
+
+func fromMoney(m *salesv1.Money) money.Money {
+	if m == nil {
+		return money.Money{}
+	}
+	return money.Money{Amount: m.Amount, Currency: m.Currency}
+}
+
+// toTimestamp only fails for dates outside years 1 to 9999, which the
+// database never holds.
+func toTimestamp(t time.Time) *timestamp.Timestamp {
+	ts, _ := ptypes.TimestampProto(t)
+	return ts
+}
+
+func str(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func toProduct(p product.Product) *salesv1.Product {
+	return &salesv1.Product{
+		Id:          p.ID,
+		Name:        p.Name,
+		Cost:        toMoney(p.Cost),
+		Quantity:    int32(p.Quantity),
+		Sold:        int32(p.Sold),
+		Revenue:     toMoney(p.Revenue),
+		CategoryId:  str(p.CategoryID),
+		Tags:        p.Tags,
+		UserId:      p.UserID,
+		DateCreated: toTimestamp(p.DateCreated),
+		DateUpdated: toTimestamp(p.DateUpdated),
+	}
+}
+
+func toSale(s product.Sale) *salesv1.Sale {
+	return &salesv1.Sale{
+		Id:             s.ID,
+		ProductId:      s.ProductID,
+		VariantId:      s.VariantID,
+		WarehouseId:    s.WarehouseID,
+		Quantity:       int32(s.Quantity),
+		ListPrice:      toMoney(s.ListPrice),
+		Net:            toMoney(s.Net),
+		Tax:            toMoney(s.Tax),
+		Paid:           toMoney(s.Paid),
+		Jurisdiction:   str(s.Jurisdiction),
+		TaxRate:        str(s.TaxRate),
+		TaxInclusive:   s.TaxInclusive,
+		CustomerName:   str(s.CustomerName),
+		CustomerEmail:  str(s.CustomerEmail),
+		ExternalRef:    str(s.ExternalRef),
+		OverrideReason: str(s.OverrideReason),
+		OverriddenBy:   str(s.OverriddenBy),
+		DateCreated:    toTimestamp(s.DateCreated),
+	}
+}
+
+func toUser(u user.User) *salesv1.User {
+	return &salesv1.User{
+		Id:          u.ID,
+		Name:        u.Name,
+		Email:       u.Email,
+		Roles:       u.Roles,
+		DateCreated: toTimestamp(u.DateCreated),
+		DateUpdated: toTimestamp(u.DateUpdated),
+	}
+}
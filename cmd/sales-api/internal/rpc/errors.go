@@ -0,0 +1,96 @@
+package rpc
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/apikey"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/login"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/money"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/authz"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/web"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/product"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/promotion"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/user"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/warehouse"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// domainCodes maps the errors of the internal packages to the code reported
+// to clients. They follow the HTTP statuses the handlers use for them. An
+// error matching more than one gets the code of the first.
+var domainCodes = []struct {
+	err  error
+	code codes.Code
+}{
+	{product.ErrNotFound, codes.NotFound},
+	{product.ErrVariantNotFound, codes.NotFound},
+	{product.ErrSaleNotFound, codes.NotFound},
+	{user.ErrNotFound, codes.NotFound},
+	{warehouse.ErrNotFound, codes.NotFound},
+
+	{product.ErrInvalidID, codes.InvalidArgument},
+	{product.ErrCategoryNotFound, codes.InvalidArgument},
+	{product.ErrOverrideReason, codes.InvalidArgument},
+	{user.ErrInvalidID, codes.InvalidArgument},
+	{money.ErrUnknownCurrency, codes.InvalidArgument},
+	{money.ErrCurrencyMismatch, codes.InvalidArgument},
+	{promotion.ErrInvalidCoupon, codes.InvalidArgument},
+	{product.ErrDuplicateSKU, codes.AlreadyExists},
+	{product.ErrDuplicateSale, codes.AlreadyExists},
+	{product.ErrInvoiced, codes.FailedPrecondition},
+	{warehouse.ErrInsufficientStock, codes.FailedPrecondition},
+
+	{user.ErrAuthenticationFailure, codes.Unauthenticated},
+	{apikey.ErrInvalidKey, codes.Unauthenticated},
+	{login.ErrThrottled, codes.ResourceExhausted},
+}
+
+// httpCodes maps the statuses of web.Errors, such as those web.Validate
+// returns, to codes.
+var httpCodes = map[int]codes.Code{
+	http.StatusBadRequest:   codes.InvalidArgument,
+	http.StatusUnauthorized: codes.Unauthenticated,
+	http.StatusForbidden:    codes.PermissionDenied,
+	http.StatusNotFound:     codes.NotFound,
+	http.StatusConflict:     codes.AlreadyExists,
+}
+
+// toStatus returns the status to report for err. Statuses pass through
+// unchanged and anything unrecognised is an internal error.
+func toStatus(err error) *status.Status {
+	if s, ok := status.FromError(err); ok {
+		return s
+	}
+
+	// Policy refusals say which rules the caller failed.
+	var denied *authz.Denied
+	if errors.As(err, &denied) {
+		return status.New(codes.PermissionDenied, denied.Error())
+	}
+
+	for _, dc := range domainCodes {
+		if errors.Is(err, dc.err) {
+			return status.New(dc.code, dc.err.Error())
+		}
+	}
+
+	var webErr *web.Error
+	if errors.As(err, &webErr) {
+		if code, ok := httpCodes[webErr.Status]; ok {
+			msg := webErr.Err.Error()
+			if len(webErr.Fields) > 0 {
+				fields := make([]string, len(webErr.Fields))
+				for i, f := range webErr.Fields {
+					fields[i] = f.Field + ": " + f.Error
+				}
+				msg += ": " + strings.Join(fields, "; ")
+			}
+			return status.New(code, msg)
+		}
+	}
+
+	return status.New(codes.Internal, http.StatusText(http.StatusInternalServerError))
+}
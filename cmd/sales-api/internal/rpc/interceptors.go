@@ -0,0 +1,219 @@
+package rpc
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
+	"go.opencensus.io/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// chain combines interceptors into one, the first being the outermost, since
+// this version of grpc only takes a single unary interceptor.
+func chain(interceptors ...grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			next, ic := handler, interceptors[i]
+			handler = func(ctx context.Context, req interface{}) (interface{}, error) {
+				return ic(ctx, req, info, next)
+			}
+		}
+		return handler(ctx, req)
+	}
+}
+
+func traceID(ctx context.Context) string {
+	return trace.FromContext(ctx).SpanContext().TraceID.String()
+}
+
+// Logger logs each call with its status code and how long it took.
+func Logger(log *log.Logger) grpc.UnaryServerInterceptor {
+	f := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, span := trace.StartSpan(ctx, "internal.rpc.Logger")
+		defer span.End()
+
+		start := time.Now()
+
+		resp, err := handler(ctx, req)
+
+		var addr string
+		if p, ok := peer.FromContext(ctx); ok {
+			addr = p.Addr.String()
+		}
+
+		log.Printf("%s : (%s) : %s -> %s (%s)",
+			traceID(ctx), status.Code(err),
+			info.FullMethod,
+			addr, time.Since(start),
+		)
+
+		return resp, err
+	}
+
+	return f
+}
+
+// Errors logs the error a call failed with and turns it into a status the
+// client can act on. Errors it does not recognise become codes.Internal
+// without their details.
+func Errors(log *log.Logger) grpc.UnaryServerInterceptor {
+	f := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, span := trace.StartSpan(ctx, "internal.rpc.Errors")
+		defer span.End()
+
+		resp, err := handler(ctx, req)
+		if err != nil {
+			log.Printf("%s : ERROR: %+v", traceID(ctx), err)
+			return nil, toStatus(err).Err()
+		}
+
+		return resp, nil
+	}
+
+	return f
+}
+
+var m = struct {
+	req *expvar.Int
+	err *expvar.Int
+}{
+	req: expvar.NewInt("grpc_requests"),
+	err: expvar.NewInt("grpc_errors"),
+}
+
+// Metrics counts the calls served and how many of them failed.
+func Metrics() grpc.UnaryServerInterceptor {
+	f := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, span := trace.StartSpan(ctx, "internal.rpc.Metrics")
+		defer span.End()
+
+		resp, err := handler(ctx, req)
+
+		m.req.Add(1)
+		if err != nil {
+			m.err.Add(1)
+		}
+
+		return resp, err
+	}
+
+	return f
+}
+
+// Panics recovers from a panicking call and fails it with an error instead.
+func Panics(log *log.Logger) grpc.UnaryServerInterceptor {
+	f := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		ctx, span := trace.StartSpan(ctx, "internal.rpc.Panics")
+		defer span.End()
+
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v", r)
+
+				log.Printf("%s :\n%s", traceID(ctx), debug.Stack())
+			}
+		}()
+
+		return handler(ctx, req)
+	}
+
+	return f
+}
+
+// Authenticate puts the claims of the credentials in the call's authorization
+// metadata into its context. Those are either a bearer token, which must not
+// be on the revoked list, or an API key, checked by apiKeys. Methods in
+// public need no credentials.
+func Authenticate(authenticator *auth.Authenticator, revoked auth.RevocationList, apiKeys auth.APIKeyFunc, public map[string]bool) grpc.UnaryServerInterceptor {
+	f := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if public[info.FullMethod] {
+			return handler(ctx, req)
+		}
+
+		ctx, span := trace.StartSpan(ctx, "internal.rpc.Authenticate")
+		defer span.End()
+
+		md, _ := metadata.FromIncomingContext(ctx)
+		values := md.Get("authorization")
+
+		var parts []string
+		if len(values) == 1 {
+			parts = strings.Split(values[0], " ")
+		}
+		if len(parts) != 2 {
+			return nil, errBadAuthorization
+		}
+
+		var claims auth.Claims
+		switch strings.ToLower(parts[0]) {
+		case "bearer":
+			var err error
+			_, span = trace.StartSpan(ctx, "auth.ParseClaims")
+			claims, err = authenticator.ParseClaims(parts[1])
+			span.End()
+			if err != nil {
+				return nil, status.Error(codes.Unauthenticated, err.Error())
+			}
+
+			if revoked.Revoked(claims.Id) {
+				return nil, status.Error(codes.Unauthenticated, "token has been revoked")
+			}
+			if claims.Tenant == "" {
+				return nil, status.Error(codes.Unauthenticated, "token has no tenant")
+			}
+
+		case "apikey":
+			var err error
+			if claims, err = apiKeys(ctx, parts[1]); err != nil {
+				return nil, err
+			}
+
+		default:
+			return nil, errBadAuthorization
+		}
+
+		ctx = context.WithValue(ctx, auth.Key, claims)
+
+		return handler(ctx, req)
+	}
+
+	return f
+}
+
+var errBadAuthorization = status.Error(codes.Unauthenticated, "expected authorization metadata format: Bearer <token> or ApiKey <key>")
+
+// Require rejects calls to the methods in perms made without the permission
+// listed for them. It must come after Authenticate.
+func Require(perms map[string]string) grpc.UnaryServerInterceptor {
+	f := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		want, ok := perms[info.FullMethod]
+		if !ok {
+			return handler(ctx, req)
+		}
+
+		ctx, span := trace.StartSpan(ctx, "internal.rpc.Require")
+		defer span.End()
+
+		claims, ok := ctx.Value(auth.Key).(auth.Claims)
+		if !ok {
+			return nil, fmt.Errorf("claims missing from context: Require called without/before Authenticate")
+		}
+		if !claims.Can(want) {
+			return nil, status.Error(codes.PermissionDenied, "you are not authorized for that action")
+		}
+
+		return handler(ctx, req)
+	}
+
+	return f
+}
@@ -0,0 +1,90 @@
+package rpc_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"testing"
+
+	"gitlab.fenbishuo.com/fenbishuo/service-training/cmd/sales-api/internal/rpc"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/authz"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/web"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/product"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/warehouse"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var info = &grpc.UnaryServerInfo{FullMethod: "/sales.v1.Products/AddSale"}
+
+func TestErrors(t *testing.T) {
+	logger := log.New(ioutil.Discard, "", 0)
+
+	tests := []struct {
+		err  error
+		code codes.Code
+		msg  string
+	}{
+		{fmt.Errorf("get product: %w", product.ErrNotFound), codes.NotFound, product.ErrNotFound.Error()},
+		{product.ErrInvalidID, codes.InvalidArgument, product.ErrInvalidID.Error()},
+		{authz.Check(auth.Claims{}, authz.Delete, authz.Resource{Kind: authz.Product}), codes.PermissionDenied, "not allowed to delete product: the caller must be one who holds products:admin"},
+		{product.ErrDuplicateSKU, codes.AlreadyExists, product.ErrDuplicateSKU.Error()},
+		{warehouse.ErrInsufficientStock, codes.FailedPrecondition, warehouse.ErrInsufficientStock.Error()},
+		{fmt.Errorf("adding sale: %w", warehouse.ErrNotFound), codes.NotFound, warehouse.ErrNotFound.Error()},
+		{web.Validate(product.NewProduct{}), codes.InvalidArgument, "field validation error: name: name is a required field; quantity: quantity must be 1 or greater"},
+		{status.Error(codes.Unauthenticated, "no token"), codes.Unauthenticated, "no token"},
+		{fmt.Errorf("selecting products: connection refused"), codes.Internal, "Internal Server Error"},
+	}
+
+	for _, tt := range tests {
+		handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, tt.err
+		}
+
+		_, err := rpc.Errors(logger)(context.Background(), nil, info, handler)
+
+		s := status.Convert(err)
+		if s.Code() != tt.code || s.Message() != tt.msg {
+			t.Errorf("%v: got %s %q, want %s %q", tt.err, s.Code(), s.Message(), tt.code, tt.msg)
+		}
+	}
+}
+
+func TestPanics(t *testing.T) {
+	logger := log.New(ioutil.Discard, "", 0)
+
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		panic("boom")
+	}
+
+	_, err := rpc.Panics(logger)(context.Background(), nil, info, handler)
+	if err == nil || err.Error() != "panic: boom" {
+		t.Fatalf("got error %v, want the panic", err)
+	}
+}
+
+func TestRequire(t *testing.T) {
+	perms := map[string]string{info.FullMethod: auth.PermProductsAdmin}
+
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", nil
+	}
+
+	user := context.WithValue(context.Background(), auth.Key, auth.Claims{Permissions: []string{auth.PermProductsWrite}})
+	if _, err := rpc.Require(perms)(user, nil, info, handler); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("user: got %v, want PermissionDenied", err)
+	}
+
+	admin := context.WithValue(context.Background(), auth.Key, auth.Claims{Permissions: []string{auth.PermProductsAdmin}})
+	if _, err := rpc.Require(perms)(admin, nil, info, handler); err != nil {
+		t.Fatalf("admin: got %v, want no error", err)
+	}
+
+	other := &grpc.UnaryServerInfo{FullMethod: "/sales.v1.Products/ListSales"}
+	if _, err := rpc.Require(perms)(user, nil, other, handler); err != nil {
+		t.Fatalf("unrestricted method: got %v, want no error", err)
+	}
+}
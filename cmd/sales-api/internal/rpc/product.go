@@ -0,0 +1,213 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang/protobuf/ptypes/empty"
+	"github.com/jmoiron/sqlx"
+	salesv1 "gitlab.fenbishuo.com/fenbishuo/service-training/api/sales/v1"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/web"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/product"
+	"go.opencensus.io/trace"
+)
+
+// Products implements salesv1.ProductsServer.
+type Products struct {
+	db *sqlx.DB
+}
+
+func (p *Products) ListProducts(ctx context.Context, req *salesv1.ListProductsRequest) (*salesv1.ListProductsResponse, error) {
+	ctx, span := trace.StartSpan(ctx, "rpc.Products.ListProducts")
+	defer span.End()
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return nil, errors.New("claims missing from context")
+	}
+
+	f := product.Filter{
+		CategoryID: req.CategoryId,
+		Tag:        req.Tag,
+	}
+
+	list, err := product.List(ctx, p.db, claims.Tenant, f)
+	if err != nil {
+		return nil, fmt.Errorf("listing products: %w", err)
+	}
+
+	resp := salesv1.ListProductsResponse{
+		Products: make([]*salesv1.Product, len(list)),
+	}
+	for i, prod := range list {
+		resp.Products[i] = toProduct(prod)
+	}
+
+	return &resp, nil
+}
+
+func (p *Products) GetProduct(ctx context.Context, req *salesv1.GetProductRequest) (*salesv1.Product, error) {
+	ctx, span := trace.StartSpan(ctx, "rpc.Products.GetProduct")
+	defer span.End()
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return nil, errors.New("claims missing from context")
+	}
+
+	prod, err := product.Retrieve(ctx, p.db, claims.Tenant, req.Id)
+	if err != nil {
+		return nil, fmt.Errorf("get product: %w", err)
+	}
+
+	return toProduct(*prod), nil
+}
+
+func (p *Products) CreateProduct(ctx context.Context, req *salesv1.CreateProductRequest) (*salesv1.Product, error) {
+	ctx, span := trace.StartSpan(ctx, "rpc.Products.CreateProduct")
+	defer span.End()
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return nil, errors.New("claims missing from context")
+	}
+
+	np := product.NewProduct{
+		Name:        req.Name,
+		SKU:         req.Sku,
+		Cost:        fromMoney(req.Cost),
+		Quantity:    int(req.Quantity),
+		WarehouseID: req.WarehouseId,
+		Tags:        req.Tags,
+	}
+	if req.CategoryId != "" {
+		np.CategoryID = &req.CategoryId
+	}
+	if err := web.Validate(np); err != nil {
+		return nil, err
+	}
+
+	prod, err := product.Create(ctx, p.db, claims, np, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("creating product: %w", err)
+	}
+
+	return toProduct(*prod), nil
+}
+
+func (p *Products) UpdateProduct(ctx context.Context, req *salesv1.UpdateProductRequest) (*empty.Empty, error) {
+	ctx, span := trace.StartSpan(ctx, "rpc.Products.UpdateProduct")
+	defer span.End()
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return nil, errors.New("claims missing from context")
+	}
+
+	var update product.UpdateProduct
+	if req.Name != nil {
+		update.Name = &req.Name.Value
+	}
+	if req.Cost != nil {
+		cost := fromMoney(req.Cost)
+		update.Cost = &cost
+	}
+	if req.Quantity != nil {
+		q := int(req.Quantity.Value)
+		update.Quantity = &q
+	}
+	if req.CategoryId != nil {
+		update.CategoryID = &req.CategoryId.Value
+	}
+	if req.Tags != nil {
+		update.Tags = append([]string{}, req.Tags.Tags...)
+	}
+	if err := web.Validate(update); err != nil {
+		return nil, err
+	}
+
+	if err := product.Update(ctx, p.db, claims, req.Id, update, time.Now()); err != nil {
+		return nil, fmt.Errorf("updating product %q: %w", req.Id, err)
+	}
+
+	return &empty.Empty{}, nil
+}
+
+func (p *Products) DeleteProduct(ctx context.Context, req *salesv1.DeleteProductRequest) (*empty.Empty, error) {
+	ctx, span := trace.StartSpan(ctx, "rpc.Products.DeleteProduct")
+	defer span.End()
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return nil, errors.New("claims missing from context")
+	}
+
+	if err := product.Delete(ctx, p.db, claims, req.Id, time.Now()); err != nil {
+		return nil, fmt.Errorf("deleting product %q: %w", req.Id, err)
+	}
+
+	return &empty.Empty{}, nil
+}
+
+func (p *Products) AddSale(ctx context.Context, req *salesv1.AddSaleRequest) (*salesv1.Sale, error) {
+	ctx, span := trace.StartSpan(ctx, "rpc.Products.AddSale")
+	defer span.End()
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return nil, errors.New("claims missing from context")
+	}
+
+	ns := product.NewSale{
+		VariantID:      req.VariantId,
+		WarehouseID:    req.WarehouseId,
+		Quantity:       int(req.Quantity),
+		Coupon:         req.Coupon,
+		OverrideReason: req.OverrideReason,
+		Jurisdiction:   req.Jurisdiction,
+		CustomerName:   req.CustomerName,
+		CustomerEmail:  req.CustomerEmail,
+		ExternalRef:    req.ExternalRef,
+	}
+	if req.Paid != nil {
+		paid := fromMoney(req.Paid)
+		ns.Paid = &paid
+	}
+	if err := web.Validate(ns); err != nil {
+		return nil, err
+	}
+
+	sale, err := product.AddSale(ctx, p.db, claims, ns, req.ProductId, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("adding new sale: %w", err)
+	}
+
+	return toSale(*sale), nil
+}
+
+func (p *Products) ListSales(ctx context.Context, req *salesv1.ListSalesRequest) (*salesv1.ListSalesResponse, error) {
+	ctx, span := trace.StartSpan(ctx, "rpc.Products.ListSales")
+	defer span.End()
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return nil, errors.New("claims missing from context")
+	}
+
+	list, err := product.ListSales(ctx, p.db, claims.Tenant, req.ProductId)
+	if err != nil {
+		return nil, fmt.Errorf("get sales list: %w", err)
+	}
+
+	resp := salesv1.ListSalesResponse{
+		Sales: make([]*salesv1.Sale, len(list)),
+	}
+	for i, s := range list {
+		resp.Sales[i] = toSale(s)
+	}
+
+	return &resp, nil
+}
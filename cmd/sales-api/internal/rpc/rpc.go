@@ -0,0 +1,63 @@
+// Package rpc serves the sales.v1 gRPC services. It is the RPC counterpart of
+// the handlers package: the services call the same internal packages and the
+// interceptors do for each call what the mid package does for each request.
+package rpc
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	salesv1 "gitlab.fenbishuo.com/fenbishuo/service-training/api/sales/v1"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/apikey"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/session"
+	"go.opencensus.io/plugin/ocgrpc"
+	"google.golang.org/grpc"
+)
+
+// public lists the methods that may be called without a bearer token.
+var public = map[string]bool{
+	"/sales.v1.Users/Token": true,
+}
+
+// perms lists the methods that need a permission, as mid.Require does for
+// their REST routes.
+var perms = map[string]string{
+	"/sales.v1.Products/CreateProduct": auth.PermProductsWrite,
+	"/sales.v1.Products/UpdateProduct": auth.PermProductsWrite,
+	"/sales.v1.Products/DeleteProduct": auth.PermProductsAdmin,
+	"/sales.v1.Products/AddSale":       auth.PermSalesCreate,
+	"/sales.v1.Users/CreateUser":       auth.PermUsersAdmin,
+}
+
+// apiKeyAuth checks the API keys given to Authenticate. Errors interceptor
+// reports invalid keys as codes.Unauthenticated.
+func apiKeyAuth(db *sqlx.DB) auth.APIKeyFunc {
+	f := func(ctx context.Context, key string) (auth.Claims, error) {
+		return apikey.Authenticate(ctx, db, key, time.Now())
+	}
+	return f
+}
+
+// Server returns a gRPC server with the Products and Users services
+// registered.
+func Server(db *sqlx.DB, log *log.Logger, authenticator *auth.Authenticator, revocations *session.Revocations) *grpc.Server {
+	srv := grpc.NewServer(
+		grpc.StatsHandler(&ocgrpc.ServerHandler{}),
+		grpc.UnaryInterceptor(chain(
+			Logger(log),
+			Errors(log),
+			Metrics(),
+			Panics(log),
+			Authenticate(authenticator, revocations, apiKeyAuth(db), public),
+			Require(perms),
+		)),
+	)
+
+	salesv1.RegisterProductsServer(srv, &Products{db: db})
+	salesv1.RegisterUsersServer(srv, &Users{db: db, authenticator: authenticator})
+
+	return srv
+}
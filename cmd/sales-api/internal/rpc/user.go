@@ -0,0 +1,101 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/golang/protobuf/ptypes/empty"
+	"github.com/jmoiron/sqlx"
+	salesv1 "gitlab.fenbishuo.com/fenbishuo/service-training/api/sales/v1"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/login"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/web"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/user"
+	"go.opencensus.io/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Users implements salesv1.UsersServer.
+type Users struct {
+	db            *sqlx.DB
+	authenticator *auth.Authenticator
+}
+
+func (u *Users) Token(ctx context.Context, req *salesv1.TokenRequest) (*salesv1.TokenResponse, error) {
+	ctx, span := trace.StartSpan(ctx, "rpc.Users.Token")
+	defer span.End()
+
+	var addr string
+	if p, ok := peer.FromContext(ctx); ok {
+		if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+			addr = host
+		}
+	}
+
+	// There is no call to answer an MFA challenge with, so users enrolled in
+	// MFA are sent to log in over HTTP.
+	claims, err := login.Authenticate(ctx, u.db, req.Email, req.Password, addr, time.Now())
+	switch {
+	case errors.Is(err, user.ErrMFARequired):
+		return nil, status.Error(codes.FailedPrecondition, "multi-factor authentication required: log in over HTTP at /v1/users/token")
+	case err != nil:
+		return nil, fmt.Errorf("authenticating: %w", err)
+	}
+
+	tkn, err := u.authenticator.GenerateToken(claims)
+	if err != nil {
+		return nil, fmt.Errorf("generating token: %w", err)
+	}
+
+	return &salesv1.TokenResponse{Token: tkn}, nil
+}
+
+func (u *Users) Me(ctx context.Context, _ *empty.Empty) (*salesv1.User, error) {
+	ctx, span := trace.StartSpan(ctx, "rpc.Users.Me")
+	defer span.End()
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return nil, errors.New("claims missing from context")
+	}
+
+	usr, err := user.Retrieve(ctx, u.db, claims.Tenant, claims.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("get user: %w", err)
+	}
+
+	return toUser(*usr), nil
+}
+
+func (u *Users) CreateUser(ctx context.Context, req *salesv1.CreateUserRequest) (*salesv1.User, error) {
+	ctx, span := trace.StartSpan(ctx, "rpc.Users.CreateUser")
+	defer span.End()
+
+	nu := user.NewUser{
+		Name:            req.Name,
+		Email:           req.Email,
+		Roles:           req.Roles,
+		Password:        req.Password,
+		PasswordConfirm: req.Password,
+	}
+	if err := web.Validate(nu); err != nil {
+		return nil, err
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return nil, errors.New("claims missing from context")
+	}
+
+	usr, err := user.Create(ctx, u.db, claims, nu, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("creating user: %w", err)
+	}
+
+	return toUser(*usr), nil
+}
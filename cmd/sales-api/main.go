@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
@@ -20,9 +21,16 @@ import (
 	openzipkin "github.com/openzipkin/zipkin-go"
 	zipkinHTTP "github.com/openzipkin/zipkin-go/reporter/http"
 	"gitlab.fenbishuo.com/fenbishuo/service-training/cmd/sales-api/internal/handlers"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/cmd/sales-api/internal/rpc"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/account"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/outbox"
 	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
 	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/conf"
 	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/database"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/mail"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/receipt"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/session"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/webhook"
 	"go.opencensus.io/trace"
 )
 
@@ -44,6 +52,9 @@ func run() error {
 			WriteTimeout    time.Duration `conf:"default:5s"`
 			ShutdownTimeout time.Duration `conf:"default:5s"`
 		}
+		GRPC struct {
+			Address string `conf:"default:localhost:9000"`
+		}
 		DB struct {
 			User       string `conf:"default:postgres"`
 			Password   string `conf:"default:postgres,noprint"`
@@ -55,6 +66,47 @@ func run() error {
 			KeyID          string `conf:"default:1"`
 			PrivateKeyFile string `conf:"default:private.pem"`
 			Algorithm      string `conf:"default:RS256"`
+
+			// KeyDir, when set, replaces PrivateKeyFile with a directory of
+			// <kid>.pem files. Tokens are signed with the key named in the
+			// directory's active file, or KeyID without one, and verified
+			// with any of them. The directory is reloaded on SIGHUP and
+			// whenever its files change.
+			KeyDir          string
+			KeyPollInterval time.Duration `conf:"default:30s"`
+
+			// JWKS is the file or URL of the key set of another issuer
+			// whose tokens are also accepted, such as a local stand-in.
+			JWKS        string
+			JWKSRefresh time.Duration `conf:"default:15m"`
+
+			// RevocationPoll is how often tokens revoked by other
+			// instances are picked up.
+			RevocationPoll time.Duration `conf:"default:5s"`
+		}
+		Receipts struct {
+			TemplateDir string
+		}
+		Mail struct {
+			// Host is the SMTP server mail is sent through. When it is
+			// blank, mail is logged instead.
+			Host     string
+			Port     int `conf:"default:587"`
+			Username string
+			Password string `conf:"noprint"`
+			From     string `conf:"default:sales@example.com"`
+		}
+		Outbox struct {
+			PollInterval time.Duration `conf:"default:1s"`
+			BatchSize    int           `conf:"default:100"`
+		}
+		Webhooks struct {
+			PollInterval time.Duration `conf:"default:1s"`
+			BatchSize    int           `conf:"default:20"`
+			Timeout      time.Duration `conf:"default:10s"`
+			MaxAttempts  int           `conf:"default:10"`
+			Backoff      time.Duration `conf:"default:30s"`
+			MaxBackoff   time.Duration `conf:"default:6h"`
 		}
 		Trace struct {
 			URL         string  `conf:"default:http://localhost:9411/api/v2/spans"`
@@ -113,41 +165,152 @@ func run() error {
 		log.Println("debug service closed", err)
 	}()
 
-	authenticator, err := createAuth(cfg.Auth.PrivateKeyFile, cfg.Auth.KeyID, cfg.Auth.Algorithm)
+	authenticator, keys, err := createAuth(cfg.Auth.KeyDir, cfg.Auth.PrivateKeyFile, cfg.Auth.KeyID, cfg.Auth.Algorithm, cfg.Auth.JWKS, cfg.Auth.JWKSRefresh)
 	if err != nil {
 		return fmt.Errorf("constructing authenticator: %w", err)
 	}
 
+	if keys != nil {
+		reloadCtx, stopReload := context.WithCancel(context.Background())
+		defer stopReload()
+
+		go keys.Watch(reloadCtx, cfg.Auth.KeyPollInterval, func(err error) {
+			log.Printf("main: reloading auth keys: %v", err)
+		})
+
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		defer signal.Stop(hup)
+
+		go func() {
+			for {
+				select {
+				case <-reloadCtx.Done():
+					return
+				case <-hup:
+					if err := keys.Reload(); err != nil {
+						log.Printf("main: reloading auth keys: %v", err)
+						continue
+					}
+					log.Printf("main: auth keys reloaded")
+				}
+			}
+		}()
+	}
+
+	revocations, err := session.NewRevocations(context.Background(), db)
+	if err != nil {
+		return fmt.Errorf("loading revoked tokens: %w", err)
+	}
+
+	receipts, err := receipt.NewRenderer(cfg.Receipts.TemplateDir)
+	if err != nil {
+		return fmt.Errorf("loading receipt templates: %w", err)
+	}
+
+	mailer := mail.Log(log)
+	if cfg.Mail.Host != "" {
+		mailer = mail.NewSMTP(mail.Config{
+			Host:     cfg.Mail.Host,
+			Port:     cfg.Mail.Port,
+			Username: cfg.Mail.Username,
+			Password: cfg.Mail.Password,
+			From:     cfg.Mail.From,
+		})
+	}
+
+	// Deliver the domain events recorded by the API, and the webhooks and
+	// mail they cause, until it shuts down.
+	dispatcher := outbox.NewDispatcher(db, log, cfg.Outbox.PollInterval, cfg.Outbox.BatchSize,
+		outbox.LogSink(log), webhook.Sink(db), account.VerificationSink(db, mailer), account.ResetSink(db, mailer))
+
+	policy := webhook.Policy{
+		MaxAttempts: cfg.Webhooks.MaxAttempts,
+		Backoff:     cfg.Webhooks.Backoff,
+		MaxBackoff:  cfg.Webhooks.MaxBackoff,
+	}
+	sender := webhook.NewSender(db, log, &http.Client{Timeout: cfg.Webhooks.Timeout}, cfg.Webhooks.PollInterval, cfg.Webhooks.BatchSize, policy)
+
+	dispatchCtx, stopDispatch := context.WithCancel(context.Background())
+	dispatchDone := make(chan struct{}, 3)
+	go func() {
+		dispatcher.Run(dispatchCtx)
+		dispatchDone <- struct{}{}
+	}()
+	go func() {
+		sender.Run(dispatchCtx)
+		dispatchDone <- struct{}{}
+	}()
+	go func() {
+		revocations.Run(dispatchCtx, log, cfg.Auth.RevocationPoll)
+		dispatchDone <- struct{}{}
+	}()
+	defer func() {
+		stopDispatch()
+		<-dispatchDone
+		<-dispatchDone
+		<-dispatchDone
+	}()
+
 	shutdown := make(chan os.Signal, 1)
 	signal.Notify(shutdown, os.Interrupt, syscall.SIGTERM)
 
 	api := http.Server{
 		Addr:         cfg.Web.Address,
-		Handler:      handlers.API(shutdown, db, log, authenticator),
+		Handler:      handlers.API(shutdown, db, log, authenticator, revocations, receipts),
 		ReadTimeout:  cfg.Web.ReadTimeout,
 		WriteTimeout: cfg.Web.WriteTimeout,
 	}
 
-	serverErrors := make(chan error, 1)
+	rpcServer := rpc.Server(db, log, authenticator, revocations)
+
+	rpcListener, err := net.Listen("tcp", cfg.GRPC.Address)
+	if err != nil {
+		return fmt.Errorf("listening for gRPC: %w", err)
+	}
+
+	serverErrors := make(chan error, 2)
 
 	go func() {
 		log.Printf("main: API listening on %s", api.Addr)
 		serverErrors <- api.ListenAndServe()
 	}()
 
+	go func() {
+		log.Printf("main: gRPC API listening on %s", cfg.GRPC.Address)
+		serverErrors <- rpcServer.Serve(rpcListener)
+	}()
+
 	select {
 	case err := <-serverErrors:
+		api.Close()
+		rpcServer.Stop()
 		return fmt.Errorf("start server: %w", err)
 	case sig := <-shutdown:
 		ctx, cancel := context.WithTimeout(context.Background(), cfg.Web.ShutdownTimeout)
 		defer cancel()
 
+		// Let the calls in flight finish, but no longer than the HTTP API
+		// is given.
+		rpcStopped := make(chan struct{})
+		go func() {
+			rpcServer.GracefulStop()
+			close(rpcStopped)
+		}()
+
 		err := api.Shutdown(ctx)
 		if err != nil {
 			log.Printf("main: graceful shutdown did not complete in %v: %v", cfg.Web.ShutdownTimeout, err)
 			err = api.Close()
 		}
 
+		select {
+		case <-rpcStopped:
+		case <-ctx.Done():
+			log.Printf("main: gRPC graceful shutdown did not complete in %v", cfg.Web.ShutdownTimeout)
+			rpcServer.Stop()
+		}
+
 		switch {
 		case sig == syscall.SIGSTOP:
 			return errors.New("integrity issue caused shutdown")
@@ -158,20 +321,48 @@ func run() error {
 	return nil
 }
 
-func createAuth(privateKeyFile, keyID, algorithm string) (*auth.Authenticator, error) {
-	keyContents, err := ioutil.ReadFile(privateKeyFile)
-	if err != nil {
-		return nil, fmt.Errorf("reading auth private key %w", err)
+// createAuth returns the Authenticator for tokens signed with the key keyID,
+// along with the KeyStore it is loaded from when keyDir is set. Tokens from
+// the issuer of the jwks key set are accepted too.
+func createAuth(keyDir, privateKeyFile, keyID, algorithm, jwks string, jwksRefresh time.Duration) (*auth.Authenticator, *auth.KeyStore, error) {
+	var (
+		keys   *auth.KeyStore
+		key    *rsa.PrivateKey
+		public auth.KeyLookupFunc
+	)
+
+	if keyDir != "" {
+		var err error
+		keys, err = auth.NewKeyStore(keyDir, keyID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("loading auth keys %w", err)
+		}
+		public = keys.PublicKey
+	} else {
+		keyContents, err := ioutil.ReadFile(privateKeyFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading auth private key %w", err)
+		}
+
+		key, err = jwt.ParseRSAPrivateKeyFromPEM(keyContents)
+		if err != nil {
+			return nil, nil, fmt.Errorf("parsing auth private key %w", err)
+		}
+		public = auth.NewSimpleKeyLookupFunc(keyID, key.Public().(*rsa.PublicKey))
 	}
 
-	key, err := jwt.ParseRSAPrivateKeyFromPEM(keyContents)
-	if err != nil {
-		return nil, fmt.Errorf("parsing auth private key %w", err)
+	if jwks != "" {
+		ks := auth.NewKeySet(jwks, &http.Client{Timeout: 10 * time.Second}, jwksRefresh)
+		public = auth.ChainKeyLookupFuncs(public, ks.Lookup)
 	}
 
-	public := auth.NewSimpleKeyLookupFunc(keyID, key.Public().(*rsa.PublicKey))
+	if keys != nil {
+		a, err := auth.NewKeyStoreAuthenticator(keys, algorithm, public)
+		return a, keys, err
+	}
 
-	return auth.NewAuthenticator(key, keyID, algorithm, public)
+	a, err := auth.NewAuthenticator(key, keyID, algorithm, public)
+	return a, nil, err
 }
 
 func registerTracer(service, httpAddr, traceURL string, probability float64) (func() error, error) {
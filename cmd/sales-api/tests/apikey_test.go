@@ -0,0 +1,85 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"gitlab.fenbishuo.com/fenbishuo/service-training/cmd/sales-api/internal/handlers"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/tests"
+)
+
+func TestAPIKeys(t *testing.T) {
+	test := tests.New(t)
+	defer test.Teardown()
+
+	shutdown := make(chan os.Signal, 1)
+	app := handlers.API(shutdown, test.DB, test.Log, test.Authenticator, test.Revocations, test.Receipts)
+	adminToken := test.Token("admin@example.com", "gophers")
+
+	body := strings.NewReader(`{"name": "batch", "user_id": "` + tests.AdminID + `", "roles": ["ADMIN"]}`)
+	req := httptest.NewRequest("POST", "/v1/api-keys", body)
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+	resp := httptest.NewRecorder()
+
+	app.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("creating: expected status code %v, got %v: %s", http.StatusCreated, resp.Code, resp.Body)
+	}
+
+	var created struct {
+		ID  string `json:"id"`
+		Key string `json:"key"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		t.Fatalf("decoding: %s", err)
+	}
+
+	call := func(key string) int {
+		req := httptest.NewRequest("GET", "/v1/sales/export", nil)
+		req.Header.Set("Authorization", "ApiKey "+key)
+		resp := httptest.NewRecorder()
+		app.ServeHTTP(resp, req)
+		return resp.Code
+	}
+
+	// The key acts as its owner, an admin, on an admin only route.
+	if code := call(created.Key); code != http.StatusOK {
+		t.Fatalf("using key: expected status code %v, got %v", http.StatusOK, code)
+	}
+	if code := call("bogus.key"); code != http.StatusUnauthorized {
+		t.Fatalf("using bogus key: expected status code %v, got %v", http.StatusUnauthorized, code)
+	}
+
+	// A key has no session to log out of, and is not revoked by trying.
+	req = httptest.NewRequest("POST", "/v1/users/logout", nil)
+	req.Header.Set("Authorization", "ApiKey "+created.Key)
+	resp = httptest.NewRecorder()
+
+	app.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("logging out with key: expected status code %v, got %v", http.StatusBadRequest, resp.Code)
+	}
+	if code := call(created.Key); code != http.StatusOK {
+		t.Fatalf("using key after logout: expected status code %v, got %v", http.StatusOK, code)
+	}
+
+	req = httptest.NewRequest("DELETE", "/v1/api-keys/"+created.ID, nil)
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+	resp = httptest.NewRecorder()
+
+	app.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusNoContent {
+		t.Fatalf("revoking: expected status code %v, got %v", http.StatusNoContent, resp.Code)
+	}
+
+	if code := call(created.Key); code != http.StatusUnauthorized {
+		t.Fatalf("using revoked key: expected status code %v, got %v", http.StatusUnauthorized, code)
+	}
+}
@@ -0,0 +1,163 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/cmd/sales-api/internal/handlers"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/tests"
+)
+
+func TestGraphQL(t *testing.T) {
+	test := tests.New(t)
+	defer test.Teardown()
+
+	shutdown := make(chan os.Signal, 1)
+
+	tests := GraphQLTests{
+		app:        handlers.API(shutdown, test.DB, test.Log, test.Authenticator, test.Revocations, test.Receipts),
+		adminToken: test.Token("admin@example.com", "gophers"),
+		userToken:  test.Token("user@example.com", "gophers"),
+	}
+
+	t.Run("RequiresAuth", tests.RequiresAuth)
+	t.Run("NestedSales", tests.NestedSales)
+	t.Run("FieldRoles", tests.FieldRoles)
+	t.Run("Complexity", tests.Complexity)
+}
+
+type GraphQLTests struct {
+	app        http.Handler
+	adminToken string
+	userToken  string
+}
+
+// graphQLResponse is a response with errors reduced to their messages.
+type graphQLResponse struct {
+	Data   map[string]interface{}
+	Errors []string
+}
+
+func (g *GraphQLTests) query(t *testing.T, token, query string) graphQLResponse {
+	t.Helper()
+
+	body, err := json.Marshal(map[string]string{"query": query})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("POST", "/graphql", strings.NewReader(string(body)))
+	resp := httptest.NewRecorder()
+
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	g.app.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusOK {
+		t.Fatalf("querying: expected status code %v, got %v", http.StatusOK, resp.Code)
+	}
+
+	var res struct {
+		Data   map[string]interface{} `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		t.Fatalf("decoding: %s", err)
+	}
+
+	out := graphQLResponse{Data: res.Data}
+	for _, e := range res.Errors {
+		out.Errors = append(out.Errors, e.Message)
+	}
+	return out
+}
+
+func (g *GraphQLTests) RequiresAuth(t *testing.T) {
+	req := httptest.NewRequest("POST", "/graphql", strings.NewReader(`{"query":"{ me { email } }"}`))
+	resp := httptest.NewRecorder()
+
+	g.app.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusUnauthorized {
+		t.Fatalf("querying anonymously: expected status code %v, got %v", http.StatusUnauthorized, resp.Code)
+	}
+}
+
+func (g *GraphQLTests) NestedSales(t *testing.T) {
+	res := g.query(t, g.adminToken, `{
+		products { name sales { id quantity paid { amount } } }
+		me { email roles }
+	}`)
+
+	if len(res.Errors) > 0 {
+		t.Fatalf("query failed: %v", res.Errors)
+	}
+
+	want := map[string]interface{}{
+		"products": []interface{}{
+			map[string]interface{}{
+				"name": "Comic Books",
+				"sales": []interface{}{
+					map[string]interface{}{"id": "98b6d4b8-f04b-4c79-8c2e-a0aef46854b7", "quantity": float64(2), "paid": map[string]interface{}{"amount": float64(100)}},
+					map[string]interface{}{"id": "85f6fb09-eb05-4874-ae39-82d1a30fe0d7", "quantity": float64(5), "paid": map[string]interface{}{"amount": float64(250)}},
+				},
+			},
+			map[string]interface{}{
+				"name": "McDonalds Toys",
+				"sales": []interface{}{
+					map[string]interface{}{"id": "a235be9e-ab5d-44e6-a987-fa1c749264c7", "quantity": float64(3), "paid": map[string]interface{}{"amount": float64(225)}},
+				},
+			},
+		},
+		"me": map[string]interface{}{
+			"email": "admin@example.com",
+			"roles": []interface{}{"ADMIN", "USER"},
+		},
+	}
+
+	if diff := cmp.Diff(want, res.Data); diff != "" {
+		t.Fatalf("Response did not match expected. Diff:\n%s", diff)
+	}
+}
+
+func (g *GraphQLTests) FieldRoles(t *testing.T) {
+	const q = `{ sale(id: "98b6d4b8-f04b-4c79-8c2e-a0aef46854b7") { quantity customer_name } }`
+
+	res := g.query(t, g.userToken, q)
+
+	want := map[string]interface{}{
+		"sale": map[string]interface{}{"quantity": float64(2), "customer_name": nil},
+	}
+	if diff := cmp.Diff(want, res.Data); diff != "" {
+		t.Fatalf("Response did not match expected. Diff:\n%s", diff)
+	}
+	if len(res.Errors) != 1 || !strings.Contains(res.Errors[0], "not authorized") {
+		t.Fatalf("expected one authorization error, got %v", res.Errors)
+	}
+
+	if res := g.query(t, g.adminToken, q); len(res.Errors) > 0 {
+		t.Fatalf("admin query failed: %v", res.Errors)
+	}
+}
+
+func (g *GraphQLTests) Complexity(t *testing.T) {
+	// Each products field costs 1 + 10 * (1 + (1 + 10 * 5)) = 521.
+	res := g.query(t, g.adminToken, `{
+		a: products { name sales { id quantity paid { amount currency } } }
+		b: products { name sales { id quantity paid { amount currency } } }
+	}`)
+
+	if res.Data != nil {
+		t.Fatalf("too complex a query returned data: %v", res.Data)
+	}
+	if len(res.Errors) != 1 || !strings.Contains(res.Errors[0], "complexity 1042") {
+		t.Fatalf("expected a complexity error, got %v", res.Errors)
+	}
+}
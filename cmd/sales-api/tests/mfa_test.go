@@ -0,0 +1,104 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"gitlab.fenbishuo.com/fenbishuo/service-training/cmd/sales-api/internal/handlers"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/mfa"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/tests"
+)
+
+func TestMFA(t *testing.T) {
+	test := tests.New(t)
+	defer test.Teardown()
+
+	shutdown := make(chan os.Signal, 1)
+	app := handlers.API(shutdown, test.DB, test.Log, test.Authenticator, test.Revocations, test.Receipts)
+	adminToken := test.Token("admin@example.com", "gophers")
+	userToken := test.Token("user@example.com", "gophers")
+
+	call := func(method, url, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp := httptest.NewRecorder()
+		app.ServeHTTP(resp, req)
+		return resp
+	}
+
+	login := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/v1/users/token", nil)
+		req.SetBasicAuth("user@example.com", "gophers")
+		resp := httptest.NewRecorder()
+		app.ServeHTTP(resp, req)
+		return resp
+	}
+
+	resp := call("POST", "/v1/users/mfa", userToken, "")
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("enrolling: expected status code %v, got %v: %s", http.StatusCreated, resp.Code, resp.Body)
+	}
+	var e mfa.Enrolment
+	if err := json.NewDecoder(resp.Body).Decode(&e); err != nil {
+		t.Fatalf("decoding: %s", err)
+	}
+
+	code, err := mfa.Code(e.Secret, time.Now().Add(-30*time.Second))
+	if err != nil {
+		t.Fatalf("generating code: %s", err)
+	}
+	resp = call("POST", "/v1/users/mfa/confirm", userToken, `{"code": "`+code+`"}`)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("confirming: expected status code %v, got %v: %s", http.StatusOK, resp.Code, resp.Body)
+	}
+	var confirmed struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&confirmed); err != nil {
+		t.Fatalf("decoding: %s", err)
+	}
+
+	// A password now only gets a challenge.
+	resp = login()
+	if resp.Code != http.StatusAccepted {
+		t.Fatalf("logging in: expected status code %v, got %v: %s", http.StatusAccepted, resp.Code, resp.Body)
+	}
+	var c mfa.Challenge
+	if err := json.NewDecoder(resp.Body).Decode(&c); err != nil {
+		t.Fatalf("decoding: %s", err)
+	}
+
+	if resp := call("POST", "/v1/users/token/mfa", "", `{"challenge": "`+c.Token+`", "code": "nope"}`); resp.Code != http.StatusUnauthorized {
+		t.Fatalf("answering with a wrong code: expected status code %v, got %v", http.StatusUnauthorized, resp.Code)
+	}
+
+	resp = call("POST", "/v1/users/token/mfa", "", `{"challenge": "`+c.Token+`", "code": "`+confirmed.RecoveryCodes[0]+`"}`)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("answering: expected status code %v, got %v: %s", http.StatusOK, resp.Code, resp.Body)
+	}
+	var tkn struct {
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tkn); err != nil {
+		t.Fatalf("decoding: %s", err)
+	}
+	if tkn.Token == "" || tkn.RefreshToken == "" {
+		t.Fatalf("got tokens %+v, want both", tkn)
+	}
+
+	// An admin can reset it, after which a password is enough again.
+	if resp := call("DELETE", "/v1/users/45b5fbd3-755f-4379-8f07-a58d4a30fa2f/mfa", adminToken, ""); resp.Code != http.StatusNoContent {
+		t.Fatalf("resetting: expected status code %v, got %v: %s", http.StatusNoContent, resp.Code, resp.Body)
+	}
+	if resp := login(); resp.Code != http.StatusOK {
+		t.Fatalf("logging in after reset: expected status code %v, got %v: %s", http.StatusOK, resp.Code, resp.Body)
+	}
+}
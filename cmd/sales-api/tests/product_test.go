@@ -12,6 +12,7 @@ import (
 	"github.com/google/go-cmp/cmp"
 	"gitlab.fenbishuo.com/fenbishuo/service-training/cmd/sales-api/internal/handlers"
 	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/tests"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/warehouse"
 )
 
 func TestProducts(t *testing.T) {
@@ -21,20 +22,25 @@ func TestProducts(t *testing.T) {
 	shutdown := make(chan os.Signal, 1)
 
 	tests := ProductTests{
-		app:        handlers.API(shutdown, test.DB, test.Log, test.Authenticator),
+		app:        handlers.API(shutdown, test.DB, test.Log, test.Authenticator, test.Revocations, test.Receipts),
 		adminToken: test.Token("admin@example.com", "gophers"),
+		userToken:  test.Token("user@example.com", "gophers"),
 	}
 
 	t.Run("List", tests.List)
+	t.Run("ListByCategory", tests.ListByCategory)
 	t.Run("CreateRequiresFields", tests.CreateRequiresFields)
 	t.Run("ProductCRUD", tests.ProductCRUD)
+	t.Run("UpdateDenied", tests.UpdateDenied)
 	t.Run("SalesList", tests.SalesList)
 	t.Run("AddSale", tests.AddSale)
+	t.Run("Receipt", tests.Receipt)
 }
 
 type ProductTests struct {
 	app        http.Handler
 	adminToken string
+	userToken  string
 }
 
 func (p *ProductTests) List(t *testing.T) {
@@ -58,24 +64,50 @@ func (p *ProductTests) List(t *testing.T) {
 		{
 			"id":           "a2b0639f-2cc6-44b8-b97b-15d69dbb511e",
 			"name":         "Comic Books",
-			"cost":         float64(50),
+			"cost":         map[string]interface{}{"amount": float64(50), "currency": "USD"},
 			"quantity":     float64(42),
 			"sold":         float64(7),
-			"revenue":      float64(350),
+			"revenue":      map[string]interface{}{"amount": float64(350), "currency": "USD"},
+			"category_id":  "6e2c4d1a-9b3f-4a8e-b7c5-1d2e3f4a5b20",
+			"tags":         []interface{}{"collectible", "paper"},
 			"user_id":      "00000000-0000-0000-0000-000000000000",
 			"date_created": "2019-01-01T00:00:01.000001Z",
 			"date_updated": "2019-01-01T00:00:01.000001Z",
+			"stock": []interface{}{
+				map[string]interface{}{
+					"warehouse_id": "3f1b6a52-0c7e-4f43-a1a8-1f0c8e3b9d11",
+					"product_id":   "a2b0639f-2cc6-44b8-b97b-15d69dbb511e",
+					"variant_id":   "a2b0639f-2cc6-44b8-b97b-15d69dbb511e",
+					"quantity":     float64(12),
+				},
+				map[string]interface{}{
+					"warehouse_id": "d3a3e3c4-7c4e-4b8a-9f0e-6d1a0f1f2b01",
+					"product_id":   "a2b0639f-2cc6-44b8-b97b-15d69dbb511e",
+					"variant_id":   "a2b0639f-2cc6-44b8-b97b-15d69dbb511e",
+					"quantity":     float64(30),
+				},
+			},
 		},
 		{
 			"id":           "72f8b983-3eb4-48db-9ed0-e45cc6bd716b",
 			"name":         "McDonalds Toys",
-			"cost":         float64(75),
+			"cost":         map[string]interface{}{"amount": float64(75), "currency": "USD"},
 			"quantity":     float64(120),
 			"sold":         float64(3),
-			"revenue":      float64(225),
+			"revenue":      map[string]interface{}{"amount": float64(225), "currency": "USD"},
+			"category_id":  "9a7b6c5d-4e3f-4a1b-8c9d-0e1f2a3b4c30",
+			"tags":         []interface{}{"collectible"},
 			"user_id":      "00000000-0000-0000-0000-000000000000",
 			"date_created": "2019-01-01T00:00:02.000001Z",
 			"date_updated": "2019-01-01T00:00:02.000001Z",
+			"stock": []interface{}{
+				map[string]interface{}{
+					"warehouse_id": "d3a3e3c4-7c4e-4b8a-9f0e-6d1a0f1f2b01",
+					"product_id":   "72f8b983-3eb4-48db-9ed0-e45cc6bd716b",
+					"variant_id":   "72f8b983-3eb4-48db-9ed0-e45cc6bd716b",
+					"quantity":     float64(120),
+				},
+			},
 		},
 	}
 
@@ -84,6 +116,38 @@ func (p *ProductTests) List(t *testing.T) {
 	}
 }
 
+func (p *ProductTests) ListByCategory(t *testing.T) {
+	tests := []struct {
+		url  string
+		want int
+	}{
+		{"/v1/categories/0b8f5a3e-6a57-4b7e-9d0b-2c6a1e4f7a10/products", 2},
+		{"/v1/categories/9a7b6c5d-4e3f-4a1b-8c9d-0e1f2a3b4c30/products", 1},
+		{"/v1/products?tag=paper", 1},
+		{"/v1/products?category=0b8f5a3e-6a57-4b7e-9d0b-2c6a1e4f7a10&tag=collectible", 2},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.url, nil)
+		resp := httptest.NewRecorder()
+
+		p.app.ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusOK {
+			t.Fatalf("%s: expected status code %v, got %v", tt.url, http.StatusOK, resp.Code)
+		}
+
+		var list []map[string]interface{}
+		if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+			t.Fatalf("%s: decoding: %s", tt.url, err)
+		}
+
+		if exp, got := tt.want, len(list); exp != got {
+			t.Fatalf("%s: expected product list size %v, got %v", tt.url, exp, got)
+		}
+	}
+}
+
 func (p *ProductTests) ProductCRUD(t *testing.T) {
 	var created map[string]interface{}
 
@@ -123,11 +187,21 @@ func (p *ProductTests) ProductCRUD(t *testing.T) {
 			"date_created": created["date_created"],
 			"date_updated": created["date_updated"],
 			"name":         "product0",
-			"cost":         float64(55),
+			"cost":         map[string]interface{}{"amount": float64(55), "currency": "USD"},
 			"quantity":     float64(6),
 			"sold":         float64(0),
-			"revenue":      float64(0),
+			"revenue":      map[string]interface{}{"amount": float64(0), "currency": "USD"},
+			"category_id":  nil,
+			"tags":         []interface{}{},
 			"user_id":      tests.AdminID,
+			"stock": []interface{}{
+				map[string]interface{}{
+					"warehouse_id": warehouse.DefaultID,
+					"product_id":   created["id"],
+					"variant_id":   created["id"],
+					"quantity":     float64(6),
+				},
+			},
 		}
 
 		if diff := cmp.Diff(want, created); diff != "" {
@@ -193,13 +267,23 @@ func (p *ProductTests) ProductCRUD(t *testing.T) {
 		want := map[string]interface{}{
 			"id":           created["id"],
 			"name":         "new name",
-			"cost":         float64(20),
+			"cost":         map[string]interface{}{"amount": float64(20), "currency": "USD"},
 			"quantity":     float64(10),
 			"sold":         float64(0),
-			"revenue":      float64(0),
+			"revenue":      map[string]interface{}{"amount": float64(0), "currency": "USD"},
+			"category_id":  nil,
+			"tags":         []interface{}{},
 			"user_id":      tests.AdminID,
 			"date_created": created["date_created"],
 			"date_updated": updated["date_updated"],
+			"stock": []interface{}{
+				map[string]interface{}{
+					"warehouse_id": warehouse.DefaultID,
+					"product_id":   created["id"],
+					"variant_id":   created["id"],
+					"quantity":     float64(10),
+				},
+			},
 		}
 
 		if diff := cmp.Diff(want, updated); diff != "" {
@@ -234,8 +318,45 @@ func (p *ProductTests) ProductCRUD(t *testing.T) {
 	}
 }
 
+// UpdateDenied checks a user editing a product they do not own is refused
+// with the policy explained.
+func (p *ProductTests) UpdateDenied(t *testing.T) {
+	body := strings.NewReader(`{"name": "Not mine"}`)
+	req := httptest.NewRequest("PUT", "/v1/products/a2b0639f-2cc6-44b8-b97b-15d69dbb511e", body)
+	req.Header.Set("Authorization", "Bearer "+p.userToken)
+	resp := httptest.NewRecorder()
+
+	p.app.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusForbidden {
+		t.Fatalf("updating: expected status code %v, got %v", http.StatusForbidden, resp.Code)
+	}
+
+	var got struct {
+		Error   string `json:"error"`
+		Details struct {
+			Action   string   `json:"action"`
+			Resource string   `json:"resource"`
+			ID       string   `json:"id"`
+			Allowed  bool     `json:"allowed"`
+			Requires []string `json:"requires"`
+		} `json:"details"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("decoding: %s", err)
+	}
+
+	want := []string{"holds products:admin", "owns it and holds products:write"}
+	if diff := cmp.Diff(want, got.Details.Requires); diff != "" {
+		t.Fatalf("requirements differ:\n%s", diff)
+	}
+	if got.Details.Action != "edit" || got.Details.Resource != "product" || got.Details.Allowed {
+		t.Fatalf("unexpected decision %+v", got.Details)
+	}
+}
+
 func (p *ProductTests) AddSale(t *testing.T) {
-	body := strings.NewReader(`{"quantity":3,"paid":5}`)
+	body := strings.NewReader(`{"quantity":3}`)
 	productID := "a2b0639f-2cc6-44b8-b97b-15d69dbb511e"
 
 	req := httptest.NewRequest("POST", "/v1/products/"+productID+"/sales", body)
@@ -256,11 +377,25 @@ func (p *ProductTests) AddSale(t *testing.T) {
 	}
 
 	want := map[string]interface{}{
-		"id":           created["id"],
-		"product_id":   created["product_id"],
-		"quantity":     float64(3),
-		"paid":         float64(5),
-		"date_created": created["date_created"],
+		"id":              created["id"],
+		"product_id":      created["product_id"],
+		"variant_id":      created["product_id"],
+		"warehouse_id":    warehouse.DefaultID,
+		"quantity":        float64(3),
+		"list_price":      map[string]interface{}{"amount": float64(150), "currency": "USD"},
+		"net":             map[string]interface{}{"amount": float64(150), "currency": "USD"},
+		"tax":             map[string]interface{}{"amount": float64(0), "currency": "USD"},
+		"paid":            map[string]interface{}{"amount": float64(150), "currency": "USD"},
+		"jurisdiction":    nil,
+		"tax_rate":        nil,
+		"tax_inclusive":   false,
+		"customer_name":   nil,
+		"customer_email":  nil,
+		"external_ref":    nil,
+		"promotions":      []interface{}{},
+		"override_reason": nil,
+		"overridden_by":   nil,
+		"date_created":    created["date_created"],
 	}
 
 	if diff := cmp.Diff(want, created); diff != "" {
@@ -288,18 +423,46 @@ func (p *ProductTests) SalesList(t *testing.T) {
 
 	want := []map[string]interface{}{
 		{
-			"id":           "98b6d4b8-f04b-4c79-8c2e-a0aef46854b7",
-			"product_id":   productID,
-			"quantity":     float64(2),
-			"paid":         float64(100),
-			"date_created": "2019-01-01T00:00:03.000001Z",
+			"id":              "98b6d4b8-f04b-4c79-8c2e-a0aef46854b7",
+			"product_id":      productID,
+			"variant_id":      productID,
+			"warehouse_id":    warehouse.DefaultID,
+			"quantity":        float64(2),
+			"list_price":      map[string]interface{}{"amount": float64(100), "currency": "USD"},
+			"net":             map[string]interface{}{"amount": float64(100), "currency": "USD"},
+			"tax":             map[string]interface{}{"amount": float64(0), "currency": "USD"},
+			"paid":            map[string]interface{}{"amount": float64(100), "currency": "USD"},
+			"jurisdiction":    nil,
+			"tax_rate":        nil,
+			"tax_inclusive":   false,
+			"customer_name":   nil,
+			"customer_email":  nil,
+			"external_ref":    nil,
+			"promotions":      []interface{}{},
+			"override_reason": nil,
+			"overridden_by":   nil,
+			"date_created":    "2019-01-01T00:00:03.000001Z",
 		},
 		{
-			"id":           "85f6fb09-eb05-4874-ae39-82d1a30fe0d7",
-			"product_id":   productID,
-			"quantity":     float64(5),
-			"paid":         float64(250),
-			"date_created": "2019-01-01T00:00:04.000001Z",
+			"id":              "85f6fb09-eb05-4874-ae39-82d1a30fe0d7",
+			"product_id":      productID,
+			"variant_id":      productID,
+			"warehouse_id":    warehouse.DefaultID,
+			"quantity":        float64(5),
+			"list_price":      map[string]interface{}{"amount": float64(250), "currency": "USD"},
+			"net":             map[string]interface{}{"amount": float64(250), "currency": "USD"},
+			"tax":             map[string]interface{}{"amount": float64(0), "currency": "USD"},
+			"paid":            map[string]interface{}{"amount": float64(250), "currency": "USD"},
+			"jurisdiction":    nil,
+			"tax_rate":        nil,
+			"tax_inclusive":   false,
+			"customer_name":   nil,
+			"customer_email":  nil,
+			"external_ref":    nil,
+			"promotions":      []interface{}{},
+			"override_reason": nil,
+			"overridden_by":   nil,
+			"date_created":    "2019-01-01T00:00:04.000001Z",
 		},
 	}
 
@@ -308,6 +471,47 @@ func (p *ProductTests) SalesList(t *testing.T) {
 	}
 }
 
+func (p *ProductTests) Receipt(t *testing.T) {
+	url := "/v1/sales/98b6d4b8-f04b-4c79-8c2e-a0aef46854b7/receipt"
+
+	for _, tt := range []struct {
+		format      string
+		contentType string
+		contains    string
+	}{
+		{"text", "text/plain; charset=utf-8", "Comic Books (COMIC-001) x 2"},
+		{"html", "text/html; charset=utf-8", "<h1>Invoice 00000001</h1>"},
+	} {
+		req := httptest.NewRequest("GET", url+"?format="+tt.format, nil)
+		resp := httptest.NewRecorder()
+
+		req.Header.Set("Authorization", "Bearer "+p.adminToken)
+
+		p.app.ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusOK {
+			t.Fatalf("receipt %s: expected status code %v, got %v", tt.format, http.StatusOK, resp.Code)
+		}
+		if exp, got := tt.contentType, resp.Header().Get("Content-Type"); exp != got {
+			t.Fatalf("receipt %s: expected content type %q, got %q", tt.format, exp, got)
+		}
+		if body := resp.Body.String(); !strings.Contains(body, tt.contains) {
+			t.Fatalf("receipt %s: expected body to contain %q, got:\n%s", tt.format, tt.contains, body)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/v1/sales/"+"5e0c3b7a-2f0d-4c57-8a2b-9f5e1d7c3a44"+"/receipt", nil)
+	resp := httptest.NewRecorder()
+
+	req.Header.Set("Authorization", "Bearer "+p.adminToken)
+
+	p.app.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusNotFound {
+		t.Fatalf("receipt of unknown sale: expected status code %v, got %v", http.StatusNotFound, resp.Code)
+	}
+}
+
 func (p *ProductTests) CreateRequiresFields(t *testing.T) {
 	body := strings.NewReader(`{}`)
 	req := httptest.NewRequest("POST", "/v1/products", body)
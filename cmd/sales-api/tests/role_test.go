@@ -0,0 +1,60 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"gitlab.fenbishuo.com/fenbishuo/service-training/cmd/sales-api/internal/handlers"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/tests"
+)
+
+func TestRoles(t *testing.T) {
+	test := tests.New(t)
+	defer test.Teardown()
+
+	shutdown := make(chan os.Signal, 1)
+	app := handlers.API(shutdown, test.DB, test.Log, test.Authenticator, test.Revocations, test.Receipts)
+	adminToken := test.Token("admin@example.com", "gophers")
+
+	call := func(method, url, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp := httptest.NewRecorder()
+		app.ServeHTTP(resp, req)
+		return resp
+	}
+
+	// USER starts without reports:read.
+	userToken := test.Token("user@example.com", "gophers")
+	if resp := call("GET", "/v1/reports/tax", userToken, ""); resp.Code != http.StatusForbidden {
+		t.Fatalf("reading report: expected status code %v, got %v", http.StatusForbidden, resp.Code)
+	}
+	if resp := call("GET", "/v1/roles", userToken, ""); resp.Code != http.StatusForbidden {
+		t.Fatalf("listing roles as user: expected status code %v, got %v", http.StatusForbidden, resp.Code)
+	}
+
+	resp := call("PUT", "/v1/roles/USER", adminToken, `{"permissions": ["products:write", "reports:read"]}`)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("setting role: expected status code %v, got %v: %s", http.StatusOK, resp.Code, resp.Body)
+	}
+
+	// Tokens carry the permissions they were issued with, so a new one
+	// sees the change.
+	userToken = test.Token("user@example.com", "gophers")
+	if resp := call("GET", "/v1/reports/tax", userToken, ""); resp.Code != http.StatusOK {
+		t.Fatalf("reading report: expected status code %v, got %v", http.StatusOK, resp.Code)
+	}
+
+	if resp := call("PUT", "/v1/roles/CLERK", adminToken, `{"permissions": ["sales:everything"]}`); resp.Code != http.StatusBadRequest {
+		t.Fatalf("setting unknown permission: expected status code %v, got %v", http.StatusBadRequest, resp.Code)
+	}
+	if resp := call("DELETE", "/v1/roles/USER", adminToken, ""); resp.Code != http.StatusConflict {
+		t.Fatalf("deleting held role: expected status code %v, got %v", http.StatusConflict, resp.Code)
+	}
+	if resp := call("GET", "/v1/roles/CLERK", adminToken, ""); resp.Code != http.StatusNotFound {
+		t.Fatalf("retrieving missing role: expected status code %v, got %v", http.StatusNotFound, resp.Code)
+	}
+}
@@ -0,0 +1,188 @@
+package tests
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes/empty"
+	"github.com/jmoiron/sqlx"
+	salesv1 "gitlab.fenbishuo.com/fenbishuo/service-training/api/sales/v1"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/cmd/sales-api/internal/rpc"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/mfa"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/tests"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestRPC(t *testing.T) {
+	test := tests.New(t)
+	defer test.Teardown()
+
+	lis := bufconn.Listen(1 << 20)
+	srv := rpc.Server(test.DB, test.Log, test.Authenticator, test.Revocations)
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	dial := func(ctx context.Context, _ string) (net.Conn, error) {
+		return lis.Dial()
+	}
+	conn, err := grpc.Dial("bufnet", grpc.WithContextDialer(dial), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	rt := RPCTests{
+		db:       test.DB,
+		products: salesv1.NewProductsClient(conn),
+		users:    salesv1.NewUsersClient(conn),
+	}
+
+	t.Run("RequireAuth", rt.RequireAuth)
+	t.Run("Token", rt.Token)
+	t.Run("ProductCRUD", rt.ProductCRUD)
+	t.Run("AddSaleRequiresAdmin", rt.AddSaleRequiresAdmin)
+	t.Run("ErrorCodes", rt.ErrorCodes)
+	t.Run("TokenMFA", rt.TokenMFA)
+}
+
+type RPCTests struct {
+	db       *sqlx.DB
+	products salesv1.ProductsClient
+	users    salesv1.UsersClient
+}
+
+func (rt *RPCTests) login(t *testing.T, email string) context.Context {
+	t.Helper()
+
+	tkn, err := rt.users.Token(context.Background(), &salesv1.TokenRequest{Email: email, Password: "gophers"})
+	if err != nil {
+		t.Fatalf("getting token: %v", err)
+	}
+
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+tkn.Token)
+}
+
+func (rt *RPCTests) RequireAuth(t *testing.T) {
+	_, err := rt.products.ListProducts(context.Background(), &salesv1.ListProductsRequest{})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("got %v, want Unauthenticated", err)
+	}
+}
+
+func (rt *RPCTests) Token(t *testing.T) {
+	_, err := rt.users.Token(context.Background(), &salesv1.TokenRequest{Email: "admin@example.com", Password: "GOPHERS"})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("bad password: got %v, want Unauthenticated", err)
+	}
+
+	me, err := rt.users.Me(rt.login(t, "admin@example.com"), &empty.Empty{})
+	if err != nil {
+		t.Fatalf("me: %v", err)
+	}
+	if me.Email != "admin@example.com" {
+		t.Fatalf("me: got %q, want admin@example.com", me.Email)
+	}
+}
+
+func (rt *RPCTests) ProductCRUD(t *testing.T) {
+	ctx := rt.login(t, "admin@example.com")
+
+	created, err := rt.products.CreateProduct(ctx, &salesv1.CreateProductRequest{
+		Name:     "Puzzles",
+		Cost:     &salesv1.Money{Amount: 500},
+		Quantity: 10,
+	})
+	if err != nil {
+		t.Fatalf("creating: %v", err)
+	}
+
+	got, err := rt.products.GetProduct(ctx, &salesv1.GetProductRequest{Id: created.Id})
+	if err != nil {
+		t.Fatalf("retrieving: %v", err)
+	}
+	if got.Name != "Puzzles" || got.Cost.Amount != 500 || got.Quantity != 10 {
+		t.Fatalf("retrieving: got %+v", got)
+	}
+
+	if _, err := rt.products.DeleteProduct(ctx, &salesv1.DeleteProductRequest{Id: created.Id}); err != nil {
+		t.Fatalf("deleting: %v", err)
+	}
+
+	_, err = rt.products.GetProduct(ctx, &salesv1.GetProductRequest{Id: created.Id})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("retrieving deleted: got %v, want NotFound", err)
+	}
+}
+
+func (rt *RPCTests) AddSaleRequiresAdmin(t *testing.T) {
+	req := salesv1.AddSaleRequest{
+		ProductId: "a2b0639f-2cc6-44b8-b97b-15d69dbb511e",
+		Quantity:  1,
+	}
+
+	_, err := rt.products.AddSale(rt.login(t, "user@example.com"), &req)
+	if status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("as user: got %v, want PermissionDenied", err)
+	}
+
+	sale, err := rt.products.AddSale(rt.login(t, "admin@example.com"), &req)
+	if err != nil {
+		t.Fatalf("as admin: %v", err)
+	}
+	if sale.Quantity != 1 || sale.ProductId != req.ProductId {
+		t.Fatalf("as admin: got %+v", sale)
+	}
+}
+
+func (rt *RPCTests) ErrorCodes(t *testing.T) {
+	ctx := rt.login(t, "admin@example.com")
+
+	_, err := rt.products.GetProduct(ctx, &salesv1.GetProductRequest{Id: "not-a-uuid"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("bad id: got %v, want InvalidArgument", err)
+	}
+
+	_, err = rt.products.CreateProduct(ctx, &salesv1.CreateProductRequest{Quantity: 1})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("missing name: got %v, want InvalidArgument", err)
+	}
+
+	_, err = rt.products.AddSale(ctx, &salesv1.AddSaleRequest{
+		ProductId: "a2b0639f-2cc6-44b8-b97b-15d69dbb511e",
+		Quantity:  1000000,
+	})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("too many: got %v, want FailedPrecondition", err)
+	}
+}
+
+// TokenMFA checks users enrolled in MFA are told to log in over HTTP, as
+// there is no call to answer the challenge with.
+func (rt *RPCTests) TokenMFA(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	e, err := mfa.Enrol(ctx, rt.db, tests.UserID, "user@example.com", now)
+	if err != nil {
+		t.Fatalf("enrolling: %s", err)
+	}
+	code, err := mfa.Code(e.Secret, now)
+	if err != nil {
+		t.Fatalf("generating code: %s", err)
+	}
+	if _, err := mfa.Confirm(ctx, rt.db, tests.UserID, code, now); err != nil {
+		t.Fatalf("confirming: %s", err)
+	}
+
+	_, err = rt.users.Token(ctx, &salesv1.TokenRequest{Email: "user@example.com", Password: "gophers"})
+	if status.Code(err) != codes.FailedPrecondition || !strings.Contains(status.Convert(err).Message(), "HTTP") {
+		t.Fatalf("got %v, want FailedPrecondition pointing at HTTP", err)
+	}
+}
@@ -0,0 +1,155 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"gitlab.fenbishuo.com/fenbishuo/service-training/cmd/sales-api/internal/handlers"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/product"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/role"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/tenant"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/tests"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/user"
+)
+
+func TestTenantIsolation(t *testing.T) {
+	test := tests.New(t)
+	defer test.Teardown()
+
+	ctx := context.Background()
+	now := time.Now()
+
+	other, err := tenant.Create(ctx, test.DB, tenant.NewTenant{Name: "Corner Shop"}, now)
+	if err != nil {
+		t.Fatalf("creating tenant: %s", err)
+	}
+
+	// New tenants' admins must enrol in MFA, which is not what is tested.
+	noMFA := false
+	ur := role.UpdateRole{Permissions: auth.Permissions, RequireMFA: &noMFA}
+	if _, err := role.Set(ctx, test.DB, other.ID, auth.RoleAdmin, ur, now); err != nil {
+		t.Fatalf("setting admin role: %s", err)
+	}
+
+	claims := auth.NewClaims("", []string{auth.RoleAdmin}, now, time.Hour)
+	claims.Permissions = auth.Permissions
+	claims.Tenant = other.ID
+	nu := user.NewUser{
+		Name:            "Corner Admin",
+		Email:           "admin@corner.example.com",
+		Roles:           []string{auth.RoleAdmin, auth.RoleUser},
+		Password:        "gophers",
+		PasswordConfirm: "gophers",
+	}
+	if _, err := user.Create(ctx, test.DB, claims, nu, now); err != nil {
+		t.Fatalf("creating user: %s", err)
+	}
+
+	shutdown := make(chan os.Signal, 1)
+	app := handlers.API(shutdown, test.DB, test.Log, test.Authenticator, test.Revocations, test.Receipts)
+	adminToken := test.Token("admin@example.com", "gophers")
+	otherToken := test.Token("admin@corner.example.com", "gophers")
+
+	call := func(method, url, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp := httptest.NewRecorder()
+		app.ServeHTTP(resp, req)
+		return resp
+	}
+
+	// The seeded product and sale belong to the default tenant.
+	if resp := call("GET", "/v1/products/a2b0639f-2cc6-44b8-b97b-15d69dbb511e", otherToken, ""); resp.Code != http.StatusNotFound {
+		t.Fatalf("retrieving product: expected status code %v, got %v", http.StatusNotFound, resp.Code)
+	}
+	if resp := call("GET", "/v1/sales/98b6d4b8-f04b-4c79-8c2e-a0aef46854b7/receipt", otherToken, ""); resp.Code != http.StatusNotFound {
+		t.Fatalf("retrieving receipt: expected status code %v, got %v", http.StatusNotFound, resp.Code)
+	}
+
+	resp := call("POST", "/v1/products", otherToken, `{"name": "Marbles", "cost": {"amount": 10, "currency": "USD"}, "quantity": 5}`)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("creating product: expected status code %v, got %v: %s", http.StatusOK, resp.Code, resp.Body)
+	}
+	var marbles product.Product
+	if err := json.NewDecoder(resp.Body).Decode(&marbles); err != nil {
+		t.Fatalf("decoding: %s", err)
+	}
+
+	if resp := call("GET", "/v1/products/"+marbles.ID, adminToken, ""); resp.Code != http.StatusNotFound {
+		t.Fatalf("retrieving other tenant's product: expected status code %v, got %v", http.StatusNotFound, resp.Code)
+	}
+	if resp := call("POST", "/v1/products/"+marbles.ID+"/sales", adminToken, `{"quantity": 1}`); resp.Code != http.StatusNotFound {
+		t.Fatalf("selling other tenant's product: expected status code %v, got %v", http.StatusNotFound, resp.Code)
+	}
+
+	// The public catalogue is the default tenant's unless another is named.
+	browse := func(tenantID string) []product.Product {
+		req := httptest.NewRequest("GET", "/v1/products", nil)
+		if tenantID != "" {
+			req.Header.Set("X-Tenant-ID", tenantID)
+		}
+		resp := httptest.NewRecorder()
+		app.ServeHTTP(resp, req)
+		if resp.Code != http.StatusOK {
+			t.Fatalf("listing products: expected status code %v, got %v", http.StatusOK, resp.Code)
+		}
+
+		var list []product.Product
+		if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+			t.Fatalf("decoding: %s", err)
+		}
+		return list
+	}
+
+	if list := browse(""); len(list) != 2 {
+		t.Fatalf("default catalogue has %d products, want 2", len(list))
+	}
+	if list := browse(other.ID); len(list) != 1 || list[0].ID != marbles.ID {
+		t.Fatalf("other catalogue has %v, want only Marbles", list)
+	}
+
+	// Anonymous callers see no stock or sales figures.
+	req := httptest.NewRequest("GET", "/v1/products", nil)
+	anon := httptest.NewRecorder()
+	app.ServeHTTP(anon, req)
+	var listings []map[string]interface{}
+	if err := json.NewDecoder(anon.Body).Decode(&listings); err != nil {
+		t.Fatalf("decoding: %s", err)
+	}
+	for _, l := range listings {
+		for _, field := range []string{"quantity", "sold", "revenue", "stock"} {
+			if _, ok := l[field]; ok {
+				t.Fatalf("anonymous listing includes %q: %v", field, l)
+			}
+		}
+	}
+
+	// A signed in caller reads their own tenant whatever the header names.
+	req = httptest.NewRequest("GET", "/v1/products", nil)
+	req.Header.Set("Authorization", "Bearer "+otherToken)
+	req.Header.Set("X-Tenant-ID", tenant.DefaultID)
+	own := httptest.NewRecorder()
+	app.ServeHTTP(own, req)
+	var mine []product.Product
+	if err := json.NewDecoder(own.Body).Decode(&mine); err != nil {
+		t.Fatalf("decoding: %s", err)
+	}
+	if len(mine) != 1 || mine[0].ID != marbles.ID {
+		t.Fatalf("signed in catalogue has %v, want only Marbles", mine)
+	}
+
+	req = httptest.NewRequest("GET", "/v1/products", nil)
+	req.Header.Set("X-Tenant-ID", "corner")
+	bad := httptest.NewRecorder()
+	app.ServeHTTP(bad, req)
+	if bad.Code != http.StatusBadRequest {
+		t.Fatalf("listing with malformed tenant: expected status code %v, got %v", http.StatusBadRequest, bad.Code)
+	}
+}
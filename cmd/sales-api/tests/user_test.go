@@ -1,13 +1,21 @@
 package tests
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"gitlab.fenbishuo.com/fenbishuo/service-training/cmd/sales-api/internal/handlers"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/account"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/login"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/outbox"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/mail"
 	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/tests"
 )
 
@@ -17,16 +25,32 @@ func TestUsers(t *testing.T) {
 
 	shutdown := make(chan os.Signal, 1)
 
-	ut := UserTests{app: handlers.API(shutdown, test.DB, test.Log, test.Authenticator)}
+	ut := UserTests{
+		app:           handlers.API(shutdown, test.DB, test.Log, test.Authenticator, test.Revocations, test.Receipts),
+		adminToken:    test.Token("admin@example.com", "gophers"),
+		mailer:        test.Mailer,
+		authenticator: test.Authenticator,
+		outbox:        outbox.NewDispatcher(test.DB, test.Log, time.Second, 10, account.ResetSink(test.DB, test.Mailer)),
+	}
 
 	t.Run("TokenRequireAuth", ut.TokenRequireAuth)
 	t.Run("TokenDenyUnknown", ut.TokenDenyUnknown)
 	t.Run("TokenDenyBadPassword", ut.TokenDenyBadPassword)
 	t.Run("TokenSuccess", ut.TokenSuccess)
+	t.Run("JWKS", ut.JWKS)
+	t.Run("TokenRequireTenant", ut.TokenRequireTenant)
+	t.Run("RefreshAndLogout", ut.RefreshAndLogout)
+	t.Run("RefreshReuse", ut.RefreshReuse)
+	t.Run("TokenThrottle", ut.TokenThrottle)
+	t.Run("PasswordReset", ut.PasswordReset)
 }
 
 type UserTests struct {
-	app http.Handler
+	app           http.Handler
+	adminToken    string
+	mailer        *mail.Memory
+	authenticator *auth.Authenticator
+	outbox        *outbox.Dispatcher
 }
 
 func (ut *UserTests) TokenRequireAuth(t *testing.T) {
@@ -83,11 +107,255 @@ func (ut *UserTests) TokenSuccess(t *testing.T) {
 		t.Fatalf("decoding: %s", err)
 	}
 
-	if len(got) != 1 {
+	if len(got) != 2 {
 		t.Error("unexpected values in token response")
 	}
 
 	if got["token"] == "" {
 		t.Fatal("token was not in response")
 	}
+
+	if got["refresh_token"] == "" {
+		t.Fatal("refresh token was not in response")
+	}
+}
+
+func (ut *UserTests) JWKS(t *testing.T) {
+	req := httptest.NewRequest("GET", "/.well-known/jwks.json", nil)
+	resp := httptest.NewRecorder()
+
+	ut.app.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected status code %v, got %v", http.StatusOK, resp.Code)
+	}
+
+	var set auth.JWKS
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		t.Fatalf("decoding: %s", err)
+	}
+
+	if len(set.Keys) != 1 || set.Keys[0].Kty != "RSA" || set.Keys[0].Kid == "" {
+		t.Fatalf("expected the signing key, got %+v", set.Keys)
+	}
+}
+
+// login returns the access and refresh tokens of user@example.com.
+// TokenRequireTenant ensures tokens that name no tenant, like those issued
+// before there were tenants, are refused.
+func (ut *UserTests) TokenRequireTenant(t *testing.T) {
+	claims := auth.NewClaims(tests.AdminID, []string{auth.RoleAdmin, auth.RoleUser}, time.Now(), time.Hour)
+	claims.Permissions = auth.Permissions
+	tkn, err := ut.authenticator.GenerateToken(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "/v1/warehouses", nil)
+	req.Header.Set("Authorization", "Bearer "+tkn)
+	resp := httptest.NewRecorder()
+
+	ut.app.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusUnauthorized {
+		t.Fatalf("getting: expected status code %v, got %v", http.StatusUnauthorized, resp.Code)
+	}
+}
+
+func (ut *UserTests) login(t *testing.T) (string, string) {
+	t.Helper()
+
+	req := httptest.NewRequest("GET", "/v1/users/token", nil)
+	resp := httptest.NewRecorder()
+
+	req.SetBasicAuth("user@example.com", "gophers")
+
+	ut.app.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusOK {
+		t.Fatalf("logging in: expected status code %v, got %v", http.StatusOK, resp.Code)
+	}
+
+	var got map[string]string
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("decoding: %s", err)
+	}
+	return got["token"], got["refresh_token"]
+}
+
+func (ut *UserTests) refresh(refreshToken string) *httptest.ResponseRecorder {
+	body := strings.NewReader(`{"refresh_token": "` + refreshToken + `"}`)
+	req := httptest.NewRequest("POST", "/v1/users/token/refresh", body)
+	resp := httptest.NewRecorder()
+
+	ut.app.ServeHTTP(resp, req)
+
+	return resp
+}
+
+func (ut *UserTests) changes(token string) int {
+	req := httptest.NewRequest("GET", "/v1/changes", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp := httptest.NewRecorder()
+
+	ut.app.ServeHTTP(resp, req)
+
+	return resp.Code
+}
+
+func (ut *UserTests) RefreshAndLogout(t *testing.T) {
+	_, refreshToken := ut.login(t)
+
+	resp := ut.refresh(refreshToken)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("refreshing: expected status code %v, got %v: %s", http.StatusOK, resp.Code, resp.Body)
+	}
+
+	var got map[string]string
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("decoding: %s", err)
+	}
+	if got["token"] == "" || got["refresh_token"] == "" || got["refresh_token"] == refreshToken {
+		t.Fatalf("expected new tokens, got %v", got)
+	}
+
+	if code := ut.changes(got["token"]); code != http.StatusOK {
+		t.Fatalf("using refreshed token: expected status code %v, got %v", http.StatusOK, code)
+	}
+
+	req := httptest.NewRequest("POST", "/v1/users/logout", nil)
+	req.Header.Set("Authorization", "Bearer "+got["token"])
+	logout := httptest.NewRecorder()
+
+	ut.app.ServeHTTP(logout, req)
+
+	if logout.Code != http.StatusNoContent {
+		t.Fatalf("logging out: expected status code %v, got %v", http.StatusNoContent, logout.Code)
+	}
+
+	if code := ut.changes(got["token"]); code != http.StatusUnauthorized {
+		t.Fatalf("using revoked token: expected status code %v, got %v", http.StatusUnauthorized, code)
+	}
+
+	if resp := ut.refresh(got["refresh_token"]); resp.Code != http.StatusUnauthorized {
+		t.Fatalf("refreshing ended session: expected status code %v, got %v", http.StatusUnauthorized, resp.Code)
+	}
+}
+
+func (ut *UserTests) RefreshReuse(t *testing.T) {
+	access, refreshToken := ut.login(t)
+
+	if resp := ut.refresh(refreshToken); resp.Code != http.StatusOK {
+		t.Fatalf("refreshing: expected status code %v, got %v", http.StatusOK, resp.Code)
+	}
+
+	if resp := ut.refresh(refreshToken); resp.Code != http.StatusUnauthorized {
+		t.Fatalf("reusing refresh token: expected status code %v, got %v", http.StatusUnauthorized, resp.Code)
+	}
+
+	// Reuse ends the session, revoking the tokens it was issued.
+	if code := ut.changes(access); code != http.StatusUnauthorized {
+		t.Fatalf("using token of ended session: expected status code %v, got %v", http.StatusUnauthorized, code)
+	}
+}
+
+// TokenThrottle makes user@example.com wait after failing too often, until
+// an admin clears its failures.
+func (ut *UserTests) TokenThrottle(t *testing.T) {
+	token := func(password string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/v1/users/token", nil)
+		req.SetBasicAuth("user@example.com", password)
+		resp := httptest.NewRecorder()
+		ut.app.ServeHTTP(resp, req)
+		return resp
+	}
+
+	for i := 1; i <= login.Accounts.Free+1; i++ {
+		if resp := token("GOPHERS"); resp.Code != http.StatusUnauthorized {
+			t.Fatalf("attempt %d: expected status code %v, got %v", i, http.StatusUnauthorized, resp.Code)
+		}
+	}
+
+	resp := token("gophers")
+	if resp.Code != http.StatusTooManyRequests {
+		t.Fatalf("attempt during delay: expected status code %v, got %v", http.StatusTooManyRequests, resp.Code)
+	}
+	if resp.Header().Get("Retry-After") != "1" {
+		t.Fatalf("expected to retry after 1 second, got %q", resp.Header().Get("Retry-After"))
+	}
+
+	// An admin can clear the failures. Only lifting a lockout is audited.
+	req := httptest.NewRequest("DELETE", "/v1/users/"+tests.UserID+"/lockout", nil)
+	req.Header.Set("Authorization", "Bearer "+ut.adminToken)
+	unlock := httptest.NewRecorder()
+	ut.app.ServeHTTP(unlock, req)
+	if unlock.Code != http.StatusNoContent {
+		t.Fatalf("unlocking: expected status code %v, got %v", http.StatusNoContent, unlock.Code)
+	}
+	if resp := token("gophers"); resp.Code != http.StatusOK {
+		t.Fatalf("attempt after unlocking: expected status code %v, got %v", http.StatusOK, resp.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/v1/users/"+tests.UserID+"/login-events", nil)
+	req.Header.Set("Authorization", "Bearer "+ut.adminToken)
+	events := httptest.NewRecorder()
+	ut.app.ServeHTTP(events, req)
+	if events.Code != http.StatusOK {
+		t.Fatalf("listing events: expected status code %v, got %v", http.StatusOK, events.Code)
+	}
+	if body := strings.TrimSpace(events.Body.String()); body != "[]" {
+		t.Fatalf("expected no events without a lockout, got %s", body)
+	}
+}
+
+func (ut *UserTests) PasswordReset(t *testing.T) {
+	post := func(url, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", url, strings.NewReader(body))
+		resp := httptest.NewRecorder()
+		ut.app.ServeHTTP(resp, req)
+		return resp
+	}
+
+	// Unknown emails get the same response, and no mail.
+	if resp := post("/v1/users/password-reset", `{"email": "nobody@example.com"}`); resp.Code != http.StatusNoContent {
+		t.Fatalf("requesting for unknown email: expected status code %v, got %v", http.StatusNoContent, resp.Code)
+	}
+	if resp := post("/v1/users/password-reset", `{"email": "user@example.com"}`); resp.Code != http.StatusNoContent {
+		t.Fatalf("requesting: expected status code %v, got %v", http.StatusNoContent, resp.Code)
+	}
+
+	// The mail goes out from the outbox.
+	if _, err := ut.outbox.Dispatch(context.Background(), time.Now()); err != nil {
+		t.Fatalf("dispatching: %s", err)
+	}
+
+	sent := ut.mailer.Sent()
+	if len(sent) != 1 || sent[0].To != "user@example.com" {
+		t.Fatalf("expected one mail to the user, got %+v", sent)
+	}
+	var token string
+	for _, f := range strings.Fields(sent[0].Body) {
+		if len(f) == 43 {
+			token = f
+		}
+	}
+
+	if resp := post("/v1/users/password-reset/confirm", `{"token": "`+token+`", "password": "otters", "password_confirm": "gophers"}`); resp.Code != http.StatusBadRequest {
+		t.Fatalf("confirming mismatched passwords: expected status code %v, got %v", http.StatusBadRequest, resp.Code)
+	}
+	body := `{"token": "` + token + `", "password": "otters", "password_confirm": "otters"}`
+	if resp := post("/v1/users/password-reset/confirm", body); resp.Code != http.StatusNoContent {
+		t.Fatalf("confirming: expected status code %v, got %v: %s", http.StatusNoContent, resp.Code, resp.Body)
+	}
+	if resp := post("/v1/users/password-reset/confirm", body); resp.Code != http.StatusBadRequest {
+		t.Fatalf("reusing token: expected status code %v, got %v", http.StatusBadRequest, resp.Code)
+	}
+
+	req := httptest.NewRequest("GET", "/v1/users/token", nil)
+	req.SetBasicAuth("user@example.com", "otters")
+	resp := httptest.NewRecorder()
+	ut.app.ServeHTTP(resp, req)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("logging in with new password: expected status code %v, got %v", http.StatusOK, resp.Code)
+	}
 }
@@ -0,0 +1,245 @@
+package account
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/outbox"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/database"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/mail"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/session"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/user"
+	"go.opencensus.io/trace"
+)
+
+// Purposes tokens are mailed for.
+const (
+	PurposeReset  = "password_reset"
+	PurposeVerify = "email_verification"
+)
+
+var (
+	// ResetLifetime is how long a password reset token may be used for.
+	ResetLifetime = time.Hour
+
+	// ResetInterval is how soon after one reset token another may be
+	// mailed to the same user, so requests cannot flood their mailbox.
+	ResetInterval = time.Minute
+
+	// VerifyLifetime is how long an email verification token may be used
+	// for.
+	VerifyLifetime = 7 * 24 * time.Hour
+)
+
+// ErrInvalidToken is returned for tokens that are unknown, expired, used or
+// for another purpose.
+var ErrInvalidToken = errors.New("token is invalid or expired")
+
+// RequestReset arranges for a password reset token to be mailed to email if
+// a user has it. The mail is sent from the outbox by ResetSink, so requests
+// take as long and report the same whether or not the account exists.
+func RequestReset(ctx context.Context, db *sqlx.DB, email string, now time.Time) error {
+	ctx, span := trace.StartSpan(ctx, "internal.account.RequestReset")
+	defer span.End()
+
+	email = user.NormalizeEmail(email)
+
+	// The user's tenant is not known until they are found.
+	return database.WithBypass(ctx, db, func(tx *sqlx.Tx) error {
+		var u struct {
+			ID       string `db:"user_id"`
+			TenantID string `db:"tenant_id"`
+		}
+		const q = `SELECT user_id, tenant_id FROM users WHERE email = $1`
+		if err := tx.GetContext(ctx, &u, q, email); err != nil {
+			if err == sql.ErrNoRows {
+				return nil
+			}
+			return fmt.Errorf("selecting user: %w", err)
+		}
+
+		r := ResetRequest{Email: email}
+		return outbox.Record(ctx, tx, u.TenantID, outbox.AggregateUser, u.ID, outbox.PasswordResetRequested, r, now)
+	})
+}
+
+// SendReset mails the user userID a password reset token for email, unless
+// one was sent within ResetInterval.
+func SendReset(ctx context.Context, db *sqlx.DB, mailer mail.Mailer, userID, email string, now time.Time) error {
+	ctx, span := trace.StartSpan(ctx, "internal.account.SendReset")
+	defer span.End()
+
+	var recent bool
+	const r = `SELECT EXISTS (SELECT 1 FROM account_tokens
+		WHERE user_id = $1 AND purpose = $2 AND date_created > $3)`
+	if err := db.GetContext(ctx, &recent, r, userID, PurposeReset, now.Add(-ResetInterval).UTC()); err != nil {
+		return fmt.Errorf("checking recent reset tokens: %w", err)
+	}
+	if recent {
+		return nil
+	}
+
+	raw, err := issue(ctx, db, userID, PurposeReset, email, ResetLifetime, now)
+	if err != nil {
+		return err
+	}
+
+	m := mail.Message{
+		To:      email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Someone asked to reset the password of your account. "+
+			"If it was you, use this token to choose a new one:\n\n%s\n\n"+
+			"If it was not, you can ignore this email.\n", raw),
+	}
+	return mailer.Send(ctx, m)
+}
+
+// ResetPassword sets the password of the user a reset token was mailed to.
+// Their other reset tokens stop working and they are signed out everywhere.
+// As the token proves they hold their email, it is verified too.
+func ResetPassword(ctx context.Context, db *sqlx.DB, r Reset, now time.Time) error {
+	ctx, span := trace.StartSpan(ctx, "internal.account.ResetPassword")
+	defer span.End()
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("starting password reset: %w", err)
+	}
+	defer tx.Rollback()
+
+	// The token, not a tenant, says whose password this is.
+	if err := database.Bypass(ctx, tx); err != nil {
+		return err
+	}
+
+	t, err := redeem(ctx, tx, r.Token, PurposeReset, now)
+	if err != nil {
+		return err
+	}
+
+	const q = `UPDATE account_tokens SET date_used = $3
+		WHERE user_id = $1 AND purpose = $2 AND date_used IS NULL`
+	if _, err := tx.ExecContext(ctx, q, t.UserID, PurposeReset, now.UTC()); err != nil {
+		return fmt.Errorf("spending reset tokens: %w", err)
+	}
+
+	if err := user.SetPassword(ctx, tx, t.UserID, r.Password, now); err != nil {
+		return err
+	}
+	if err := user.VerifyEmail(ctx, tx, t.UserID, t.Email, now); err != nil {
+		return err
+	}
+	if err := session.EndAll(ctx, tx, t.UserID, now); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing password reset: %w", err)
+	}
+	return nil
+}
+
+// SendVerification mails the user userID a token to verify they hold email.
+func SendVerification(ctx context.Context, db *sqlx.DB, mailer mail.Mailer, userID, email string, now time.Time) error {
+	ctx, span := trace.StartSpan(ctx, "internal.account.SendVerification")
+	defer span.End()
+
+	raw, err := issue(ctx, db, userID, PurposeVerify, email, VerifyLifetime, now)
+	if err != nil {
+		return err
+	}
+
+	m := mail.Message{
+		To:      email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("An account was created with this email address. "+
+			"Use this token to verify it is yours:\n\n%s\n", raw),
+	}
+	return mailer.Send(ctx, m)
+}
+
+// Verify records that the user a verification token was mailed to holds the
+// address it was mailed to.
+func Verify(ctx context.Context, db *sqlx.DB, raw string, now time.Time) error {
+	ctx, span := trace.StartSpan(ctx, "internal.account.Verify")
+	defer span.End()
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("starting verification: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := database.Bypass(ctx, tx); err != nil {
+		return err
+	}
+
+	t, err := redeem(ctx, tx, raw, PurposeVerify, now)
+	if err != nil {
+		return err
+	}
+
+	if err := user.VerifyEmail(ctx, tx, t.UserID, t.Email, now); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing verification: %w", err)
+	}
+	return nil
+}
+
+// issue stores a new token for the user userID and returns it.
+func issue(ctx context.Context, db *sqlx.DB, userID, purpose, email string, lifetime time.Duration, now time.Time) (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("generating token: %w", err)
+	}
+	raw := base64.RawURLEncoding.EncodeToString(secret)
+
+	const q = `INSERT INTO account_tokens
+		(token_hash, user_id, purpose, email, date_created, date_expires)
+		VALUES ($1, $2, $3, $4, $5, $6)`
+	_, err := db.ExecContext(ctx, q, hash(raw), userID, purpose, email, now.UTC(), now.Add(lifetime).UTC())
+	if err != nil {
+		return "", fmt.Errorf("inserting %s token: %w", purpose, err)
+	}
+
+	return raw, nil
+}
+
+// redeem spends the token raw if it is good for purpose.
+func redeem(ctx context.Context, tx *sqlx.Tx, raw, purpose string, now time.Time) (token, error) {
+	var t token
+	const q = `SELECT * FROM account_tokens WHERE token_hash = $1 AND purpose = $2 FOR UPDATE`
+	if err := tx.GetContext(ctx, &t, q, hash(raw), purpose); err != nil {
+		if err == sql.ErrNoRows {
+			return token{}, ErrInvalidToken
+		}
+		return token{}, fmt.Errorf("selecting token: %w", err)
+	}
+	if t.DateUsed != nil || !now.Before(t.DateExpires) {
+		return token{}, ErrInvalidToken
+	}
+
+	const u = `UPDATE account_tokens SET date_used = $2 WHERE token_hash = $1`
+	if _, err := tx.ExecContext(ctx, u, t.Hash, now.UTC()); err != nil {
+		return token{}, fmt.Errorf("spending token: %w", err)
+	}
+
+	return t, nil
+}
+
+// hash is how tokens are stored. They are random and long enough that a
+// fast hash cannot be brute forced.
+func hash(raw string) []byte {
+	sum := sha256.Sum256([]byte(raw))
+	return sum[:]
+}
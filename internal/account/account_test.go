@@ -0,0 +1,182 @@
+package account_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/account"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/outbox"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/mail"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/schema"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/tests"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/user"
+)
+
+// token returns the token mailed in m.
+func token(t *testing.T, m mail.Message) string {
+	t.Helper()
+
+	for _, f := range strings.Fields(m.Body) {
+		if len(f) == 43 {
+			return f
+		}
+	}
+	t.Fatalf("no token in %q", m.Body)
+	return ""
+}
+
+func TestPasswordReset(t *testing.T) {
+	db, teardown := tests.NewUnit(t)
+	defer teardown()
+
+	if err := schema.Seed(db); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	now := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+	mailer := &mail.Memory{}
+
+	// Tokens are mailed from the outbox.
+	d := outbox.NewDispatcher(db, log.New(ioutil.Discard, "", 0), time.Second, 10, account.ResetSink(db, mailer))
+	request := func(email string, at time.Time) {
+		t.Helper()
+		if err := account.RequestReset(ctx, db, email, at); err != nil {
+			t.Fatalf("requesting reset for %q: %s", email, err)
+		}
+		if _, err := d.Dispatch(ctx, at); err != nil {
+			t.Fatalf("dispatching: %s", err)
+		}
+	}
+
+	request("nobody@example.com", now)
+	request(" User@Example.com", now)
+
+	// Asking again straight away mails nothing more.
+	request("user@example.com", now.Add(time.Second))
+	sent := mailer.Sent()
+	if len(sent) != 1 || sent[0].To != "user@example.com" {
+		t.Fatalf("got mail %+v, want one to the user", sent)
+	}
+	first := token(t, sent[0])
+
+	now = now.Add(account.ResetInterval)
+	request("user@example.com", now)
+	second := token(t, mailer.Sent()[1])
+
+	// Expired tokens are refused.
+	r := account.Reset{Token: first, Password: "otters", PasswordConfirm: "otters"}
+	if err := account.ResetPassword(ctx, db, r, now.Add(account.ResetLifetime)); err != account.ErrInvalidToken {
+		t.Fatalf("resetting with an expired token: got %v, want %v", err, account.ErrInvalidToken)
+	}
+
+	if err := account.ResetPassword(ctx, db, r, now); err != nil {
+		t.Fatalf("resetting: %s", err)
+	}
+	if _, err := user.Authenticate(ctx, db, now, "user@example.com", "otters"); err != nil {
+		t.Fatalf("logging in with the new password: %s", err)
+	}
+	if _, err := user.Authenticate(ctx, db, now, "user@example.com", "gophers"); err != user.ErrAuthenticationFailure {
+		t.Fatalf("logging in with the old password: got %v, want %v", err, user.ErrAuthenticationFailure)
+	}
+
+	// The token is spent, along with any others of the user's.
+	for _, tkn := range []string{first, second} {
+		r.Token = tkn
+		if err := account.ResetPassword(ctx, db, r, now); err != account.ErrInvalidToken {
+			t.Fatalf("resetting with a spent token: got %v, want %v", err, account.ErrInvalidToken)
+		}
+	}
+
+	// A reset proves the user holds their email.
+	u, err := user.Retrieve(ctx, db, tests.TenantID, tests.UserID)
+	if err != nil {
+		t.Fatalf("retrieving user: %s", err)
+	}
+	if u.DateEmailVerified == nil {
+		t.Fatal("email was not verified by the reset")
+	}
+}
+
+func TestVerification(t *testing.T) {
+	db, teardown := tests.NewUnit(t)
+	defer teardown()
+
+	if err := schema.Seed(db); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	now := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+	mailer := &mail.Memory{}
+
+	claims := auth.NewClaims(tests.AdminID, []string{auth.RoleAdmin}, now, time.Hour)
+	claims.Permissions = auth.Permissions
+	claims.Tenant = tests.TenantID
+	nu := user.NewUser{
+		Name:            "New Gopher",
+		Email:           "new@example.com",
+		Roles:           []string{auth.RoleUser},
+		Password:        "gophers",
+		PasswordConfirm: "gophers",
+	}
+	u, err := user.Create(ctx, db, claims, nu, now)
+	if err != nil {
+		t.Fatalf("creating user: %s", err)
+	}
+	if u.DateEmailVerified != nil {
+		t.Fatal("new user's email is already verified")
+	}
+
+	// The outbox hands the sink the event of the new user.
+	payload, err := json.Marshal(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := outbox.Event{
+		AggregateType: outbox.AggregateUser,
+		AggregateID:   u.ID,
+		Type:          outbox.UserCreated,
+		Payload:       payload,
+	}
+	if err := account.VerificationSink(db, mailer).Deliver(ctx, e); err != nil {
+		t.Fatalf("delivering event: %s", err)
+	}
+
+	sent := mailer.Sent()
+	if len(sent) != 1 || sent[0].To != "new@example.com" {
+		t.Fatalf("got mail %+v, want one to the new user", sent)
+	}
+	tkn := token(t, sent[0])
+
+	if err := account.Verify(ctx, db, "nope", now); err != account.ErrInvalidToken {
+		t.Fatalf("verifying with an unknown token: got %v, want %v", err, account.ErrInvalidToken)
+	}
+
+	// Verification tokens cannot reset passwords.
+	r := account.Reset{Token: tkn, Password: "otters", PasswordConfirm: "otters"}
+	if err := account.ResetPassword(ctx, db, r, now); err != account.ErrInvalidToken {
+		t.Fatalf("resetting with a verification token: got %v, want %v", err, account.ErrInvalidToken)
+	}
+
+	if err := account.Verify(ctx, db, tkn, now); err != nil {
+		t.Fatalf("verifying: %s", err)
+	}
+	if err := account.Verify(ctx, db, tkn, now); err != account.ErrInvalidToken {
+		t.Fatalf("verifying twice: got %v, want %v", err, account.ErrInvalidToken)
+	}
+
+	u, err = user.Retrieve(ctx, db, tests.TenantID, u.ID)
+	if err != nil {
+		t.Fatalf("retrieving user: %s", err)
+	}
+	if u.DateEmailVerified == nil || !u.DateEmailVerified.Equal(now) {
+		t.Fatalf("got email verified at %v, want %v", u.DateEmailVerified, now)
+	}
+}
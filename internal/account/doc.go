@@ -0,0 +1,5 @@
+// Package account lets users prove they hold their email address. New users
+// are mailed a token to verify it with, and users who forget their password
+// are mailed one to set a new password with. Tokens expire, may be used
+// once, and only their hashes are kept.
+package account
@@ -0,0 +1,26 @@
+package account
+
+import "time"
+
+// ResetRequest asks for a password reset token to be mailed to Email.
+type ResetRequest struct {
+	Email string `json:"email" validate:"required"`
+}
+
+// Reset sets a new password with a mailed reset token.
+type Reset struct {
+	Token           string `json:"token" validate:"required"`
+	Password        string `json:"password" validate:"required"`
+	PasswordConfirm string `json:"password_confirm" validate:"eqfield=Password"`
+}
+
+// token is a stored token, mailed to Email for Purpose.
+type token struct {
+	Hash        []byte     `db:"token_hash"`
+	UserID      string     `db:"user_id"`
+	Purpose     string     `db:"purpose"`
+	Email       string     `db:"email"`
+	DateCreated time.Time  `db:"date_created"`
+	DateExpires time.Time  `db:"date_expires"`
+	DateUsed    *time.Time `db:"date_used"`
+}
@@ -0,0 +1,49 @@
+package account
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/outbox"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/mail"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/user"
+)
+
+// VerificationSink returns an outbox.Sink that mails each new user a token
+// to verify their email with. It is sent once the user is committed, and
+// again should the outbox hand the event over twice.
+func VerificationSink(db *sqlx.DB, mailer mail.Mailer) outbox.Sink {
+	return outbox.SinkFunc(func(ctx context.Context, e outbox.Event) error {
+		if e.Type != outbox.UserCreated {
+			return nil
+		}
+
+		var u user.User
+		if err := json.Unmarshal(e.Payload, &u); err != nil {
+			return fmt.Errorf("decoding event %d: %w", e.Sequence, err)
+		}
+
+		return SendVerification(ctx, db, mailer, u.ID, u.Email, time.Now())
+	})
+}
+
+// ResetSink returns an outbox.Sink that mails the password reset tokens
+// users asked for with RequestReset. Tokens last from when they were asked
+// for, however long the outbox took to hand the request over.
+func ResetSink(db *sqlx.DB, mailer mail.Mailer) outbox.Sink {
+	return outbox.SinkFunc(func(ctx context.Context, e outbox.Event) error {
+		if e.Type != outbox.PasswordResetRequested {
+			return nil
+		}
+
+		var r ResetRequest
+		if err := json.Unmarshal(e.Payload, &r); err != nil {
+			return fmt.Errorf("decoding event %d: %w", e.Sequence, err)
+		}
+
+		return SendReset(ctx, db, mailer, e.AggregateID, r.Email, e.DateCreated)
+	})
+}
@@ -0,0 +1,263 @@
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/database"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/user"
+	"go.opencensus.io/trace"
+)
+
+// lastUsedPrecision is how stale DateLastUsed may get, so a busy key is not
+// written to on every request.
+const lastUsedPrecision = time.Minute
+
+var (
+	ErrNotFound  = errors.New("API key not found")
+	ErrInvalidID = errors.New("ID is not in its proper form")
+
+	// ErrInvalidKey is returned for keys that are malformed, unknown,
+	// expired or revoked.
+	ErrInvalidKey = errors.New("API key is invalid, expired or revoked")
+
+	// ErrOwnerNotFound is returned when issuing a key to an unknown user.
+	ErrOwnerNotFound = errors.New("API key owner not found")
+
+	// ErrRoleNotHeld is returned when a key is given a role its owner lacks.
+	ErrRoleNotHeld = errors.New("API key roles must be held by its owner")
+
+	// ErrExpired is returned when issuing a key that has already expired.
+	ErrExpired = errors.New("API key expiry must be in the future")
+)
+
+// List returns the keys of the users of tenantID.
+func List(ctx context.Context, db *sqlx.DB, tenantID string) ([]Key, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.apikey.List")
+	defer span.End()
+
+	keys := []Key{}
+	const q = `SELECT k.* FROM api_keys AS k
+		JOIN users AS u ON u.user_id = k.user_id
+		WHERE u.tenant_id = $1
+		ORDER BY k.date_created, k.key_id`
+	err := database.WithTenant(ctx, db, tenantID, func(tx *sqlx.Tx) error {
+		return tx.SelectContext(ctx, &keys, q, tenantID)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("selecting API keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+// Retrieve returns the key id of a user of tenantID.
+func Retrieve(ctx context.Context, db *sqlx.DB, tenantID, id string) (*Key, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.apikey.Retrieve")
+	defer span.End()
+
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrInvalidID
+	}
+
+	var k Key
+	const q = `SELECT k.* FROM api_keys AS k
+		JOIN users AS u ON u.user_id = k.user_id
+		WHERE k.key_id = $1 AND u.tenant_id = $2`
+	err := database.WithTenant(ctx, db, tenantID, func(tx *sqlx.Tx) error {
+		return tx.GetContext(ctx, &k, q, id, tenantID)
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("selecting API key %q: %w", id, err)
+	}
+
+	return &k, nil
+}
+
+// Create issues a key to a user of tenantID. The key is made of a random
+// prefix, which is stored to find it by, and a random secret, of which only a
+// hash is stored.
+func Create(ctx context.Context, db *sqlx.DB, tenantID string, nk NewKey, now time.Time) (*CreatedKey, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.apikey.Create")
+	defer span.End()
+
+	if nk.DateExpires != nil && !nk.DateExpires.After(now) {
+		return nil, ErrExpired
+	}
+
+	var held pq.StringArray
+	const r = `SELECT roles FROM users WHERE user_id = $1 AND tenant_id = $2`
+	err := database.WithTenant(ctx, db, tenantID, func(tx *sqlx.Tx) error {
+		return tx.GetContext(ctx, &held, r, nk.UserID, tenantID)
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrOwnerNotFound
+		}
+		return nil, fmt.Errorf("selecting API key owner: %w", err)
+	}
+	for _, role := range nk.Roles {
+		if !contains(held, role) {
+			return nil, ErrRoleNotHeld
+		}
+	}
+
+	prefix := make([]byte, 6)
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, fmt.Errorf("generating API key: %w", err)
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("generating API key: %w", err)
+	}
+
+	k := Key{
+		ID:          uuid.New().String(),
+		Prefix:      hex.EncodeToString(prefix),
+		Name:        nk.Name,
+		UserID:      nk.UserID,
+		Roles:       nk.Roles,
+		DateCreated: now.UTC(),
+	}
+	raw := base64.RawURLEncoding.EncodeToString(secret)
+	k.SecretHash = hash(raw)
+	if nk.DateExpires != nil {
+		expires := nk.DateExpires.UTC()
+		k.DateExpires = &expires
+	}
+
+	const q = `INSERT INTO api_keys
+		(key_id, prefix, secret_hash, name, user_id, roles, date_created, date_expires)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	_, err = db.ExecContext(ctx, q,
+		k.ID, k.Prefix, k.SecretHash, k.Name, k.UserID, k.Roles, k.DateCreated, k.DateExpires)
+	if err != nil {
+		return nil, fmt.Errorf("inserting API key: %w", err)
+	}
+
+	return &CreatedKey{Key: k, Secret: k.Prefix + "." + raw}, nil
+}
+
+// Revoke stops the key id of a user of tenantID from working. The key is kept
+// so its use can still be audited.
+func Revoke(ctx context.Context, db *sqlx.DB, tenantID, id string, now time.Time) error {
+	ctx, span := trace.StartSpan(ctx, "internal.apikey.Revoke")
+	defer span.End()
+
+	if _, err := uuid.Parse(id); err != nil {
+		return ErrInvalidID
+	}
+
+	const q = `UPDATE api_keys AS k SET date_revoked = COALESCE(k.date_revoked, $2)
+		FROM users AS u
+		WHERE k.key_id = $1 AND u.user_id = k.user_id AND u.tenant_id = $3`
+	return database.WithTenant(ctx, db, tenantID, func(tx *sqlx.Tx) error {
+		res, err := tx.ExecContext(ctx, q, id, now.UTC(), tenantID)
+		if err != nil {
+			return fmt.Errorf("revoking API key %q: %w", id, err)
+		}
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
+}
+
+// Authenticate returns the claims of the key raw, which are those of its
+// owner limited to the roles of the key the owner still holds. The claims
+// carry the key's ID as their token ID and the owner's tenant.
+func Authenticate(ctx context.Context, db *sqlx.DB, raw string, now time.Time) (auth.Claims, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.apikey.Authenticate")
+	defer span.End()
+
+	parts := strings.SplitN(raw, ".", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return auth.Claims{}, ErrInvalidKey
+	}
+
+	var k struct {
+		Key
+		Held   pq.StringArray `db:"held"`
+		Tenant string         `db:"tenant"`
+	}
+	const q = `SELECT k.*, u.roles AS held, u.tenant_id AS tenant
+		FROM api_keys AS k
+		JOIN users AS u ON u.user_id = k.user_id
+		WHERE k.prefix = $1`
+	err := database.WithBypass(ctx, db, func(tx *sqlx.Tx) error {
+		return tx.GetContext(ctx, &k, q, parts[0])
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return auth.Claims{}, ErrInvalidKey
+		}
+		return auth.Claims{}, fmt.Errorf("selecting API key: %w", err)
+	}
+
+	if subtle.ConstantTimeCompare(k.SecretHash, hash(parts[1])) != 1 {
+		return auth.Claims{}, ErrInvalidKey
+	}
+	if k.DateRevoked != nil || (k.DateExpires != nil && !now.Before(*k.DateExpires)) {
+		return auth.Claims{}, ErrInvalidKey
+	}
+
+	const u = `UPDATE api_keys SET date_last_used = $2
+		WHERE key_id = $1 AND (date_last_used IS NULL OR date_last_used <= $3)`
+	if _, err := db.ExecContext(ctx, u, k.ID, now.UTC(), now.Add(-lastUsedPrecision).UTC()); err != nil {
+		return auth.Claims{}, fmt.Errorf("recording API key use: %w", err)
+	}
+
+	roles := []string{}
+	for _, role := range k.Roles {
+		if contains(k.Held, role) {
+			roles = append(roles, role)
+		}
+	}
+
+	perms, err := user.Permissions(ctx, db, k.Tenant, k.UserID, roles)
+	if err != nil {
+		return auth.Claims{}, err
+	}
+
+	claims := auth.NewClaims(k.UserID, roles, now, time.Hour)
+	claims.Id = k.ID
+	claims.Permissions = perms
+	claims.Tenant = k.Tenant
+	if k.DateExpires != nil && k.DateExpires.Unix() < claims.ExpiresAt {
+		claims.ExpiresAt = k.DateExpires.Unix()
+	}
+
+	return claims, nil
+}
+
+// hash is how key secrets are stored. They are random and long enough that
+// a fast hash cannot be brute forced.
+func hash(secret string) []byte {
+	sum := sha256.Sum256([]byte(secret))
+	return sum[:]
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package apikey_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/apikey"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/mfa"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/schema"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/tests"
+)
+
+func TestAPIKeys(t *testing.T) {
+	db, teardown := tests.NewUnit(t)
+	defer teardown()
+
+	if err := schema.Seed(db); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	now := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+	expires := now.Add(24 * time.Hour)
+
+	nk := apikey.NewKey{
+		Name:        "nightly import",
+		UserID:      tests.AdminID,
+		Roles:       []string{auth.RoleAdmin},
+		DateExpires: &expires,
+	}
+	created, err := apikey.Create(ctx, db, tests.TenantID, nk, now)
+	if err != nil {
+		t.Fatalf("creating key: %s", err)
+	}
+
+	claims, err := apikey.Authenticate(ctx, db, created.Secret, now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("authenticating: %s", err)
+	}
+	if claims.Subject != tests.AdminID || !cmp.Equal(claims.Roles, []string{auth.RoleAdmin}) {
+		t.Fatalf("got claims %+v", claims)
+	}
+
+	// The admin role requires MFA, so until the admin enrols their key grants
+	// no more than their tokens would.
+	if len(claims.Permissions) != 0 {
+		t.Fatalf("got permissions %v before enrolling, want none", claims.Permissions)
+	}
+	e, err := mfa.Enrol(ctx, db, tests.AdminID, "admin@example.com", now)
+	if err != nil {
+		t.Fatalf("enrolling: %s", err)
+	}
+	code, err := mfa.Code(e.Secret, now)
+	if err != nil {
+		t.Fatalf("generating code: %s", err)
+	}
+	if _, err := mfa.Confirm(ctx, db, tests.AdminID, code, now); err != nil {
+		t.Fatalf("confirming: %s", err)
+	}
+	claims, err = apikey.Authenticate(ctx, db, created.Secret, now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("authenticating: %s", err)
+	}
+	if !claims.Can(auth.PermUsersAdmin) {
+		t.Fatalf("got permissions %v after enrolling, want the admin's", claims.Permissions)
+	}
+
+	saved, err := apikey.Retrieve(ctx, db, tests.TenantID, created.ID)
+	if err != nil {
+		t.Fatalf("retrieving key: %s", err)
+	}
+	if saved.DateLastUsed == nil || !saved.DateLastUsed.Equal(now.Add(time.Hour)) {
+		t.Fatalf("last used not recorded: %v", saved.DateLastUsed)
+	}
+
+	// Other tenants neither see the key nor can revoke it, and cannot issue
+	// keys to this tenant's users.
+	const other = "7f0c2b1e-9d3a-4c5b-8e6f-1a2b3c4d5e6f"
+	if _, err := apikey.Retrieve(ctx, db, other, created.ID); err != apikey.ErrNotFound {
+		t.Fatalf("retrieving from another tenant: got %v, want %v", err, apikey.ErrNotFound)
+	}
+	if list, err := apikey.List(ctx, db, other); err != nil || len(list) != 0 {
+		t.Fatalf("listing another tenant's keys: got %v, %v, want none", list, err)
+	}
+	if err := apikey.Revoke(ctx, db, other, created.ID, now); err != apikey.ErrNotFound {
+		t.Fatalf("revoking from another tenant: got %v, want %v", err, apikey.ErrNotFound)
+	}
+	if _, err := apikey.Create(ctx, db, other, nk, now); err != apikey.ErrOwnerNotFound {
+		t.Fatalf("issuing to another tenant's user: got %v, want %v", err, apikey.ErrOwnerNotFound)
+	}
+
+	for name, key := range map[string]string{
+		"Malformed":   "nodot",
+		"WrongSecret": created.Prefix + ".wrong",
+		"Unknown":     "000000000000." + created.Secret[len(created.Prefix)+1:],
+	} {
+		if _, err := apikey.Authenticate(ctx, db, key, now); err != apikey.ErrInvalidKey {
+			t.Errorf("%s: got %v, want %v", name, err, apikey.ErrInvalidKey)
+		}
+	}
+
+	if _, err := apikey.Authenticate(ctx, db, created.Secret, expires); err != apikey.ErrInvalidKey {
+		t.Fatalf("expired: got %v, want %v", err, apikey.ErrInvalidKey)
+	}
+
+	if err := apikey.Revoke(ctx, db, tests.TenantID, created.ID, now.Add(2*time.Hour)); err != nil {
+		t.Fatalf("revoking: %s", err)
+	}
+	if _, err := apikey.Authenticate(ctx, db, created.Secret, now.Add(3*time.Hour)); err != apikey.ErrInvalidKey {
+		t.Fatalf("revoked: got %v, want %v", err, apikey.ErrInvalidKey)
+	}
+
+	nk = apikey.NewKey{Name: "too strong", UserID: tests.UserID, Roles: []string{auth.RoleAdmin}}
+	if _, err := apikey.Create(ctx, db, tests.TenantID, nk, now); err != apikey.ErrRoleNotHeld {
+		t.Fatalf("role not held: got %v, want %v", err, apikey.ErrRoleNotHeld)
+	}
+}
@@ -0,0 +1,5 @@
+// Package apikey manages the keys machine clients such as batch jobs
+// authenticate with instead of a user's password. A key acts for its owner
+// with a subset of the owner's roles. Keys are shown once when created and
+// only a hash of their secret is kept.
+package apikey
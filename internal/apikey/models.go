@@ -0,0 +1,39 @@
+package apikey
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Key is an API key. Prefix is the public part of the key, shown so keys can
+// be told apart. A key stops working once DateExpires passes or it is
+// revoked.
+type Key struct {
+	ID           string         `db:"key_id" json:"id"`
+	Prefix       string         `db:"prefix" json:"prefix"`
+	SecretHash   []byte         `db:"secret_hash" json:"-"`
+	Name         string         `db:"name" json:"name"`
+	UserID       string         `db:"user_id" json:"user_id"`
+	Roles        pq.StringArray `db:"roles" json:"roles"`
+	DateCreated  time.Time      `db:"date_created" json:"date_created"`
+	DateExpires  *time.Time     `db:"date_expires" json:"date_expires"`
+	DateLastUsed *time.Time     `db:"date_last_used" json:"date_last_used"`
+	DateRevoked  *time.Time     `db:"date_revoked" json:"date_revoked"`
+}
+
+// NewKey is what we require from admins when issuing a Key. Roles must all
+// be held by the owner, UserID. Keys without DateExpires never expire.
+type NewKey struct {
+	Name        string     `json:"name" validate:"required"`
+	UserID      string     `json:"user_id" validate:"required,uuid"`
+	Roles       []string   `json:"roles" validate:"required,min=1"`
+	DateExpires *time.Time `json:"date_expires"`
+}
+
+// CreatedKey is returned when a key is issued. It is the only time the full
+// key is shown.
+type CreatedKey struct {
+	Key
+	Secret string `json:"key"`
+}
@@ -0,0 +1,204 @@
+package category
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/database"
+	"go.opencensus.io/trace"
+)
+
+var (
+	ErrNotFound      = errors.New("category not found")
+	ErrInvalidID     = errors.New("ID is not in its proper form")
+	ErrCycle         = errors.New("category cannot be moved below itself")
+	ErrHasChildren   = errors.New("category still has child categories")
+	ErrDuplicateName = errors.New("category name is already used under this parent")
+)
+
+// List returns every category of tenantID ordered so parents come before
+// their children.
+func List(ctx context.Context, db *sqlx.DB, tenantID string) ([]Category, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.category.List")
+	defer span.End()
+
+	var categories []Category
+
+	const q = `WITH RECURSIVE tree AS (
+			SELECT c.*, ARRAY[c.name] AS path FROM categories AS c
+			WHERE c.tenant_id = $1 AND c.parent_id IS NULL
+			UNION ALL
+			SELECT c.*, t.path || c.name FROM categories AS c JOIN tree AS t ON c.parent_id = t.category_id
+		)
+		SELECT category_id, tenant_id, parent_id, name, date_created, date_updated FROM tree ORDER BY path`
+	if err := db.SelectContext(ctx, &categories, q, tenantID); err != nil {
+		return nil, fmt.Errorf("selecting categories: %w", err)
+	}
+
+	return categories, nil
+}
+
+func Retrieve(ctx context.Context, db *sqlx.DB, tenantID, id string) (*Category, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.category.Retrieve")
+	defer span.End()
+
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrInvalidID
+	}
+
+	var c Category
+
+	const q = `SELECT * FROM categories WHERE tenant_id = $1 AND category_id = $2`
+	if err := db.GetContext(ctx, &c, q, tenantID, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+
+		return nil, fmt.Errorf("selecting single category: %w", err)
+	}
+
+	return &c, nil
+}
+
+// Create adds a category of tenantID. Its parent must be one of the tenant's
+// own categories.
+func Create(ctx context.Context, db *sqlx.DB, tenantID string, nc NewCategory, now time.Time) (*Category, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.category.Create")
+	defer span.End()
+
+	c := Category{
+		ID:          uuid.New().String(),
+		TenantID:    tenantID,
+		ParentID:    nc.ParentID,
+		Name:        nc.Name,
+		DateCreated: now.UTC(),
+		DateUpdated: now.UTC(),
+	}
+
+	const q = `INSERT INTO categories
+		(category_id, tenant_id, parent_id, name, date_created, date_updated)
+		VALUES ($1, $2, $3, $4, $5, $6)`
+	if _, err := db.ExecContext(ctx, q, c.ID, c.TenantID, c.ParentID, c.Name, c.DateCreated, c.DateUpdated); err != nil {
+		return nil, translate(err, "inserting category")
+	}
+
+	return &c, nil
+}
+
+func Update(ctx context.Context, db *sqlx.DB, tenantID, id string, update UpdateCategory, now time.Time) error {
+	ctx, span := trace.StartSpan(ctx, "internal.category.Update")
+	defer span.End()
+
+	c, err := Retrieve(ctx, db, tenantID, id)
+	if err != nil {
+		return err
+	}
+
+	if update.Name != nil {
+		c.Name = *update.Name
+	}
+
+	if update.ParentID != nil {
+		c.ParentID = nil
+		if *update.ParentID != "" {
+			if _, err := uuid.Parse(*update.ParentID); err != nil {
+				return ErrInvalidID
+			}
+
+			descendants, err := Descendants(ctx, db, c.ID)
+			if err != nil {
+				return err
+			}
+			for _, d := range descendants {
+				if d == *update.ParentID {
+					return ErrCycle
+				}
+			}
+
+			c.ParentID = update.ParentID
+		}
+	}
+
+	c.DateUpdated = now
+
+	const q = `UPDATE categories SET name = $3, parent_id = $4, date_updated = $5 WHERE tenant_id = $1 AND category_id = $2`
+	if _, err := db.ExecContext(ctx, q, c.TenantID, c.ID, c.Name, c.ParentID, c.DateUpdated); err != nil {
+		return translate(err, "updating category")
+	}
+
+	return nil
+}
+
+// Delete removes a category of tenantID that has no children. Products in it
+// are left uncategorised.
+func Delete(ctx context.Context, db *sqlx.DB, tenantID, id string) error {
+	ctx, span := trace.StartSpan(ctx, "internal.category.Delete")
+	defer span.End()
+
+	if _, err := uuid.Parse(id); err != nil {
+		return ErrInvalidID
+	}
+
+	return database.WithTenant(ctx, db, tenantID, func(tx *sqlx.Tx) error {
+		const p = `UPDATE products SET category_id = NULL WHERE tenant_id = $1 AND category_id = $2`
+		if _, err := tx.ExecContext(ctx, p, tenantID, id); err != nil {
+			return fmt.Errorf("uncategorising products: %w", err)
+		}
+
+		const q = `DELETE FROM categories WHERE tenant_id = $1 AND category_id = $2`
+		if _, err := tx.ExecContext(ctx, q, tenantID, id); err != nil {
+			var pqErr *pq.Error
+			if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+				return ErrHasChildren
+			}
+			return fmt.Errorf("deleting category: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// Descendants returns the ID of a category and of every category below it.
+func Descendants(ctx context.Context, db *sqlx.DB, id string) ([]string, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.category.Descendants")
+	defer span.End()
+
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrInvalidID
+	}
+
+	var ids []string
+
+	const q = `WITH RECURSIVE tree AS (
+			SELECT category_id FROM categories WHERE category_id = $1
+			UNION ALL
+			SELECT c.category_id FROM categories AS c JOIN tree AS t ON c.parent_id = t.category_id
+		)
+		SELECT category_id FROM tree`
+	if err := db.SelectContext(ctx, &ids, q, id); err != nil {
+		return nil, fmt.Errorf("selecting category descendants: %w", err)
+	}
+
+	return ids, nil
+}
+
+// translate maps constraint violations on the categories table to the
+// package's errors.
+func translate(err error, action string) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case "23503":
+			return ErrNotFound
+		case "23505":
+			return ErrDuplicateName
+		}
+	}
+	return fmt.Errorf("%s: %w", action, err)
+}
@@ -0,0 +1,82 @@
+package category_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/category"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/tests"
+)
+
+func TestCategoryTree(t *testing.T) {
+	db, teardown := tests.NewUnit(t)
+	defer teardown()
+
+	now := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+
+	root, err := category.Create(ctx, db, tests.TenantID, category.NewCategory{Name: "Toys"}, now)
+	if err != nil {
+		t.Fatalf("creating root category: %s", err)
+	}
+
+	child, err := category.Create(ctx, db, tests.TenantID, category.NewCategory{Name: "Puzzles", ParentID: &root.ID}, now)
+	if err != nil {
+		t.Fatalf("creating child category: %s", err)
+	}
+
+	grandchild, err := category.Create(ctx, db, tests.TenantID, category.NewCategory{Name: "Jigsaws", ParentID: &child.ID}, now)
+	if err != nil {
+		t.Fatalf("creating grandchild category: %s", err)
+	}
+
+	if _, err := category.Create(ctx, db, tests.TenantID, category.NewCategory{Name: "Puzzles", ParentID: &root.ID}, now); err != category.ErrDuplicateName {
+		t.Fatalf("expected duplicate sibling name to fail with %v, got %v", category.ErrDuplicateName, err)
+	}
+
+	// Another tenant neither sees the categories nor can file its own below
+	// them.
+	const other = "7f0c2b1e-9d3a-4c5b-8e6f-1a2b3c4d5e6f"
+	if _, err := category.Retrieve(ctx, db, other, root.ID); err != category.ErrNotFound {
+		t.Fatalf("expected retrieving from another tenant to fail with %v, got %v", category.ErrNotFound, err)
+	}
+	if _, err := category.Create(ctx, db, other, category.NewCategory{Name: "Kites", ParentID: &root.ID}, now); err != category.ErrNotFound {
+		t.Fatalf("expected a parent of another tenant to fail with %v, got %v", category.ErrNotFound, err)
+	}
+	if list, err := category.List(ctx, db, other); err != nil || len(list) != 0 {
+		t.Fatalf("expected another tenant to list no categories, got %v, %v", list, err)
+	}
+
+	ids, err := category.Descendants(ctx, db, root.ID)
+	if err != nil {
+		t.Fatalf("listing descendants: %s", err)
+	}
+	if exp, got := 3, len(ids); exp != got {
+		t.Fatalf("expected %v categories in the tree, got %v", exp, got)
+	}
+
+	move := category.UpdateCategory{ParentID: &grandchild.ID}
+	if err := category.Update(ctx, db, tests.TenantID, root.ID, move, now); err != category.ErrCycle {
+		t.Fatalf("expected moving a category below itself to fail with %v, got %v", category.ErrCycle, err)
+	}
+
+	if err := category.Delete(ctx, db, tests.TenantID, child.ID); err != category.ErrHasChildren {
+		t.Fatalf("expected deleting a parent to fail with %v, got %v", category.ErrHasChildren, err)
+	}
+
+	list, err := category.List(ctx, db, tests.TenantID)
+	if err != nil {
+		t.Fatalf("listing categories: %s", err)
+	}
+
+	want := []string{root.ID, child.ID, grandchild.ID}
+	if exp, got := len(want), len(list); exp != got {
+		t.Fatalf("expected category list size %v, got %v", exp, got)
+	}
+	for i, id := range want {
+		if list[i].ID != id {
+			t.Fatalf("expected category %d to be %v, got %v", i, id, list[i].ID)
+		}
+	}
+}
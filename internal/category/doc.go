@@ -0,0 +1,3 @@
+// Package category implements the tree of categories products are
+// organised into.
+package category
@@ -0,0 +1,28 @@
+package category
+
+import (
+	"time"
+)
+
+// Category groups a tenant's products. Top level categories have no
+// ParentID.
+type Category struct {
+	ID          string    `db:"category_id" json:"id"`
+	TenantID    string    `db:"tenant_id" json:"-"`
+	ParentID    *string   `db:"parent_id" json:"parent_id"`
+	Name        string    `db:"name" json:"name"`
+	DateCreated time.Time `db:"date_created" json:"date_created"`
+	DateUpdated time.Time `db:"date_updated" json:"date_updated"`
+}
+
+type NewCategory struct {
+	Name     string  `json:"name" validate:"required"`
+	ParentID *string `json:"parent_id" validate:"omitempty,uuid"`
+}
+
+// UpdateCategory renames or moves a Category. An empty ParentID moves it to
+// the top level.
+type UpdateCategory struct {
+	Name     *string `json:"name" validate:"omitempty,min=1"`
+	ParentID *string `json:"parent_id" validate:"omitempty"`
+}
@@ -0,0 +1,124 @@
+package change
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/outbox"
+	"go.opencensus.io/trace"
+)
+
+// MaxLimit caps how many changes a page holds.
+const MaxLimit = 1000
+
+var ErrInvalidToken = errors.New("token is not one issued by the change feed")
+
+// position is where a client is in the feed: the last change it has seen.
+type position struct {
+	txid     int64
+	sequence int64
+}
+
+// Since returns up to limit changes to the aggregates of tenantID made after
+// the change token was issued for, or from the start of the feed when token
+// is empty.
+//
+// Changes are ordered by the transaction that made them and are held back
+// until every transaction that started before it has finished. A change can
+// therefore never appear behind a token that has already been handed out,
+// whichever sales-api instance is asked.
+func Since(ctx context.Context, db *sqlx.DB, tenantID, token string, limit int) (*Page, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.change.Since")
+	defer span.End()
+
+	var pos position
+	if token != "" {
+		var err error
+		if pos, err = decodeToken(token); err != nil {
+			return nil, err
+		}
+	}
+
+	if limit <= 0 || limit > MaxLimit {
+		limit = MaxLimit
+	}
+
+	const q = `SELECT * FROM outbox
+		WHERE tenant_id = $1
+			AND aggregate_type IN ($2, $3)
+			AND (txid, sequence) > ($4, $5)
+			AND txid < txid_snapshot_xmin(txid_current_snapshot())
+		ORDER BY txid, sequence
+		LIMIT $6`
+
+	var events []outbox.Event
+	if err := db.SelectContext(ctx, &events, q, tenantID, outbox.AggregateProduct, outbox.AggregateSale, pos.txid, pos.sequence, limit); err != nil {
+		return nil, fmt.Errorf("selecting changes: %w", err)
+	}
+
+	page := Page{
+		Changes: make([]Change, 0, len(events)),
+		Next:    token,
+		More:    len(events) == limit,
+	}
+
+	for _, e := range events {
+		c := Change{
+			Token: encodeToken(position{txid: e.TxID, sequence: e.Sequence}),
+			ID:    e.AggregateID,
+			Type:  e.Type,
+			Data:  e.Payload,
+			Date:  e.DateCreated,
+		}
+
+		switch e.AggregateType {
+		case outbox.AggregateProduct:
+			c.Entity = EntityProduct
+		case outbox.AggregateSale:
+			c.Entity = EntitySale
+		}
+
+		if e.Type == outbox.ProductDeleted {
+			c.Deleted = true
+			c.Data = nil
+		}
+
+		page.Changes = append(page.Changes, c)
+		page.Next = c.Token
+	}
+
+	return &page, nil
+}
+
+func encodeToken(p position) string {
+	s := strconv.FormatInt(p.txid, 10) + "." + strconv.FormatInt(p.sequence, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(s))
+}
+
+func decodeToken(token string) (position, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return position{}, ErrInvalidToken
+	}
+
+	parts := strings.SplitN(string(b), ".", 2)
+	if len(parts) != 2 {
+		return position{}, ErrInvalidToken
+	}
+
+	txid, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return position{}, ErrInvalidToken
+	}
+	sequence, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return position{}, ErrInvalidToken
+	}
+
+	return position{txid: txid, sequence: sequence}, nil
+}
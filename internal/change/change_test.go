@@ -0,0 +1,120 @@
+package change_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/change"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/money"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/outbox"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/product"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/tests"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/user"
+)
+
+func TestSince(t *testing.T) {
+	db, teardown := tests.NewUnit(t)
+	defer teardown()
+
+	ctx := context.Background()
+	now := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	claims := auth.NewClaims(
+		"718ffbea-f4a1-4667-8ae3-b349da52675e",
+		[]string{auth.RoleAdmin, auth.RoleUser},
+		now, time.Hour,
+	)
+	claims.Permissions = auth.Permissions
+	claims.Tenant = tests.TenantID
+
+	comics, err := product.Create(ctx, db, claims, product.NewProduct{Name: "Comics", Cost: money.Money{Amount: 10}, Quantity: 5}, now)
+	if err != nil {
+		t.Fatalf("creating product: %s", err)
+	}
+
+	sale, err := product.AddSale(ctx, db, claims, product.NewSale{Quantity: 1}, comics.ID, now)
+	if err != nil {
+		t.Fatalf("adding sale: %s", err)
+	}
+
+	// Users are not part of the feed.
+	nu := user.NewUser{Name: "Anna", Email: "anna@example.com", Roles: []string{auth.RoleUser}, Password: "gophers", PasswordConfirm: "gophers"}
+	if _, err := user.Create(ctx, db, claims, nu, now); err != nil {
+		t.Fatalf("creating user: %s", err)
+	}
+
+	puzzles, err := product.Create(ctx, db, claims, product.NewProduct{Name: "Puzzles", Cost: money.Money{Amount: 25}, Quantity: 5}, now)
+	if err != nil {
+		t.Fatalf("creating product: %s", err)
+	}
+	if err := product.Delete(ctx, db, claims, puzzles.ID, now); err != nil {
+		t.Fatalf("deleting product: %s", err)
+	}
+
+	type seen struct {
+		Entity, ID, Type string
+		Deleted          bool
+	}
+	summarize := func(p *change.Page) []seen {
+		var s []seen
+		for _, c := range p.Changes {
+			s = append(s, seen{c.Entity, c.ID, c.Type, c.Deleted})
+			if c.Deleted != (c.Data == nil) {
+				t.Errorf("change %s of %s has deleted %v and data %s", c.Type, c.ID, c.Deleted, c.Data)
+			}
+		}
+		return s
+	}
+
+	first, err := change.Since(ctx, db, tests.TenantID, "", 2)
+	if err != nil {
+		t.Fatalf("reading first page: %s", err)
+	}
+	if !first.More {
+		t.Error("first page of two should say there are more")
+	}
+
+	rest, err := change.Since(ctx, db, tests.TenantID, first.Next, 10)
+	if err != nil {
+		t.Fatalf("reading second page: %s", err)
+	}
+	if rest.More {
+		t.Error("last page should not say there are more")
+	}
+
+	want := []seen{
+		{change.EntityProduct, comics.ID, outbox.ProductCreated, false},
+		{change.EntitySale, sale.ID, outbox.SaleRecorded, false},
+		{change.EntityProduct, puzzles.ID, outbox.ProductCreated, false},
+		{change.EntityProduct, puzzles.ID, outbox.ProductDeleted, true},
+	}
+	got := append(summarize(first), summarize(rest)...)
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("changes differ:\n%s", diff)
+	}
+
+	// A client that is up to date gets nothing and keeps its token.
+	empty, err := change.Since(ctx, db, tests.TenantID, rest.Next, 10)
+	if err != nil {
+		t.Fatalf("reading past the end: %s", err)
+	}
+	if len(empty.Changes) != 0 || empty.Next != rest.Next {
+		t.Fatalf("reading past the end returned %d changes and token %q", len(empty.Changes), empty.Next)
+	}
+
+	// Another tenant sees none of these changes.
+	other, err := change.Since(ctx, db, "7f0c2b1e-9d3a-4c5b-8e6f-1a2b3c4d5e6f", "", 10)
+	if err != nil {
+		t.Fatalf("reading another tenant's feed: %s", err)
+	}
+	if len(other.Changes) != 0 {
+		t.Fatalf("another tenant's feed returned %d changes, want none", len(other.Changes))
+	}
+
+	if _, err := change.Since(ctx, db, tests.TenantID, "bogus", 10); err != change.ErrInvalidToken {
+		t.Fatalf("reading with a bad token: got %v, want %v", err, change.ErrInvalidToken)
+	}
+}
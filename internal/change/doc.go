@@ -0,0 +1,3 @@
+// Package change serves the feed of product and sale changes clients use to
+// sync incrementally. The feed is read from the outbox of domain events.
+package change
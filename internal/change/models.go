@@ -0,0 +1,33 @@
+package change
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Entities a change can be about.
+const (
+	EntityProduct = "product"
+	EntitySale    = "sale"
+)
+
+// Change is one change to a product or sale. Data is the entity as it was
+// after the change. Deletions are tombstones: Deleted is set and Data is
+// null.
+type Change struct {
+	Token   string          `json:"token"`
+	Entity  string          `json:"entity"`
+	ID      string          `json:"id"`
+	Type    string          `json:"type"`
+	Deleted bool            `json:"deleted"`
+	Data    json.RawMessage `json:"data"`
+	Date    time.Time       `json:"date"`
+}
+
+// Page is a run of changes. Next is the token to ask for the changes after
+// them and More says whether there already are some.
+type Page struct {
+	Changes []Change `json:"changes"`
+	Next    string   `json:"next"`
+	More    bool     `json:"more"`
+}
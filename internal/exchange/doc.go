@@ -0,0 +1,3 @@
+// Package exchange maintains the exchange rates used to convert money into a
+// base currency.
+package exchange
@@ -0,0 +1,140 @@
+package exchange
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/money"
+	"go.opencensus.io/trace"
+)
+
+var (
+	ErrInvalidRate  = errors.New("rate must be a positive decimal number")
+	ErrRateNotFound = errors.New("no exchange rate for that currency and date")
+)
+
+// List returns every rate into base, newest first for each currency.
+func List(ctx context.Context, db *sqlx.DB, base string) ([]Rate, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.exchange.List")
+	defer span.End()
+
+	var rates []Rate
+
+	const q = `SELECT currency, base, rate::TEXT AS rate, valid_from FROM exchange_rates
+		WHERE base = $1
+		ORDER BY currency, valid_from DESC`
+	if err := db.SelectContext(ctx, &rates, q, money.NormalizeCurrency(base)); err != nil {
+		return nil, fmt.Errorf("selecting exchange rates: %w", err)
+	}
+
+	return rates, nil
+}
+
+// Set records the rate for a currency pair from a date onwards, replacing any
+// rate already recorded for that date.
+func Set(ctx context.Context, db *sqlx.DB, nr NewRate) (*Rate, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.exchange.Set")
+	defer span.End()
+
+	r := Rate{
+		Currency:  strings.ToUpper(nr.Currency),
+		Base:      strings.ToUpper(nr.Base),
+		Rate:      nr.Rate,
+		ValidFrom: truncateDay(nr.ValidFrom),
+	}
+
+	if !money.ValidCurrency(r.Currency) || !money.ValidCurrency(r.Base) {
+		return nil, money.ErrUnknownCurrency
+	}
+
+	if rat, ok := new(big.Rat).SetString(r.Rate); !ok || rat.Sign() <= 0 {
+		return nil, ErrInvalidRate
+	}
+
+	const q = `INSERT INTO exchange_rates (currency, base, rate, valid_from) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (currency, base, valid_from) DO UPDATE SET rate = EXCLUDED.rate`
+	if _, err := db.ExecContext(ctx, q, r.Currency, r.Base, r.Rate, r.ValidFrom); err != nil {
+		return nil, fmt.Errorf("setting exchange rate: %w", err)
+	}
+
+	return &r, nil
+}
+
+// Converter converts money into a single base currency at the rate that was
+// valid on a given day.
+type Converter struct {
+	base  string
+	rates map[string][]rate
+}
+
+type rate struct {
+	validFrom time.Time
+	rate      *big.Rat
+}
+
+// NewConverter loads every rate into base.
+func NewConverter(ctx context.Context, db *sqlx.DB, base string) (*Converter, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.exchange.NewConverter")
+	defer span.End()
+
+	base = money.NormalizeCurrency(base)
+	if !money.ValidCurrency(base) {
+		return nil, money.ErrUnknownCurrency
+	}
+
+	list, err := List(ctx, db, base)
+	if err != nil {
+		return nil, err
+	}
+
+	c := Converter{
+		base:  base,
+		rates: make(map[string][]rate),
+	}
+	for _, r := range list {
+		rat, ok := new(big.Rat).SetString(r.Rate)
+		if !ok {
+			return nil, fmt.Errorf("parsing stored rate %q for %s", r.Rate, r.Currency)
+		}
+		c.rates[r.Currency] = append(c.rates[r.Currency], rate{validFrom: r.ValidFrom, rate: rat})
+	}
+
+	// Newest first so Convert can stop at the first rate valid on the day.
+	for _, rs := range c.rates {
+		sort.Slice(rs, func(i, j int) bool { return rs[i].validFrom.After(rs[j].validFrom) })
+	}
+
+	return &c, nil
+}
+
+// Base is the currency the Converter converts into.
+func (c *Converter) Base() string {
+	return c.base
+}
+
+// Convert returns m in the base currency at the rate valid on the day of at.
+func (c *Converter) Convert(m money.Money, at time.Time) (money.Money, error) {
+	if m.Currency == c.base {
+		return m, nil
+	}
+
+	day := truncateDay(at)
+	for _, r := range c.rates[m.Currency] {
+		if !r.validFrom.After(day) {
+			return m.Convert(c.base, r.rate, money.HalfEven)
+		}
+	}
+
+	return money.Money{}, ErrRateNotFound
+}
+
+func truncateDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
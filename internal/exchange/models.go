@@ -0,0 +1,22 @@
+package exchange
+
+import (
+	"time"
+)
+
+// Rate says that from ValidFrom onwards one major unit of Currency is worth
+// Rate major units of Base. Rate is kept as a decimal string so it is never
+// rounded through a float.
+type Rate struct {
+	Currency  string    `db:"currency" json:"currency"`
+	Base      string    `db:"base" json:"base"`
+	Rate      string    `db:"rate" json:"rate"`
+	ValidFrom time.Time `db:"valid_from" json:"valid_from"`
+}
+
+type NewRate struct {
+	Currency  string    `json:"currency" validate:"required,len=3"`
+	Base      string    `json:"base" validate:"required,len=3,nefield=Currency"`
+	Rate      string    `json:"rate" validate:"required"`
+	ValidFrom time.Time `json:"valid_from" validate:"required"`
+}
@@ -0,0 +1,118 @@
+package graph
+
+import (
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// listFactor is how many items a list field is assumed to return when
+// estimating the cost of a query.
+const listFactor = 10
+
+// complexity estimates the cost of running operation in doc. Each field
+// costs one plus the cost of its selections, which count listFactor times
+// over when the field is a list.
+func complexity(schema graphql.Schema, doc *ast.Document, operation string) int {
+	fragments := make(map[string]*ast.FragmentDefinition)
+	var op *ast.OperationDefinition
+
+	for _, def := range doc.Definitions {
+		switch def := def.(type) {
+		case *ast.FragmentDefinition:
+			fragments[def.Name.Value] = def
+		case *ast.OperationDefinition:
+			if op == nil || (def.Name != nil && def.Name.Value == operation) {
+				op = def
+			}
+		}
+	}
+	if op == nil {
+		return 0
+	}
+
+	var root *graphql.Object
+	switch op.Operation {
+	case ast.OperationTypeMutation:
+		root = schema.MutationType()
+	default:
+		root = schema.QueryType()
+	}
+
+	c := costing{schema: schema, fragments: fragments, visiting: make(map[string]bool)}
+	return c.selections(root, op.SelectionSet)
+}
+
+type costing struct {
+	schema    graphql.Schema
+	fragments map[string]*ast.FragmentDefinition
+	visiting  map[string]bool
+}
+
+// selections costs set as selected on parent, which is nil when the type is
+// unknown, as it is below the introspection fields.
+func (c *costing) selections(parent *graphql.Object, set *ast.SelectionSet) int {
+	if set == nil {
+		return 0
+	}
+
+	total := 0
+	for _, sel := range set.Selections {
+		switch sel := sel.(type) {
+		case *ast.Field:
+			total += c.field(parent, sel)
+
+		case *ast.InlineFragment:
+			on := parent
+			if sel.TypeCondition != nil {
+				on, _ = c.schema.Type(sel.TypeCondition.Name.Value).(*graphql.Object)
+			}
+			total += c.selections(on, sel.SelectionSet)
+
+		case *ast.FragmentSpread:
+			name := sel.Name.Value
+			frag, ok := c.fragments[name]
+			if !ok || c.visiting[name] {
+				continue
+			}
+			c.visiting[name] = true
+			on, _ := c.schema.Type(frag.TypeCondition.Name.Value).(*graphql.Object)
+			total += c.selections(on, frag.SelectionSet)
+			c.visiting[name] = false
+		}
+	}
+
+	return total
+}
+
+func (c *costing) field(parent *graphql.Object, f *ast.Field) int {
+	var (
+		child *graphql.Object
+		list  bool
+	)
+
+	if parent != nil {
+		if def, ok := parent.Fields()[f.Name.Value]; ok {
+			t := def.Type
+			for {
+				if nn, ok := t.(*graphql.NonNull); ok {
+					t = nn.OfType
+					continue
+				}
+				if l, ok := t.(*graphql.List); ok {
+					list = true
+					t = l.OfType
+					continue
+				}
+				break
+			}
+			child, _ = t.(*graphql.Object)
+		}
+	}
+
+	inner := c.selections(child, f.SelectionSet)
+	if list {
+		inner *= listFactor
+	}
+
+	return 1 + inner
+}
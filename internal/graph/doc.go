@@ -0,0 +1,5 @@
+// Package graph serves products, sales and the current user over GraphQL.
+// Queries run with the claims of the authenticated caller, fields restricted
+// to a permission resolve to null with an error for everyone else, and queries
+// whose estimated cost is too high are rejected before they run.
+package graph
@@ -0,0 +1,84 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	"github.com/jmoiron/sqlx"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
+	"go.opencensus.io/trace"
+)
+
+// Request is a GraphQL query as clients post it.
+type Request struct {
+	Query         string                 `json:"query" validate:"required"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+	Extensions    map[string]interface{} `json:"extensions"`
+}
+
+// Executor runs queries against the schema.
+type Executor struct {
+	db            *sqlx.DB
+	schema        graphql.Schema
+	maxComplexity int
+}
+
+// NewExecutor builds the schema. Queries estimated to cost more than
+// maxComplexity are refused.
+func NewExecutor(db *sqlx.DB, maxComplexity int) (*Executor, error) {
+	schema, err := newSchema(db)
+	if err != nil {
+		return nil, fmt.Errorf("building graphql schema: %w", err)
+	}
+
+	e := Executor{
+		db:            db,
+		schema:        schema,
+		maxComplexity: maxComplexity,
+	}
+	return &e, nil
+}
+
+// Do runs req on behalf of the caller whose claims are in ctx. Every
+// problem, including an invalid or too costly query, is reported in the
+// result's errors as GraphQL clients expect.
+func (e *Executor) Do(ctx context.Context, req Request) *graphql.Result {
+	ctx, span := trace.StartSpan(ctx, "internal.graph.Do")
+	defer span.End()
+
+	src := source.NewSource(&source.Source{Body: []byte(req.Query), Name: "GraphQL request"})
+
+	doc, err := parser.Parse(parser.ParseParams{Source: src})
+	if err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}
+	}
+
+	if v := graphql.ValidateDocument(&e.schema, doc, nil); !v.IsValid {
+		return &graphql.Result{Errors: v.Errors}
+	}
+
+	if c := complexity(e.schema, doc, req.OperationName); c > e.maxComplexity {
+		msg := fmt.Sprintf("query complexity %d exceeds the limit of %d", c, e.maxComplexity)
+		return &graphql.Result{Errors: []gqlerrors.FormattedError{gqlerrors.NewFormattedError(msg)}}
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return &graphql.Result{Errors: []gqlerrors.FormattedError{gqlerrors.NewFormattedError("claims missing from context")}}
+	}
+
+	ctx = context.WithValue(ctx, loaderKey{}, newSalesLoader(e.db, claims.Tenant))
+
+	return graphql.Execute(graphql.ExecuteParams{
+		Schema:        e.schema,
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       ctx,
+	})
+}
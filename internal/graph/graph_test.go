@@ -0,0 +1,37 @@
+package graph_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/graph"
+)
+
+// TestComplexity checks the cost estimate through the limit error, which is
+// reported before the query touches the database.
+func TestComplexity(t *testing.T) {
+	exec, err := graph.NewExecutor(nil, 1)
+	if err != nil {
+		t.Fatalf("building executor: %s", err)
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		cost  string
+	}{
+		{"scalars", `{ me { id email } }`, "complexity 3 "},
+		{"list", `{ products { id name } }`, "complexity 21 "},
+		{"nested lists", `{ products { sales { id } } }`, "complexity 111 "},
+		{"aliases", `{ a: me { id } b: me { id } }`, "complexity 4 "},
+		{"fragments", `{ products { ...P } } fragment P on Product { id ... on Product { name } }`, "complexity 21 "},
+	}
+
+	for _, tt := range tests {
+		res := exec.Do(context.Background(), graph.Request{Query: tt.query})
+		if len(res.Errors) != 1 || !strings.Contains(res.Errors[0].Message, tt.cost) {
+			t.Errorf("%s: expected an error about %q, got %v", tt.name, tt.cost, res.Errors)
+		}
+	}
+}
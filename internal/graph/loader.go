@@ -0,0 +1,55 @@
+package graph
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/product"
+)
+
+// salesLoader batches the sales of every product in a query into one
+// lookup. The executor resolves a whole level of the query before it calls
+// the thunks load returns, so by the time the first thunk runs every product
+// on that level has been registered.
+type salesLoader struct {
+	db       *sqlx.DB
+	tenantID string
+	pending  []string
+	loaded   map[string][]product.Sale
+}
+
+func newSalesLoader(db *sqlx.DB, tenantID string) *salesLoader {
+	return &salesLoader{
+		db:       db,
+		tenantID: tenantID,
+		loaded:   make(map[string][]product.Sale),
+	}
+}
+
+// load registers productID and returns a thunk for its sales.
+func (l *salesLoader) load(ctx context.Context, productID string) func() (interface{}, error) {
+	if _, ok := l.loaded[productID]; !ok {
+		l.pending = append(l.pending, productID)
+	}
+
+	return func() (interface{}, error) {
+		if len(l.pending) > 0 {
+			ids := l.pending
+			l.pending = nil
+
+			byProduct, err := product.SalesByProduct(ctx, l.db, l.tenantID, ids)
+			if err != nil {
+				return nil, err
+			}
+			for _, id := range ids {
+				l.loaded[id] = byProduct[id]
+			}
+		}
+
+		sales := l.loaded[productID]
+		if sales == nil {
+			sales = []product.Sale{}
+		}
+		return sales, nil
+	}
+}
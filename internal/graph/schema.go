@@ -0,0 +1,190 @@
+package graph
+
+import (
+	"context"
+	"errors"
+
+	"github.com/graphql-go/graphql"
+	"github.com/jmoiron/sqlx"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/product"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/user"
+)
+
+var ErrForbidden = errors.New("you are not authorized for that field")
+
+// loaderKey holds the request's salesLoader in the context resolvers see.
+type loaderKey struct{}
+
+// newSchema describes products, sales and users with the same field names
+// as their JSON in the REST API, so the default resolvers read them.
+func newSchema(db *sqlx.DB) (graphql.Schema, error) {
+	moneyType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Money",
+		Description: "An amount in the minor units of a currency.",
+		Fields: graphql.Fields{
+			"amount":   &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"currency": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		},
+	})
+
+	saleType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Sale",
+		Fields: graphql.Fields{
+			"id":              &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+			"product_id":      &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+			"variant_id":      &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+			"warehouse_id":    &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+			"quantity":        &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"list_price":      &graphql.Field{Type: graphql.NewNonNull(moneyType)},
+			"net":             &graphql.Field{Type: graphql.NewNonNull(moneyType)},
+			"tax":             &graphql.Field{Type: graphql.NewNonNull(moneyType)},
+			"paid":            &graphql.Field{Type: graphql.NewNonNull(moneyType)},
+			"jurisdiction":    &graphql.Field{Type: graphql.String},
+			"tax_rate":        &graphql.Field{Type: graphql.String},
+			"tax_inclusive":   &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+			"customer_name":   &graphql.Field{Type: graphql.String, Resolve: restricted(auth.PermSalesAdmin, nil)},
+			"customer_email":  &graphql.Field{Type: graphql.String, Resolve: restricted(auth.PermSalesAdmin, nil)},
+			"external_ref":    &graphql.Field{Type: graphql.String},
+			"override_reason": &graphql.Field{Type: graphql.String, Resolve: restricted(auth.PermSalesAdmin, nil)},
+			"overridden_by":   &graphql.Field{Type: graphql.ID, Resolve: restricted(auth.PermSalesAdmin, nil)},
+			"date_created":    &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+		},
+	})
+
+	productType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Product",
+		Fields: graphql.Fields{
+			"id":           &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+			"name":         &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"cost":         &graphql.Field{Type: graphql.NewNonNull(moneyType)},
+			"quantity":     &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"sold":         &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"revenue":      &graphql.Field{Type: graphql.NewNonNull(moneyType)},
+			"category_id":  &graphql.Field{Type: graphql.ID},
+			"tags":         &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String)))},
+			"user_id":      &graphql.Field{Type: graphql.ID, Resolve: restricted(auth.PermProductsAdmin, nil)},
+			"date_created": &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+			"date_updated": &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+			"sales": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(saleType))),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					l, ok := p.Context.Value(loaderKey{}).(*salesLoader)
+					if !ok {
+						return nil, errors.New("sales loader missing from context")
+					}
+					return l.load(p.Context, p.Source.(product.Product).ID), nil
+				},
+			},
+		},
+	})
+
+	userType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "User",
+		Fields: graphql.Fields{
+			"id":           &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+			"name":         &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"email":        &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"roles":        &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String)))},
+			"date_created": &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+			"date_updated": &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+		},
+	})
+
+	queryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"products": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(productType))),
+				Args: graphql.FieldConfigArgument{
+					"category": &graphql.ArgumentConfig{Type: graphql.ID},
+					"tag":      &graphql.ArgumentConfig{Type: graphql.String},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					f := product.Filter{}
+					f.CategoryID, _ = p.Args["category"].(string)
+					f.Tag, _ = p.Args["tag"].(string)
+					return product.List(p.Context, db, tenant(p.Context), f)
+				},
+			},
+			"product": &graphql.Field{
+				Type: productType,
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					prod, err := product.Retrieve(p.Context, db, tenant(p.Context), p.Args["id"].(string))
+					if err == product.ErrNotFound {
+						return nil, nil
+					}
+					if err != nil {
+						return nil, err
+					}
+					return *prod, nil
+				},
+			},
+			"sale": &graphql.Field{
+				Type: saleType,
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					s, err := product.RetrieveSale(p.Context, db, tenant(p.Context), p.Args["id"].(string))
+					if err == product.ErrSaleNotFound {
+						return nil, nil
+					}
+					if err != nil {
+						return nil, err
+					}
+					return *s, nil
+				},
+			},
+			"me": &graphql.Field{
+				Type: userType,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					claims, ok := p.Context.Value(auth.Key).(auth.Claims)
+					if !ok {
+						return nil, errors.New("claims missing from context")
+					}
+					u, err := user.Retrieve(p.Context, db, claims.Tenant, claims.Subject)
+					if err == user.ErrNotFound {
+						return nil, nil
+					}
+					if err != nil {
+						return nil, err
+					}
+					return *u, nil
+				},
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: queryType})
+}
+
+// restricted only resolves a field for callers granted perm. A nil resolve
+// reads the field from its source as usual.
+func restricted(perm string, resolve graphql.FieldResolveFn) graphql.FieldResolveFn {
+	if resolve == nil {
+		resolve = graphql.DefaultResolveFn
+	}
+
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		if !can(p.Context, perm) {
+			return nil, ErrForbidden
+		}
+		return resolve(p)
+	}
+}
+
+func can(ctx context.Context, perm string) bool {
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	return ok && claims.Can(perm)
+}
+
+// tenant returns the tenant of the caller, whose claims Do has checked are
+// in ctx.
+func tenant(ctx context.Context) string {
+	claims, _ := ctx.Value(auth.Key).(auth.Claims)
+	return claims.Tenant
+}
@@ -0,0 +1,6 @@
+// Package login guards password logins against guessing. Failures are
+// counted for the account, by email whether or not a user has it, and for
+// the client address. Past a few failures each attempt must wait longer than
+// the last, and enough of them lock the subject out for a while. Lockouts
+// and their lifting by an admin are kept as audit events.
+package login
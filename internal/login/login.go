@@ -0,0 +1,367 @@
+package login
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/mfa"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/database"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/user"
+	"go.opencensus.io/trace"
+)
+
+// Kinds of subject failures are counted against.
+const (
+	KindAccount = "account"
+	KindAddress = "address"
+)
+
+// Events recorded in the audit log.
+const (
+	EventLocked   = "locked"
+	EventUnlocked = "unlocked"
+)
+
+var (
+	// Accounts limits the failures against one email.
+	Accounts = Policy{
+		Free:     3,
+		Delay:    time.Second,
+		MaxDelay: 30 * time.Second,
+		Lockout:  10,
+		LockFor:  15 * time.Minute,
+		Window:   15 * time.Minute,
+	}
+
+	// Addresses limits the failures from one client address across all
+	// emails. It is looser than Accounts as many users may share an address.
+	Addresses = Policy{
+		Free:     10,
+		Delay:    time.Second,
+		MaxDelay: 30 * time.Second,
+		Lockout:  100,
+		LockFor:  15 * time.Minute,
+		Window:   15 * time.Minute,
+	}
+)
+
+// ErrThrottled matches every *Throttled.
+var ErrThrottled = errors.New("too many failed logins")
+
+// Throttled is returned for a login refused, without its password being
+// checked, because the account or address must wait until Until.
+type Throttled struct {
+	Until  time.Time
+	Locked bool
+}
+
+func (t *Throttled) Error() string {
+	if t.Locked {
+		return "too many failed logins: locked out for now"
+	}
+	return "too many failed logins: try again later"
+}
+
+func (t *Throttled) Is(target error) bool {
+	return target == ErrThrottled
+}
+
+// Wait returns how long a subject must wait before trying again after its
+// nth failure in a row. It does not account for lockouts.
+func (p Policy) Wait(n int) time.Duration {
+	if n <= p.Free {
+		return 0
+	}
+
+	d := p.Delay
+	for i := p.Free + 1; i < n && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	return d
+}
+
+// subject is something failures are counted against.
+type subject struct {
+	kind   string
+	key    string
+	policy Policy
+}
+
+// Authenticate is user.Authenticate for a login from addr. Attempts are
+// refused with a *Throttled while the account or addr must wait, and each
+// that fails makes them wait longer. addr may be blank when it is unknown.
+//
+// The failures of the account and addr stay locked from the check until the
+// outcome is recorded, so concurrent attempts against them take turns rather
+// than all passing a check made before any of them failed.
+func Authenticate(ctx context.Context, db *sqlx.DB, email, password, addr string, now time.Time) (auth.Claims, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.login.Authenticate")
+	defer span.End()
+
+	// Failures are counted against the normalized email so changing its case
+	// does not buy more attempts.
+	account := subject{KindAccount, user.NormalizeEmail(email), Accounts}
+	subjects := []subject{account}
+	if addr != "" {
+		subjects = append(subjects, subject{KindAddress, addr, Addresses})
+	}
+
+	tx, fs, err := reserve(ctx, db, subjects, now)
+	if err != nil {
+		return auth.Claims{}, err
+	}
+	defer tx.Rollback()
+
+	claims, err := user.Authenticate(ctx, db, now, email, password)
+	switch {
+	case err == user.ErrAuthenticationFailure:
+		return auth.Claims{}, settle(ctx, tx, subjects, fs, err, now)
+
+	// A login held for MFA had the right password all the same.
+	case err != nil && !errors.Is(err, user.ErrMFARequired):
+		return auth.Claims{}, err
+	}
+
+	// The address keeps its count, as it may be trying many accounts.
+	const q = `DELETE FROM login_failures WHERE kind = $1 AND subject = $2`
+	if _, err := tx.ExecContext(ctx, q, account.kind, account.key); err != nil {
+		return auth.Claims{}, fmt.Errorf("clearing login failures: %w", err)
+	}
+
+	if err := settle(ctx, tx, subjects[1:], fs[1:], nil, now); err != nil {
+		return auth.Claims{}, err
+	}
+	return claims, err
+}
+
+// AuthenticateMFA is user.AuthenticateMFA for a login from addr. Wrong codes
+// count as failures against addr, as a challenge only bounds the guesses
+// made at it and not how many challenges a stolen password can get.
+func AuthenticateMFA(ctx context.Context, db *sqlx.DB, challenge, code, addr string, now time.Time) (auth.Claims, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.login.AuthenticateMFA")
+	defer span.End()
+
+	var subjects []subject
+	if addr != "" {
+		subjects = append(subjects, subject{KindAddress, addr, Addresses})
+	}
+
+	tx, fs, err := reserve(ctx, db, subjects, now)
+	if err != nil {
+		return auth.Claims{}, err
+	}
+	defer tx.Rollback()
+
+	claims, err := user.AuthenticateMFA(ctx, db, now, challenge, code)
+	switch {
+	case err == mfa.ErrInvalidCode || err == mfa.ErrInvalidChallenge:
+		return auth.Claims{}, settle(ctx, tx, subjects, fs, err, now)
+	case err != nil:
+		return auth.Claims{}, err
+	}
+
+	if err := settle(ctx, tx, subjects, fs, nil, now); err != nil {
+		return auth.Claims{}, err
+	}
+	return claims, nil
+}
+
+// Unlock clears the failures of u's account, lifting any lockout. actor is
+// recorded as having lifted it.
+func Unlock(ctx context.Context, db *sqlx.DB, actor auth.Claims, u user.User, now time.Time) error {
+	ctx, span := trace.StartSpan(ctx, "internal.login.Unlock")
+	defer span.End()
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("starting unlock: %w", err)
+	}
+	defer tx.Rollback()
+
+	var f failures
+	const q = `DELETE FROM login_failures WHERE kind = $1 AND subject = $2 RETURNING *`
+	if err := tx.GetContext(ctx, &f, q, KindAccount, user.NormalizeEmail(u.Email)); err != nil {
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return fmt.Errorf("unlocking %q: %w", u.ID, err)
+	}
+
+	if f.Locked && now.Before(f.DateRetry) {
+		if err := record(ctx, tx, EventUnlocked, f, &u.ID, &actor.Subject, now); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing unlock: %w", err)
+	}
+	return nil
+}
+
+// Events returns the audit events of the account of userID, newest first.
+func Events(ctx context.Context, db *sqlx.DB, userID string) ([]Event, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.login.Events")
+	defer span.End()
+
+	events := []Event{}
+	const q = `SELECT * FROM login_events WHERE user_id = $1 ORDER BY date_created DESC`
+	if err := db.SelectContext(ctx, &events, q, userID); err != nil {
+		return nil, fmt.Errorf("selecting login events: %w", err)
+	}
+
+	return events, nil
+}
+
+// reserve locks the failures of subjects for an attempt against them,
+// adding any they do not have yet, and returns them in the same order in a
+// transaction that holds the locks. It returns a *Throttled instead if any
+// of subjects must still wait, for the latest time any of them may try
+// again. Callers must lock subjects in the same order, accounts first, so
+// they cannot deadlock.
+func reserve(ctx context.Context, db *sqlx.DB, subjects []subject, now time.Time) (*sqlx.Tx, []failures, error) {
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("starting login attempt: %w", err)
+	}
+
+	fs, err := lock(ctx, tx, subjects, now)
+	if err != nil {
+		tx.Rollback()
+		return nil, nil, err
+	}
+
+	var throttled *Throttled
+	for _, f := range fs {
+		if now.Before(f.DateRetry) && (throttled == nil || f.DateRetry.After(throttled.Until)) {
+			throttled = &Throttled{Until: f.DateRetry, Locked: f.Locked}
+		}
+	}
+
+	if throttled != nil {
+		tx.Rollback()
+		return nil, nil, throttled
+	}
+	return tx, fs, nil
+}
+
+// lock selects the failures of subjects for update in tx.
+func lock(ctx context.Context, tx *sqlx.Tx, subjects []subject, now time.Time) ([]failures, error) {
+	// A locked out account is found by email, whichever tenant it is in.
+	if err := database.Bypass(ctx, tx); err != nil {
+		return nil, err
+	}
+
+	fs := make([]failures, len(subjects))
+	for i, s := range subjects {
+
+		// A subject without failures gets a row all the same, so there is
+		// something to lock. It is removed again unless the attempt fails.
+		const ins = `INSERT INTO login_failures (kind, subject, failures, date_last_failed, date_retry)
+			VALUES ($1, $2, 0, $3, $3)
+			ON CONFLICT DO NOTHING`
+		if _, err := tx.ExecContext(ctx, ins, s.kind, s.key, now.UTC()); err != nil {
+			return nil, fmt.Errorf("inserting login failures: %w", err)
+		}
+
+		const q = `SELECT * FROM login_failures WHERE kind = $1 AND subject = $2 FOR UPDATE`
+		if err := tx.GetContext(ctx, &fs[i], q, s.kind, s.key); err != nil {
+			return nil, fmt.Errorf("selecting login failures: %w", err)
+		}
+	}
+
+	return fs, nil
+}
+
+// settle records the outcome of an attempt reserved for subjects and commits
+// tx. A failed attempt, with err set, counts against each subject, otherwise
+// the rows added just to be locked are removed. It returns err when the
+// outcome is recorded.
+func settle(ctx context.Context, tx *sqlx.Tx, subjects []subject, fs []failures, err error, now time.Time) error {
+	for i, s := range subjects {
+		if err == nil {
+			const q = `DELETE FROM login_failures WHERE kind = $1 AND subject = $2 AND failures = 0`
+			if _, err := tx.ExecContext(ctx, q, s.kind, s.key); err != nil {
+				return fmt.Errorf("clearing login failures: %w", err)
+			}
+			continue
+		}
+
+		if err := fail(ctx, tx, s, fs[i], now); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing login attempt: %w", err)
+	}
+	return err
+}
+
+// fail counts a failure against s, whose failures f are locked in tx,
+// locking it out when it has failed too often.
+func fail(ctx context.Context, tx *sqlx.Tx, s subject, f failures, now time.Time) error {
+
+	// A quiet spell or a lockout that has run its course starts the count
+	// again.
+	if now.Sub(f.DateLastFailed) >= s.policy.Window || (f.Locked && !now.Before(f.DateRetry)) {
+		f.Failures = 0
+		f.Locked = false
+	}
+
+	f.Failures++
+	f.DateLastFailed = now.UTC()
+	f.DateRetry = now.Add(s.policy.Wait(f.Failures)).UTC()
+
+	if f.Failures >= s.policy.Lockout {
+		f.Locked = true
+		f.DateRetry = now.Add(s.policy.LockFor).UTC()
+
+		var userID *string
+		if s.kind == KindAccount {
+			var id string
+			const byEmail = `SELECT user_id FROM users WHERE email = $1`
+			err := tx.GetContext(ctx, &id, byEmail, s.key)
+			switch {
+			case err == nil:
+				userID = &id
+			case err != sql.ErrNoRows:
+				return fmt.Errorf("selecting locked out user: %w", err)
+			}
+		}
+
+		if err := record(ctx, tx, EventLocked, f, userID, nil, now); err != nil {
+			return err
+		}
+	}
+
+	const u = `UPDATE login_failures SET
+		failures = $3, locked = $4, date_last_failed = $5, date_retry = $6
+		WHERE kind = $1 AND subject = $2`
+	if _, err := tx.ExecContext(ctx, u, f.Kind, f.Subject, f.Failures, f.Locked, f.DateLastFailed, f.DateRetry); err != nil {
+		return fmt.Errorf("updating login failures: %w", err)
+	}
+	return nil
+}
+
+// record adds an audit event about the subject of f.
+func record(ctx context.Context, tx *sqlx.Tx, event string, f failures, userID, actorID *string, now time.Time) error {
+	const q = `INSERT INTO login_events
+		(event_id, event, kind, subject, user_id, actor_id, failures, date_created)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	_, err := tx.ExecContext(ctx, q,
+		uuid.New().String(), event, f.Kind, f.Subject, userID, actorID, f.Failures, now.UTC())
+	if err != nil {
+		return fmt.Errorf("recording %s event: %w", event, err)
+	}
+	return nil
+}
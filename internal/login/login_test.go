@@ -0,0 +1,210 @@
+package login_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/login"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/schema"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/tests"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/user"
+)
+
+func TestWait(t *testing.T) {
+	p := login.Policy{Free: 3, Delay: time.Second, MaxDelay: 5 * time.Second}
+
+	cases := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{1, 0},
+		{3, 0},
+		{4, time.Second},
+		{5, 2 * time.Second},
+		{6, 4 * time.Second},
+		{7, 5 * time.Second},
+		{50, 5 * time.Second},
+	}
+
+	for _, tt := range cases {
+		if got := p.Wait(tt.failures); got != tt.want {
+			t.Errorf("after %d failures: got %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestLockout(t *testing.T) {
+	db, teardown := tests.NewUnit(t)
+	defer teardown()
+
+	if err := schema.Seed(db); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	now := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	const addr = "192.0.2.1"
+
+	// attempt tries a login at the earliest time it is allowed, returning
+	// the error of that attempt.
+	attempt := func(email, password string) error {
+		_, err := login.Authenticate(ctx, db, email, password, addr, now)
+		var throttled *login.Throttled
+		if errors.As(err, &throttled) {
+			now = throttled.Until
+			_, err = login.Authenticate(ctx, db, email, password, addr, now)
+		}
+		return err
+	}
+
+	// Wrong passwords are slowed down past the free attempts. Case does not
+	// make for a new account.
+	for i := 1; i <= login.Accounts.Free; i++ {
+		if err := attempt("Admin@example.com", "wrong"); err != user.ErrAuthenticationFailure {
+			t.Fatalf("attempt %d: got %v, want %v", i, err, user.ErrAuthenticationFailure)
+		}
+	}
+	if _, err := login.Authenticate(ctx, db, "admin@example.com", "wrong", addr, now); err != user.ErrAuthenticationFailure {
+		t.Fatalf("first attempt past the free ones: got %v, want %v", err, user.ErrAuthenticationFailure)
+	}
+	_, err := login.Authenticate(ctx, db, "admin@example.com", "gophers", addr, now)
+	var throttled *login.Throttled
+	if !errors.As(err, &throttled) {
+		t.Fatalf("attempt during delay: got %v, want a *Throttled", err)
+	}
+	if throttled.Locked || !throttled.Until.Equal(now.Add(time.Second)) {
+		t.Fatalf("got %+v, want a delay of a second", throttled)
+	}
+
+	// Enough failures lock the account, even to the right password.
+	for i := login.Accounts.Free + 2; i <= login.Accounts.Lockout; i++ {
+		if err := attempt("admin@example.com", "wrong"); err != user.ErrAuthenticationFailure {
+			t.Fatalf("attempt %d: got %v, want %v", i, err, user.ErrAuthenticationFailure)
+		}
+	}
+	_, err = login.Authenticate(ctx, db, "admin@example.com", "gophers", addr, now.Add(time.Minute))
+	if !errors.As(err, &throttled) || !throttled.Locked {
+		t.Fatalf("logging in to locked account: got %v, want a lockout", err)
+	}
+
+	events, err := login.Events(ctx, db, tests.AdminID)
+	if err != nil {
+		t.Fatalf("listing events: %s", err)
+	}
+	if len(events) != 1 || events[0].Event != login.EventLocked || events[0].Failures != login.Accounts.Lockout {
+		t.Fatalf("got events %+v, want one lockout", events)
+	}
+
+	// An admin can lift it.
+	u, err := user.Retrieve(ctx, db, tests.TenantID, tests.AdminID)
+	if err != nil {
+		t.Fatalf("retrieving user: %s", err)
+	}
+	actor := auth.NewClaims(tests.UserID, []string{auth.RoleAdmin}, now, time.Hour)
+	if err := login.Unlock(ctx, db, actor, *u, now); err != nil {
+		t.Fatalf("unlocking: %s", err)
+	}
+	// Emails match whatever their case.
+	if _, err := login.Authenticate(ctx, db, " Admin@Example.com", "gophers", addr, now); err != nil {
+		t.Fatalf("logging in after unlock: %s", err)
+	}
+
+	events, err = login.Events(ctx, db, tests.AdminID)
+	if err != nil {
+		t.Fatalf("listing events: %s", err)
+	}
+	if len(events) != 2 || events[0].Event != login.EventUnlocked || events[0].ActorID == nil || *events[0].ActorID != tests.UserID {
+		t.Fatalf("got events %+v, want the unlock by the user first", events)
+	}
+
+	// Unknown emails are treated the same, so they give nothing away.
+	for i := 1; i <= login.Accounts.Lockout; i++ {
+		if err := attempt("nobody@example.com", "wrong"); err != user.ErrAuthenticationFailure {
+			t.Fatalf("unknown email attempt %d: got %v, want %v", i, err, user.ErrAuthenticationFailure)
+		}
+	}
+	_, err = login.Authenticate(ctx, db, "nobody@example.com", "wrong", addr, now)
+	if !errors.As(err, &throttled) || !throttled.Locked {
+		t.Fatalf("unknown email: got %v, want a lockout", err)
+	}
+}
+
+func TestAddressThrottle(t *testing.T) {
+	db, teardown := tests.NewUnit(t)
+	defer teardown()
+
+	if err := schema.Seed(db); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	now := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	// One address guessing a password for each of many emails is slowed
+	// down though no single account is.
+	for i := 1; i <= login.Addresses.Free+1; i++ {
+		email := fmt.Sprintf("guess%d@example.com", i)
+		if _, err := login.Authenticate(ctx, db, email, "gophers", "192.0.2.1", now); err != user.ErrAuthenticationFailure {
+			t.Fatalf("attempt %d: got %v, want %v", i, err, user.ErrAuthenticationFailure)
+		}
+	}
+
+	_, err := login.Authenticate(ctx, db, "user@example.com", "gophers", "192.0.2.1", now)
+	if !errors.Is(err, login.ErrThrottled) {
+		t.Fatalf("attempt from busy address: got %v, want %v", err, login.ErrThrottled)
+	}
+
+	// Other addresses are unaffected.
+	if _, err := login.Authenticate(ctx, db, "user@example.com", "gophers", "192.0.2.2", now); err != nil {
+		t.Fatalf("attempt from other address: %s", err)
+	}
+}
+
+func TestConcurrentAttempts(t *testing.T) {
+	db, teardown := tests.NewUnit(t)
+	defer teardown()
+
+	if err := schema.Seed(db); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	now := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	// Attempts made at once take turns, so only those the count allows get
+	// their password checked.
+	const n = 10
+	errs := make(chan error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := login.Authenticate(ctx, db, "admin@example.com", "wrong", "", now)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	var failed, throttled int
+	for err := range errs {
+		switch {
+		case err == user.ErrAuthenticationFailure:
+			failed++
+		case errors.Is(err, login.ErrThrottled):
+			throttled++
+		default:
+			t.Fatalf("got %v, want a failure or a *Throttled", err)
+		}
+	}
+	if want := login.Accounts.Free + 1; failed != want || throttled != n-want {
+		t.Fatalf("got %d failed and %d throttled, want %d and %d", failed, throttled, want, n-want)
+	}
+}
@@ -0,0 +1,45 @@
+package login
+
+import "time"
+
+// Policy says how failed logins against one subject are slowed down and
+// when they lock it out.
+type Policy struct {
+	// Free is how many failures in a row carry no delay.
+	Free int
+
+	// Delay is the wait after the first failure past Free. It doubles with
+	// every failure after that, up to MaxDelay.
+	Delay    time.Duration
+	MaxDelay time.Duration
+
+	// Lockout is how many failures in a row lock the subject out for
+	// LockFor.
+	Lockout int
+	LockFor time.Duration
+
+	// Window is how long after the last failure the count is forgotten.
+	Window time.Duration
+}
+
+// Event is an audit record of a subject being locked out or unlocked.
+type Event struct {
+	ID          string    `db:"event_id" json:"id"`
+	Event       string    `db:"event" json:"event"`
+	Kind        string    `db:"kind" json:"kind"`
+	Subject     string    `db:"subject" json:"subject"`
+	UserID      *string   `db:"user_id" json:"user_id"`
+	ActorID     *string   `db:"actor_id" json:"actor_id"`
+	Failures    int       `db:"failures" json:"failures"`
+	DateCreated time.Time `db:"date_created" json:"date_created"`
+}
+
+// failures is the count of recent failed logins against a subject.
+type failures struct {
+	Kind           string    `db:"kind"`
+	Subject        string    `db:"subject"`
+	Failures       int       `db:"failures"`
+	Locked         bool      `db:"locked"`
+	DateLastFailed time.Time `db:"date_last_failed"`
+	DateRetry      time.Time `db:"date_retry"`
+}
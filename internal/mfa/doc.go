@@ -0,0 +1,6 @@
+// Package mfa provides the second factor of logins: time-based one-time
+// passwords (TOTP, RFC 6238) from an authenticator app, with single-use
+// recovery codes for when the app is lost. A user enrols by being given a
+// secret and confirms the enrolment with a code generated from it. Logins by
+// enrolled users are held at a challenge until a code is presented for it.
+package mfa
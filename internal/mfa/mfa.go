@@ -0,0 +1,312 @@
+package mfa
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"go.opencensus.io/trace"
+)
+
+const (
+	// ChallengeLifetime is how long a challenge may be answered for.
+	ChallengeLifetime = 5 * time.Minute
+
+	// MaxAttempts is how many codes may be tried against one challenge.
+	MaxAttempts = 5
+
+	// RecoveryCodes is how many recovery codes a user is given.
+	RecoveryCodes = 10
+)
+
+// Issuer names the service in authenticator apps.
+var Issuer = "Sales API"
+
+var (
+	ErrNotEnrolled     = errors.New("user is not enrolled in MFA")
+	ErrAlreadyEnrolled = errors.New("user is already enrolled in MFA")
+	ErrInvalidCode     = errors.New("MFA code is invalid")
+
+	// ErrInvalidChallenge is returned for challenges that are unknown,
+	// expired, answered or tried too many times.
+	ErrInvalidChallenge = errors.New("MFA challenge is invalid or expired")
+)
+
+// Enrol starts the enrolment of the user userID, whose account is named
+// account in authenticator apps. Until it is confirmed, enrolling again
+// replaces the secret.
+func Enrol(ctx context.Context, db *sqlx.DB, userID, account string, now time.Time) (*Enrolment, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.mfa.Enrol")
+	defer span.End()
+
+	secret, err := newSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	const q = `INSERT INTO mfa_totp (user_id, secret, date_created)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO UPDATE SET secret = $2, last_step = 0, date_created = $3
+		WHERE mfa_totp.date_confirmed IS NULL`
+	res, err := db.ExecContext(ctx, q, userID, secret, now.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("enrolling %q: %w", userID, err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return nil, ErrAlreadyEnrolled
+	}
+
+	e := Enrolment{
+		Secret: secret,
+		URI:    URI(Issuer, account, secret),
+	}
+	return &e, nil
+}
+
+// Confirm completes the enrolment of the user userID with a code from their
+// app, which shows it was set up. It returns the user's recovery codes,
+// which are not kept and cannot be shown again.
+func Confirm(ctx context.Context, db *sqlx.DB, userID, code string, now time.Time) ([]string, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.mfa.Confirm")
+	defer span.End()
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("starting MFA confirmation: %w", err)
+	}
+	defer tx.Rollback()
+
+	var t totp
+	const q = `SELECT * FROM mfa_totp WHERE user_id = $1 FOR UPDATE`
+	if err := tx.GetContext(ctx, &t, q, userID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotEnrolled
+		}
+		return nil, fmt.Errorf("selecting enrolment of %q: %w", userID, err)
+	}
+	if t.DateConfirmed != nil {
+		return nil, ErrAlreadyEnrolled
+	}
+
+	s, ok, err := verify(t.Secret, code, now)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidCode
+	}
+
+	const u = `UPDATE mfa_totp SET last_step = $2, date_confirmed = $3 WHERE user_id = $1`
+	if _, err := tx.ExecContext(ctx, u, userID, s, now.UTC()); err != nil {
+		return nil, fmt.Errorf("confirming enrolment of %q: %w", userID, err)
+	}
+
+	codes, err := recoveryCodes(ctx, tx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("committing MFA confirmation: %w", err)
+	}
+	return codes, nil
+}
+
+// Enabled reports whether the user userID has confirmed an enrolment.
+func Enabled(ctx context.Context, db sqlx.QueryerContext, userID string) (bool, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.mfa.Enabled")
+	defer span.End()
+
+	var enabled bool
+	const q = `SELECT EXISTS (SELECT 1 FROM mfa_totp WHERE user_id = $1 AND date_confirmed IS NOT NULL)`
+	if err := sqlx.GetContext(ctx, db, &enabled, q, userID); err != nil {
+		return false, fmt.Errorf("checking MFA of %q: %w", userID, err)
+	}
+
+	return enabled, nil
+}
+
+// Reset removes the enrolment of the user userID, with their recovery codes
+// and open challenges, as when they have lost their app. They log in with a
+// password alone until they enrol again.
+func Reset(ctx context.Context, db *sqlx.DB, userID string) error {
+	ctx, span := trace.StartSpan(ctx, "internal.mfa.Reset")
+	defer span.End()
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("starting MFA reset: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, table := range []string{"mfa_totp", "mfa_recovery_codes", "mfa_challenges"} {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE user_id = $1`, userID); err != nil {
+			return fmt.Errorf("resetting MFA of %q: %w", userID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing MFA reset: %w", err)
+	}
+	return nil
+}
+
+// Issue returns a new challenge for the user userID.
+func Issue(ctx context.Context, db *sqlx.DB, userID string, now time.Time) (Challenge, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.mfa.Issue")
+	defer span.End()
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return Challenge{}, fmt.Errorf("generating challenge: %w", err)
+	}
+
+	c := Challenge{
+		Token:       base64.RawURLEncoding.EncodeToString(secret),
+		DateExpires: now.Add(ChallengeLifetime).UTC(),
+	}
+
+	const q = `INSERT INTO mfa_challenges (challenge_hash, user_id, date_expires) VALUES ($1, $2, $3)`
+	if _, err := db.ExecContext(ctx, q, hash(c.Token), userID, c.DateExpires); err != nil {
+		return Challenge{}, fmt.Errorf("inserting challenge: %w", err)
+	}
+
+	return c, nil
+}
+
+// Redeem answers the challenge raw with code, which is either the current
+// TOTP code or an unused recovery code, and returns the user it was issued
+// to. Each code is accepted once.
+func Redeem(ctx context.Context, db *sqlx.DB, raw, code string, now time.Time) (string, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.mfa.Redeem")
+	defer span.End()
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("starting MFA redemption: %w", err)
+	}
+	defer tx.Rollback()
+
+	var c challenge
+	const q = `SELECT * FROM mfa_challenges WHERE challenge_hash = $1 FOR UPDATE`
+	if err := tx.GetContext(ctx, &c, q, hash(raw)); err != nil {
+		if err == sql.ErrNoRows {
+			return "", ErrInvalidChallenge
+		}
+		return "", fmt.Errorf("selecting challenge: %w", err)
+	}
+	if c.DateUsed != nil || !now.Before(c.DateExpires) || c.Attempts >= MaxAttempts {
+		return "", ErrInvalidChallenge
+	}
+
+	ok, err := accept(ctx, tx, c.UserID, code, now)
+	if err != nil {
+		return "", err
+	}
+
+	// Failed attempts are kept so a challenge cannot be guessed at for long.
+	const u = `UPDATE mfa_challenges SET attempts = attempts + 1, date_used = $2 WHERE challenge_hash = $1`
+	var used *time.Time
+	if ok {
+		t := now.UTC()
+		used = &t
+	}
+	if _, err := tx.ExecContext(ctx, u, c.Hash, used); err != nil {
+		return "", fmt.Errorf("updating challenge: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("committing MFA redemption: %w", err)
+	}
+
+	if !ok {
+		return "", ErrInvalidCode
+	}
+	return c.UserID, nil
+}
+
+// accept spends code for the user userID if it is good.
+func accept(ctx context.Context, tx *sqlx.Tx, userID, code string, now time.Time) (bool, error) {
+	var t totp
+	const q = `SELECT * FROM mfa_totp WHERE user_id = $1 AND date_confirmed IS NOT NULL FOR UPDATE`
+	if err := tx.GetContext(ctx, &t, q, userID); err != nil {
+		if err == sql.ErrNoRows {
+			return false, ErrInvalidChallenge
+		}
+		return false, fmt.Errorf("selecting enrolment of %q: %w", userID, err)
+	}
+
+	code = strings.TrimSpace(code)
+	if len(code) == digits {
+		s, ok, err := verify(t.Secret, code, now)
+		if err != nil {
+			return false, err
+		}
+
+		// A code seen once may not be replayed, nor may an older one.
+		if !ok || s <= t.LastStep {
+			return false, nil
+		}
+
+		const u = `UPDATE mfa_totp SET last_step = $2 WHERE user_id = $1`
+		if _, err := tx.ExecContext(ctx, u, userID, s); err != nil {
+			return false, fmt.Errorf("updating enrolment of %q: %w", userID, err)
+		}
+		return true, nil
+	}
+
+	const u = `UPDATE mfa_recovery_codes SET date_used = $3
+		WHERE user_id = $1 AND code_hash = $2 AND date_used IS NULL`
+	res, err := tx.ExecContext(ctx, u, userID, hash(normalize(code)), now.UTC())
+	if err != nil {
+		return false, fmt.Errorf("using recovery code: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("using recovery code: %w", err)
+	}
+	return n == 1, nil
+}
+
+// recoveryCodes replaces the recovery codes of the user userID with new ones
+// and returns them.
+func recoveryCodes(ctx context.Context, tx *sqlx.Tx, userID string) ([]string, error) {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return nil, fmt.Errorf("deleting recovery codes: %w", err)
+	}
+
+	codes := make([]string, RecoveryCodes)
+	for i := range codes {
+		b := make([]byte, 10)
+		if _, err := rand.Read(b); err != nil {
+			return nil, fmt.Errorf("generating recovery code: %w", err)
+		}
+		c := strings.ToLower(encoding.EncodeToString(b))[:10]
+		codes[i] = c[:5] + "-" + c[5:]
+
+		const q = `INSERT INTO mfa_recovery_codes (user_id, code_hash) VALUES ($1, $2)`
+		if _, err := tx.ExecContext(ctx, q, userID, hash(c)); err != nil {
+			return nil, fmt.Errorf("inserting recovery code: %w", err)
+		}
+	}
+
+	return codes, nil
+}
+
+// normalize returns a recovery code as it is hashed, however it was typed.
+func normalize(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+func hash(raw string) []byte {
+	sum := sha256.Sum256([]byte(raw))
+	return sum[:]
+}
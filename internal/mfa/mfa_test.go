@@ -0,0 +1,154 @@
+package mfa_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/mfa"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/schema"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/tests"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/user"
+)
+
+func TestCode(t *testing.T) {
+	// The SHA-1 vectors of RFC 6238, cut down to six digits.
+	const secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+	cases := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, tt := range cases {
+		got, err := mfa.Code(secret, time.Unix(tt.unix, 0))
+		if err != nil {
+			t.Fatalf("at %d: %s", tt.unix, err)
+		}
+		if got != tt.want {
+			t.Errorf("at %d: got %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestMFA(t *testing.T) {
+	db, teardown := tests.NewUnit(t)
+	defer teardown()
+
+	if err := schema.Seed(db); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	now := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	code := func(secret string, at time.Time) string {
+		c, err := mfa.Code(secret, at)
+		if err != nil {
+			t.Fatalf("generating code: %s", err)
+		}
+		return c
+	}
+
+	// login logs the admin in, answering any challenge with c.
+	login := func(c string) error {
+		_, err := user.Authenticate(ctx, db, now, "admin@example.com", "gophers")
+		var required *user.MFARequired
+		if !errors.As(err, &required) {
+			t.Fatalf("logging in: got %v, want a challenge", err)
+		}
+		_, err = user.AuthenticateMFA(ctx, db, now, required.Challenge.Token, c)
+		return err
+	}
+
+	// Admins must enrol before their roles grant anything.
+	claims, err := user.Authenticate(ctx, db, now, "admin@example.com", "gophers")
+	if err != nil {
+		t.Fatalf("logging in before enrolling: %s", err)
+	}
+	if len(claims.Permissions) != 0 {
+		t.Fatalf("got permissions %v before enrolling, want none", claims.Permissions)
+	}
+
+	e, err := mfa.Enrol(ctx, db, tests.AdminID, "admin@example.com", now)
+	if err != nil {
+		t.Fatalf("enrolling: %s", err)
+	}
+	if !strings.HasPrefix(e.URI, "otpauth://totp/") || !strings.Contains(e.URI, "secret="+e.Secret) {
+		t.Fatalf("got URI %q, want an otpauth URI with the secret", e.URI)
+	}
+
+	if _, err := mfa.Confirm(ctx, db, tests.AdminID, "000000", now); err != mfa.ErrInvalidCode {
+		t.Fatalf("confirming with a wrong code: got %v, want %v", err, mfa.ErrInvalidCode)
+	}
+	recovery, err := mfa.Confirm(ctx, db, tests.AdminID, code(e.Secret, now), now)
+	if err != nil {
+		t.Fatalf("confirming: %s", err)
+	}
+	if len(recovery) != mfa.RecoveryCodes {
+		t.Fatalf("got %d recovery codes, want %d", len(recovery), mfa.RecoveryCodes)
+	}
+	if _, err := mfa.Enrol(ctx, db, tests.AdminID, "admin@example.com", now); err != mfa.ErrAlreadyEnrolled {
+		t.Fatalf("enrolling again: got %v, want %v", err, mfa.ErrAlreadyEnrolled)
+	}
+
+	// The code used to confirm may not be used again, but the next one may.
+	if err := login(code(e.Secret, now)); err != mfa.ErrInvalidCode {
+		t.Fatalf("replaying a code: got %v, want %v", err, mfa.ErrInvalidCode)
+	}
+	now = now.Add(30 * time.Second)
+	if err := login(code(e.Secret, now)); err != nil {
+		t.Fatalf("logging in with a code: %s", err)
+	}
+
+	// Recovery codes work once, however they are typed.
+	if err := login(strings.ToUpper(recovery[0])); err != nil {
+		t.Fatalf("logging in with a recovery code: %s", err)
+	}
+	if err := login(recovery[0]); err != mfa.ErrInvalidCode {
+		t.Fatalf("reusing a recovery code: got %v, want %v", err, mfa.ErrInvalidCode)
+	}
+
+	// A challenge stops taking codes once it has been guessed at too often
+	// or has expired.
+	c, err := mfa.Issue(ctx, db, tests.AdminID, now)
+	if err != nil {
+		t.Fatalf("issuing challenge: %s", err)
+	}
+	for i := 0; i < mfa.MaxAttempts; i++ {
+		if _, err := mfa.Redeem(ctx, db, c.Token, "000000", now); err != mfa.ErrInvalidCode {
+			t.Fatalf("guess %d: got %v, want %v", i, err, mfa.ErrInvalidCode)
+		}
+	}
+	if _, err := mfa.Redeem(ctx, db, c.Token, recovery[1], now); err != mfa.ErrInvalidChallenge {
+		t.Fatalf("answering after too many guesses: got %v, want %v", err, mfa.ErrInvalidChallenge)
+	}
+
+	c, err = mfa.Issue(ctx, db, tests.AdminID, now)
+	if err != nil {
+		t.Fatalf("issuing challenge: %s", err)
+	}
+	if _, err := mfa.Redeem(ctx, db, c.Token, recovery[1], now.Add(mfa.ChallengeLifetime)); err != mfa.ErrInvalidChallenge {
+		t.Fatalf("answering an expired challenge: got %v, want %v", err, mfa.ErrInvalidChallenge)
+	}
+
+	// Once reset, the admin logs in with a password but must enrol again.
+	if err := mfa.Reset(ctx, db, tests.AdminID); err != nil {
+		t.Fatalf("resetting: %s", err)
+	}
+	claims, err = user.Authenticate(ctx, db, now, "admin@example.com", "gophers")
+	if err != nil {
+		t.Fatalf("logging in after reset: %s", err)
+	}
+	if len(claims.Permissions) != 0 {
+		t.Fatalf("got permissions %v after reset, want none", claims.Permissions)
+	}
+}
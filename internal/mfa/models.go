@@ -0,0 +1,35 @@
+package mfa
+
+import "time"
+
+// Enrolment is what a user sets their authenticator app up with. Secret is
+// for typing in by hand when URI cannot be scanned.
+type Enrolment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// Challenge is handed out in place of tokens for a correct password, to be
+// presented with a code before DateExpires.
+type Challenge struct {
+	Token       string    `json:"challenge"`
+	DateExpires time.Time `json:"date_expires"`
+}
+
+// totp is a user's TOTP enrolment.
+type totp struct {
+	UserID        string     `db:"user_id"`
+	Secret        string     `db:"secret"`
+	LastStep      int64      `db:"last_step"`
+	DateCreated   time.Time  `db:"date_created"`
+	DateConfirmed *time.Time `db:"date_confirmed"`
+}
+
+// challenge is a stored challenge. Only the hash of its token is kept.
+type challenge struct {
+	Hash        []byte     `db:"challenge_hash"`
+	UserID      string     `db:"user_id"`
+	Attempts    int        `db:"attempts"`
+	DateExpires time.Time  `db:"date_expires"`
+	DateUsed    *time.Time `db:"date_used"`
+}
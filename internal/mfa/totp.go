@@ -0,0 +1,112 @@
+package mfa
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// The parameters of the codes, which are those authenticator apps assume.
+const (
+	digits = 6
+	period = 30 * time.Second
+
+	// skew is how many steps a code may be off by either way, for clocks
+	// that drift and codes typed in as they change.
+	skew = 1
+)
+
+// encoding is how secrets are written, as authenticator apps expect them.
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// newSecret returns a random secret of 160 bits, the size of a SHA-1 key.
+func newSecret() (string, error) {
+	key := make([]byte, 20)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("generating secret: %w", err)
+	}
+	return encoding.EncodeToString(key), nil
+}
+
+// URI returns the otpauth URI authenticator apps read, usually from a QR
+// code, to be set up with secret for account.
+func URI(issuer, account, secret string) string {
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", issuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", fmt.Sprint(digits))
+	q.Set("period", fmt.Sprint(int(period/time.Second)))
+
+	u := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + issuer + ":" + account,
+		RawQuery: q.Encode(),
+	}
+	return u.String()
+}
+
+// Code returns the code for secret at t.
+func Code(secret string, t time.Time) (string, error) {
+	key, err := decode(secret)
+	if err != nil {
+		return "", err
+	}
+	return code(key, step(t)), nil
+}
+
+// verify returns the time step c is the code of, if it is the code of
+// secret at now or a step either side.
+func verify(secret, c string, now time.Time) (int64, bool, error) {
+	key, err := decode(secret)
+	if err != nil {
+		return 0, false, err
+	}
+
+	at := step(now)
+	for s := at - skew; s <= at+skew; s++ {
+		if hmac.Equal([]byte(code(key, s)), []byte(c)) {
+			return s, true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+func decode(secret string) ([]byte, error) {
+	key, err := encoding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil {
+		return nil, fmt.Errorf("decoding secret: %w", err)
+	}
+	return key, nil
+}
+
+// step returns the number of periods from the Unix epoch to t.
+func step(t time.Time) int64 {
+	return t.Unix() / int64(period/time.Second)
+}
+
+// code returns the HOTP code (RFC 4226) of key for the counter s.
+func code(key []byte, s int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(s))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0xf
+	n := binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, n%mod)
+}
@@ -16,22 +16,60 @@ var ErrForbidden = web.NewRequestError(
 	http.StatusForbidden,
 )
 
-func Authenticate(authenticator *auth.Authenticator) web.Middleware {
+var ErrRevoked = web.NewRequestError(
+	errors.New("token has been revoked"),
+	http.StatusUnauthorized,
+)
+
+// ErrNoTenant rejects tokens that name no tenant, such as those issued before
+// there were tenants, as nothing they could do would be scoped.
+var ErrNoTenant = web.NewRequestError(
+	errors.New("token has no tenant"),
+	http.StatusUnauthorized,
+)
+
+// Authenticate puts the claims of the request's credentials into its context.
+// Those are either a bearer token, which must not be on the revoked list, or
+// an API key, checked by apiKeys.
+func Authenticate(authenticator *auth.Authenticator, revoked auth.RevocationList, apiKeys auth.APIKeyFunc) web.Middleware {
 	f := func(after web.Handler) web.Handler {
 		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 			ctx, span := trace.StartSpan(ctx, "internal.mid.Authenticate")
 			defer span.End()
 
 			parts := strings.Split(r.Header.Get("Authorization"), " ")
-			if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
-				err := errors.New("expected authorization header format: Bearer <token>")
+			if len(parts) != 2 {
+				err := errors.New("expected authorization header format: Bearer <token> or ApiKey <key>")
 				return web.NewRequestError(err, http.StatusUnauthorized)
 			}
 
-			_, span = trace.StartSpan(ctx, "auth.ParseClaims")
-			claims, err := authenticator.ParseClaims(parts[1])
-			span.End()
-			if err != nil {
+			var claims auth.Claims
+			switch strings.ToLower(parts[0]) {
+			case "bearer":
+				var err error
+				_, span = trace.StartSpan(ctx, "auth.ParseClaims")
+				claims, err = authenticator.ParseClaims(parts[1])
+				span.End()
+				if err != nil {
+					return web.NewRequestError(err, http.StatusUnauthorized)
+				}
+
+				if revoked.Revoked(claims.Id) {
+					return ErrRevoked
+				}
+				if claims.Tenant == "" {
+					return ErrNoTenant
+				}
+
+			case "apikey":
+				var err error
+				claims, err = apiKeys(ctx, parts[1])
+				if err != nil {
+					return err
+				}
+
+			default:
+				err := errors.New("expected authorization header format: Bearer <token> or ApiKey <key>")
 				return web.NewRequestError(err, http.StatusUnauthorized)
 			}
 
@@ -46,6 +84,26 @@ func Authenticate(authenticator *auth.Authenticator) web.Middleware {
 	return f
 }
 
+// Optional is authenticate for routes anonymous callers may use too: requests
+// without credentials go on with no claims in their context, while any that
+// are presented must be valid.
+func Optional(authenticate web.Middleware) web.Middleware {
+	f := func(after web.Handler) web.Handler {
+		authenticated := authenticate(after)
+
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			if r.Header.Get("Authorization") == "" {
+				return after(ctx, w, r)
+			}
+			return authenticated(ctx, w, r)
+		}
+
+		return h
+	}
+
+	return f
+}
+
 func HasRole(roles ...string) web.Middleware {
 	f := func(after web.Handler) web.Handler {
 		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
@@ -66,3 +124,26 @@ func HasRole(roles ...string) web.Middleware {
 	}
 	return f
 }
+
+// Require rejects requests whose claims do not grant permission p. It must
+// come after Authenticate.
+func Require(p string) web.Middleware {
+	f := func(after web.Handler) web.Handler {
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			ctx, span := trace.StartSpan(ctx, "internal.mid.Require")
+			defer span.End()
+
+			claims, ok := ctx.Value(auth.Key).(auth.Claims)
+			if !ok {
+				return errors.New("claims missing from context: Require called without/before Authenticate")
+			}
+			if !claims.Can(p) {
+				return ErrForbidden
+			}
+
+			return after(ctx, w, r)
+		}
+		return h
+	}
+	return f
+}
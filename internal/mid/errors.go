@@ -2,9 +2,11 @@ package mid
 
 import (
 	"context"
+	"errors"
 	"log"
 	"net/http"
 
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/authz"
 	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/web"
 	"go.opencensus.io/trace"
 )
@@ -23,6 +25,13 @@ func Errors(log *log.Logger) web.Middleware {
 			if err := before(ctx, w, r); err != nil {
 				log.Printf("%s : ERROR: %+v", v.TraceID, err)
 
+				// Policy refusals come from the domain packages and
+				// explain themselves to the client.
+				var denied *authz.Denied
+				if errors.As(err, &denied) {
+					err = &web.Error{Err: denied, Status: http.StatusForbidden, Details: denied.Decision}
+				}
+
 				if err := web.RespondError(ctx, w, err); err != nil {
 					return err
 				}
@@ -0,0 +1,49 @@
+package money
+
+import "strings"
+
+// DefaultCurrency is assumed wherever an amount is given without a currency.
+const DefaultCurrency = "USD"
+
+// exponents holds the number of minor unit digits of each currency we accept.
+var exponents = map[string]int{
+	"AUD": 2,
+	"BHD": 3,
+	"CAD": 2,
+	"CHF": 2,
+	"CNY": 2,
+	"EUR": 2,
+	"GBP": 2,
+	"HKD": 2,
+	"INR": 2,
+	"JPY": 0,
+	"KRW": 0,
+	"KWD": 3,
+	"NZD": 2,
+	"SEK": 2,
+	"SGD": 2,
+	"USD": 2,
+}
+
+// Exponent returns the number of minor unit digits of currency, e.g. 2 for
+// USD where 100 cents make a dollar, and whether the currency is known.
+func Exponent(currency string) (int, bool) {
+	e, ok := exponents[currency]
+	return e, ok
+}
+
+// ValidCurrency reports whether currency is an ISO 4217 code we accept.
+func ValidCurrency(currency string) bool {
+	_, ok := exponents[currency]
+	return ok
+}
+
+// NormalizeCurrency upper cases a currency code and substitutes
+// DefaultCurrency for an empty one.
+func NormalizeCurrency(currency string) string {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if currency == "" {
+		return DefaultCurrency
+	}
+	return currency
+}
@@ -0,0 +1,4 @@
+// Package money represents amounts of money as integer minor units of an
+// ISO 4217 currency and implements the arithmetic and rounding we allow on
+// them.
+package money
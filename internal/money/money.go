@@ -0,0 +1,133 @@
+package money
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+var (
+	ErrCurrencyMismatch = errors.New("amounts are in different currencies")
+	ErrUnknownCurrency  = errors.New("currency is not a supported ISO 4217 code")
+)
+
+// Money is an amount in the minor units of Currency, e.g. {1999, "USD"} is
+// $19.99.
+type Money struct {
+	Amount   int64  `db:"amount" json:"amount" validate:"gte=0"`
+	Currency string `db:"currency" json:"currency" validate:"omitempty,len=3"`
+}
+
+// New returns amount minor units of currency.
+func New(amount int64, currency string) (Money, error) {
+	currency = NormalizeCurrency(currency)
+	if !ValidCurrency(currency) {
+		return Money{}, ErrUnknownCurrency
+	}
+	return Money{Amount: amount, Currency: currency}, nil
+}
+
+// Add returns m + o. Both must be in the same currency.
+func (m Money) Add(o Money) (Money, error) {
+	if m.Currency != o.Currency {
+		return Money{}, ErrCurrencyMismatch
+	}
+	return Money{Amount: m.Amount + o.Amount, Currency: m.Currency}, nil
+}
+
+// Sub returns m - o. Both must be in the same currency.
+func (m Money) Sub(o Money) (Money, error) {
+	if m.Currency != o.Currency {
+		return Money{}, ErrCurrencyMismatch
+	}
+	return Money{Amount: m.Amount - o.Amount, Currency: m.Currency}, nil
+}
+
+// Times returns m multiplied by a whole number, e.g. a unit price by a quantity.
+func (m Money) Times(n int64) Money {
+	return Money{Amount: m.Amount * n, Currency: m.Currency}
+}
+
+// Mul returns m multiplied by factor, rounded to a whole minor unit.
+func (m Money) Mul(factor *big.Rat, mode RoundingMode) Money {
+	r := new(big.Rat).Mul(new(big.Rat).SetInt64(m.Amount), factor)
+	return Money{Amount: Round(r, mode), Currency: m.Currency}
+}
+
+// Convert returns m in currency to, where one major unit of m's currency is
+// worth rate major units of to.
+func (m Money) Convert(to string, rate *big.Rat, mode RoundingMode) (Money, error) {
+	fromExp, ok := Exponent(m.Currency)
+	if !ok {
+		return Money{}, ErrUnknownCurrency
+	}
+	toExp, ok := Exponent(to)
+	if !ok {
+		return Money{}, ErrUnknownCurrency
+	}
+
+	r := new(big.Rat).Mul(new(big.Rat).SetInt64(m.Amount), rate)
+	scale := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(toExp-fromExp))), nil))
+	if toExp > fromExp {
+		r.Mul(r, scale)
+	} else {
+		r.Quo(r, scale)
+	}
+
+	return Money{Amount: Round(r, mode), Currency: to}, nil
+}
+
+// String formats m in major units, e.g. "USD 19.99".
+func (m Money) String() string {
+	exp, ok := Exponent(m.Currency)
+	if !ok || exp == 0 {
+		return fmt.Sprintf("%s %d", m.Currency, m.Amount)
+	}
+
+	sign := ""
+	amount := m.Amount
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	unit := int64(1)
+	for i := 0; i < exp; i++ {
+		unit *= 10
+	}
+
+	return fmt.Sprintf("%s %s%d.%0*d", m.Currency, sign, amount/unit, exp, amount%unit)
+}
+
+// UnmarshalJSON accepts either a {"amount", "currency"} object or a bare
+// number of minor units with no currency, which callers default.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] != '{' && data[0] != 'n' {
+		var amount int64
+		if err := json.Unmarshal(data, &amount); err != nil {
+			return fmt.Errorf("money amount must be a whole number of minor units: %w", err)
+		}
+		*m = Money{Amount: amount}
+		return nil
+	}
+
+	type plain Money
+	var p plain
+	if err := json.Unmarshal(data, &p); err != nil {
+		return err
+	}
+	*m = Money(p)
+	m.Currency = strings.ToUpper(m.Currency)
+	return nil
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,106 @@
+package money_test
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/money"
+)
+
+func TestRound(t *testing.T) {
+	tests := []struct {
+		in   string
+		mode money.RoundingMode
+		want int64
+	}{
+		{"5/2", money.HalfUp, 3},
+		{"-5/2", money.HalfUp, -3},
+		{"5/2", money.HalfEven, 2},
+		{"7/2", money.HalfEven, 4},
+		{"-5/2", money.HalfEven, -2},
+		{"249/100", money.HalfUp, 2},
+		{"251/100", money.HalfEven, 3},
+		{"29/10", money.Down, 2},
+		{"-29/10", money.Down, -2},
+		{"4", money.HalfEven, 4},
+	}
+
+	for _, tt := range tests {
+		r, ok := new(big.Rat).SetString(tt.in)
+		if !ok {
+			t.Fatalf("parsing %s", tt.in)
+		}
+		if got := money.Round(r, tt.mode); got != tt.want {
+			t.Errorf("Round(%s, %v) = %v, want %v", tt.in, tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		from money.Money
+		to   string
+		rate string
+		want money.Money
+	}{
+		{money.Money{Amount: 1000, Currency: "USD"}, "EUR", "0.9", money.Money{Amount: 900, Currency: "EUR"}},
+		{money.Money{Amount: 1000, Currency: "USD"}, "JPY", "108.5", money.Money{Amount: 1085, Currency: "JPY"}},
+		{money.Money{Amount: 1085, Currency: "JPY"}, "USD", "0.0092", money.Money{Amount: 998, Currency: "USD"}},
+		{money.Money{Amount: 1, Currency: "USD"}, "KWD", "0.305", money.Money{Amount: 3, Currency: "KWD"}},
+	}
+
+	for _, tt := range tests {
+		rate, _ := new(big.Rat).SetString(tt.rate)
+		got, err := tt.from.Convert(tt.to, rate, money.HalfEven)
+		if err != nil {
+			t.Fatalf("converting %v: %s", tt.from, err)
+		}
+		if got != tt.want {
+			t.Errorf("%v to %s at %s = %v, want %v", tt.from, tt.to, tt.rate, got, tt.want)
+		}
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	usd := money.Money{Amount: 150, Currency: "USD"}
+	eur := money.Money{Amount: 150, Currency: "EUR"}
+
+	if _, err := usd.Add(eur); err != money.ErrCurrencyMismatch {
+		t.Fatalf("expected adding different currencies to fail with %v, got %v", money.ErrCurrencyMismatch, err)
+	}
+
+	sum, err := usd.Add(usd.Times(2))
+	if err != nil {
+		t.Fatalf("adding: %s", err)
+	}
+	if exp, got := "USD 4.50", sum.String(); exp != got {
+		t.Fatalf("expected %v, got %v", exp, got)
+	}
+
+	if exp, got := "JPY 150", (money.Money{Amount: 150, Currency: "JPY"}).String(); exp != got {
+		t.Fatalf("expected %v, got %v", exp, got)
+	}
+
+	if exp, got := "USD -0.05", (money.Money{Amount: -5, Currency: "USD"}).String(); exp != got {
+		t.Fatalf("expected %v, got %v", exp, got)
+	}
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	var v struct {
+		Bare   money.Money `json:"bare"`
+		Object money.Money `json:"object"`
+	}
+
+	if err := json.Unmarshal([]byte(`{"bare": 55, "object": {"amount": 70, "currency": "eur"}}`), &v); err != nil {
+		t.Fatalf("decoding: %s", err)
+	}
+
+	if exp, got := (money.Money{Amount: 55}), v.Bare; exp != got {
+		t.Errorf("expected %v, got %v", exp, got)
+	}
+	if exp, got := (money.Money{Amount: 70, Currency: "EUR"}), v.Object; exp != got {
+		t.Errorf("expected %v, got %v", exp, got)
+	}
+}
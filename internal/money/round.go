@@ -0,0 +1,49 @@
+package money
+
+import (
+	"math/big"
+)
+
+// RoundingMode decides how a fractional number of minor units is rounded.
+type RoundingMode int
+
+const (
+	// HalfUp rounds halves away from zero: 2.5 -> 3, -2.5 -> -3.
+	HalfUp RoundingMode = iota
+
+	// HalfEven rounds halves to the nearest even number: 2.5 -> 2, 3.5 -> 4.
+	HalfEven
+
+	// Down truncates towards zero: 2.9 -> 2, -2.9 -> -2.
+	Down
+)
+
+// Round rounds r to a whole number using mode.
+func Round(r *big.Rat, mode RoundingMode) int64 {
+	num := new(big.Int).Set(r.Num())
+	den := r.Denom()
+
+	neg := num.Sign() < 0
+	num.Abs(num)
+
+	q, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+
+	if rem.Sign() != 0 && mode != Down {
+		// Compare twice the remainder with the denominator to find out
+		// whether we are below, at or above the half.
+		cmp := new(big.Int).Lsh(rem, 1).Cmp(den)
+		switch {
+		case cmp > 0:
+			q.Add(q, big.NewInt(1))
+		case cmp == 0:
+			if mode == HalfUp || q.Bit(0) == 1 {
+				q.Add(q, big.NewInt(1))
+			}
+		}
+	}
+
+	if neg {
+		q.Neg(q)
+	}
+	return q.Int64()
+}
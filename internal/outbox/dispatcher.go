@@ -0,0 +1,167 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"go.opencensus.io/trace"
+)
+
+// dispatchLock is the advisory lock key held while a batch is dispatched so
+// that only one sales-api instance delivers events at a time.
+const dispatchLock = 0x6f7574626f78
+
+// maxBackoff caps how long a failing event waits between attempts.
+const maxBackoff = time.Hour
+
+// Sink is somewhere events are delivered. Deliver may be called more than
+// once for the same event, so sinks must tolerate duplicates.
+type Sink interface {
+	Deliver(ctx context.Context, e Event) error
+}
+
+// SinkFunc adapts a function to a Sink.
+type SinkFunc func(ctx context.Context, e Event) error
+
+// Deliver calls f.
+func (f SinkFunc) Deliver(ctx context.Context, e Event) error {
+	return f(ctx, e)
+}
+
+// LogSink returns a Sink that writes each event to log.
+func LogSink(log *log.Logger) Sink {
+	return SinkFunc(func(ctx context.Context, e Event) error {
+		log.Printf("outbox : %d %s %s %s", e.Sequence, e.Type, e.AggregateType, e.AggregateID)
+		return nil
+	})
+}
+
+// Dispatcher delivers the events in the outbox to its sinks. An event is
+// marked dispatched once every sink has accepted it. Until then it and every
+// later event of the same aggregate wait to be retried, twice as long after
+// each failed attempt, starting from the poll interval.
+type Dispatcher struct {
+	db        *sqlx.DB
+	log       *log.Logger
+	sinks     []Sink
+	interval  time.Duration
+	batchSize int
+}
+
+// NewDispatcher makes a Dispatcher that polls the outbox every interval and
+// delivers up to batchSize events at a time.
+func NewDispatcher(db *sqlx.DB, log *log.Logger, interval time.Duration, batchSize int, sinks ...Sink) *Dispatcher {
+	return &Dispatcher{
+		db:        db,
+		log:       log,
+		sinks:     sinks,
+		interval:  interval,
+		batchSize: batchSize,
+	}
+}
+
+// Run dispatches events until ctx is cancelled.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		// Keep going while there is a backlog rather than waiting a tick.
+		for {
+			n, err := d.Dispatch(ctx, time.Now())
+			if err != nil && ctx.Err() == nil {
+				d.log.Printf("outbox : ERROR : %v", err)
+			}
+			if err != nil || n < d.batchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Dispatch delivers one batch of pending events and reports how many were
+// delivered. It does nothing when another instance is dispatching.
+func (d *Dispatcher) Dispatch(ctx context.Context, now time.Time) (int, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.outbox.Dispatch")
+	defer span.End()
+
+	tx, err := d.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("starting dispatch: %w", err)
+	}
+	defer tx.Rollback()
+
+	var locked bool
+	if err := tx.GetContext(ctx, &locked, `SELECT pg_try_advisory_xact_lock($1)`, dispatchLock); err != nil {
+		return 0, fmt.Errorf("locking outbox: %w", err)
+	}
+	if !locked {
+		return 0, nil
+	}
+
+	events, err := pending(ctx, tx, d.batchSize, now)
+	if err != nil {
+		return 0, err
+	}
+
+	// Once an event of an aggregate fails, its later events wait for it.
+	blocked := make(map[string]bool)
+
+	var delivered int
+	for _, e := range events {
+		key := e.AggregateType + "/" + e.AggregateID
+		if blocked[key] {
+			continue
+		}
+
+		if err := d.deliver(ctx, e); err != nil {
+			blocked[key] = true
+			if err := markFailed(ctx, tx, e, err, now.Add(d.backoff(e.Attempts))); err != nil {
+				return 0, err
+			}
+			continue
+		}
+
+		if err := markDispatched(ctx, tx, e, now); err != nil {
+			return 0, err
+		}
+		delivered++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("committing dispatch: %w", err)
+	}
+
+	return delivered, nil
+}
+
+// backoff is how long to wait before retrying an event that has failed
+// attempts times before this failure.
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	wait := d.interval
+	for i := 0; i < attempts && wait < maxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > maxBackoff {
+		wait = maxBackoff
+	}
+	return wait
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, e Event) error {
+	for _, s := range d.sinks {
+		if err := s.Deliver(ctx, e); err != nil {
+			return fmt.Errorf("delivering event %d: %w", e.Sequence, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,3 @@
+// Package outbox records domain events in the same transaction as the change
+// they describe and delivers them to sinks once that transaction commits.
+package outbox
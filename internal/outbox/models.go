@@ -0,0 +1,57 @@
+package outbox
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Aggregates events are recorded against. Events of one aggregate are
+// delivered in the order they were recorded.
+const (
+	AggregateProduct = "product"
+	AggregateVariant = "variant"
+	AggregateSale    = "sale"
+	AggregateUser    = "user"
+)
+
+// Event types.
+const (
+	ProductCreated = "ProductCreated"
+	ProductUpdated = "ProductUpdated"
+	ProductDeleted = "ProductDeleted"
+	VariantAdded   = "VariantAdded"
+	VariantUpdated = "VariantUpdated"
+	SaleRecorded   = "SaleRecorded"
+	UserCreated    = "UserCreated"
+)
+
+// PasswordResetRequested is recorded when a user asks for a password reset
+// token so it is mailed from the outbox. It is for this service alone and is
+// not among the Types published to webhooks.
+const PasswordResetRequested = "PasswordResetRequested"
+
+// Types lists every event type.
+var Types = []string{
+	ProductCreated, ProductUpdated, ProductDeleted,
+	VariantAdded, VariantUpdated,
+	SaleRecorded,
+	UserCreated,
+}
+
+// Event is something that happened to an aggregate. Payload is the JSON of
+// the aggregate as it was after the change.
+type Event struct {
+	ID             string          `db:"event_id" json:"id"`
+	Sequence       int64           `db:"sequence" json:"sequence"`
+	TxID           int64           `db:"txid" json:"-"`
+	TenantID       string          `db:"tenant_id" json:"-"`
+	AggregateType  string          `db:"aggregate_type" json:"aggregate_type"`
+	AggregateID    string          `db:"aggregate_id" json:"aggregate_id"`
+	Type           string          `db:"event_type" json:"type"`
+	Payload        json.RawMessage `db:"payload" json:"payload"`
+	Attempts       int             `db:"attempts" json:"-"`
+	LastError      *string         `db:"last_error" json:"-"`
+	DateRetry      *time.Time      `db:"date_retry" json:"-"`
+	DateCreated    time.Time       `db:"date_created" json:"date_created"`
+	DateDispatched *time.Time      `db:"date_dispatched" json:"-"`
+}
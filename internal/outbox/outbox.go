@@ -0,0 +1,74 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// Record adds an event about an aggregate of tenantID to the outbox as part
+// of tx, so it is delivered only if tx commits. Callers record the event
+// after writing the aggregate so the row lock that write takes keeps the
+// events of an aggregate in order.
+func Record(ctx context.Context, tx *sqlx.Tx, tenantID, aggregateType, aggregateID, eventType string, payload interface{}, now time.Time) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("encoding %s payload: %w", eventType, err)
+	}
+
+	const q = `INSERT INTO outbox
+		(event_id, tenant_id, aggregate_type, aggregate_id, event_type, payload, date_created)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	if _, err := tx.ExecContext(ctx, q, uuid.New().String(), tenantID, aggregateType, aggregateID, eventType, data, now.UTC()); err != nil {
+		return fmt.Errorf("recording %s event: %w", eventType, err)
+	}
+
+	return nil
+}
+
+// pending returns up to limit undelivered events due by now in the order
+// they were recorded. An event waiting to be retried holds back the later
+// events of its aggregate, which are left out so they do not crowd the events
+// of other aggregates out of the batch.
+func pending(ctx context.Context, tx *sqlx.Tx, limit int, now time.Time) ([]Event, error) {
+	const q = `SELECT * FROM outbox AS o
+		WHERE o.date_dispatched IS NULL
+		AND NOT EXISTS (
+			SELECT 1 FROM outbox AS w
+			WHERE w.date_dispatched IS NULL AND w.date_retry > $2
+			AND w.aggregate_type = o.aggregate_type AND w.aggregate_id = o.aggregate_id
+			AND w.sequence <= o.sequence
+		)
+		ORDER BY o.sequence
+		LIMIT $1`
+
+	var events []Event
+	if err := tx.SelectContext(ctx, &events, q, limit, now.UTC()); err != nil {
+		return nil, fmt.Errorf("selecting pending events: %w", err)
+	}
+
+	return events, nil
+}
+
+// markDispatched records that every sink has received e.
+func markDispatched(ctx context.Context, tx *sqlx.Tx, e Event, now time.Time) error {
+	const q = `UPDATE outbox SET attempts = attempts + 1, last_error = NULL, date_retry = NULL, date_dispatched = $2 WHERE event_id = $1`
+	if _, err := tx.ExecContext(ctx, q, e.ID, now.UTC()); err != nil {
+		return fmt.Errorf("marking event dispatched: %w", err)
+	}
+	return nil
+}
+
+// markFailed records a failed attempt to deliver e. It stays pending until
+// retry.
+func markFailed(ctx context.Context, tx *sqlx.Tx, e Event, cause error, retry time.Time) error {
+	const q = `UPDATE outbox SET attempts = attempts + 1, last_error = $2, date_retry = $3 WHERE event_id = $1`
+	if _, err := tx.ExecContext(ctx, q, e.ID, cause.Error(), retry.UTC()); err != nil {
+		return fmt.Errorf("marking event failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package outbox_test
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/money"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/outbox"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/product"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/tests"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/user"
+)
+
+func TestDispatch(t *testing.T) {
+	db, teardown := tests.NewUnit(t)
+	defer teardown()
+
+	ctx := context.Background()
+	now := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	claims := auth.NewClaims(
+		"718ffbea-f4a1-4667-8ae3-b349da52675e",
+		[]string{auth.RoleAdmin, auth.RoleUser},
+		now, time.Hour,
+	)
+	claims.Permissions = auth.Permissions
+	claims.Tenant = tests.TenantID
+
+	p, err := product.Create(ctx, db, claims, product.NewProduct{Name: "Comic Books", Cost: money.Money{Amount: 10}, Quantity: 5}, now)
+	if err != nil {
+		t.Fatalf("creating product: %s", err)
+	}
+
+	nu := user.NewUser{
+		Name:            "Anna",
+		Email:           "anna@example.com",
+		Roles:           []string{auth.RoleUser},
+		Password:        "gophers",
+		PasswordConfirm: "gophers",
+	}
+	u, err := user.Create(ctx, db, claims, nu, now)
+	if err != nil {
+		t.Fatalf("creating user: %s", err)
+	}
+
+	if err := product.Update(ctx, db, claims, p.ID, product.UpdateProduct{Name: tests.StringPointer("Comics")}, now); err != nil {
+		t.Fatalf("updating product: %s", err)
+	}
+	if err := product.Delete(ctx, db, claims, p.ID, now); err != nil {
+		t.Fatalf("deleting product: %s", err)
+	}
+
+	// An event recorded in a transaction that rolls back is never delivered.
+	tx := db.MustBegin()
+	if err := outbox.Record(ctx, tx, tests.TenantID, outbox.AggregateProduct, p.ID, outbox.ProductUpdated, p, now); err != nil {
+		t.Fatalf("recording event: %s", err)
+	}
+	tx.Rollback()
+
+	var got []string
+	failUpdate := true
+	sink := outbox.SinkFunc(func(ctx context.Context, e outbox.Event) error {
+		if e.Type == outbox.ProductUpdated && failUpdate {
+			failUpdate = false
+			return errors.New("sink unavailable")
+		}
+		got = append(got, e.Type+" "+e.AggregateID)
+		return nil
+	})
+
+	d := outbox.NewDispatcher(db, log.New(os.Stderr, "", 0), time.Second, 10, sink)
+
+	// The failed update holds back the later delete of the same product but
+	// not the events of other aggregates.
+	n, err := d.Dispatch(ctx, now)
+	if err != nil {
+		t.Fatalf("dispatching: %s", err)
+	}
+	if n != 2 {
+		t.Errorf("first dispatch delivered %d events, want 2", n)
+	}
+
+	// The update is not retried until it has waited out its backoff.
+	if n, err := d.Dispatch(ctx, now); err != nil || n != 0 {
+		t.Fatalf("dispatching before the retry is due: got %d, %v", n, err)
+	}
+
+	n, err = d.Dispatch(ctx, now.Add(time.Second))
+	if err != nil {
+		t.Fatalf("dispatching: %s", err)
+	}
+	if n != 2 {
+		t.Errorf("retrying dispatch delivered %d events, want 2", n)
+	}
+
+	want := []string{
+		outbox.ProductCreated + " " + p.ID,
+		outbox.UserCreated + " " + u.ID,
+		outbox.ProductUpdated + " " + p.ID,
+		outbox.ProductDeleted + " " + p.ID,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("delivered events differ:\n%s", diff)
+	}
+
+	if n, err := d.Dispatch(ctx, now.Add(time.Second)); err != nil || n != 0 {
+		t.Fatalf("dispatching an empty outbox: got %d, %v", n, err)
+	}
+}
@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"context"
 	"crypto/rsa"
 	"errors"
 	"fmt"
@@ -10,6 +11,16 @@ import (
 
 type KeyLookupFunc func(kid string) (*rsa.PublicKey, error)
 
+// APIKeyFunc returns the claims of the API key presented by a client instead
+// of a token.
+type APIKeyFunc func(ctx context.Context, key string) (Claims, error)
+
+// RevocationList reports whether the token with the ID jti was revoked
+// before it expired.
+type RevocationList interface {
+	Revoked(jti string) bool
+}
+
 func NewSimpleKeyLookupFunc(activeKID string, publicKey *rsa.PublicKey) KeyLookupFunc {
 	f := func(kid string) (*rsa.PublicKey, error) {
 		if activeKID != kid {
@@ -21,8 +32,7 @@ func NewSimpleKeyLookupFunc(activeKID string, publicKey *rsa.PublicKey) KeyLooku
 }
 
 type Authenticator struct {
-	privateKey       *rsa.PrivateKey
-	activeKID        string
+	keys             keySource
 	algorithm        string
 	pubKeyLookupFunc KeyLookupFunc
 	parser           *jwt.Parser
@@ -37,6 +47,21 @@ func NewAuthenticator(privateKey *rsa.PrivateKey, activeKID, algorithm string, p
 		return nil, errors.New("active kid cannot be blank")
 	}
 
+	return newAuthenticator(singleKey{kid: activeKID, key: privateKey}, algorithm, publicKeyLookupFunc)
+}
+
+// NewKeyStoreAuthenticator returns an Authenticator signing with the active
+// key of ks. Keys reloaded into ks, including a change of active key, are
+// used from the next token on.
+func NewKeyStoreAuthenticator(ks *KeyStore, algorithm string, publicKeyLookupFunc KeyLookupFunc) (*Authenticator, error) {
+	if ks == nil {
+		return nil, errors.New("key store cannot be nil")
+	}
+
+	return newAuthenticator(ks, algorithm, publicKeyLookupFunc)
+}
+
+func newAuthenticator(keys keySource, algorithm string, publicKeyLookupFunc KeyLookupFunc) (*Authenticator, error) {
 	if jwt.GetSigningMethod(algorithm) == nil {
 		return nil, fmt.Errorf("unknown algorithm %q", algorithm)
 	}
@@ -50,8 +75,7 @@ func NewAuthenticator(privateKey *rsa.PrivateKey, activeKID, algorithm string, p
 	}
 
 	a := Authenticator{
-		privateKey:       privateKey,
-		activeKID:        activeKID,
+		keys:             keys,
 		algorithm:        algorithm,
 		pubKeyLookupFunc: publicKeyLookupFunc,
 		parser:           &parser,
@@ -63,16 +87,23 @@ func NewAuthenticator(privateKey *rsa.PrivateKey, activeKID, algorithm string, p
 func (a *Authenticator) GenerateToken(claims Claims) (string, error) {
 	method := jwt.GetSigningMethod(a.algorithm)
 
+	kid, key := a.keys.ActiveKey()
+
 	tkn := jwt.NewWithClaims(method, claims)
-	tkn.Header["kid"] = a.activeKID
+	tkn.Header["kid"] = kid
 
-	str, err := tkn.SignedString(a.privateKey)
+	str, err := tkn.SignedString(key)
 	if err != nil {
 		return "", fmt.Errorf("signing token %w", err)
 	}
 	return str, nil
 }
 
+// JWKS returns the key set other services verify our tokens with.
+func (a *Authenticator) JWKS() JWKS {
+	return NewJWKS(a.algorithm, a.keys.PublicKeys())
+}
+
 func (a *Authenticator) ParseClaims(tokenStr string) (Claims, error) {
 	keyFunc := func(t *jwt.Token) (interface{}, error) {
 		kid, ok := t.Header["kid"]
@@ -0,0 +1,236 @@
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JWK is an RSA public key in JSON Web Key form (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// JWKS is a JSON Web Key Set, the document published at
+// /.well-known/jwks.json.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewJWKS returns the key set holding keys, which are indexed by their key
+// ID, ordered by key ID.
+func NewJWKS(algorithm string, keys map[string]*rsa.PublicKey) JWKS {
+	set := JWKS{Keys: make([]JWK, 0, len(keys))}
+	for kid, key := range keys {
+		set.Keys = append(set.Keys, JWK{
+			Kty: "RSA",
+			Use: "sig",
+			Alg: algorithm,
+			Kid: kid,
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		})
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+	return set
+}
+
+// PublicKeys returns the RSA signing keys in the set indexed by key ID. Keys
+// of other types or uses are left out.
+func (s JWKS) PublicKeys() (map[string]*rsa.PublicKey, error) {
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range s.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("decoding modulus of key %q: %w", k.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("decoding exponent of key %q: %w", k.Kid, err)
+		}
+
+		exp := new(big.Int).SetBytes(e)
+		if !exp.IsInt64() || exp.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("exponent of key %q is too large", k.Kid)
+		}
+
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())}
+	}
+	return keys, nil
+}
+
+// ChainKeyLookupFuncs returns a KeyLookupFunc trying each of funcs in turn
+// until one knows the key ID.
+func ChainKeyLookupFuncs(funcs ...KeyLookupFunc) KeyLookupFunc {
+	f := func(kid string) (*rsa.PublicKey, error) {
+		err := fmt.Errorf("unrecognized key id %q", kid)
+		for _, lookup := range funcs {
+			var key *rsa.PublicKey
+			if key, err = lookup(kid); err == nil {
+				return key, nil
+			}
+		}
+		return nil, err
+	}
+	return f
+}
+
+// refetchUnknown is how long a KeySet waits between fetches triggered by
+// tokens signed with a key it does not know, so a flood of forged key IDs
+// does not turn into a flood of requests to the issuer.
+const refetchUnknown = time.Minute
+
+// fetchTimeout bounds how long a KeySet waits for its source to respond.
+const fetchTimeout = 10 * time.Second
+
+// KeySet looks up public keys in a JWKS document read from a file or URL.
+// The keys are cached and fetched again once they are older than the refresh
+// interval, or sooner when a token names a key ID that is not in the cache,
+// which is how keys rotated in by the issuer are picked up.
+//
+// Only one fetch runs at a time and it runs without the lock held, so
+// lookups of cached keys are never held up by a slow source. Lookups of a
+// key that is not cached wait for the fetch in flight instead of starting
+// their own.
+type KeySet struct {
+	source  string
+	client  *http.Client
+	refresh time.Duration
+
+	mu       sync.Mutex
+	keys     map[string]*rsa.PublicKey
+	fetched  time.Time
+	err      error
+	fetching chan struct{}
+}
+
+// NewKeySet returns a KeySet reading source, which is an http(s) URL or a
+// file path. The client is used for URLs and may be nil.
+func NewKeySet(source string, client *http.Client, refresh time.Duration) *KeySet {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &KeySet{
+		source:  source,
+		client:  client,
+		refresh: refresh,
+	}
+}
+
+// Lookup returns the key with the ID kid. It is a KeyLookupFunc. When the
+// source cannot be read the cached keys are used until it can be again.
+func (ks *KeySet) Lookup(kid string) (*rsa.PublicKey, error) {
+	ks.mu.Lock()
+
+	now := time.Now()
+	_, known := ks.keys[kid]
+
+	stale := ks.fetched.IsZero() || now.Sub(ks.fetched) >= ks.refresh
+	if !known && now.Sub(ks.fetched) >= refetchUnknown {
+		stale = true
+	}
+
+	switch {
+	case ks.fetching != nil && !known:
+		wait := ks.fetching
+		ks.mu.Unlock()
+		<-wait
+		ks.mu.Lock()
+
+	case stale && ks.fetching == nil:
+		done := make(chan struct{})
+		ks.fetching = done
+		ks.mu.Unlock()
+
+		keys, err := ks.fetch()
+
+		ks.mu.Lock()
+		if err == nil {
+			ks.keys = keys
+		}
+		ks.err = err
+		// A failed fetch is retried no sooner than a successful one would
+		// have been.
+		ks.fetched = now
+		ks.fetching = nil
+		close(done)
+	}
+
+	defer ks.mu.Unlock()
+
+	key, known := ks.keys[kid]
+	if ks.keys == nil && ks.err != nil {
+		return nil, ks.err
+	}
+	if !known {
+		return nil, fmt.Errorf("unrecognized key id %q", kid)
+	}
+	return key, nil
+}
+
+func (ks *KeySet) fetch() (map[string]*rsa.PublicKey, error) {
+	var data []byte
+	if strings.HasPrefix(ks.source, "http://") || strings.HasPrefix(ks.source, "https://") {
+		ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+		defer cancel()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, ks.source, nil)
+		if err != nil {
+			return nil, fmt.Errorf("fetching key set: %w", err)
+		}
+
+		resp, err := ks.client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("fetching key set: %w", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("fetching key set: unexpected status %s", resp.Status)
+		}
+
+		if data, err = ioutil.ReadAll(resp.Body); err != nil {
+			return nil, fmt.Errorf("reading key set: %w", err)
+		}
+	} else {
+		f, err := os.Open(ks.source)
+		if err != nil {
+			return nil, fmt.Errorf("opening key set: %w", err)
+		}
+		defer f.Close()
+
+		if data, err = ioutil.ReadAll(f); err != nil {
+			return nil, fmt.Errorf("reading key set: %w", err)
+		}
+	}
+
+	var set JWKS
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("decoding key set: %w", err)
+	}
+	if len(set.Keys) == 0 {
+		return nil, errors.New("key set holds no keys")
+	}
+
+	return set.PublicKeys()
+}
@@ -0,0 +1,201 @@
+package auth_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
+)
+
+func newAuthenticator(t *testing.T, kid string, lookup auth.KeyLookupFunc) (*auth.Authenticator, *rsa.PrivateKey) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lookup == nil {
+		lookup = auth.NewSimpleKeyLookupFunc(kid, &key.PublicKey)
+	}
+	a, err := auth.NewAuthenticator(key, kid, "RS256", lookup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a, key
+}
+
+func TestJWKSRoundTrip(t *testing.T) {
+	issuer, key := newAuthenticator(t, "issuer-1", nil)
+
+	data, err := json.Marshal(issuer.JWKS())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var set auth.JWKS
+	if err := json.Unmarshal(data, &set); err != nil {
+		t.Fatal(err)
+	}
+	if len(set.Keys) != 1 || set.Keys[0].Kid != "issuer-1" || set.Keys[0].Alg != "RS256" {
+		t.Fatalf("got key set %+v", set)
+	}
+
+	keys, err := set.PublicKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := keys["issuer-1"]
+	if got == nil || got.N.Cmp(key.N) != 0 || got.E != key.E {
+		t.Fatal("decoded key does not match the issuer's key")
+	}
+}
+
+func TestKeySetURL(t *testing.T) {
+	issuer, _ := newAuthenticator(t, "issuer-1", nil)
+
+	var hits int32
+	var failing int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		if atomic.LoadInt32(&failing) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(issuer.JWKS())
+	}))
+	defer srv.Close()
+
+	t.Run("Verify", func(t *testing.T) {
+		ks := auth.NewKeySet(srv.URL, nil, time.Hour)
+		verifier, _ := newAuthenticator(t, "verifier-1", ks.Lookup)
+
+		tkn, err := issuer.GenerateToken(auth.NewClaims("user-1", []string{auth.RoleUser}, time.Now(), time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		claims, err := verifier.ParseClaims(tkn)
+		if err != nil {
+			t.Fatalf("verifying issuer token: %v", err)
+		}
+		if claims.Subject != "user-1" {
+			t.Fatalf("got subject %q, want user-1", claims.Subject)
+		}
+	})
+
+	t.Run("Cached", func(t *testing.T) {
+		atomic.StoreInt32(&hits, 0)
+		ks := auth.NewKeySet(srv.URL, nil, time.Hour)
+
+		for i := 0; i < 3; i++ {
+			if _, err := ks.Lookup("issuer-1"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := ks.Lookup("unknown"); err == nil {
+			t.Fatal("looking up an unknown key id should fail")
+		}
+
+		if n := atomic.LoadInt32(&hits); n != 1 {
+			t.Fatalf("key set fetched %d times, want 1", n)
+		}
+	})
+
+	t.Run("StaleOnError", func(t *testing.T) {
+		ks := auth.NewKeySet(srv.URL, nil, 0)
+		if _, err := ks.Lookup("issuer-1"); err != nil {
+			t.Fatal(err)
+		}
+
+		atomic.StoreInt32(&failing, 1)
+		defer atomic.StoreInt32(&failing, 0)
+
+		if _, err := ks.Lookup("issuer-1"); err != nil {
+			t.Fatalf("cached key should be used while the source fails: %v", err)
+		}
+	})
+}
+
+func TestKeySetSlowSource(t *testing.T) {
+	issuer, _ := newAuthenticator(t, "issuer-1", nil)
+
+	var hits int32
+	gate := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The first fetch is served at once; the rest wait for the gate.
+		if atomic.AddInt32(&hits, 1) > 1 {
+			<-gate
+		}
+		json.NewEncoder(w).Encode(issuer.JWKS())
+	}))
+	defer srv.Close()
+	defer close(gate)
+
+	ks := auth.NewKeySet(srv.URL, nil, 0)
+	if _, err := ks.Lookup("issuer-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The key is now stale. The lookup that refetches it blocks on the
+	// source, but the cached key keeps being served to everyone else.
+	go ks.Lookup("issuer-1")
+	for atomic.LoadInt32(&hits) < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := ks.Lookup("issuer-1")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("looking up a cached key during a fetch: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("lookup of a cached key waited for the fetch")
+	}
+
+	if n := atomic.LoadInt32(&hits); n != 2 {
+		t.Fatalf("key set fetched %d times, want 2", n)
+	}
+}
+
+func TestKeySetFile(t *testing.T) {
+	issuer, _ := newAuthenticator(t, "issuer-1", nil)
+
+	dir, err := ioutil.TempDir("", "jwks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	data, err := json.Marshal(issuer.JWKS())
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "jwks.json")
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	ks := auth.NewKeySet(path, nil, time.Hour)
+	lookup := auth.ChainKeyLookupFuncs(auth.NewSimpleKeyLookupFunc("local", nil), ks.Lookup)
+
+	if _, err := lookup("issuer-1"); err != nil {
+		t.Fatalf("looking up issuer key: %v", err)
+	}
+	if _, err := lookup("other"); err == nil {
+		t.Fatal("looking up an unknown key id should fail")
+	}
+}
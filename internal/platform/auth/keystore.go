@@ -0,0 +1,238 @@
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// keySource holds the keys an Authenticator signs with and publishes.
+type keySource interface {
+	ActiveKey() (string, *rsa.PrivateKey)
+	PublicKeys() map[string]*rsa.PublicKey
+}
+
+// singleKey is the keySource of an Authenticator built from one private key.
+type singleKey struct {
+	kid string
+	key *rsa.PrivateKey
+}
+
+func (s singleKey) ActiveKey() (string, *rsa.PrivateKey) {
+	return s.kid, s.key
+}
+
+func (s singleKey) PublicKeys() map[string]*rsa.PublicKey {
+	return map[string]*rsa.PublicKey{s.kid: &s.key.PublicKey}
+}
+
+// activeFile is the file in a KeyStore directory holding the ID of the
+// active key.
+const activeFile = "active"
+
+// KeyStore holds the private keys in a directory of PEM files, each named for
+// its key ID, e.g. 2020-03.pem holds the key with ID 2020-03. Tokens are signed
+// with the active key and verified with any key in the directory, so keys are
+// rotated by adding the new key, making it active and removing the old one
+// once the tokens it signed have expired.
+//
+// The active key is the one named in the file called active in the
+// directory, or the key the store was made with when there is no such file.
+//
+// Reload reads the directory again. The keys in use are only replaced once
+// the whole directory has loaded, so a bad file never leaves the store empty
+// or without its active key.
+type KeyStore struct {
+	dir        string
+	defaultKID string
+
+	mu        sync.RWMutex
+	activeKID string
+	keys      map[string]*rsa.PrivateKey
+	sum       string
+}
+
+// NewKeyStore loads the keys in dir. The active key is named by the active
+// file in dir or, failing that, by activeKID.
+func NewKeyStore(dir, activeKID string) (*KeyStore, error) {
+	ks := KeyStore{dir: dir, defaultKID: activeKID}
+	if err := ks.Reload(); err != nil {
+		return nil, err
+	}
+	return &ks, nil
+}
+
+// ActiveKID returns the ID of the key tokens are signed with.
+func (ks *KeyStore) ActiveKID() string {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	return ks.activeKID
+}
+
+// ActiveKey returns the key tokens are signed with along with its ID.
+func (ks *KeyStore) ActiveKey() (string, *rsa.PrivateKey) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	return ks.activeKID, ks.keys[ks.activeKID]
+}
+
+// Reload replaces the keys with those now in the directory.
+func (ks *KeyStore) Reload() error {
+	files, sum, err := ks.scan()
+	if err != nil {
+		return err
+	}
+
+	activeKID := ks.defaultKID
+	switch b, err := ioutil.ReadFile(filepath.Join(ks.dir, activeFile)); {
+	case err == nil:
+		activeKID = strings.TrimSpace(string(b))
+	case !os.IsNotExist(err):
+		return fmt.Errorf("reading active key id: %w", err)
+	}
+	if activeKID == "" {
+		return errors.New("active kid cannot be blank")
+	}
+
+	keys := make(map[string]*rsa.PrivateKey, len(files))
+	for kid, path := range files {
+		pem, err := ioutil.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("reading key %q: %w", kid, err)
+		}
+		key, err := jwt.ParseRSAPrivateKeyFromPEM(pem)
+		if err != nil {
+			return fmt.Errorf("parsing key %q: %w", kid, err)
+		}
+		keys[kid] = key
+	}
+
+	if _, ok := keys[activeKID]; !ok {
+		return fmt.Errorf("active key %q not found in %s", activeKID, ks.dir)
+	}
+
+	ks.mu.Lock()
+	ks.activeKID = activeKID
+	ks.keys = keys
+	ks.sum = sum
+	ks.mu.Unlock()
+
+	return nil
+}
+
+// Watch reloads the keys whenever the files in the directory change, checking
+// every interval until ctx is done. Failed reloads are passed to onError and
+// the keys already loaded stay in use.
+func (ks *KeyStore) Watch(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		_, sum, err := ks.scan()
+		if err == nil {
+			ks.mu.RLock()
+			changed := sum != ks.sum
+			ks.mu.RUnlock()
+			if !changed {
+				continue
+			}
+			err = ks.Reload()
+		}
+		if err != nil {
+			onError(err)
+		}
+	}
+}
+
+// scan returns the path of each key file by key ID along with a summary of
+// their names, sizes and modification times, and those of the active file,
+// that changes when any of them do.
+func (ks *KeyStore) scan() (map[string]string, string, error) {
+	infos, err := ioutil.ReadDir(ks.dir)
+	if err != nil {
+		return nil, "", fmt.Errorf("reading key directory: %w", err)
+	}
+
+	files := make(map[string]string)
+	var sum []string
+	for _, fi := range infos {
+		name := fi.Name()
+		isKey := !strings.HasPrefix(name, ".") && filepath.Ext(name) == ".pem"
+		if !isKey && name != activeFile {
+			continue
+		}
+
+		path := filepath.Join(ks.dir, name)
+
+		// Follow symlinks, as mounted secrets are usually links to the
+		// current version of each file.
+		if fi.Mode()&os.ModeSymlink != 0 {
+			if fi, err = os.Stat(path); err != nil {
+				return nil, "", fmt.Errorf("reading key directory: %w", err)
+			}
+		}
+		if !fi.Mode().IsRegular() {
+			continue
+		}
+
+		if isKey {
+			files[strings.TrimSuffix(name, ".pem")] = path
+		}
+		sum = append(sum, fmt.Sprintf("%s:%d:%d", name, fi.Size(), fi.ModTime().UnixNano()))
+	}
+	sort.Strings(sum)
+
+	return files, strings.Join(sum, ","), nil
+}
+
+// PrivateKey returns the key with the ID kid.
+func (ks *KeyStore) PrivateKey(kid string) (*rsa.PrivateKey, error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unrecognized key id %q", kid)
+	}
+	return key, nil
+}
+
+// PublicKey returns the public half of the key with the ID kid. It is a
+// KeyLookupFunc.
+func (ks *KeyStore) PublicKey(kid string) (*rsa.PublicKey, error) {
+	key, err := ks.PrivateKey(kid)
+	if err != nil {
+		return nil, err
+	}
+	return &key.PublicKey, nil
+}
+
+// PublicKeys returns the public half of every key by key ID.
+func (ks *KeyStore) PublicKeys() map[string]*rsa.PublicKey {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	keys := make(map[string]*rsa.PublicKey, len(ks.keys))
+	for kid, key := range ks.keys {
+		keys[kid] = &key.PublicKey
+	}
+	return keys
+}
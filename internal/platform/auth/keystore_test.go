@@ -0,0 +1,179 @@
+package auth_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
+)
+
+func writeKey(t *testing.T, dir, kid string) *rsa.PrivateKey {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block := pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}
+	if err := ioutil.WriteFile(filepath.Join(dir, kid+".pem"), pem.EncodeToMemory(&block), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestKeyStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	old := writeKey(t, dir, "2020-01")
+	writeKey(t, dir, "2020-02")
+	if err := ioutil.WriteFile(filepath.Join(dir, "README"), []byte("not a key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	ks, err := auth.NewKeyStore(dir, "2020-02")
+	if err != nil {
+		t.Fatalf("loading keys: %v", err)
+	}
+
+	a, err := auth.NewKeyStoreAuthenticator(ks, "RS256", ks.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims := auth.NewClaims("user-1", []string{auth.RoleUser}, time.Now(), time.Hour)
+
+	t.Run("SignWithActive", func(t *testing.T) {
+		tkn, err := a.GenerateToken(claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := a.ParseClaims(tkn); err != nil {
+			t.Fatalf("verifying: %v", err)
+		}
+
+		set := a.JWKS()
+		if len(set.Keys) != 2 || set.Keys[0].Kid != "2020-01" || set.Keys[1].Kid != "2020-02" {
+			t.Fatalf("expected both keys published, got %+v", set.Keys)
+		}
+	})
+
+	t.Run("VerifyOlder", func(t *testing.T) {
+		issuer, err := auth.NewAuthenticator(old, "2020-01", "RS256", auth.NewSimpleKeyLookupFunc("2020-01", &old.PublicKey))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tkn, err := issuer.GenerateToken(claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := a.ParseClaims(tkn); err != nil {
+			t.Fatalf("verifying token signed with older key: %v", err)
+		}
+	})
+
+	t.Run("BadReloadKeepsKeys", func(t *testing.T) {
+		bad := filepath.Join(dir, "broken.pem")
+		if err := ioutil.WriteFile(bad, []byte("garbage"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(bad)
+
+		if err := ks.Reload(); err == nil {
+			t.Fatal("reloading a directory with a bad key should fail")
+		}
+		if _, err := a.GenerateToken(claims); err != nil {
+			t.Fatalf("signing after failed reload: %v", err)
+		}
+	})
+
+	t.Run("ActiveFile", func(t *testing.T) {
+		active := filepath.Join(dir, "active")
+		if err := ioutil.WriteFile(active, []byte("2020-01\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			os.Remove(active)
+			if err := ks.Reload(); err != nil {
+				t.Fatal(err)
+			}
+		}()
+
+		if err := ks.Reload(); err != nil {
+			t.Fatalf("reloading: %v", err)
+		}
+		if kid := ks.ActiveKID(); kid != "2020-01" {
+			t.Fatalf("active key is %q, want 2020-01", kid)
+		}
+
+		tkn, err := a.GenerateToken(claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		verifier, err := auth.NewAuthenticator(old, "2020-01", "RS256", auth.NewSimpleKeyLookupFunc("2020-01", &old.PublicKey))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := verifier.ParseClaims(tkn); err != nil {
+			t.Fatalf("token not signed with the new active key: %v", err)
+		}
+
+		// Naming a key that is not there keeps the active key in use.
+		if err := ioutil.WriteFile(active, []byte("2020-09"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := ks.Reload(); err == nil {
+			t.Fatal("reloading with a missing active key should fail")
+		}
+		if kid := ks.ActiveKID(); kid != "2020-01" {
+			t.Fatalf("active key is %q after failed reload, want 2020-01", kid)
+		}
+	})
+
+	t.Run("MissingActiveKey", func(t *testing.T) {
+		if _, err := auth.NewKeyStore(dir, "2020-03"); err == nil {
+			t.Fatal("loading without the active key should fail")
+		}
+	})
+
+	t.Run("Watch", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		errs := make(chan error, 10)
+		go ks.Watch(ctx, 10*time.Millisecond, func(err error) { errs <- err })
+
+		// Keep modification times apart on filesystems with coarse
+		// timestamps.
+		time.Sleep(20 * time.Millisecond)
+		writeKey(t, dir, "2020-03")
+
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			if _, err := ks.PublicKey("2020-03"); err == nil {
+				break
+			}
+			select {
+			case err := <-errs:
+				t.Fatalf("reloading: %v", err)
+			default:
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("new key was not picked up")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+}
@@ -0,0 +1,73 @@
+package auth
+
+// Permissions are what roles grant. A token carries the permissions of its
+// roles when it is issued, and routes require a permission rather than a
+// role so roles can be redefined without changing code.
+const (
+	// PermProductsWrite allows adding products and editing those you own.
+	PermProductsWrite = "products:write"
+
+	// PermProductsAdmin allows editing any product, deleting products and
+	// importing them in bulk.
+	PermProductsAdmin = "products:admin"
+
+	// PermSalesCreate allows recording sales.
+	PermSalesCreate = "sales:create"
+
+	// PermSalesAdmin allows overriding sale prices, importing and exporting
+	// sales, and seeing customer details.
+	PermSalesAdmin = "sales:admin"
+
+	// PermInventoryAdmin allows managing warehouses, stock and transfers.
+	PermInventoryAdmin = "inventory:admin"
+
+	// PermCatalogAdmin allows managing categories.
+	PermCatalogAdmin = "catalog:admin"
+
+	// PermPricingAdmin allows managing exchange rates, tax rates and
+	// promotions.
+	PermPricingAdmin = "pricing:admin"
+
+	// PermReportsRead allows reading reports.
+	PermReportsRead = "reports:read"
+
+	// PermWebhooksAdmin allows managing webhook subscriptions.
+	PermWebhooksAdmin = "webhooks:admin"
+
+	// PermUsersAdmin allows managing users, API keys and role definitions.
+	PermUsersAdmin = "users:admin"
+)
+
+// Permissions lists every permission a role may grant.
+var Permissions = []string{
+	PermProductsWrite,
+	PermProductsAdmin,
+	PermSalesCreate,
+	PermSalesAdmin,
+	PermInventoryAdmin,
+	PermCatalogAdmin,
+	PermPricingAdmin,
+	PermReportsRead,
+	PermWebhooksAdmin,
+	PermUsersAdmin,
+}
+
+// KnownPermission reports whether p is one of Permissions.
+func KnownPermission(p string) bool {
+	for _, known := range Permissions {
+		if p == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Can reports whether the claims grant the permission p.
+func (c Claims) Can(p string) bool {
+	for _, has := range c.Permissions {
+		if has == p {
+			return true
+		}
+	}
+	return false
+}
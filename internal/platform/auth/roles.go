@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
 )
 
 const (
@@ -15,8 +16,13 @@ type ctxKey int
 
 const Key ctxKey = 1
 
+// Claims are what a token says about its holder. Permissions are those
+// granted by Roles when the token was issued, and Tenant is the shop whose
+// data the holder works with.
 type Claims struct {
-	Roles []string `json:"roles"`
+	Roles       []string `json:"roles"`
+	Permissions []string `json:"permissions,omitempty"`
+	Tenant      string   `json:"tenant,omitempty"`
 	jwt.StandardClaims
 }
 
@@ -31,10 +37,13 @@ func (c Claims) HasRole(roles ...string) bool {
 	return false
 }
 
+// NewClaims returns the claims of a token for subject expiring after expires.
+// Each token gets a unique ID, its jti, by which it can be revoked.
 func NewClaims(subject string, roles []string, now time.Time, expires time.Duration) Claims {
 	c := Claims{
 		Roles: roles,
 		StandardClaims: jwt.StandardClaims{
+			Id:        uuid.New().String(),
 			Subject:   subject,
 			IssuedAt:  now.Unix(),
 			ExpiresAt: now.Add(expires).Unix(),
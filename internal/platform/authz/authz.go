@@ -0,0 +1,133 @@
+// Package authz decides whether the holder of a set of claims may act on a
+// resource. The policies are declared once in policies.go and every domain
+// package asks Check rather than comparing roles and owners itself, so a
+// refusal can always say which rules it was weighed against.
+package authz
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
+)
+
+// ErrForbidden is matched by every *Denied with errors.Is.
+var ErrForbidden = errors.New("attempted action is not allowed")
+
+// Kind names a type of resource.
+type Kind string
+
+const (
+	Product Kind = "product"
+	Sale    Kind = "sale"
+	User    Kind = "user"
+)
+
+// Action is something done to a resource.
+type Action string
+
+const (
+	Create        Action = "create"
+	Edit          Action = "edit"
+	Delete        Action = "delete"
+	OverridePrice Action = "override_price"
+)
+
+// Resource is what an action is done to. OwnerID is the subject the
+// resource belongs to, if any.
+type Resource struct {
+	Kind    Kind
+	ID      string
+	OwnerID string
+}
+
+// Rule allows an action when Allows holds. Describe says in words who it
+// allows, for explaining decisions.
+type Rule struct {
+	Describe string
+	Allows   func(claims auth.Claims, r Resource) bool
+}
+
+// Permission allows holders of the permission p.
+func Permission(p string) Rule {
+	return Rule{
+		Describe: "holds " + p,
+		Allows: func(claims auth.Claims, r Resource) bool {
+			return claims.Can(p)
+		},
+	}
+}
+
+// Owner allows the owner of the resource if they hold the permission p.
+func Owner(p string) Rule {
+	return Rule{
+		Describe: "owns it and holds " + p,
+		Allows: func(claims auth.Claims, r Resource) bool {
+			return r.OwnerID != "" && r.OwnerID == claims.Subject && claims.Can(p)
+		},
+	}
+}
+
+// Decision is the outcome of evaluating a policy. A denial lists what any
+// one of the rules considered would have needed.
+type Decision struct {
+	Action   Action   `json:"action"`
+	Kind     Kind     `json:"resource"`
+	ID       string   `json:"id,omitempty"`
+	Allowed  bool     `json:"allowed"`
+	Reason   string   `json:"reason"`
+	Requires []string `json:"requires,omitempty"`
+}
+
+// Denied is the error returned for a decision against the caller.
+type Denied struct {
+	Decision Decision
+}
+
+func (d *Denied) Error() string {
+	return fmt.Sprintf("not allowed to %s %s: %s", strings.Replace(string(d.Decision.Action), "_", " ", -1), d.Decision.Kind, d.Decision.Reason)
+}
+
+// Is reports ErrForbidden as matching.
+func (d *Denied) Is(target error) bool {
+	return target == ErrForbidden
+}
+
+// Evaluate decides whether claims allow action on r. The admin permission of
+// the resource kind allows any action; otherwise the first rule of the
+// policy that holds does. With no policy for the action nothing is allowed.
+func Evaluate(claims auth.Claims, action Action, r Resource) Decision {
+	d := Decision{Action: action, Kind: r.Kind, ID: r.ID}
+
+	rules := policies[r.Kind][action]
+	if p, ok := admins[r.Kind]; ok {
+		rules = append([]Rule{Permission(p)}, rules...)
+	}
+
+	for _, rule := range rules {
+		if rule.Allows(claims, r) {
+			d.Allowed = true
+			d.Reason = "allowed as the caller " + rule.Describe
+			return d
+		}
+		d.Requires = append(d.Requires, rule.Describe)
+	}
+
+	switch len(d.Requires) {
+	case 0:
+		d.Reason = "no policy allows it"
+	default:
+		d.Reason = "the caller must be one who " + strings.Join(d.Requires, ", or one who ")
+	}
+	return d
+}
+
+// Check returns a *Denied unless claims allow action on r.
+func Check(claims auth.Claims, action Action, r Resource) error {
+	d := Evaluate(claims, action, r)
+	if !d.Allowed {
+		return &Denied{Decision: d}
+	}
+	return nil
+}
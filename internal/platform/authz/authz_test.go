@@ -0,0 +1,104 @@
+package authz_test
+
+import (
+	"errors"
+	"testing"
+
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/authz"
+)
+
+func TestPolicies(t *testing.T) {
+	const (
+		alice = "718ffbea-f4a1-4667-8ae3-b349da52675e"
+		bob   = "45b5fbd3-755f-4379-8f07-a58d4a30fa2f"
+	)
+
+	claims := func(subject string, perms ...string) auth.Claims {
+		c := auth.Claims{Permissions: perms}
+		c.Subject = subject
+		return c
+	}
+
+	owner := claims(alice, auth.PermProductsWrite)
+	other := claims(bob, auth.PermProductsWrite)
+	productAdmin := claims(bob, auth.PermProductsAdmin)
+	clerk := claims(bob, auth.PermSalesCreate)
+	salesAdmin := claims(bob, auth.PermSalesAdmin)
+	userAdmin := claims(bob, auth.PermUsersAdmin)
+	nobody := claims(alice)
+
+	alicesProduct := authz.Resource{Kind: authz.Product, ID: "a2b0639f-2cc6-44b8-b97b-15d69dbb511e", OwnerID: alice}
+	newProduct := authz.Resource{Kind: authz.Product}
+	sale := authz.Resource{Kind: authz.Sale}
+	user := authz.Resource{Kind: authz.User}
+
+	tests := []struct {
+		name     string
+		claims   auth.Claims
+		action   authz.Action
+		resource authz.Resource
+		allowed  bool
+	}{
+		{"writer creates product", other, authz.Create, newProduct, true},
+		{"nobody creates product", nobody, authz.Create, newProduct, false},
+		{"owner edits product", owner, authz.Edit, alicesProduct, true},
+		{"other writer edits product", other, authz.Edit, alicesProduct, false},
+		{"owner without permission edits product", nobody, authz.Edit, alicesProduct, false},
+		{"admin edits product", productAdmin, authz.Edit, alicesProduct, true},
+		{"owner deletes product", owner, authz.Delete, alicesProduct, false},
+		{"admin deletes product", productAdmin, authz.Delete, alicesProduct, true},
+		{"product admin sells", productAdmin, authz.Create, sale, false},
+		{"clerk sells", clerk, authz.Create, sale, true},
+		{"clerk overrides price", clerk, authz.OverridePrice, sale, false},
+		{"sales admin overrides price", salesAdmin, authz.OverridePrice, sale, true},
+		{"writer creates user", owner, authz.Create, user, false},
+		{"user admin creates user", userAdmin, authz.Create, user, true},
+		{"unknown kind", userAdmin, authz.Edit, authz.Resource{Kind: "invoice"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := authz.Evaluate(tt.claims, tt.action, tt.resource)
+			if d.Allowed != tt.allowed {
+				t.Fatalf("got allowed %v, want %v: %s", d.Allowed, tt.allowed, d.Reason)
+			}
+			if d.Reason == "" {
+				t.Error("decision has no reason")
+			}
+
+			err := authz.Check(tt.claims, tt.action, tt.resource)
+			if tt.allowed != (err == nil) {
+				t.Fatalf("Check returned %v for a decision of %v", err, tt.allowed)
+			}
+			if err != nil && !errors.Is(err, authz.ErrForbidden) {
+				t.Fatalf("got %v, want it to match %v", err, authz.ErrForbidden)
+			}
+		})
+	}
+}
+
+func TestExplain(t *testing.T) {
+	c := auth.Claims{Permissions: []string{auth.PermProductsWrite}}
+	c.Subject = "45b5fbd3-755f-4379-8f07-a58d4a30fa2f"
+
+	r := authz.Resource{Kind: authz.Product, ID: "a2b0639f-2cc6-44b8-b97b-15d69dbb511e", OwnerID: "718ffbea-f4a1-4667-8ae3-b349da52675e"}
+
+	err := authz.Check(c, authz.Edit, r)
+
+	var denied *authz.Denied
+	if !errors.As(err, &denied) {
+		t.Fatalf("got %v, want a *Denied", err)
+	}
+
+	want := "not allowed to edit product: the caller must be one who holds products:admin, or one who owns it and holds products:write"
+	if err.Error() != want {
+		t.Fatalf("got %q, want %q", err.Error(), want)
+	}
+	if got := len(denied.Decision.Requires); got != 2 {
+		t.Fatalf("got %d requirements, want 2", got)
+	}
+	if denied.Decision.ID != r.ID {
+		t.Fatalf("decision is for %q, want %q", denied.Decision.ID, r.ID)
+	}
+}
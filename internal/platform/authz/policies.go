@@ -0,0 +1,27 @@
+package authz
+
+import "gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
+
+// admins maps each kind of resource to the permission allowing any action
+// on it.
+var admins = map[Kind]string{
+	Product: auth.PermProductsAdmin,
+	Sale:    auth.PermSalesAdmin,
+	User:    auth.PermUsersAdmin,
+}
+
+// policies lists the rules, other than the admin permission, allowing each
+// action. Actions left out, such as deleting products or anything to do
+// with users, are for admins only.
+var policies = map[Kind]map[Action][]Rule{
+	Product: {
+		// Anyone who may write products may add one, and may then edit it
+		// and its variants.
+		Create: {Permission(auth.PermProductsWrite)},
+		Edit:   {Owner(auth.PermProductsWrite)},
+	},
+	Sale: {
+		// Staff may sell; only sales admins may override the price.
+		Create: {Permission(auth.PermSalesCreate)},
+	},
+}
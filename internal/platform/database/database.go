@@ -2,6 +2,7 @@ package database
 
 import (
 	"context"
+	"fmt"
 	"net/url"
 
 	"github.com/jmoiron/sqlx"
@@ -47,3 +48,69 @@ func StatusCheck(ctx context.Context, db *sqlx.DB) error {
 
 	return db.QueryRowContext(ctx, q).Scan(&tmp)
 }
+
+// SetTenant makes tx act for tenantID: once row level security is enabled,
+// the tables holding tenants' rows only show it that tenant's for the rest
+// of the transaction. A transaction that sets no tenant sees none of them.
+func SetTenant(ctx context.Context, tx *sqlx.Tx, tenantID string) error {
+	const q = `SELECT set_config('app.tenant_id', $1, true)`
+	if _, err := tx.ExecContext(ctx, q, tenantID); err != nil {
+		return fmt.Errorf("setting tenant: %w", err)
+	}
+	return nil
+}
+
+// Bypass makes tx see the rows of every tenant for the rest of the
+// transaction. It is for the lookups that must work before the tenant is
+// known, such as finding a user by email to log them in.
+func Bypass(ctx context.Context, tx *sqlx.Tx) error {
+	const q = `SELECT set_config('app.bypass', 'on', true)`
+	if _, err := tx.ExecContext(ctx, q); err != nil {
+		return fmt.Errorf("bypassing tenants: %w", err)
+	}
+	return nil
+}
+
+// WithBypass runs fn in a transaction that sees every tenant's rows,
+// committing it if fn succeeds.
+func WithBypass(ctx context.Context, db *sqlx.DB, fn func(tx *sqlx.Tx) error) error {
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := Bypass(ctx, tx); err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing transaction: %w", err)
+	}
+	return nil
+}
+
+// WithTenant runs fn in a transaction acting for tenantID, committing it if
+// fn succeeds.
+func WithTenant(ctx context.Context, db *sqlx.DB, tenantID string, fn func(tx *sqlx.Tx) error) error {
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := SetTenant(ctx, tx, tenantID); err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing transaction: %w", err)
+	}
+	return nil
+}
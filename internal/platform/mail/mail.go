@@ -0,0 +1,132 @@
+// Package mail sends the emails the service writes to its users. Mail goes
+// through a Mailer so it can be sent by SMTP in production and kept in
+// memory in tests.
+package mail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opencensus.io/trace"
+)
+
+// ErrInvalidHeader is returned for messages whose addresses or subject
+// would break out of their header.
+var ErrInvalidHeader = errors.New("mail header contains a line break")
+
+// Message is a plain text email to one recipient.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends messages.
+type Mailer interface {
+	Send(ctx context.Context, m Message) error
+}
+
+// MailerFunc adapts a function to a Mailer.
+type MailerFunc func(ctx context.Context, m Message) error
+
+// Send calls f.
+func (f MailerFunc) Send(ctx context.Context, m Message) error {
+	return f(ctx, m)
+}
+
+// Log returns a Mailer that writes each message to log instead of sending
+// it, for development without a mail server.
+func Log(log *log.Logger) Mailer {
+	return MailerFunc(func(ctx context.Context, m Message) error {
+		log.Printf("mail : to %s: %s\n%s", m.To, m.Subject, m.Body)
+		return nil
+	})
+}
+
+type Config struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// SMTP sends messages through an SMTP server, authenticating with PLAIN
+// when a username is configured. The connection is upgraded with STARTTLS
+// when the server offers it.
+type SMTP struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+// NewSMTP returns a Mailer sending through the server in cfg.
+func NewSMTP(cfg Config) *SMTP {
+	s := SMTP{
+		addr: net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)),
+		from: cfg.From,
+	}
+	if cfg.Username != "" {
+		s.auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+	return &s
+}
+
+// Send sends m.
+func (s *SMTP) Send(ctx context.Context, m Message) error {
+	_, span := trace.StartSpan(ctx, "platform.SMTP.Send")
+	defer span.End()
+
+	for _, h := range []string{s.from, m.To, m.Subject} {
+		if strings.ContainsAny(h, "\r\n") {
+			return ErrInvalidHeader
+		}
+	}
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", s.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", m.To)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", m.Subject)
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("\r\n")
+	msg.WriteString(strings.ReplaceAll(m.Body, "\n", "\r\n"))
+
+	if err := smtp.SendMail(s.addr, s.auth, s.from, []string{m.To}, []byte(msg.String())); err != nil {
+		return fmt.Errorf("sending mail to %s: %w", m.To, err)
+	}
+	return nil
+}
+
+// Memory keeps the messages sent to it rather than sending them. It is safe
+// for concurrent use.
+type Memory struct {
+	mu   sync.Mutex
+	sent []Message
+}
+
+// Send keeps m.
+func (mem *Memory) Send(ctx context.Context, m Message) error {
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+
+	mem.sent = append(mem.sent, m)
+	return nil
+}
+
+// Sent returns the messages sent so far, oldest first.
+func (mem *Memory) Sent() []Message {
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+
+	return append([]Message(nil), mem.sent...)
+}
@@ -0,0 +1,38 @@
+package mail_test
+
+import (
+	"context"
+	"testing"
+
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/mail"
+)
+
+func TestMemory(t *testing.T) {
+	var m mail.Memory
+
+	msg := mail.Message{To: "user@example.com", Subject: "Hello", Body: "Hi there.\n"}
+	if err := m.Send(context.Background(), msg); err != nil {
+		t.Fatalf("sending: %s", err)
+	}
+
+	sent := m.Sent()
+	if len(sent) != 1 || sent[0] != msg {
+		t.Fatalf("got %+v, want the message sent", sent)
+	}
+
+	// The messages returned are a copy.
+	sent[0].To = "other@example.com"
+	if m.Sent()[0].To != msg.To {
+		t.Fatal("changing the messages returned changed those kept")
+	}
+}
+
+func TestSMTPHeaders(t *testing.T) {
+	s := mail.NewSMTP(mail.Config{Host: "localhost", Port: 25, From: "sales@example.com"})
+
+	// The message is refused before any connection is made.
+	msg := mail.Message{To: "user@example.com", Subject: "Hello\r\nBcc: everyone@example.com"}
+	if err := s.Send(context.Background(), msg); err != mail.ErrInvalidHeader {
+		t.Fatalf("got %v, want %v", err, mail.ErrInvalidHeader)
+	}
+}
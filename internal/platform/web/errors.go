@@ -8,14 +8,18 @@ type FieldError struct {
 }
 
 type ErrorResponse struct {
-	Error  string       `json:"error"`
-	Fields []FieldError `json:"fields,omitempty"`
+	Error   string       `json:"error"`
+	Fields  []FieldError `json:"fields,omitempty"`
+	Details interface{}  `json:"details,omitempty"`
 }
 
+// Error is an error to report to the client with Status. Details, when set,
+// is sent along to explain it.
 type Error struct {
-	Err    error
-	Status int
-	Fields []FieldError
+	Err     error
+	Status  int
+	Fields  []FieldError
+	Details interface{}
 }
 
 func NewRequestError(err error, status int) error {
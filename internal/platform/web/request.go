@@ -41,6 +41,12 @@ func Decode(r *http.Request, val interface{}) error {
 		return NewRequestError(err, http.StatusBadRequest)
 	}
 
+	return Validate(val)
+}
+
+// Validate checks val against its validate tags. Failures are returned as an
+// *Error listing each offending field.
+func Validate(val interface{}) error {
 	if err := validate.Struct(val); err != nil {
 		verrors, ok := err.(validator.ValidationErrors)
 		if !ok {
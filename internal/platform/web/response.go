@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"io"
 	"net/http"
 )
 
@@ -33,12 +34,51 @@ func Respond(ctx context.Context, w http.ResponseWriter, data interface{}, statu
 	return nil
 }
 
+// RespondRaw writes body as is for responses that are not JSON.
+func RespondRaw(ctx context.Context, w http.ResponseWriter, body []byte, contentType string, statusCode int) error {
+	v, ok := ctx.Value(KeyValues).(*Values)
+	if !ok {
+		return NewShutdownError("web value missing from context")
+	}
+	v.StatusCode = statusCode
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(statusCode)
+	if _, err := w.Write(body); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RespondStream sends the headers of a response whose body is too large to
+// hold in memory and then has write produce the body straight into w. An
+// error from write cuts the body short; it is returned for logging only.
+func RespondStream(ctx context.Context, w http.ResponseWriter, contentType string, statusCode int, write func(io.Writer) error) error {
+	v, ok := ctx.Value(KeyValues).(*Values)
+	if !ok {
+		return NewShutdownError("web value missing from context")
+	}
+	v.StatusCode = statusCode
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(statusCode)
+	v.Streamed = true
+
+	return write(w)
+}
+
 func RespondError(ctx context.Context, w http.ResponseWriter, err error) error {
+	if v, ok := ctx.Value(KeyValues).(*Values); ok && v.Streamed {
+		return nil
+	}
+
 	var webErr *Error
 	if errors.As(err, &webErr) {
 		er := ErrorResponse{
-			Error:  webErr.Err.Error(),
-			Fields: webErr.Fields,
+			Error:   webErr.Err.Error(),
+			Fields:  webErr.Fields,
+			Details: webErr.Details,
 		}
 		if err := Respond(ctx, w, er, webErr.Status); err != nil {
 			return err
@@ -22,6 +22,10 @@ type Values struct {
 	StatusCode int
 	Start      time.Time
 	TraceID    string
+
+	// Streamed is set once a streamed response has sent its headers, after
+	// which an error can no longer be reported to the client.
+	Streamed bool
 }
 
 type Handler func(context.Context, http.ResponseWriter, *http.Request) error
@@ -0,0 +1,3 @@
+// Package pos imports the sales recorded by point of sale systems in our
+// physical stores.
+package pos
@@ -0,0 +1,18 @@
+package pos
+
+// Report reconciles an import with the file it came from. Every line is
+// either imported, skipped as a Duplicate of a sale imported before, or
+// Rejected with the reason why.
+type Report struct {
+	Lines      int         `json:"lines"`
+	Imported   int         `json:"imported"`
+	Duplicates int         `json:"duplicates"`
+	Rejected   []Rejection `json:"rejected"`
+}
+
+// Rejection is a line that could not be imported.
+type Rejection struct {
+	Line      int    `json:"line"`
+	Reference string `json:"reference"`
+	Reason    string `json:"reason"`
+}
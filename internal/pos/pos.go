@@ -0,0 +1,283 @@
+package pos
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/money"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/database"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/web"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/product"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/promotion"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/warehouse"
+	"go.opencensus.io/trace"
+)
+
+// OverrideReason is recorded on imported sales that carry the price the till
+// charged.
+const OverrideReason = "imported from point of sale"
+
+var (
+	ErrBadHeader        = errors.New("header must name known columns including reference, quantity and one of product_id, sku or name")
+	ErrProductNotFound  = errors.New("no product matches the line")
+	ErrAmbiguousProduct = errors.New("more than one product has that name")
+	ErrProductMismatch  = errors.New("sku belongs to a different product")
+)
+
+// columns are the columns a POS file may have. Each line needs a reference
+// and a quantity, and names its product by product_id, sku or name, tried in
+// that order. date is when the sale was made and defaults to the time of
+// the import. paid is in minor units; when present it is kept as the price
+// instead of pricing the sale again.
+var columns = []string{"reference", "date", "product_id", "sku", "name", "quantity", "paid", "currency", "warehouse_id", "jurisdiction"}
+
+var dateLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02"}
+
+// Import records a sale for each line of the POS CSV file in r. Lines are
+// imported one at a time, so a rejected line does not hold up the others,
+// and a line whose reference was imported before is skipped. The returned
+// Report accounts for every line.
+func Import(ctx context.Context, db *sqlx.DB, user auth.Claims, r io.Reader, now time.Time) (*Report, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.pos.Import")
+	defer span.End()
+
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil, ErrBadHeader
+		}
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+
+	index, err := columnIndex(header)
+	if err != nil {
+		return nil, err
+	}
+
+	rep := Report{Rejected: []Rejection{}}
+
+	for line := 2; ; line++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		rep.Lines++
+
+		if err != nil {
+			var perr *csv.ParseError
+			if !errors.As(err, &perr) {
+				return nil, fmt.Errorf("reading line %d: %w", line, err)
+			}
+			rep.Rejected = append(rep.Rejected, Rejection{Line: line, Reason: perr.Err.Error()})
+			continue
+		}
+
+		field := func(name string) string {
+			i, ok := index[name]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+
+		err = importLine(ctx, db, user, field, now)
+		switch {
+		case err == nil:
+			rep.Imported++
+		case err == product.ErrDuplicateSale:
+			rep.Duplicates++
+		case isRejection(err):
+			rep.Rejected = append(rep.Rejected, Rejection{Line: line, Reference: field("reference"), Reason: err.Error()})
+		default:
+			return nil, fmt.Errorf("importing line %d: %w", line, err)
+		}
+	}
+
+	return &rep, nil
+}
+
+// lineError is a problem with the contents of a line.
+type lineError string
+
+func (e lineError) Error() string {
+	return string(e)
+}
+
+// isRejection reports whether err means the line was bad rather than that
+// the import itself failed.
+func isRejection(err error) bool {
+	var le lineError
+	var verr *web.Error
+	if errors.As(err, &le) || errors.As(err, &verr) {
+		return true
+	}
+
+	switch err {
+	case ErrProductNotFound, ErrAmbiguousProduct, ErrProductMismatch,
+		product.ErrInvalidID, product.ErrVariantNotFound,
+		warehouse.ErrInsufficientStock, warehouse.ErrNotFound,
+		money.ErrCurrencyMismatch, money.ErrUnknownCurrency,
+		promotion.ErrInvalidCoupon:
+		return true
+	}
+	return false
+}
+
+func importLine(ctx context.Context, db *sqlx.DB, user auth.Claims, field func(string) string, now time.Time) error {
+	ns := product.NewSale{
+		WarehouseID:  field("warehouse_id"),
+		Jurisdiction: field("jurisdiction"),
+		ExternalRef:  field("reference"),
+	}
+
+	if ns.ExternalRef == "" {
+		return lineError("reference is required")
+	}
+
+	// Skip lines imported before without pricing them again.
+	var exists bool
+	const q = `SELECT EXISTS (SELECT 1 FROM sales WHERE tenant_id = $1 AND external_ref = $2)`
+	err := database.WithTenant(ctx, db, user.Tenant, func(tx *sqlx.Tx) error {
+		return tx.GetContext(ctx, &exists, q, user.Tenant, ns.ExternalRef)
+	})
+	if err != nil {
+		return fmt.Errorf("checking reference: %w", err)
+	}
+	if exists {
+		return product.ErrDuplicateSale
+	}
+
+	if ns.Quantity, err = strconv.Atoi(field("quantity")); err != nil {
+		return lineError("quantity must be a whole number")
+	}
+
+	date := now
+	if d := field("date"); d != "" {
+		if date, err = parseDate(d); err != nil {
+			return err
+		}
+	}
+
+	if p := field("paid"); p != "" {
+		amount, err := strconv.ParseInt(p, 10, 64)
+		if err != nil {
+			return lineError("paid must be a whole number of minor units")
+		}
+		ns.Paid = &money.Money{Amount: amount, Currency: money.NormalizeCurrency(field("currency"))}
+		ns.OverrideReason = OverrideReason
+	}
+
+	var productID, variantID string
+	err = database.WithTenant(ctx, db, user.Tenant, func(tx *sqlx.Tx) error {
+		var err error
+		productID, variantID, err = match(ctx, tx, user.Tenant, field("product_id"), field("sku"), field("name"))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	ns.VariantID = variantID
+
+	if err := web.Validate(ns); err != nil {
+		return err
+	}
+
+	_, err = product.AddSale(ctx, db, user, ns, productID, date)
+	return err
+}
+
+// match finds the product of tenantID and variant a line is for. A SKU names
+// a variant directly; a product ID or name sells the product's first variant.
+func match(ctx context.Context, tx *sqlx.Tx, tenantID, productID, sku, name string) (string, string, error) {
+	if sku != "" {
+		var v struct {
+			ID        string `db:"variant_id"`
+			ProductID string `db:"product_id"`
+		}
+		const q = `SELECT v.variant_id, v.product_id
+			FROM variants AS v
+			JOIN products AS p ON p.product_id = v.product_id
+			WHERE v.sku = $1 AND p.tenant_id = $2`
+		if err := tx.GetContext(ctx, &v, q, sku, tenantID); err != nil {
+			if err == sql.ErrNoRows {
+				return "", "", ErrProductNotFound
+			}
+			return "", "", fmt.Errorf("matching sku: %w", err)
+		}
+		if productID != "" && productID != v.ProductID {
+			return "", "", ErrProductMismatch
+		}
+		return v.ProductID, v.ID, nil
+	}
+
+	if productID != "" {
+		return productID, "", nil
+	}
+
+	if name != "" {
+		var ids []string
+		const q = `SELECT product_id FROM products WHERE tenant_id = $1 AND LOWER(name) = LOWER($2) LIMIT 2`
+		if err := tx.SelectContext(ctx, &ids, q, tenantID, name); err != nil {
+			return "", "", fmt.Errorf("matching name: %w", err)
+		}
+		switch len(ids) {
+		case 0:
+			return "", "", ErrProductNotFound
+		case 1:
+			return ids[0], "", nil
+		default:
+			return "", "", ErrAmbiguousProduct
+		}
+	}
+
+	return "", "", ErrProductNotFound
+}
+
+func columnIndex(header []string) (map[string]int, error) {
+	known := make(map[string]bool)
+	for _, c := range columns {
+		known[c] = true
+	}
+
+	index := make(map[string]int)
+	for i, h := range header {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if !known[h] {
+			return nil, ErrBadHeader
+		}
+		index[h] = i
+	}
+
+	_, ref := index["reference"]
+	_, qty := index["quantity"]
+	_, id := index["product_id"]
+	_, sku := index["sku"]
+	_, name := index["name"]
+	if !ref || !qty || !(id || sku || name) {
+		return nil, ErrBadHeader
+	}
+
+	return index, nil
+}
+
+func parseDate(s string) (time.Time, error) {
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, lineError("date must be a date, a date and time, or an RFC 3339 timestamp")
+}
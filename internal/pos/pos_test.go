@@ -0,0 +1,96 @@
+package pos_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/pos"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/product"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/schema"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/tests"
+)
+
+func TestImport(t *testing.T) {
+	db, teardown := tests.NewUnit(t)
+	defer teardown()
+
+	if err := schema.Seed(db); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2019, time.April, 1, 0, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+
+	claims := auth.NewClaims(tests.AdminID, []string{auth.RoleAdmin}, now, time.Hour)
+	claims.Permissions = auth.Permissions
+	claims.Tenant = tests.TenantID
+
+	file := `reference,date,product_id,sku,name,quantity,paid
+T1-001,2019-03-31 10:15:00,a2b0639f-2cc6-44b8-b97b-15d69dbb511e,,,1,45
+T1-002,2019-03-31 10:20:00,,TOY-001,,2,
+T1-003,2019-03-31 11:00:00,,,comic books,1,
+T1-004,2019-03-31 11:05:00,,,Kites,1,
+T1-005,2019-03-31 11:10:00,,,Comic Books,many,
+T1-002,2019-03-31 10:20:00,,TOY-001,,2,
+T1-006,2019-03-31 11:15:00,,TOY-002,,1,
+`
+	rep, err := pos.Import(ctx, db, claims, strings.NewReader(file), now)
+	if err != nil {
+		t.Fatalf("importing: %s", err)
+	}
+
+	want := pos.Report{
+		Lines:      7,
+		Imported:   3,
+		Duplicates: 1,
+		Rejected: []pos.Rejection{
+			{Line: 5, Reference: "T1-004", Reason: pos.ErrProductNotFound.Error()},
+			{Line: 6, Reference: "T1-005", Reason: "quantity must be a whole number"},
+			{Line: 8, Reference: "T1-006", Reason: "insufficient stock"},
+		},
+	}
+	if diff := cmp.Diff(want, *rep); diff != "" {
+		t.Fatalf("report did not match:\n%s", diff)
+	}
+
+	sales, err := product.ListSales(ctx, db, tests.TenantID, "a2b0639f-2cc6-44b8-b97b-15d69dbb511e")
+	if err != nil {
+		t.Fatalf("listing sales: %s", err)
+	}
+	var till *product.Sale
+	for i := range sales {
+		if sales[i].ExternalRef != nil && *sales[i].ExternalRef == "T1-001" {
+			till = &sales[i]
+		}
+	}
+	if till == nil {
+		t.Fatal("expected the sale referenced T1-001 to be imported")
+	}
+	if exp, got := int64(45), till.Paid.Amount; exp != got {
+		t.Fatalf("expected the till price %v to be kept, got %v", exp, got)
+	}
+	if exp, got := time.Date(2019, time.March, 31, 10, 15, 0, 0, time.UTC), till.DateCreated; !exp.Equal(got) {
+		t.Fatalf("expected the sale dated %v, got %v", exp, got)
+	}
+
+	// Importing the same file again only finds duplicates and the lines that
+	// were rejected before.
+	rep, err = pos.Import(ctx, db, claims, strings.NewReader(file), now)
+	if err != nil {
+		t.Fatalf("importing again: %s", err)
+	}
+	if exp, got := 4, rep.Duplicates; exp != got {
+		t.Fatalf("expected %v duplicates, got %v", exp, got)
+	}
+	if exp, got := 0, rep.Imported; exp != got {
+		t.Fatalf("expected %v imported, got %v", exp, got)
+	}
+
+	if _, err := pos.Import(ctx, db, claims, strings.NewReader("reference,sku\nT2-001,TOY-001\n"), now); err != pos.ErrBadHeader {
+		t.Fatalf("expected a file without quantities to fail with %v, got %v", pos.ErrBadHeader, err)
+	}
+}
@@ -0,0 +1,241 @@
+package product
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"go.opencensus.io/trace"
+)
+
+// Formats products are imported and exported in.
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+)
+
+var (
+	ErrUnknownFormat = errors.New("format must be csv or ndjson")
+	ErrBadHeader     = errors.New("csv header must name known columns and include name")
+)
+
+// csvColumns are the columns of a product CSV file. Cost is in minor units
+// and tags are separated by "|".
+var csvColumns = []string{"name", "sku", "cost", "currency", "quantity", "warehouse_id", "category_id", "tags"}
+
+// ImportRow is one product read from an import file. Line counts the CSV
+// header as line 1 and assumes records do not span lines. Err is set when
+// the row could not be parsed, in which case Product is incomplete.
+type ImportRow struct {
+	Line    int
+	Product NewProduct
+	Err     error
+}
+
+// ReadImport reads the products in r. CSV files need a header row naming
+// their columns; NDJSON files hold one NewProduct object per line. Rows that
+// fail to parse are returned with Err set so every problem can be reported
+// at once. An error is only returned when the file as a whole is unusable.
+func ReadImport(r io.Reader, format string) ([]ImportRow, error) {
+	switch format {
+	case FormatCSV:
+		return readCSV(r)
+	case FormatNDJSON:
+		return readNDJSON(r)
+	default:
+		return nil, ErrUnknownFormat
+	}
+}
+
+func readCSV(r io.Reader) ([]ImportRow, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil, ErrBadHeader
+		}
+		return nil, fmt.Errorf("reading csv header: %w", err)
+	}
+
+	known := make(map[string]bool)
+	for _, c := range csvColumns {
+		known[c] = true
+	}
+	index := make(map[string]int)
+	for i, h := range header {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if !known[h] {
+			return nil, ErrBadHeader
+		}
+		index[h] = i
+	}
+	if _, ok := index["name"]; !ok {
+		return nil, ErrBadHeader
+	}
+
+	var rows []ImportRow
+	for line := 2; ; line++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var perr *csv.ParseError
+			if errors.As(err, &perr) {
+				rows = append(rows, ImportRow{Line: line, Err: perr.Err})
+				continue
+			}
+			return nil, fmt.Errorf("reading csv: %w", err)
+		}
+
+		field := func(name string) string {
+			i, ok := index[name]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+
+		row := ImportRow{Line: line}
+		np := &row.Product
+		np.Name = field("name")
+		np.SKU = field("sku")
+		np.Cost.Currency = field("currency")
+		np.WarehouseID = field("warehouse_id")
+		if c := field("category_id"); c != "" {
+			np.CategoryID = &c
+		}
+		if t := field("tags"); t != "" {
+			np.Tags = strings.Split(t, "|")
+		}
+		if c := field("cost"); c != "" {
+			if np.Cost.Amount, err = strconv.ParseInt(c, 10, 64); err != nil {
+				row.Err = errors.New("cost must be a whole number of minor units")
+			}
+		}
+		if q := field("quantity"); q != "" && row.Err == nil {
+			if np.Quantity, err = strconv.Atoi(q); err != nil {
+				row.Err = errors.New("quantity must be a whole number")
+			}
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+func readNDJSON(r io.Reader) ([]ImportRow, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var rows []ImportRow
+	for line := 1; sc.Scan(); line++ {
+		b := bytes.TrimSpace(sc.Bytes())
+		if len(b) == 0 {
+			continue
+		}
+
+		row := ImportRow{Line: line}
+
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&row.Product); err != nil {
+			row.Err = err
+		}
+
+		rows = append(rows, row)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("reading ndjson: %w", err)
+	}
+
+	return rows, nil
+}
+
+// Export writes every product of tenantID to w in a form ReadImport accepts,
+// so an export can be imported into another catalogue. Quantities are the
+// totals across all warehouses.
+func Export(ctx context.Context, db *sqlx.DB, tenantID string, w io.Writer, format string) error {
+	ctx, span := trace.StartSpan(ctx, "internal.product.Export")
+	defer span.End()
+
+	if format != FormatCSV && format != FormatNDJSON {
+		return ErrUnknownFormat
+	}
+
+	products, err := List(ctx, db, tenantID, Filter{})
+	if err != nil {
+		return err
+	}
+
+	// The product's first variant shares its ID and carries its SKU.
+	var skus []struct {
+		ID  string `db:"variant_id"`
+		SKU string `db:"sku"`
+	}
+	const q = `select variant_id, sku from variants where variant_id = product_id`
+	if err := db.SelectContext(ctx, &skus, q); err != nil {
+		return fmt.Errorf("selecting skus: %w", err)
+	}
+	sku := make(map[string]string)
+	for _, s := range skus {
+		sku[s.ID] = s.SKU
+	}
+
+	if format == FormatNDJSON {
+		enc := json.NewEncoder(w)
+		for _, p := range products {
+			np := NewProduct{
+				Name:       p.Name,
+				SKU:        sku[p.ID],
+				Cost:       p.Cost,
+				Quantity:   p.Quantity,
+				CategoryID: p.CategoryID,
+				Tags:       p.Tags,
+			}
+			if err := enc.Encode(np); err != nil {
+				return fmt.Errorf("writing ndjson: %w", err)
+			}
+		}
+		return nil
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvColumns); err != nil {
+		return fmt.Errorf("writing csv: %w", err)
+	}
+	for _, p := range products {
+		category := ""
+		if p.CategoryID != nil {
+			category = *p.CategoryID
+		}
+		record := []string{
+			p.Name,
+			sku[p.ID],
+			strconv.FormatInt(p.Cost.Amount, 10),
+			p.Cost.Currency,
+			strconv.Itoa(p.Quantity),
+			"",
+			category,
+			strings.Join(p.Tags, "|"),
+		}
+		if err := cw.Write(record); err != nil {
+			return fmt.Errorf("writing csv: %w", err)
+		}
+	}
+	cw.Flush()
+
+	return cw.Error()
+}
@@ -0,0 +1,68 @@
+package product_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/money"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/product"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/tests"
+)
+
+func TestReadImport(t *testing.T) {
+	books := "6e2c4d1a-9b3f-4a8e-b7c5-1d2e3f4a5b20"
+
+	csv := `name,sku,cost,currency,quantity,category_id,tags
+Atlas,ATLAS-1,1500,EUR,4,` + books + `,maps|paper
+Globe,,12.50,,1,,
+`
+	rows, err := product.ReadImport(strings.NewReader(csv), product.FormatCSV)
+	if err != nil {
+		t.Fatalf("reading csv: %s", err)
+	}
+	if exp, got := 2, len(rows); exp != got {
+		t.Fatalf("expected %v rows, got %v", exp, got)
+	}
+
+	want := product.NewProduct{
+		Name:       "Atlas",
+		SKU:        "ATLAS-1",
+		Cost:       money.Money{Amount: 1500, Currency: "EUR"},
+		Quantity:   4,
+		CategoryID: tests.StringPointer(books),
+		Tags:       []string{"maps", "paper"},
+	}
+	if diff := cmp.Diff(want, rows[0].Product); diff != "" {
+		t.Fatalf("first row did not match:\n%s", diff)
+	}
+	if rows[1].Err == nil || rows[1].Line != 3 {
+		t.Fatalf("expected a parse error on line 3, got %v on line %v", rows[1].Err, rows[1].Line)
+	}
+
+	if _, err := product.ReadImport(strings.NewReader("name,colour\nAtlas,blue\n"), product.FormatCSV); err != product.ErrBadHeader {
+		t.Fatalf("expected an unknown column to fail with %v, got %v", product.ErrBadHeader, err)
+	}
+
+	ndjson := `{"name":"Atlas","cost":1500,"quantity":4}
+
+{"name":"Globe","colour":"blue"}
+`
+	rows, err = product.ReadImport(strings.NewReader(ndjson), product.FormatNDJSON)
+	if err != nil {
+		t.Fatalf("reading ndjson: %s", err)
+	}
+	if exp, got := 2, len(rows); exp != got {
+		t.Fatalf("expected %v rows, got %v", exp, got)
+	}
+	if rows[0].Err != nil || rows[0].Product.Name != "Atlas" {
+		t.Fatalf("expected Atlas on the first line, got %+v", rows[0])
+	}
+	if rows[1].Err == nil || rows[1].Line != 3 {
+		t.Fatalf("expected an unknown field error on line 3, got %v on line %v", rows[1].Err, rows[1].Line)
+	}
+
+	if _, err := product.ReadImport(strings.NewReader(""), "xml"); err != product.ErrUnknownFormat {
+		t.Fatalf("expected xml to fail with %v, got %v", product.ErrUnknownFormat, err)
+	}
+}
@@ -0,0 +1,127 @@
+package product
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/money"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/authz"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/database"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/web"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/warehouse"
+	"go.opencensus.io/trace"
+)
+
+// ImportOptions control how Import treats the rows it is given. A DryRun
+// checks every row, including against the database, without keeping any of
+// them. An Atomic import keeps nothing unless every row succeeds.
+type ImportOptions struct {
+	DryRun bool
+	Atomic bool
+}
+
+// ImportResult reports what an import did. Created counts the products that
+// were kept, or that would have been in a dry run.
+type ImportResult struct {
+	Rows    int        `json:"rows"`
+	Created int        `json:"created"`
+	DryRun  bool       `json:"dry_run"`
+	Atomic  bool       `json:"atomic"`
+	Errors  []RowError `json:"errors"`
+}
+
+// RowError explains why a row was rejected. Fields lists the failed
+// validations when the row did not satisfy NewProduct's validate tags.
+type RowError struct {
+	Line   int              `json:"line"`
+	Error  string           `json:"error"`
+	Fields []web.FieldError `json:"fields,omitempty"`
+}
+
+// Import creates a product for each row that parses and passes the same
+// validation as a single product would. Every row is tried in one
+// transaction behind its own savepoint so a failing row does not stop the
+// rest from being checked.
+func Import(ctx context.Context, db *sqlx.DB, user auth.Claims, rows []ImportRow, opts ImportOptions, now time.Time) (*ImportResult, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.product.Import")
+	defer span.End()
+
+	if err := authz.Check(user, authz.Create, authz.Resource{Kind: authz.Product}); err != nil {
+		return nil, err
+	}
+
+	res := ImportResult{
+		Rows:   len(rows),
+		DryRun: opts.DryRun,
+		Atomic: opts.Atomic,
+		Errors: []RowError{},
+	}
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("starting import: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := database.SetTenant(ctx, tx, user.Tenant); err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		if row.Err != nil {
+			res.Errors = append(res.Errors, RowError{Line: row.Line, Error: row.Err.Error()})
+			continue
+		}
+
+		if err := web.Validate(row.Product); err != nil {
+			re := RowError{Line: row.Line, Error: err.Error()}
+			var verr *web.Error
+			if errors.As(err, &verr) {
+				re.Fields = verr.Fields
+			}
+			res.Errors = append(res.Errors, re)
+			continue
+		}
+
+		if _, err := tx.ExecContext(ctx, `SAVEPOINT import_row`); err != nil {
+			return nil, fmt.Errorf("starting import row: %w", err)
+		}
+
+		_, err := create(ctx, tx, user, row.Product, now)
+		switch err {
+		case nil:
+			if _, err := tx.ExecContext(ctx, `RELEASE SAVEPOINT import_row`); err != nil {
+				return nil, fmt.Errorf("finishing import row: %w", err)
+			}
+			res.Created++
+			continue
+		case ErrDuplicateSKU, ErrCategoryNotFound, warehouse.ErrNotFound, money.ErrUnknownCurrency:
+			res.Errors = append(res.Errors, RowError{Line: row.Line, Error: err.Error()})
+		default:
+			return nil, fmt.Errorf("importing line %d: %w", row.Line, err)
+		}
+
+		if _, err := tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT import_row`); err != nil {
+			return nil, fmt.Errorf("undoing import row: %w", err)
+		}
+	}
+
+	if opts.Atomic && len(res.Errors) > 0 {
+		res.Created = 0
+		return &res, nil
+	}
+
+	if opts.DryRun {
+		return &res, nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("committing import: %w", err)
+	}
+
+	return &res, nil
+}
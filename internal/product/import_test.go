@@ -0,0 +1,99 @@
+package product_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/product"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/tests"
+)
+
+func TestImport(t *testing.T) {
+	db, teardown := tests.NewUnit(t)
+	defer teardown()
+
+	now := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+
+	claims := auth.NewClaims(tests.AdminID, []string{auth.RoleAdmin}, now, time.Hour)
+	claims.Permissions = auth.Permissions
+	claims.Tenant = tests.TenantID
+
+	// Line 3 fails NewProduct's validation and line 4 reuses line 2's SKU.
+	csv := `name,sku,cost,quantity
+Atlas,ATLAS-1,1500,4
+Globe,GLOBE-1,900,0
+Map,ATLAS-1,300,2
+Compass,COMPASS-1,700,1
+`
+	read := func() []product.ImportRow {
+		rows, err := product.ReadImport(strings.NewReader(csv), product.FormatCSV)
+		if err != nil {
+			t.Fatalf("reading csv: %s", err)
+		}
+		return rows
+	}
+
+	count := func() int {
+		list, err := product.List(ctx, db, tests.TenantID, product.Filter{})
+		if err != nil {
+			t.Fatalf("listing products: %s", err)
+		}
+		return len(list)
+	}
+
+	for _, opts := range []product.ImportOptions{
+		{DryRun: true},
+		{Atomic: true},
+		{DryRun: true, Atomic: true},
+	} {
+		res, err := product.Import(ctx, db, claims, read(), opts, now)
+		if err != nil {
+			t.Fatalf("importing with %+v: %s", opts, err)
+		}
+		if exp, got := 2, len(res.Errors); exp != got {
+			t.Fatalf("importing with %+v: expected %v row errors, got %v", opts, exp, got)
+		}
+		if exp, got := 0, count(); exp != got {
+			t.Fatalf("importing with %+v: expected %v products kept, got %v", opts, exp, got)
+		}
+	}
+
+	res, err := product.Import(ctx, db, claims, read(), product.ImportOptions{}, now)
+	if err != nil {
+		t.Fatalf("importing: %s", err)
+	}
+	if exp, got := 2, res.Created; exp != got {
+		t.Fatalf("expected %v products created, got %v", exp, got)
+	}
+	if exp, got := 3, res.Errors[0].Line; exp != got {
+		t.Fatalf("expected the first error on line %v, got %v", exp, got)
+	}
+	if exp, got := "quantity", res.Errors[0].Fields[0].Field; exp != got {
+		t.Fatalf("expected the first error for field %v, got %v", exp, got)
+	}
+	if exp, got := product.ErrDuplicateSKU.Error(), res.Errors[1].Error; exp != got {
+		t.Fatalf("expected the second error %q, got %q", exp, got)
+	}
+
+	// An export imports back into the same products under new SKUs.
+	var buf bytes.Buffer
+	if err := product.Export(ctx, db, tests.TenantID, &buf, product.FormatNDJSON); err != nil {
+		t.Fatalf("exporting: %s", err)
+	}
+	rows, err := product.ReadImport(strings.NewReader(strings.Replace(buf.String(), `-1"`, `-2"`, -1)), product.FormatNDJSON)
+	if err != nil {
+		t.Fatalf("reading export: %s", err)
+	}
+	res, err = product.Import(ctx, db, claims, rows, product.ImportOptions{Atomic: true}, now)
+	if err != nil {
+		t.Fatalf("importing export: %s", err)
+	}
+	if exp, got := 2, res.Created; exp != got {
+		t.Fatalf("expected %v products created from the export, got %v (%+v)", exp, got, res.Errors)
+	}
+}
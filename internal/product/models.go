@@ -1,43 +1,195 @@
 package product
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
 	"time"
+
+	"github.com/lib/pq"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/money"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/promotion"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/warehouse"
 )
 
-// Product is an item we sell.
+// Product is an item we sell. Quantity is the total held across all
+// warehouses and Stock breaks it down per warehouse. Revenue only totals
+// sales paid in the product's currency; reports convert the rest.
 type Product struct {
-	ID          string    `db:"product_id" json:"id"`
-	Name        string    `db:"name" json:"name"`
-	Cost        int       `db:"cost" json:"cost"`
-	Quantity    int       `db:"quantity" json:"quantity"`
-	Sold        int       `db:"sold" json:"sold"`
-	Revenue     int       `db:"revenue" json:"revenue"`
-	UserID      string    `db:"user_id" json:"user_id"`
-	DateCreated time.Time `db:"date_created" json:"date_created"`
-	DateUpdated time.Time `db:"date_updated" json:"date_updated"`
+	ID          string            `db:"product_id" json:"id"`
+	Name        string            `db:"name" json:"name"`
+	Cost        money.Money       `db:"cost" json:"cost"`
+	Quantity    int               `db:"quantity" json:"quantity"`
+	Sold        int               `db:"sold" json:"sold"`
+	Revenue     money.Money       `db:"revenue" json:"revenue"`
+	CategoryID  *string           `db:"category_id" json:"category_id"`
+	Tags        pq.StringArray    `db:"tags" json:"tags"`
+	UserID      string            `db:"user_id" json:"user_id"`
+	DateCreated time.Time         `db:"date_created" json:"date_created"`
+	DateUpdated time.Time         `db:"date_updated" json:"date_updated"`
+	Stock       []warehouse.Stock `db:"-" json:"stock"`
+}
+
+// Listing is what anonymous callers browsing the catalogue see of a Product.
+// It leaves out stock and sales figures, which are for the tenant's users.
+type Listing struct {
+	ID          string         `json:"id"`
+	Name        string         `json:"name"`
+	Cost        money.Money    `json:"cost"`
+	CategoryID  *string        `json:"category_id"`
+	Tags        pq.StringArray `json:"tags"`
+	DateCreated time.Time      `json:"date_created"`
+	DateUpdated time.Time      `json:"date_updated"`
+}
+
+// Listings returns the Listing of each of products.
+func Listings(products []Product) []Listing {
+	list := make([]Listing, len(products))
+	for i, p := range products {
+		list[i] = Listing{
+			ID:          p.ID,
+			Name:        p.Name,
+			Cost:        p.Cost,
+			CategoryID:  p.CategoryID,
+			Tags:        p.Tags,
+			DateCreated: p.DateCreated,
+			DateUpdated: p.DateUpdated,
+		}
+	}
+	return list
 }
 
+// NewProduct is what we require from clients when adding a Product. Every
+// product starts with a single variant that shares its ID and carries SKU,
+// which is derived from the ID when left blank. The initial Quantity is
+// stocked in WarehouseID, or in the default warehouse when it is left blank.
+// A Cost without a currency is in money.DefaultCurrency.
 type NewProduct struct {
-	Name     string `json:"name" validate:"required"`
-	Cost     int    `json:"cost" validate:"gte=0"`
-	Quantity int    `json:"quantity" validate:"gte=1"`
+	Name        string      `json:"name" validate:"required"`
+	SKU         string      `json:"sku"`
+	Cost        money.Money `json:"cost"`
+	Quantity    int         `json:"quantity" validate:"gte=1"`
+	WarehouseID string      `json:"warehouse_id" validate:"omitempty,uuid"`
+	CategoryID  *string     `json:"category_id" validate:"omitempty,uuid"`
+	Tags        []string    `json:"tags"`
 }
 
+// UpdateProduct defines what information may be provided to modify an
+// existing Product. Quantity sets the level held in the default warehouse;
+// stock elsewhere is managed through the warehouse package. An empty
+// CategoryID uncategorises the product and a non-nil Tags replaces its tags.
+// A Cost without a currency keeps the product's currency.
 type UpdateProduct struct {
-	Name     *string `json:"name" validate:"required"`
-	Cost     *int    `json:"cost" validate:"gte=0"`
-	Quantity *int    `json:"quantity" validate:"gte=1"`
+	Name       *string      `json:"name" validate:"required"`
+	Cost       *money.Money `json:"cost"`
+	Quantity   *int         `json:"quantity" validate:"gte=1"`
+	CategoryID *string      `json:"category_id"`
+	Tags       []string     `json:"tags"`
+}
+
+// Variant is a purchasable version of a Product such as a particular size or
+// colour. Cost is in minor units of the product's currency and is nil when
+// the variant sells at the product's cost.
+type Variant struct {
+	ID          string     `db:"variant_id" json:"id"`
+	ProductID   string     `db:"product_id" json:"product_id"`
+	SKU         string     `db:"sku" json:"sku"`
+	Attributes  Attributes `db:"attributes" json:"attributes"`
+	Cost        *int       `db:"cost" json:"cost"`
+	Quantity    int        `db:"quantity" json:"quantity"`
+	DateCreated time.Time  `db:"date_created" json:"date_created"`
+	DateUpdated time.Time  `db:"date_updated" json:"date_updated"`
+}
+
+type NewVariant struct {
+	SKU         string     `json:"sku" validate:"required"`
+	Attributes  Attributes `json:"attributes"`
+	Cost        *int       `json:"cost" validate:"omitempty,gte=0"`
+	Quantity    int        `json:"quantity" validate:"gte=0"`
+	WarehouseID string     `json:"warehouse_id" validate:"omitempty,uuid"`
+}
+
+type UpdateVariant struct {
+	SKU        *string    `json:"sku" validate:"omitempty,min=1"`
+	Attributes Attributes `json:"attributes"`
+	Cost       *int       `json:"cost" validate:"omitempty,gte=0"`
+}
+
+// Attributes describe what sets a Variant apart, e.g. {"size": "large"}.
+type Attributes map[string]string
+
+// Value stores Attributes as a JSON object.
+func (a Attributes) Value() (driver.Value, error) {
+	if a == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(a)
+}
+
+// Scan reads Attributes from a JSON object column.
+func (a *Attributes) Scan(src interface{}) error {
+	var data []byte
+	switch v := src.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	case nil:
+		*a = Attributes{}
+		return nil
+	default:
+		return errors.New("attributes must be a JSON object")
+	}
+
+	return json.Unmarshal(data, a)
 }
 
+// Sale records a sale of a product variant. ListPrice is what the units cost
+// before any discount and Net is the price after Promotions, or what an admin
+// set it to when OverrideReason is present. Tax is charged on Net at TaxRate
+// in the sale's Jurisdiction, and Paid is the total charged including tax.
+// TaxRate is nil when no rate applied.
 type Sale struct {
-	ID          string    `db:"sale_id" json:"id"`
-	ProductID   string    `db:"product_id" json:"product_id"`
-	Quantity    int       `db:"quantity" json:"quantity"`
-	Paid        int       `db:"paid" json:"paid"`
-	DateCreated time.Time `db:"date_created" json:"date_created"`
+	ID             string              `db:"sale_id" json:"id"`
+	ProductID      string              `db:"product_id" json:"product_id"`
+	VariantID      string              `db:"variant_id" json:"variant_id"`
+	WarehouseID    string              `db:"warehouse_id" json:"warehouse_id"`
+	Quantity       int                 `db:"quantity" json:"quantity"`
+	ListPrice      money.Money         `db:"list_price" json:"list_price"`
+	Net            money.Money         `db:"net" json:"net"`
+	Tax            money.Money         `db:"tax" json:"tax"`
+	Paid           money.Money         `db:"paid" json:"paid"`
+	Jurisdiction   *string             `db:"jurisdiction" json:"jurisdiction"`
+	TaxRate        *string             `db:"tax_rate" json:"tax_rate"`
+	TaxInclusive   bool                `db:"tax_inclusive" json:"tax_inclusive"`
+	CustomerName   *string             `db:"customer_name" json:"customer_name"`
+	CustomerEmail  *string             `db:"customer_email" json:"customer_email"`
+	ExternalRef    *string             `db:"external_ref" json:"external_ref"`
+	Promotions     []promotion.Applied `db:"-" json:"promotions"`
+	OverrideReason *string             `db:"override_reason" json:"override_reason"`
+	OverriddenBy   *string             `db:"overridden_by" json:"overridden_by"`
+	DateCreated    time.Time           `db:"date_created" json:"date_created"`
 }
 
+// NewSale is what we require from clients for recording a Sale. A blank
+// VariantID sells the product's first variant, and when WarehouseID is blank
+// the warehouse holding the most stock of the variant fulfils it. The price
+// is worked out from the variant's cost and the live promotions, plus the one
+// named by Coupon. Only admins may set Paid to override that price, and they
+// must give an OverrideReason. Tax is charged at the rate for the product's
+// category in Jurisdiction; sales without a Jurisdiction are not taxed. The
+// customer is optional and only printed on the receipt. ExternalRef
+// identifies a sale recorded by another system, such as a till, and may only
+// be used once.
 type NewSale struct {
-	Quantity int `json:"quantity" validate:"gte=0"`
-	Paid     int `json:"paid" validate:"gte=0"`
+	VariantID      string       `json:"variant_id" validate:"omitempty,uuid"`
+	WarehouseID    string       `json:"warehouse_id" validate:"omitempty,uuid"`
+	Quantity       int          `json:"quantity" validate:"gte=0"`
+	Coupon         string       `json:"coupon"`
+	Paid           *money.Money `json:"paid"`
+	OverrideReason string       `json:"override_reason"`
+	Jurisdiction   string       `json:"jurisdiction"`
+	CustomerName   string       `json:"customer_name"`
+	CustomerEmail  string       `json:"customer_email" validate:"omitempty,email"`
+	ExternalRef    string       `json:"external_ref"`
 }
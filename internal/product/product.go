@@ -5,41 +5,114 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/money"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/outbox"
 	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/authz"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/database"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/warehouse"
 	"go.opencensus.io/trace"
 )
 
 var (
 	ErrNotFound  = errors.New("product not found")
 	ErrInvalidID = errors.New("ID is not in its proper form")
-	ErrForbidden = errors.New("attempted action is not allowed")
+
+	ErrCategoryNotFound = errors.New("category not found")
+	ErrInvoiced         = errors.New("product has invoiced sales")
 )
 
-func List(ctx context.Context, db *sqlx.DB) ([]Product, error) {
+// Filter narrows the products returned by List. Filtering by CategoryID
+// also returns products in every category below it.
+type Filter struct {
+	CategoryID string
+	Tag        string
+}
+
+// selectProducts is the query List and Retrieve narrow down with a WHERE
+// clause before grouping by product_id.
+const selectProducts = `SELECT
+			p.product_id, p.name, p.category_id, p.user_id, p.date_created, p.date_updated,
+			p.cost AS "cost.amount",
+			p.currency AS "cost.currency",
+			COALESCE((SELECT SUM(st.quantity) FROM stock AS st WHERE st.product_id = p.product_id), 0) AS quantity,
+			COALESCE((SELECT array_agg(t.tag ORDER BY t.tag) FROM product_tags AS t WHERE t.product_id = p.product_id), '{}') AS tags,
+			COALESCE(SUM(s.quantity), 0) AS sold,
+			COALESCE(SUM(s.paid) FILTER (WHERE s.currency = p.currency), 0) AS "revenue.amount",
+			p.currency AS "revenue.currency"
+		FROM products AS p
+		LEFT JOIN sales AS s ON p.product_id = s.product_id`
+
+// List returns the products of tenantID that pass f.
+func List(ctx context.Context, db *sqlx.DB, tenantID string, f Filter) ([]Product, error) {
 	ctx, span := trace.StartSpan(ctx, "internal.product.List")
 	defer span.End()
 
-	var products []Product
+	var (
+		where = []string{"p.tenant_id = $1"}
+		args  = []interface{}{tenantID}
+	)
 
-	const q = `SELECT
-			p.*,
-			COALESCE(SUM(s.quantity), 0) AS sold,
-			COALESCE(SUM(s.paid), 0) AS revenue
-		FROM products AS p
-		LEFT JOIN sales AS s ON p.product_id = s.product_id
-		GROUP BY p.product_id`
-	if err := db.SelectContext(ctx, &products, q); err != nil {
+	if f.CategoryID != "" {
+		if _, err := uuid.Parse(f.CategoryID); err != nil {
+			return nil, ErrInvalidID
+		}
+		args = append(args, f.CategoryID)
+		where = append(where, fmt.Sprintf(`p.category_id IN (
+			WITH RECURSIVE tree AS (
+				SELECT category_id FROM categories WHERE category_id = $%d
+				UNION ALL
+				SELECT c.category_id FROM categories AS c JOIN tree AS t ON c.parent_id = t.category_id
+			)
+			SELECT category_id FROM tree)`, len(args)))
+	}
+
+	if f.Tag != "" {
+		args = append(args, normalizeTag(f.Tag))
+		where = append(where, fmt.Sprintf(`EXISTS (
+			SELECT 1 FROM product_tags AS t WHERE t.product_id = p.product_id AND t.tag = $%d)`, len(args)))
+	}
+
+	q := selectProducts
+	q += "\n\t\tWHERE " + strings.Join(where, " AND ")
+	q += "\n\t\tGROUP BY p.product_id"
+
+	var products []Product
+	err := database.WithTenant(ctx, db, tenantID, func(tx *sqlx.Tx) error {
+		return tx.SelectContext(ctx, &products, q, args...)
+	})
+	if err != nil {
 		return nil, fmt.Errorf("selecting products: %w", err)
 	}
 
+	stock, err := warehouse.AllStock(ctx, db, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	levels := make(map[string][]warehouse.Stock)
+	for _, s := range stock {
+		levels[s.ProductID] = append(levels[s.ProductID], s)
+	}
+	for i := range products {
+		products[i].Stock = levels[products[i].ID]
+		if products[i].Stock == nil {
+			products[i].Stock = []warehouse.Stock{}
+		}
+	}
+
 	return products, nil
 }
 
-func Retrieve(ctx context.Context, db *sqlx.DB, id string) (*Product, error) {
+// Retrieve returns the product id of tenantID.
+func Retrieve(ctx context.Context, db *sqlx.DB, tenantID, id string) (*Product, error) {
 	ctx, span := trace.StartSpan(ctx, "internal.product.Retrieve")
 	defer span.End()
 
@@ -47,17 +120,27 @@ func Retrieve(ctx context.Context, db *sqlx.DB, id string) (*Product, error) {
 		return nil, ErrInvalidID
 	}
 
+	var p *Product
+	err := database.WithTenant(ctx, db, tenantID, func(tx *sqlx.Tx) error {
+		var err error
+		p, err = retrieve(ctx, tx, tenantID, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// retrieve returns the product id of tenantID as tx sees it.
+func retrieve(ctx context.Context, tx *sqlx.Tx, tenantID, id string) (*Product, error) {
 	var p Product
 
-	const q = `SELECT
-			p.*,
-			COALESCE(SUM(s.quantity), 0) AS sold,
-			COALESCE(SUM(s.paid), 0) AS revenue
-		FROM products AS p
-		LEFT JOIN sales AS s ON p.product_id = s.product_id
-		WHERE p.product_id = $1
+	const q = selectProducts + `
+		WHERE p.product_id = $1 AND p.tenant_id = $2
 		GROUP BY p.product_id`
-	if err := db.GetContext(ctx, &p, q, id); err != nil {
+	if err := tx.GetContext(ctx, &p, q, id, tenantID); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, ErrNotFound
 		}
@@ -65,6 +148,15 @@ func Retrieve(ctx context.Context, db *sqlx.DB, id string) (*Product, error) {
 		return nil, fmt.Errorf("selecting single product %w", err)
 	}
 
+	stock, err := warehouse.ProductStock(ctx, tx, p.ID)
+	if err != nil {
+		return nil, err
+	}
+	p.Stock = stock
+	if p.Stock == nil {
+		p.Stock = []warehouse.Stock{}
+	}
+
 	return &p, nil
 }
 
@@ -72,29 +164,108 @@ func Create(ctx context.Context, db *sqlx.DB, user auth.Claims, np NewProduct, n
 	ctx, span := trace.StartSpan(ctx, "internal.product.Create")
 	defer span.End()
 
+	if err := authz.Check(user, authz.Create, authz.Resource{Kind: authz.Product}); err != nil {
+		return nil, err
+	}
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("starting product insert: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := database.SetTenant(ctx, tx, user.Tenant); err != nil {
+		return nil, err
+	}
+
+	p, err := create(ctx, tx, user, np, now)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("committing product insert: %w", err)
+	}
+
+	return p, nil
+}
+
+// create inserts a product of the caller's tenant with its first variant and
+// opening stock as part of tx.
+func create(ctx context.Context, tx *sqlx.Tx, user auth.Claims, np NewProduct, now time.Time) (*Product, error) {
+	warehouseID := np.WarehouseID
+	if warehouseID == "" {
+		var err error
+		if warehouseID, err = warehouse.Default(ctx, tx, user.Tenant); err != nil {
+			return nil, err
+		}
+	}
+
+	cost, err := money.New(np.Cost.Amount, np.Cost.Currency)
+	if err != nil {
+		return nil, err
+	}
+
 	p := Product{
 		ID:          uuid.New().String(),
 		Name:        np.Name,
-		Cost:        np.Cost,
+		Cost:        cost,
+		Revenue:     money.Money{Currency: cost.Currency},
 		Quantity:    np.Quantity,
+		CategoryID:  np.CategoryID,
+		Tags:        normalizeTags(np.Tags),
 		UserID:      user.Subject,
 		DateCreated: now.UTC(),
 		DateUpdated: now.UTC(),
 	}
+	p.Stock = []warehouse.Stock{
+		{WarehouseID: warehouseID, ProductID: p.ID, VariantID: p.ID, Quantity: np.Quantity},
+	}
 
 	const q = `
 		insert into products
-		(product_id, user_id, name, cost, quantity, date_created, date_updated)
-		values($1, $2, $3, $4, $5, $6, $7)
+		(product_id, tenant_id, user_id, name, cost, currency, category_id, date_created, date_updated)
+		values($1, $2, $3, $4, $5, $6, $7, $8, $9)
 		`
-	_, err := db.ExecContext(ctx, q,
-		p.ID, p.UserID, p.Name,
-		p.Cost, p.Quantity,
+	_, err = tx.ExecContext(ctx, q,
+		p.ID, user.Tenant, p.UserID, p.Name,
+		p.Cost.Amount, p.Cost.Currency, p.CategoryID,
 		p.DateCreated, p.DateUpdated)
 	if err != nil {
+		if isForeignKeyViolation(err) {
+			return nil, ErrCategoryNotFound
+		}
 		return nil, fmt.Errorf("inserting product %w", err)
 	}
 
+	if err := replaceTags(ctx, tx, p.ID, p.Tags); err != nil {
+		return nil, err
+	}
+
+	v := Variant{
+		ID:          p.ID,
+		ProductID:   p.ID,
+		SKU:         np.SKU,
+		Attributes:  Attributes{},
+		DateCreated: p.DateCreated,
+		DateUpdated: p.DateUpdated,
+	}
+	if v.SKU == "" {
+		v.SKU = defaultSKU(p.ID)
+	}
+
+	if err := insertVariant(ctx, tx, v); err != nil {
+		return nil, err
+	}
+
+	if err := warehouse.Deposit(ctx, tx, user.Tenant, warehouseID, v.ID, np.Quantity); err != nil {
+		return nil, err
+	}
+
+	if err := outbox.Record(ctx, tx, user.Tenant, outbox.AggregateProduct, p.ID, outbox.ProductCreated, p, now); err != nil {
+		return nil, err
+	}
+
 	return &p, nil
 }
 
@@ -102,13 +273,35 @@ func Update(ctx context.Context, db *sqlx.DB, user auth.Claims, id string, updat
 	ctx, span := trace.StartSpan(ctx, "internal.product.Update")
 	defer span.End()
 
-	p, err := Retrieve(ctx, db, id)
+	if _, err := uuid.Parse(id); err != nil {
+		return ErrInvalidID
+	}
+
+	tx, err := db.BeginTxx(ctx, nil)
 	if err != nil {
+		return fmt.Errorf("starting product update: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := database.SetTenant(ctx, tx, user.Tenant); err != nil {
 		return err
 	}
 
-	if !user.HasRole(auth.RoleAdmin) && p.UserID != user.Subject {
-		return ErrForbidden
+	// Locking the product before reading it makes a concurrent update wait
+	// and then start from this one's result, rather than both starting from
+	// the same product and the later write undoing the earlier.
+	const lock = `SELECT product_id FROM products WHERE product_id = $1 AND tenant_id = $2 FOR UPDATE`
+	if _, err := tx.ExecContext(ctx, lock, id, user.Tenant); err != nil {
+		return fmt.Errorf("locking product: %w", err)
+	}
+
+	p, err := retrieve(ctx, tx, user.Tenant, id)
+	if err != nil {
+		return err
+	}
+
+	if err := authz.Check(user, authz.Edit, p.resource()); err != nil {
+		return err
 	}
 
 	if update.Name != nil {
@@ -116,24 +309,66 @@ func Update(ctx context.Context, db *sqlx.DB, user auth.Claims, id string, updat
 	}
 
 	if update.Cost != nil {
-		p.Cost = *update.Cost
+		currency := update.Cost.Currency
+		if currency == "" {
+			currency = p.Cost.Currency
+		}
+		cost, err := money.New(update.Cost.Amount, currency)
+		if err != nil {
+			return err
+		}
+		p.Cost = cost
 	}
 
-	if update.Quantity != nil {
-		p.Quantity = *update.Quantity
+	if update.CategoryID != nil {
+		p.CategoryID = nil
+		if *update.CategoryID != "" {
+			if _, err := uuid.Parse(*update.CategoryID); err != nil {
+				return ErrInvalidID
+			}
+			p.CategoryID = update.CategoryID
+		}
 	}
 
 	p.DateUpdated = now
 
-	const q = `update products set name = $2, cost = $3, quantity = $4, date_updated = $5 where product_id = $1`
-	_, err = db.ExecContext(ctx, q, p.ID, p.Name, p.Cost, p.Quantity, p.DateUpdated)
+	const q = `update products set name = $2, cost = $3, currency = $4, category_id = $5, date_updated = $6 where product_id = $1 and tenant_id = $7`
+	_, err = tx.ExecContext(ctx, q, p.ID, p.Name, p.Cost.Amount, p.Cost.Currency, p.CategoryID, p.DateUpdated, user.Tenant)
 	if err != nil {
+		if isForeignKeyViolation(err) {
+			return ErrCategoryNotFound
+		}
 		return fmt.Errorf("updating product: %w", err)
 	}
+
+	if update.Tags != nil {
+		if err := replaceTags(ctx, tx, p.ID, normalizeTags(update.Tags)); err != nil {
+			return err
+		}
+	}
+
+	// The product's first variant shares its ID.
+	if update.Quantity != nil {
+		warehouseID, err := warehouse.Default(ctx, tx, user.Tenant)
+		if err != nil {
+			return err
+		}
+		if err := warehouse.Replace(ctx, tx, user.Tenant, warehouseID, p.ID, *update.Quantity); err != nil {
+			return err
+		}
+	}
+
+	if err := outbox.Record(ctx, tx, user.Tenant, outbox.AggregateProduct, p.ID, outbox.ProductUpdated, p, now); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing product update: %w", err)
+	}
 	return nil
 }
 
-func Delete(ctx context.Context, db *sqlx.DB, id string) error {
+func Delete(ctx context.Context, db *sqlx.DB, user auth.Claims, id string, now time.Time) error {
 	ctx, span := trace.StartSpan(ctx, "internal.product.Delete")
 	defer span.End()
 
@@ -141,11 +376,94 @@ func Delete(ctx context.Context, db *sqlx.DB, id string) error {
 		return ErrInvalidID
 	}
 
-	const q = `delete from products where product_id = $1`
+	if err := authz.Check(user, authz.Delete, authz.Resource{Kind: authz.Product, ID: id}); err != nil {
+		return err
+	}
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("starting product delete: %w", err)
+	}
+	defer tx.Rollback()
 
-	if _, err := db.ExecContext(ctx, q, id); err != nil {
+	if err := database.SetTenant(ctx, tx, user.Tenant); err != nil {
+		return err
+	}
+
+	const q = `delete from products where product_id = $1 and tenant_id = $2`
+
+	res, err := tx.ExecContext(ctx, q, id, user.Tenant)
+	if err != nil {
+		// Invoices are never deleted, so neither are the sales they are for.
+		if isForeignKeyViolation(err) {
+			return ErrInvoiced
+		}
 		return fmt.Errorf("deleting product: %w", err)
 	}
 
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("deleting product: %w", err)
+	}
+	if n > 0 {
+		payload := struct {
+			ID string `json:"id"`
+		}{id}
+		if err := outbox.Record(ctx, tx, user.Tenant, outbox.AggregateProduct, id, outbox.ProductDeleted, payload, now); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing product delete: %w", err)
+	}
+
 	return nil
 }
+
+// resource describes p to authz.
+func (p *Product) resource() authz.Resource {
+	return authz.Resource{Kind: authz.Product, ID: p.ID, OwnerID: p.UserID}
+}
+
+// replaceTags sets the tags of a product as part of tx.
+func replaceTags(ctx context.Context, tx *sqlx.Tx, productID string, tags []string) error {
+	const d = `delete from product_tags where product_id = $1`
+	if _, err := tx.ExecContext(ctx, d, productID); err != nil {
+		return fmt.Errorf("deleting product tags: %w", err)
+	}
+
+	const q = `insert into product_tags (product_id, tag) select $1, unnest($2::text[])`
+	if _, err := tx.ExecContext(ctx, q, productID, pq.StringArray(tags)); err != nil {
+		return fmt.Errorf("inserting product tags: %w", err)
+	}
+
+	return nil
+}
+
+// normalizeTags lower cases, de-duplicates and sorts tags.
+func normalizeTags(tags []string) pq.StringArray {
+	seen := make(map[string]bool)
+	out := pq.StringArray{}
+	for _, t := range tags {
+		t = normalizeTag(t)
+		if t == "" || seen[t] {
+			continue
+		}
+		seen[t] = true
+		out = append(out, t)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+// isForeignKeyViolation reports whether err was caused by referencing a row
+// that does not exist.
+func isForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23503"
+}
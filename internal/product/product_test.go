@@ -2,10 +2,12 @@ package product_test
 
 import (
 	"context"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/money"
 	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
 	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/product"
 	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/schema"
@@ -18,7 +20,7 @@ func TestProducts(t *testing.T) {
 
 	newP := product.NewProduct{
 		Name:     "Comic Book",
-		Cost:     10,
+		Cost:     money.Money{Amount: 10, Currency: "USD"},
 		Quantity: 55,
 	}
 
@@ -30,13 +32,15 @@ func TestProducts(t *testing.T) {
 		[]string{auth.RoleAdmin, auth.RoleUser},
 		now, time.Hour,
 	)
+	claims.Permissions = auth.Permissions
+	claims.Tenant = tests.TenantID
 
 	p0, err := product.Create(ctx, db, claims, newP, now)
 	if err != nil {
 		t.Fatalf("creating product p0: %s", err)
 	}
 
-	p1, err := product.Retrieve(ctx, db, p0.ID)
+	p1, err := product.Retrieve(ctx, db, tests.TenantID, p0.ID)
 	if err != nil {
 		t.Fatalf("getting product p0: %s", err)
 	}
@@ -47,7 +51,7 @@ func TestProducts(t *testing.T) {
 
 	update := product.UpdateProduct{
 		Name: tests.StringPointer("Comics"),
-		Cost: tests.IntPointer(25),
+		Cost: &money.Money{Amount: 25},
 	}
 	updatedTime := time.Date(2019, time.January, 1, 1, 1, 1, 0, time.UTC)
 
@@ -55,25 +59,55 @@ func TestProducts(t *testing.T) {
 		t.Fatalf("updating product p0: %s", err)
 	}
 
-	saved, err := product.Retrieve(ctx, db, p0.ID)
+	saved, err := product.Retrieve(ctx, db, tests.TenantID, p0.ID)
 	if err != nil {
 		t.Fatalf("getting product p0: %s", err)
 	}
 
 	want := *p0
 	want.Name = "Comics"
-	want.Cost = 25
+	want.Cost = money.Money{Amount: 25, Currency: "USD"}
 	want.DateUpdated = updatedTime
 
 	if diff := cmp.Diff(want, *saved); diff != "" {
 		t.Fatalf("updated record dit not match:\n%s", diff)
 	}
 
-	if err := product.Delete(ctx, db, p0.ID); err != nil {
+	// Concurrent updates of different fields each keep the other's change.
+	updates := []product.UpdateProduct{
+		{Name: tests.StringPointer("Graphic Novels")},
+		{Cost: &money.Money{Amount: 30}},
+	}
+	errs := make(chan error, len(updates))
+	var wg sync.WaitGroup
+	for _, u := range updates {
+		wg.Add(1)
+		go func(u product.UpdateProduct) {
+			defer wg.Done()
+			errs <- product.Update(ctx, db, claims, p0.ID, u, updatedTime)
+		}(u)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("updating product p0 concurrently: %s", err)
+		}
+	}
+
+	saved, err = product.Retrieve(ctx, db, tests.TenantID, p0.ID)
+	if err != nil {
+		t.Fatalf("getting product p0: %s", err)
+	}
+	if saved.Name != "Graphic Novels" || saved.Cost.Amount != 30 {
+		t.Fatalf("got %q costing %v, want both concurrent updates", saved.Name, saved.Cost)
+	}
+
+	if err := product.Delete(ctx, db, claims, p0.ID, updatedTime); err != nil {
 		t.Fatalf("deleting product: %v", err)
 	}
 
-	_, err = product.Retrieve(ctx, db, p0.ID)
+	_, err = product.Retrieve(ctx, db, tests.TenantID, p0.ID)
 	if err == nil {
 		t.Fatalf("should not be able to retrieve deleted product")
 	}
@@ -89,7 +123,7 @@ func TestProductList(t *testing.T) {
 
 	ctx := context.Background()
 
-	ps, err := product.List(ctx, db)
+	ps, err := product.List(ctx, db, tests.TenantID, product.Filter{})
 	if err != nil {
 		t.Fatalf("listing products: %s", err)
 	}
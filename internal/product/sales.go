@@ -2,46 +2,325 @@ package product
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/money"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/outbox"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/authz"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/database"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/promotion"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/tax"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/warehouse"
 	"go.opencensus.io/trace"
 )
 
-func AddSale(ctx context.Context, db *sqlx.DB, ns NewSale, productID string, now time.Time) (*Sale, error) {
+var (
+	ErrSaleNotFound  = errors.New("sale not found")
+	ErrDuplicateSale = errors.New("a sale with that external reference already exists")
+
+	// ErrOverrideReason is returned when a price override is missing its reason.
+	ErrOverrideReason = errors.New("overriding the price requires a reason")
+)
+
+// AddSale records a sale of a product variant and takes the sold quantity out
+// of the fulfilling warehouse's stock. It returns warehouse.ErrNotFound when
+// the named warehouse does not exist and warehouse.ErrInsufficientStock when
+// that warehouse, or every warehouse if none was named, holds too little.
+//
+// The price is the variant's cost, or the product's when the variant has
+// none, less every live promotion. When ns.Paid is set it replaces that price
+// and no promotions apply; only sales admins may do so. Tax is then charged on the
+// price at the rate tax.Lookup finds for ns.Jurisdiction.
+func AddSale(ctx context.Context, db *sqlx.DB, user auth.Claims, ns NewSale, productID string, now time.Time) (*Sale, error) {
 	ctx, span := trace.StartSpan(ctx, "internal.product.AddSale")
 	defer span.End()
 
+	if _, err := uuid.Parse(productID); err != nil {
+		return nil, ErrInvalidID
+	}
+
+	if err := authz.Check(user, authz.Create, authz.Resource{Kind: authz.Sale}); err != nil {
+		return nil, err
+	}
+
+	if ns.Paid != nil {
+		if err := authz.Check(user, authz.OverridePrice, authz.Resource{Kind: authz.Sale}); err != nil {
+			return nil, err
+		}
+		if ns.OverrideReason == "" {
+			return nil, ErrOverrideReason
+		}
+	}
+
 	s := Sale{
 		ID:          uuid.New().String(),
 		ProductID:   productID,
+		VariantID:   ns.VariantID,
+		WarehouseID: ns.WarehouseID,
 		Quantity:    ns.Quantity,
-		Paid:        ns.Paid,
+		Promotions:  []promotion.Applied{},
 		DateCreated: now,
 	}
+	if ns.CustomerName != "" {
+		s.CustomerName = &ns.CustomerName
+	}
+	if ns.CustomerEmail != "" {
+		s.CustomerEmail = &ns.CustomerEmail
+	}
+	if ns.ExternalRef != "" {
+		s.ExternalRef = &ns.ExternalRef
+	}
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("starting sale: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := database.SetTenant(ctx, tx, user.Tenant); err != nil {
+		return nil, err
+	}
 
-	const q = `insert into sales (sale_id, product_id, quantity, paid, date_created) values ($1, $2, $3, $4, $5)`
+	// The product's first variant shares its ID.
+	if s.VariantID == "" {
+		s.VariantID = s.ProductID
+	}
 
-	_, err := db.ExecContext(ctx, q, s.ID, s.ProductID, s.Quantity, s.Paid, s.DateCreated)
+	var owner struct {
+		ProductID  string      `db:"product_id"`
+		CategoryID *string     `db:"category_id"`
+		Cost       money.Money `db:"cost"`
+	}
+	const v = `select v.product_id, p.category_id,
+			COALESCE(v.cost, p.cost) as "cost.amount", p.currency as "cost.currency"
+		from variants as v
+		join products as p on p.product_id = v.product_id
+		where v.variant_id = $1 and p.tenant_id = $2`
+	if err := tx.GetContext(ctx, &owner, v, s.VariantID, user.Tenant); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrVariantNotFound
+		}
+		return nil, fmt.Errorf("selecting sale variant: %w", err)
+	}
+	if owner.ProductID != s.ProductID {
+		return nil, ErrVariantNotFound
+	}
+
+	var price money.Money
+	if ns.Paid != nil {
+		s.ListPrice = owner.Cost.Times(int64(s.Quantity))
+		price = *ns.Paid
+		if price.Currency == "" {
+			price.Currency = owner.Cost.Currency
+		}
+		if price.Currency != owner.Cost.Currency {
+			return nil, money.ErrCurrencyMismatch
+		}
+		s.OverrideReason = &ns.OverrideReason
+		s.OverriddenBy = &user.Subject
+	} else {
+		promos, err := promotion.Live(ctx, tx, user.Tenant, s.ProductID, ns.Coupon, now)
+		if err != nil {
+			return nil, err
+		}
+		p := promotion.Apply(owner.Cost, s.Quantity, promos)
+		s.ListPrice = p.Subtotal
+		s.Promotions = p.Applied
+		price = p.Total
+	}
+
+	line := tax.Untaxed(price)
+	if j := tax.NormalizeJurisdiction(ns.Jurisdiction); j != "" {
+		s.Jurisdiction = &j
+
+		rate, err := tax.Lookup(ctx, tx, user.Tenant, j, owner.CategoryID)
+		switch err {
+		case nil:
+			r, err := tax.ParseRate(rate.Rate)
+			if err != nil {
+				return nil, err
+			}
+			line = tax.Split(price, r, rate.Inclusive)
+			s.TaxRate = &rate.Rate
+			s.TaxInclusive = rate.Inclusive
+		case tax.ErrNotFound:
+			// No rate is set for the jurisdiction so nothing is charged.
+		default:
+			return nil, err
+		}
+	}
+	s.Net, s.Tax, s.Paid = line.Net, line.Tax, line.Gross
+
+	if s.WarehouseID == "" {
+		s.WarehouseID, err = warehouse.Pick(ctx, tx, user.Tenant, s.VariantID, s.Quantity)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := warehouse.Withdraw(ctx, tx, user.Tenant, s.WarehouseID, s.VariantID, s.Quantity); err != nil {
+		return nil, err
+	}
+
+	const q = `insert into sales
+		(sale_id, tenant_id, product_id, variant_id, warehouse_id, quantity, list_price, net, tax, paid, currency,
+		jurisdiction, tax_rate, tax_inclusive, customer_name, customer_email, external_ref,
+		override_reason, overridden_by, date_created)
+		values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20)`
+
+	_, err = tx.ExecContext(ctx, q,
+		s.ID, user.Tenant, s.ProductID, s.VariantID, s.WarehouseID, s.Quantity,
+		s.ListPrice.Amount, s.Net.Amount, s.Tax.Amount, s.Paid.Amount, s.Paid.Currency,
+		s.Jurisdiction, s.TaxRate, s.TaxInclusive, s.CustomerName, s.CustomerEmail, s.ExternalRef,
+		s.OverrideReason, s.OverriddenBy, s.DateCreated)
 	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrDuplicateSale
+		}
 		return nil, fmt.Errorf("inserting sale: %w", err)
 	}
 
+	if err := promotion.Redeem(ctx, tx, s.ID, s.Promotions); err != nil {
+		return nil, err
+	}
+
+	if err := outbox.Record(ctx, tx, user.Tenant, outbox.AggregateSale, s.ID, outbox.SaleRecorded, s, now); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("committing sale: %w", err)
+	}
+
 	return &s, nil
 }
 
-func ListSales(ctx context.Context, db *sqlx.DB, productID string) ([]Sale, error) {
+// selectSales is the query ListSales and RetrieveSale narrow down with a
+// WHERE clause.
+const selectSales = `select sale_id, product_id, variant_id, warehouse_id, quantity,
+			COALESCE(list_price, paid) as "list_price.amount", currency as "list_price.currency",
+			COALESCE(net, paid) as "net.amount", currency as "net.currency",
+			tax as "tax.amount", currency as "tax.currency",
+			paid as "paid.amount", currency as "paid.currency",
+			jurisdiction, tax_rate::TEXT as tax_rate, tax_inclusive,
+			customer_name, customer_email, external_ref,
+			override_reason, overridden_by, date_created
+		from sales`
+
+// ListSales returns the sales of a product of tenantID.
+func ListSales(ctx context.Context, db *sqlx.DB, tenantID, productID string) ([]Sale, error) {
 	ctx, span := trace.StartSpan(ctx, "internal.product.ListSales")
 	defer span.End()
 
 	var sales []Sale
 
-	const q = `select * from sales where product_id = $1`
+	const q = selectSales + ` where product_id = $1 and tenant_id = $2`
 
-	if err := db.SelectContext(ctx, &sales, q, productID); err != nil {
+	err := database.WithTenant(ctx, db, tenantID, func(tx *sqlx.Tx) error {
+		return tx.SelectContext(ctx, &sales, q, productID, tenantID)
+	})
+	if err != nil {
 		return nil, fmt.Errorf("selecting sales: %w", err)
 	}
+
+	if err := attachPromotions(ctx, db, tenantID, sales); err != nil {
+		return nil, err
+	}
+
 	return sales, nil
 }
+
+// SalesByProduct returns the sales of each of productIDs of tenantID with a
+// single query. Products without sales are left out of the map.
+func SalesByProduct(ctx context.Context, db *sqlx.DB, tenantID string, productIDs []string) (map[string][]Sale, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.product.SalesByProduct")
+	defer span.End()
+
+	for _, id := range productIDs {
+		if _, err := uuid.Parse(id); err != nil {
+			return nil, ErrInvalidID
+		}
+	}
+
+	var sales []Sale
+
+	const q = selectSales + ` where product_id = ANY($1) and tenant_id = $2 order by date_created, sale_id`
+
+	err := database.WithTenant(ctx, db, tenantID, func(tx *sqlx.Tx) error {
+		return tx.SelectContext(ctx, &sales, q, pq.StringArray(productIDs), tenantID)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("selecting sales: %w", err)
+	}
+
+	if err := attachPromotions(ctx, db, tenantID, sales); err != nil {
+		return nil, err
+	}
+
+	byProduct := make(map[string][]Sale)
+	for _, s := range sales {
+		byProduct[s.ProductID] = append(byProduct[s.ProductID], s)
+	}
+
+	return byProduct, nil
+}
+
+// RetrieveSale returns the sale id of tenantID.
+func RetrieveSale(ctx context.Context, db *sqlx.DB, tenantID, id string) (*Sale, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.product.RetrieveSale")
+	defer span.End()
+
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrInvalidID
+	}
+
+	var s Sale
+
+	const q = selectSales + ` where sale_id = $1 and tenant_id = $2`
+
+	err := database.WithTenant(ctx, db, tenantID, func(tx *sqlx.Tx) error {
+		return tx.GetContext(ctx, &s, q, id, tenantID)
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrSaleNotFound
+		}
+		return nil, fmt.Errorf("selecting single sale: %w", err)
+	}
+
+	sales := []Sale{s}
+	if err := attachPromotions(ctx, db, tenantID, sales); err != nil {
+		return nil, err
+	}
+
+	return &sales[0], nil
+}
+
+// attachPromotions fills in the promotions applied to each of sales of
+// tenantID.
+func attachPromotions(ctx context.Context, db *sqlx.DB, tenantID string, sales []Sale) error {
+	ids := make([]string, len(sales))
+	for i, s := range sales {
+		ids[i] = s.ID
+	}
+
+	applied, err := promotion.ForSales(ctx, db, tenantID, ids)
+	if err != nil {
+		return err
+	}
+	for i := range sales {
+		sales[i].Promotions = applied[sales[i].ID]
+		if sales[i].Promotions == nil {
+			sales[i].Promotions = []promotion.Applied{}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,231 @@
+package product
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/database"
+	"go.opencensus.io/trace"
+)
+
+// exportBatch is how many sales are fetched from the cursor at a time. It
+// bounds the memory an export uses however many sales it covers.
+const exportBatch = 500
+
+var ErrInvalidCursor = errors.New("cursor is not one issued by a sales export")
+
+// exportColumns are the columns of a CSV sales export.
+var exportColumns = []string{
+	"cursor", "id", "date_created", "product_id", "variant_id", "warehouse_id", "quantity", "currency",
+	"list_price", "net", "tax", "paid", "jurisdiction", "tax_rate", "tax_inclusive", "external_ref",
+}
+
+// ExportedSale is one line of a sales export. Cursor resumes the export
+// after this sale.
+type ExportedSale struct {
+	Cursor       string    `db:"-" json:"cursor"`
+	ID           string    `db:"sale_id" json:"id"`
+	DateCreated  time.Time `db:"date_created" json:"date_created"`
+	ProductID    string    `db:"product_id" json:"product_id"`
+	VariantID    string    `db:"variant_id" json:"variant_id"`
+	WarehouseID  string    `db:"warehouse_id" json:"warehouse_id"`
+	Quantity     int       `db:"quantity" json:"quantity"`
+	Currency     string    `db:"currency" json:"currency"`
+	ListPrice    int64     `db:"list_price" json:"list_price"`
+	Net          int64     `db:"net" json:"net"`
+	Tax          int64     `db:"tax" json:"tax"`
+	Paid         int64     `db:"paid" json:"paid"`
+	Jurisdiction *string   `db:"jurisdiction" json:"jurisdiction"`
+	TaxRate      *string   `db:"tax_rate" json:"tax_rate"`
+	TaxInclusive bool      `db:"tax_inclusive" json:"tax_inclusive"`
+	ExternalRef  *string   `db:"external_ref" json:"external_ref"`
+}
+
+// SalesExport streams the sales made in a period from a server side cursor.
+// It holds a database transaction open until Close is called.
+type SalesExport struct {
+	tx *sqlx.Tx
+}
+
+// OpenSalesExport declares a cursor over the sales of tenantID made in
+// [from, to) in the order they were made. When cursor is not empty the
+// export resumes after the sale it was issued for.
+func OpenSalesExport(ctx context.Context, db *sqlx.DB, tenantID string, from, to time.Time, cursor string) (*SalesExport, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.product.OpenSalesExport")
+	defer span.End()
+
+	afterDate, afterID := time.Time{}, uuid.Nil.String()
+	if cursor != "" {
+		var err error
+		if afterDate, afterID, err = decodeCursor(cursor); err != nil {
+			return nil, err
+		}
+	}
+
+	tx, err := db.BeginTxx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("starting sales export: %w", err)
+	}
+
+	if err := database.SetTenant(ctx, tx, tenantID); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	const q = `DECLARE sales_export NO SCROLL CURSOR FOR
+		SELECT sale_id, date_created, product_id, variant_id, warehouse_id, quantity, currency,
+			COALESCE(list_price, paid) AS list_price, COALESCE(net, paid) AS net, tax, paid,
+			jurisdiction, tax_rate::TEXT AS tax_rate, tax_inclusive, external_ref
+		FROM sales
+		WHERE tenant_id = $5 AND date_created >= $1 AND date_created < $2
+			AND (date_created, sale_id) > ($3, $4)
+		ORDER BY date_created, sale_id`
+	if _, err := tx.ExecContext(ctx, q, from.UTC(), to.UTC(), afterDate, afterID, tenantID); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("declaring sales export cursor: %w", err)
+	}
+
+	return &SalesExport{tx: tx}, nil
+}
+
+// Write writes every sale left in the export to w as CSV or NDJSON. When w
+// has a Flush method it is called after each batch so the sales reach the
+// client as they are read.
+func (e *SalesExport) Write(ctx context.Context, w io.Writer, format string) error {
+	ctx, span := trace.StartSpan(ctx, "internal.product.SalesExport.Write")
+	defer span.End()
+
+	var write func(ExportedSale) error
+	var flush func() error
+
+	switch format {
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(exportColumns); err != nil {
+			return err
+		}
+		write = func(s ExportedSale) error { return cw.Write(s.record()) }
+		flush = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+	case FormatNDJSON:
+		enc := json.NewEncoder(w)
+		write = func(s ExportedSale) error { return enc.Encode(s) }
+		flush = func() error { return nil }
+	default:
+		return ErrUnknownFormat
+	}
+
+	flusher, _ := w.(interface{ Flush() })
+
+	for {
+		rows, err := e.tx.QueryxContext(ctx, `FETCH `+strconv.Itoa(exportBatch)+` FROM sales_export`)
+		if err != nil {
+			return fmt.Errorf("fetching sales: %w", err)
+		}
+
+		n := 0
+		for rows.Next() {
+			var s ExportedSale
+			if err := rows.StructScan(&s); err != nil {
+				rows.Close()
+				return fmt.Errorf("scanning sale: %w", err)
+			}
+			s.Cursor = encodeCursor(s.DateCreated, s.ID)
+			if err := write(s); err != nil {
+				rows.Close()
+				return err
+			}
+			n++
+		}
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("fetching sales: %w", err)
+		}
+		rows.Close()
+
+		if err := flush(); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		if n < exportBatch {
+			return nil
+		}
+	}
+}
+
+// Close releases the cursor and its transaction.
+func (e *SalesExport) Close() error {
+	return e.tx.Rollback()
+}
+
+func (s ExportedSale) record() []string {
+	str := func(p *string) string {
+		if p == nil {
+			return ""
+		}
+		return *p
+	}
+
+	return []string{
+		s.Cursor,
+		s.ID,
+		s.DateCreated.UTC().Format(time.RFC3339Nano),
+		s.ProductID,
+		s.VariantID,
+		s.WarehouseID,
+		strconv.Itoa(s.Quantity),
+		s.Currency,
+		strconv.FormatInt(s.ListPrice, 10),
+		strconv.FormatInt(s.Net, 10),
+		strconv.FormatInt(s.Tax, 10),
+		strconv.FormatInt(s.Paid, 10),
+		str(s.Jurisdiction),
+		str(s.TaxRate),
+		strconv.FormatBool(s.TaxInclusive),
+		str(s.ExternalRef),
+	}
+}
+
+// encodeCursor makes the token that resumes an export after the sale made
+// at date with id. Sales are exported in that order, so the token stays
+// valid whichever instance serves the resumed request.
+func encodeCursor(date time.Time, id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(date.UTC().Format(time.RFC3339Nano) + "|" + id))
+}
+
+func decodeCursor(cursor string) (time.Time, string, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+
+	parts := strings.SplitN(string(b), "|", 2)
+	if len(parts) != 2 {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+
+	date, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+	if _, err := uuid.Parse(parts[1]); err != nil {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+
+	return date, parts[1], nil
+}
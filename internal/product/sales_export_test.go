@@ -0,0 +1,107 @@
+package product_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/product"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/schema"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/tests"
+)
+
+func TestSalesExport(t *testing.T) {
+	db, teardown := tests.NewUnit(t)
+	defer teardown()
+
+	if err := schema.Seed(db); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	from := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2019, time.January, 2, 0, 0, 0, 0, time.UTC)
+
+	export := func(cursor string) []product.ExportedSale {
+		t.Helper()
+
+		exp, err := product.OpenSalesExport(ctx, db, tests.TenantID, from, to, cursor)
+		if err != nil {
+			t.Fatalf("opening export: %s", err)
+		}
+		defer exp.Close()
+
+		var buf bytes.Buffer
+		if err := exp.Write(ctx, &buf, product.FormatNDJSON); err != nil {
+			t.Fatalf("writing export: %s", err)
+		}
+
+		var sales []product.ExportedSale
+		dec := json.NewDecoder(&buf)
+		for dec.More() {
+			var s product.ExportedSale
+			if err := dec.Decode(&s); err != nil {
+				t.Fatalf("decoding export: %s", err)
+			}
+			sales = append(sales, s)
+		}
+		return sales
+	}
+
+	ids := func(sales []product.ExportedSale) []string {
+		var ids []string
+		for _, s := range sales {
+			ids = append(ids, s.ID)
+		}
+		return ids
+	}
+
+	all := export("")
+	want := []string{
+		"98b6d4b8-f04b-4c79-8c2e-a0aef46854b7",
+		"85f6fb09-eb05-4874-ae39-82d1a30fe0d7",
+		"a235be9e-ab5d-44e6-a987-fa1c749264c7",
+	}
+	if diff := cmp.Diff(want, ids(all)); diff != "" {
+		t.Fatalf("exported sales differ:\n%s", diff)
+	}
+
+	resumed := export(all[0].Cursor)
+	if diff := cmp.Diff(want[1:], ids(resumed)); diff != "" {
+		t.Fatalf("resumed export differs:\n%s", diff)
+	}
+
+	if rest := export(all[2].Cursor); len(rest) != 0 {
+		t.Fatalf("export after the last sale returned %d sales", len(rest))
+	}
+
+	if _, err := product.OpenSalesExport(ctx, db, tests.TenantID, from, to, "not-a-cursor"); err != product.ErrInvalidCursor {
+		t.Fatalf("opening export with a bad cursor: got %v, want %v", err, product.ErrInvalidCursor)
+	}
+
+	exp, err := product.OpenSalesExport(ctx, db, tests.TenantID, from, to, "")
+	if err != nil {
+		t.Fatalf("opening export: %s", err)
+	}
+	defer exp.Close()
+
+	var buf bytes.Buffer
+	if err := exp.Write(ctx, &buf, product.FormatCSV); err != nil {
+		t.Fatalf("writing CSV export: %s", err)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("reading CSV export: %s", err)
+	}
+	if len(records) != 4 {
+		t.Fatalf("CSV export has %d records, want a header and 3 sales", len(records))
+	}
+	if records[1][0] != all[0].Cursor || records[1][1] != want[0] {
+		t.Fatalf("first CSV sale is %v, want cursor %q and id %q", records[1][:2], all[0].Cursor, want[0])
+	}
+}
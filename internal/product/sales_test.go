@@ -2,12 +2,16 @@ package product_test
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/money"
 	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/authz"
 	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/product"
 	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/tests"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/warehouse"
 )
 
 func TestSales(t *testing.T) {
@@ -20,7 +24,7 @@ func TestSales(t *testing.T) {
 
 	newPuzzles := product.NewProduct{
 		Name:     "Puzzles",
-		Cost:     25,
+		Cost:     money.Money{Amount: 25, Currency: "USD"},
 		Quantity: 6,
 	}
 
@@ -29,6 +33,8 @@ func TestSales(t *testing.T) {
 		[]string{auth.RoleAdmin, auth.RoleUser},
 		now, time.Hour,
 	)
+	claims.Permissions = auth.Permissions
+	claims.Tenant = tests.TenantID
 
 	puzzles, err := product.Create(ctx, db, claims, newPuzzles, now)
 	if err != nil {
@@ -37,7 +43,7 @@ func TestSales(t *testing.T) {
 
 	newToys := product.NewProduct{
 		Name:     "Toys",
-		Cost:     40,
+		Cost:     money.Money{Amount: 40, Currency: "USD"},
 		Quantity: 3,
 	}
 	toys, err := product.Create(ctx, db, claims, newToys, now)
@@ -48,15 +54,17 @@ func TestSales(t *testing.T) {
 	{
 		ns := product.NewSale{
 			Quantity: 3,
-			Paid:     70,
 		}
 
-		s, err := product.AddSale(ctx, db, ns, puzzles.ID, now)
+		s, err := product.AddSale(ctx, db, claims, ns, puzzles.ID, now)
 		if err != nil {
 			t.Fatalf("adding sale: %s", err)
 		}
+		if exp, got := (money.Money{Amount: 75, Currency: "USD"}), s.Paid; exp != got {
+			t.Fatalf("expected sale priced at %v, got %v", exp, got)
+		}
 
-		sales, err := product.ListSales(ctx, db, puzzles.ID)
+		sales, err := product.ListSales(ctx, db, tests.TenantID, puzzles.ID)
 		if err != nil {
 			t.Fatalf("listing sales: %s", err)
 		}
@@ -68,7 +76,7 @@ func TestSales(t *testing.T) {
 			t.Fatalf("expected first sale ID %v, got %v", exp, got)
 		}
 
-		sales, err = product.ListSales(ctx, db, toys.ID)
+		sales, err = product.ListSales(ctx, db, tests.TenantID, toys.ID)
 		if err != nil {
 			t.Fatalf("listing sales: %s", err)
 		}
@@ -76,4 +84,58 @@ func TestSales(t *testing.T) {
 			t.Fatalf("expected sale list size %v, got %v", exp, got)
 		}
 	}
+
+	{
+		ns := product.NewSale{
+			Quantity: 4,
+		}
+
+		if _, err := product.AddSale(ctx, db, claims, ns, toys.ID, now); err != warehouse.ErrInsufficientStock {
+			t.Fatalf("expected selling more than stocked to fail with %v, got %v", warehouse.ErrInsufficientStock, err)
+		}
+
+		ns.Quantity = 3
+		ns.Paid = &money.Money{Amount: 100}
+
+		clerk := auth.NewClaims(tests.UserID, []string{auth.RoleUser}, now, time.Hour)
+		clerk.Permissions = []string{auth.PermSalesCreate}
+		clerk.Tenant = tests.TenantID
+		if _, err := product.AddSale(ctx, db, clerk, ns, toys.ID, now); !errors.Is(err, authz.ErrForbidden) {
+			t.Fatalf("expected a clerk overriding the price to fail with %v, got %v", authz.ErrForbidden, err)
+		}
+		if _, err := product.AddSale(ctx, db, claims, ns, toys.ID, now); err != product.ErrOverrideReason {
+			t.Fatalf("expected overriding without a reason to fail with %v, got %v", product.ErrOverrideReason, err)
+		}
+
+		ns.OverrideReason = "damaged packaging"
+		s, err := product.AddSale(ctx, db, claims, ns, toys.ID, now)
+		if err != nil {
+			t.Fatalf("adding sale: %s", err)
+		}
+		if exp, got := warehouse.DefaultID, s.WarehouseID; exp != got {
+			t.Fatalf("expected sale fulfilled from %v, got %v", exp, got)
+		}
+		if exp, got := int64(100), s.Paid.Amount; exp != got {
+			t.Fatalf("expected overridden price %v, got %v", exp, got)
+		}
+		if exp, got := int64(120), s.ListPrice.Amount; exp != got {
+			t.Fatalf("expected list price %v, got %v", exp, got)
+		}
+
+		sales, err := product.ListSales(ctx, db, tests.TenantID, toys.ID)
+		if err != nil {
+			t.Fatalf("listing sales: %s", err)
+		}
+		if sales[0].OverrideReason == nil || *sales[0].OverrideReason != ns.OverrideReason {
+			t.Fatalf("expected override reason %q to be recorded, got %v", ns.OverrideReason, sales[0].OverrideReason)
+		}
+
+		p, err := product.Retrieve(ctx, db, tests.TenantID, toys.ID)
+		if err != nil {
+			t.Fatalf("getting product: %s", err)
+		}
+		if exp, got := 0, p.Quantity; exp != got {
+			t.Fatalf("expected remaining quantity %v, got %v", exp, got)
+		}
+	}
 }
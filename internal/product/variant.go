@@ -0,0 +1,241 @@
+package product
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/outbox"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/authz"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/database"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/warehouse"
+	"go.opencensus.io/trace"
+)
+
+var (
+	ErrVariantNotFound = errors.New("variant not found")
+	ErrDuplicateSKU    = errors.New("SKU is already in use")
+)
+
+// ListVariants returns the variants of a product of tenantID.
+func ListVariants(ctx context.Context, db *sqlx.DB, tenantID, productID string) ([]Variant, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.product.ListVariants")
+	defer span.End()
+
+	if _, err := uuid.Parse(productID); err != nil {
+		return nil, ErrInvalidID
+	}
+
+	var variants []Variant
+
+	const q = `SELECT
+			v.*,
+			COALESCE(SUM(st.quantity), 0) AS quantity
+		FROM variants AS v
+		JOIN products AS p ON p.product_id = v.product_id
+		LEFT JOIN stock AS st ON v.variant_id = st.variant_id
+		WHERE v.product_id = $1 AND p.tenant_id = $2
+		GROUP BY v.variant_id
+		ORDER BY v.date_created, v.sku`
+	err := database.WithTenant(ctx, db, tenantID, func(tx *sqlx.Tx) error {
+		return tx.SelectContext(ctx, &variants, q, productID, tenantID)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("selecting variants: %w", err)
+	}
+
+	return variants, nil
+}
+
+// RetrieveVariant returns the variant id of a product of tenantID.
+func RetrieveVariant(ctx context.Context, db *sqlx.DB, tenantID, id string) (*Variant, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.product.RetrieveVariant")
+	defer span.End()
+
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrInvalidID
+	}
+
+	var v Variant
+
+	const q = `SELECT
+			v.*,
+			COALESCE(SUM(st.quantity), 0) AS quantity
+		FROM variants AS v
+		JOIN products AS p ON p.product_id = v.product_id
+		LEFT JOIN stock AS st ON v.variant_id = st.variant_id
+		WHERE v.variant_id = $1 AND p.tenant_id = $2
+		GROUP BY v.variant_id`
+	err := database.WithTenant(ctx, db, tenantID, func(tx *sqlx.Tx) error {
+		return tx.GetContext(ctx, &v, q, id, tenantID)
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrVariantNotFound
+		}
+
+		return nil, fmt.Errorf("selecting single variant: %w", err)
+	}
+
+	return &v, nil
+}
+
+// AddVariant adds a variant to an existing product, stocking its initial
+// Quantity in the requested or default warehouse.
+func AddVariant(ctx context.Context, db *sqlx.DB, user auth.Claims, productID string, nv NewVariant, now time.Time) (*Variant, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.product.AddVariant")
+	defer span.End()
+
+	p, err := Retrieve(ctx, db, user.Tenant, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := authz.Check(user, authz.Edit, p.resource()); err != nil {
+		return nil, err
+	}
+
+	v := Variant{
+		ID:          uuid.New().String(),
+		ProductID:   p.ID,
+		SKU:         nv.SKU,
+		Attributes:  nv.Attributes,
+		Cost:        nv.Cost,
+		Quantity:    nv.Quantity,
+		DateCreated: now.UTC(),
+		DateUpdated: now.UTC(),
+	}
+	if v.Attributes == nil {
+		v.Attributes = Attributes{}
+	}
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("starting variant insert: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := database.SetTenant(ctx, tx, user.Tenant); err != nil {
+		return nil, err
+	}
+
+	warehouseID := nv.WarehouseID
+	if warehouseID == "" {
+		if warehouseID, err = warehouse.Default(ctx, tx, user.Tenant); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := insertVariant(ctx, tx, v); err != nil {
+		return nil, err
+	}
+
+	if v.Quantity > 0 {
+		if err := warehouse.Deposit(ctx, tx, user.Tenant, warehouseID, v.ID, v.Quantity); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := outbox.Record(ctx, tx, user.Tenant, outbox.AggregateVariant, v.ID, outbox.VariantAdded, v, now); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("committing variant insert: %w", err)
+	}
+
+	return &v, nil
+}
+
+func EditVariant(ctx context.Context, db *sqlx.DB, user auth.Claims, id string, update UpdateVariant, now time.Time) error {
+	ctx, span := trace.StartSpan(ctx, "internal.product.EditVariant")
+	defer span.End()
+
+	v, err := RetrieveVariant(ctx, db, user.Tenant, id)
+	if err != nil {
+		return err
+	}
+
+	p, err := Retrieve(ctx, db, user.Tenant, v.ProductID)
+	if err != nil {
+		return err
+	}
+
+	if err := authz.Check(user, authz.Edit, p.resource()); err != nil {
+		return err
+	}
+
+	if update.SKU != nil {
+		v.SKU = *update.SKU
+	}
+
+	if update.Attributes != nil {
+		v.Attributes = update.Attributes
+	}
+
+	if update.Cost != nil {
+		v.Cost = update.Cost
+	}
+
+	v.DateUpdated = now
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("starting variant update: %w", err)
+	}
+	defer tx.Rollback()
+
+	const q = `update variants set sku = $2, attributes = $3, cost = $4, date_updated = $5 where variant_id = $1`
+	_, err = tx.ExecContext(ctx, q, v.ID, v.SKU, v.Attributes, v.Cost, v.DateUpdated)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrDuplicateSKU
+		}
+		return fmt.Errorf("updating variant: %w", err)
+	}
+
+	if err := outbox.Record(ctx, tx, user.Tenant, outbox.AggregateVariant, v.ID, outbox.VariantUpdated, v, now); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing variant update: %w", err)
+	}
+	return nil
+}
+
+// insertVariant writes a new variant as part of tx.
+func insertVariant(ctx context.Context, tx *sqlx.Tx, v Variant) error {
+	const q = `INSERT INTO variants
+		(variant_id, product_id, sku, attributes, cost, date_created, date_updated)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	_, err := tx.ExecContext(ctx, q,
+		v.ID, v.ProductID, v.SKU, v.Attributes,
+		v.Cost, v.DateCreated, v.DateUpdated)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrDuplicateSKU
+		}
+		return fmt.Errorf("inserting variant: %w", err)
+	}
+
+	return nil
+}
+
+// defaultSKU derives a SKU from a product ID for products created without one.
+func defaultSKU(productID string) string {
+	return strings.ToUpper(strings.Replace(productID, "-", "", -1))
+}
+
+// isUniqueViolation reports whether err was caused by a duplicate key.
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
@@ -0,0 +1,76 @@
+package product_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/money"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/product"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/tests"
+)
+
+func TestVariants(t *testing.T) {
+	db, teardown := tests.NewUnit(t)
+	defer teardown()
+
+	now := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+
+	claims := auth.NewClaims(
+		"718ffbea-f4a1-4667-8ae3-b349da52675e",
+		[]string{auth.RoleAdmin, auth.RoleUser},
+		now, time.Hour,
+	)
+	claims.Permissions = auth.Permissions
+	claims.Tenant = tests.TenantID
+
+	shirt, err := product.Create(ctx, db, claims, product.NewProduct{Name: "Shirt", SKU: "SHIRT-S", Cost: money.Money{Amount: 20, Currency: "USD"}, Quantity: 5}, now)
+	if err != nil {
+		t.Fatalf("creating product: %s", err)
+	}
+
+	nv := product.NewVariant{
+		SKU:        "SHIRT-L",
+		Attributes: product.Attributes{"size": "large"},
+		Cost:       tests.IntPointer(25),
+		Quantity:   2,
+	}
+	large, err := product.AddVariant(ctx, db, claims, shirt.ID, nv, now)
+	if err != nil {
+		t.Fatalf("adding variant: %s", err)
+	}
+
+	if _, err := product.AddVariant(ctx, db, claims, shirt.ID, nv, now); err != product.ErrDuplicateSKU {
+		t.Fatalf("expected reusing a SKU to fail with %v, got %v", product.ErrDuplicateSKU, err)
+	}
+
+	variants, err := product.ListVariants(ctx, db, tests.TenantID, shirt.ID)
+	if err != nil {
+		t.Fatalf("listing variants: %s", err)
+	}
+	if exp, got := 2, len(variants); exp != got {
+		t.Fatalf("expected variant list size %v, got %v", exp, got)
+	}
+
+	ns := product.NewSale{VariantID: large.ID, Quantity: 2}
+	s, err := product.AddSale(ctx, db, claims, ns, shirt.ID, now)
+	if err != nil {
+		t.Fatalf("adding sale: %s", err)
+	}
+	if exp, got := large.ID, s.VariantID; exp != got {
+		t.Fatalf("expected sale of variant %v, got %v", exp, got)
+	}
+	if exp, got := int64(50), s.Paid.Amount; exp != got {
+		t.Fatalf("expected sale priced at the variant cost %v, got %v", exp, got)
+	}
+
+	saved, err := product.Retrieve(ctx, db, tests.TenantID, shirt.ID)
+	if err != nil {
+		t.Fatalf("getting product: %s", err)
+	}
+	if exp, got := 5, saved.Quantity; exp != got {
+		t.Fatalf("expected remaining quantity %v, got %v", exp, got)
+	}
+}
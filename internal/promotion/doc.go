@@ -0,0 +1,3 @@
+// Package promotion implements the discounts, offers and coupons applied when
+// pricing a sale.
+package promotion
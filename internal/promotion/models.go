@@ -0,0 +1,72 @@
+package promotion
+
+import (
+	"time"
+
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/money"
+)
+
+// Kinds of promotion.
+const (
+	// KindPercentage takes Percent percent off the line.
+	KindPercentage = "percentage"
+
+	// KindFixed takes Amount off the line.
+	KindFixed = "fixed"
+
+	// KindBuyXGetY gives FreeQuantity units free for every BuyQuantity
+	// units paid for.
+	KindBuyXGetY = "buy_x_get_y"
+)
+
+// Promotion is a discount applied to sales. It applies to a single product
+// when ProductID is set and to every product otherwise. Promotions with a
+// CouponCode only apply when the code is presented. A promotion is live while
+// it is Active, between StartsAt and EndsAt when set, and until UsageCount
+// reaches UsageLimit when set.
+type Promotion struct {
+	ID           string      `db:"promotion_id" json:"id"`
+	TenantID     string      `db:"tenant_id" json:"-"`
+	Name         string      `db:"name" json:"name"`
+	Kind         string      `db:"kind" json:"kind"`
+	Percent      int         `db:"percent" json:"percent,omitempty"`
+	Amount       money.Money `db:"amount" json:"amount"`
+	BuyQuantity  int         `db:"buy_quantity" json:"buy_quantity,omitempty"`
+	FreeQuantity int         `db:"free_quantity" json:"free_quantity,omitempty"`
+	ProductID    *string     `db:"product_id" json:"product_id"`
+	CouponCode   *string     `db:"coupon_code" json:"coupon_code"`
+	UsageLimit   *int        `db:"usage_limit" json:"usage_limit"`
+	UsageCount   int         `db:"usage_count" json:"usage_count"`
+	StartsAt     *time.Time  `db:"starts_at" json:"starts_at"`
+	EndsAt       *time.Time  `db:"ends_at" json:"ends_at"`
+	Active       bool        `db:"active" json:"active"`
+	DateCreated  time.Time   `db:"date_created" json:"date_created"`
+}
+
+type NewPromotion struct {
+	Name         string      `json:"name" validate:"required"`
+	Kind         string      `json:"kind" validate:"required,oneof=percentage fixed buy_x_get_y"`
+	Percent      int         `json:"percent" validate:"gte=0,lte=100"`
+	Amount       money.Money `json:"amount"`
+	BuyQuantity  int         `json:"buy_quantity" validate:"gte=0"`
+	FreeQuantity int         `json:"free_quantity" validate:"gte=0"`
+	ProductID    *string     `json:"product_id" validate:"omitempty,uuid"`
+	CouponCode   *string     `json:"coupon_code" validate:"omitempty,min=3"`
+	UsageLimit   *int        `json:"usage_limit" validate:"omitempty,gte=1"`
+	StartsAt     *time.Time  `json:"starts_at"`
+	EndsAt       *time.Time  `json:"ends_at"`
+}
+
+// Applied records how much a promotion took off a sale.
+type Applied struct {
+	PromotionID string      `db:"promotion_id" json:"promotion_id"`
+	Name        string      `db:"name" json:"name"`
+	Discount    money.Money `db:"discount" json:"discount"`
+}
+
+// Price is the outcome of pricing a sale.
+type Price struct {
+	Subtotal money.Money `json:"subtotal"`
+	Total    money.Money `json:"total"`
+	Applied  []Applied   `json:"applied"`
+}
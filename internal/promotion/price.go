@@ -0,0 +1,80 @@
+package promotion
+
+import (
+	"math/big"
+	"sort"
+
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/money"
+)
+
+// order decides which kinds of promotion apply first: free units come off
+// before percentages, and fixed amounts come off last.
+var order = map[string]int{
+	KindBuyXGetY:   0,
+	KindPercentage: 1,
+	KindFixed:      2,
+}
+
+// Apply prices quantity units at unit and takes every applicable promotion
+// off in turn. Each promotion discounts what is left after the ones before
+// it and the total never drops below zero. Fixed amounts in a currency other
+// than unit's do not apply.
+func Apply(unit money.Money, quantity int, promos []Promotion) Price {
+	sorted := make([]Promotion, len(promos))
+	copy(sorted, promos)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return order[sorted[i].Kind] < order[sorted[j].Kind]
+	})
+
+	subtotal := unit.Times(int64(quantity))
+	p := Price{
+		Subtotal: subtotal,
+		Total:    subtotal,
+		Applied:  []Applied{},
+	}
+
+	for _, promo := range sorted {
+		var discount money.Money
+
+		switch promo.Kind {
+		case KindBuyXGetY:
+			group := promo.BuyQuantity + promo.FreeQuantity
+			if promo.BuyQuantity <= 0 || promo.FreeQuantity <= 0 || quantity < group {
+				continue
+			}
+			free := (quantity / group) * promo.FreeQuantity
+			discount = unit.Times(int64(free))
+
+		case KindPercentage:
+			if promo.Percent <= 0 {
+				continue
+			}
+			discount = p.Total.Mul(big.NewRat(int64(promo.Percent), 100), money.HalfUp)
+
+		case KindFixed:
+			if promo.Amount.Currency != unit.Currency || promo.Amount.Amount <= 0 {
+				continue
+			}
+			discount = promo.Amount
+
+		default:
+			continue
+		}
+
+		if discount.Amount > p.Total.Amount {
+			discount.Amount = p.Total.Amount
+		}
+		if discount.Amount <= 0 {
+			continue
+		}
+
+		p.Total.Amount -= discount.Amount
+		p.Applied = append(p.Applied, Applied{
+			PromotionID: promo.ID,
+			Name:        promo.Name,
+			Discount:    discount,
+		})
+	}
+
+	return p
+}
@@ -0,0 +1,53 @@
+package promotion_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/money"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/promotion"
+)
+
+func TestApply(t *testing.T) {
+	unit := money.Money{Amount: 999, Currency: "USD"}
+
+	tenOff := promotion.Promotion{ID: "p1", Name: "10% off", Kind: promotion.KindPercentage, Percent: 10}
+	threeForTwo := promotion.Promotion{ID: "p2", Name: "3 for 2", Kind: promotion.KindBuyXGetY, BuyQuantity: 2, FreeQuantity: 1}
+	fiveOff := promotion.Promotion{ID: "p3", Name: "$5 off", Kind: promotion.KindFixed, Amount: money.Money{Amount: 500, Currency: "USD"}}
+	euroOff := promotion.Promotion{ID: "p4", Name: "5 EUR off", Kind: promotion.KindFixed, Amount: money.Money{Amount: 500, Currency: "EUR"}}
+	hugeOff := promotion.Promotion{ID: "p5", Name: "$100 off", Kind: promotion.KindFixed, Amount: money.Money{Amount: 10000, Currency: "USD"}}
+
+	tests := []struct {
+		name     string
+		quantity int
+		promos   []promotion.Promotion
+		total    int64
+		applied  []string
+	}{
+		{"no promotions", 3, nil, 2997, []string{}},
+		{"percentage rounds half up", 1, []promotion.Promotion{tenOff}, 899, []string{"p1"}},
+		{"buy x get y", 7, []promotion.Promotion{threeForTwo}, 4995, []string{"p2"}},
+		{"buy x get y below threshold", 2, []promotion.Promotion{threeForTwo}, 1998, []string{}},
+		{"stacked in kind order", 3, []promotion.Promotion{fiveOff, tenOff, threeForTwo}, 1298, []string{"p2", "p1", "p3"}},
+		{"fixed in other currency ignored", 1, []promotion.Promotion{euroOff}, 999, []string{}},
+		{"never below zero", 1, []promotion.Promotion{hugeOff, fiveOff}, 0, []string{"p5"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := promotion.Apply(unit, tt.quantity, tt.promos)
+
+			if exp, got := tt.total, p.Total.Amount; exp != got {
+				t.Fatalf("expected total %v, got %v", exp, got)
+			}
+
+			applied := []string{}
+			for _, a := range p.Applied {
+				applied = append(applied, a.PromotionID)
+			}
+			if diff := cmp.Diff(tt.applied, applied); diff != "" {
+				t.Fatalf("applied promotions did not match:\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,255 @@
+package promotion
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/money"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/database"
+	"go.opencensus.io/trace"
+)
+
+var (
+	ErrNotFound        = errors.New("promotion not found")
+	ErrInvalidID       = errors.New("ID is not in its proper form")
+	ErrInvalidCoupon   = errors.New("coupon is unknown, expired or used up")
+	ErrDuplicateCoupon = errors.New("coupon code already in use")
+	ErrInvalidTerms    = errors.New("promotion terms do not match its kind")
+	ErrProductNotFound = errors.New("product not found")
+)
+
+const selectPromotions = `SELECT promotion_id, tenant_id, name, kind, percent,
+		amount AS "amount.amount", currency AS "amount.currency",
+		buy_quantity, free_quantity, product_id, coupon_code,
+		usage_limit, usage_count, starts_at, ends_at, active, date_created
+	FROM promotions`
+
+func List(ctx context.Context, db *sqlx.DB, tenantID string) ([]Promotion, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.promotion.List")
+	defer span.End()
+
+	var promos []Promotion
+
+	const q = selectPromotions + ` WHERE tenant_id = $1 ORDER BY date_created`
+	if err := db.SelectContext(ctx, &promos, q, tenantID); err != nil {
+		return nil, fmt.Errorf("selecting promotions: %w", err)
+	}
+
+	return promos, nil
+}
+
+func Retrieve(ctx context.Context, db *sqlx.DB, tenantID, id string) (*Promotion, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.promotion.Retrieve")
+	defer span.End()
+
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrInvalidID
+	}
+
+	var p Promotion
+
+	const q = selectPromotions + ` WHERE tenant_id = $1 AND promotion_id = $2`
+	if err := db.GetContext(ctx, &p, q, tenantID, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+
+		return nil, fmt.Errorf("selecting single promotion: %w", err)
+	}
+
+	return &p, nil
+}
+
+// Create adds a promotion of tenantID. A promotion of a single product can
+// only name one of the tenant's own products.
+func Create(ctx context.Context, db *sqlx.DB, tenantID string, np NewPromotion, now time.Time) (*Promotion, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.promotion.Create")
+	defer span.End()
+
+	p := Promotion{
+		ID:           uuid.New().String(),
+		TenantID:     tenantID,
+		Name:         np.Name,
+		Kind:         np.Kind,
+		Percent:      np.Percent,
+		BuyQuantity:  np.BuyQuantity,
+		FreeQuantity: np.FreeQuantity,
+		ProductID:    np.ProductID,
+		UsageLimit:   np.UsageLimit,
+		StartsAt:     np.StartsAt,
+		EndsAt:       np.EndsAt,
+		Active:       true,
+		DateCreated:  now.UTC(),
+	}
+
+	amount, err := money.New(np.Amount.Amount, np.Amount.Currency)
+	if err != nil {
+		return nil, err
+	}
+	p.Amount = amount
+
+	switch {
+	case p.Kind == KindPercentage && p.Percent == 0,
+		p.Kind == KindFixed && p.Amount.Amount == 0,
+		p.Kind == KindBuyXGetY && (p.BuyQuantity == 0 || p.FreeQuantity == 0),
+		p.StartsAt != nil && p.EndsAt != nil && !p.EndsAt.After(*p.StartsAt):
+		return nil, ErrInvalidTerms
+	}
+
+	if np.CouponCode != nil {
+		code := normalizeCode(*np.CouponCode)
+		p.CouponCode = &code
+	}
+
+	const q = `INSERT INTO promotions
+		(promotion_id, tenant_id, name, kind, percent, amount, currency, buy_quantity, free_quantity,
+		product_id, coupon_code, usage_limit, starts_at, ends_at, date_created)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)`
+	_, err = db.ExecContext(ctx, q,
+		p.ID, p.TenantID, p.Name, p.Kind, p.Percent, p.Amount.Amount, p.Amount.Currency,
+		p.BuyQuantity, p.FreeQuantity, p.ProductID, p.CouponCode, p.UsageLimit,
+		p.StartsAt, p.EndsAt, p.DateCreated)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) {
+			switch pqErr.Code {
+			case "23505":
+				return nil, ErrDuplicateCoupon
+			case "23503":
+				return nil, ErrProductNotFound
+			}
+		}
+		return nil, fmt.Errorf("inserting promotion: %w", err)
+	}
+
+	return &p, nil
+}
+
+// Deactivate stops a promotion of tenantID from applying to any further
+// sales. Sales it already applied to keep their discount.
+func Deactivate(ctx context.Context, db *sqlx.DB, tenantID, id string) error {
+	ctx, span := trace.StartSpan(ctx, "internal.promotion.Deactivate")
+	defer span.End()
+
+	if _, err := uuid.Parse(id); err != nil {
+		return ErrInvalidID
+	}
+
+	const q = `UPDATE promotions SET active = FALSE WHERE tenant_id = $1 AND promotion_id = $2`
+	res, err := db.ExecContext(ctx, q, tenantID, id)
+	if err != nil {
+		return fmt.Errorf("deactivating promotion: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Live returns the promotions of tenantID that apply to a sale of productID at
+// now: every live automatic promotion plus the promotion for coupon when one
+// is given.
+// The rows are locked until tx ends so that usage limits hold under
+// concurrent sales. It returns ErrInvalidCoupon when coupon does not name a
+// live promotion for the product.
+func Live(ctx context.Context, tx *sqlx.Tx, tenantID, productID, coupon string, now time.Time) ([]Promotion, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.promotion.Live")
+	defer span.End()
+
+	var promos []Promotion
+
+	const q = selectPromotions + `
+		WHERE tenant_id = $1 AND active
+		AND (product_id IS NULL OR product_id = $2)
+		AND (coupon_code IS NULL OR coupon_code = $3)
+		AND (starts_at IS NULL OR starts_at <= $4)
+		AND (ends_at IS NULL OR ends_at > $4)
+		AND (usage_limit IS NULL OR usage_count < usage_limit)
+		ORDER BY date_created
+		FOR UPDATE`
+	code := normalizeCode(coupon)
+	if err := tx.SelectContext(ctx, &promos, q, tenantID, productID, code, now.UTC()); err != nil {
+		return nil, fmt.Errorf("selecting live promotions: %w", err)
+	}
+
+	if code != "" {
+		found := false
+		for _, p := range promos {
+			if p.CouponCode != nil && *p.CouponCode == code {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, ErrInvalidCoupon
+		}
+	}
+
+	return promos, nil
+}
+
+// Redeem records the promotions applied to a sale and counts them against
+// their usage limits as part of tx.
+func Redeem(ctx context.Context, tx *sqlx.Tx, saleID string, applied []Applied) error {
+	ctx, span := trace.StartSpan(ctx, "internal.promotion.Redeem")
+	defer span.End()
+
+	const q = `INSERT INTO sale_promotions (sale_id, promotion_id, discount) VALUES ($1, $2, $3)`
+	const u = `UPDATE promotions SET usage_count = usage_count + 1 WHERE promotion_id = $1`
+
+	for _, a := range applied {
+		if _, err := tx.ExecContext(ctx, q, saleID, a.PromotionID, a.Discount.Amount); err != nil {
+			return fmt.Errorf("inserting sale promotion: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, u, a.PromotionID); err != nil {
+			return fmt.Errorf("counting promotion use: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// ForSales returns the promotions applied to each of the given sales of
+// tenantID, keyed by sale ID.
+func ForSales(ctx context.Context, db *sqlx.DB, tenantID string, saleIDs []string) (map[string][]Applied, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.promotion.ForSales")
+	defer span.End()
+
+	var rows []struct {
+		SaleID string `db:"sale_id"`
+		Applied
+	}
+
+	const q = `SELECT sp.sale_id, sp.promotion_id, p.name,
+			sp.discount AS "discount.amount", s.currency AS "discount.currency"
+		FROM sale_promotions AS sp
+		JOIN promotions AS p ON p.promotion_id = sp.promotion_id
+		JOIN sales AS s ON s.sale_id = sp.sale_id
+		WHERE s.tenant_id = $1 AND sp.sale_id = ANY($2)
+		ORDER BY sp.sale_id, p.date_created`
+	err := database.WithTenant(ctx, db, tenantID, func(tx *sqlx.Tx) error {
+		return tx.SelectContext(ctx, &rows, q, tenantID, pq.StringArray(saleIDs))
+	})
+	if err != nil {
+		return nil, fmt.Errorf("selecting sale promotions: %w", err)
+	}
+
+	applied := make(map[string][]Applied)
+	for _, r := range rows {
+		applied[r.SaleID] = append(applied[r.SaleID], r.Applied)
+	}
+
+	return applied, nil
+}
+
+func normalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
@@ -0,0 +1,101 @@
+package promotion_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/money"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/product"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/promotion"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/tenant"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/tests"
+)
+
+func TestPromotions(t *testing.T) {
+	db, teardown := tests.NewUnit(t)
+	defer teardown()
+
+	now := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+
+	claims := auth.NewClaims(tests.AdminID, []string{auth.RoleAdmin}, now, time.Hour)
+	claims.Permissions = auth.Permissions
+	claims.Tenant = tests.TenantID
+
+	np := product.NewProduct{Name: "Kite", Cost: money.Money{Amount: 1000, Currency: "USD"}, Quantity: 20}
+	kite, err := product.Create(ctx, db, claims, np, now)
+	if err != nil {
+		t.Fatalf("creating product: %s", err)
+	}
+
+	ends := now.Add(24 * time.Hour)
+	promos := []promotion.NewPromotion{
+		{Name: "Kite sale", Kind: promotion.KindPercentage, Percent: 10, ProductID: &kite.ID, EndsAt: &ends},
+		{Name: "Launch coupon", Kind: promotion.KindFixed, Amount: money.Money{Amount: 200}, CouponCode: tests.StringPointer("launch"), UsageLimit: tests.IntPointer(1)},
+	}
+	for _, p := range promos {
+		if _, err := promotion.Create(ctx, db, tests.TenantID, p, now); err != nil {
+			t.Fatalf("creating promotion: %s", err)
+		}
+	}
+
+	if _, err := promotion.Create(ctx, db, tests.TenantID, promos[1], now); err != promotion.ErrDuplicateCoupon {
+		t.Fatalf("expected reusing a coupon code to fail with %v, got %v", promotion.ErrDuplicateCoupon, err)
+	}
+
+	// Another tenant cannot discount the kite, and neither its promotions
+	// nor its coupons touch this tenant's sales.
+	other, err := tenant.Create(ctx, db, tenant.NewTenant{Name: "Corner Shop"}, now)
+	if err != nil {
+		t.Fatalf("creating tenant: %s", err)
+	}
+	if _, err := promotion.Create(ctx, db, other.ID, promos[0], now); err != promotion.ErrProductNotFound {
+		t.Fatalf("expected promoting another tenant's product to fail with %v, got %v", promotion.ErrProductNotFound, err)
+	}
+	everything := promotion.NewPromotion{Name: "Everything", Kind: promotion.KindPercentage, Percent: 50, CouponCode: tests.StringPointer("launch")}
+	if _, err := promotion.Create(ctx, db, other.ID, everything, now); err != nil {
+		t.Fatalf("creating another tenant's promotion: %s", err)
+	}
+	if list, err := promotion.List(ctx, db, tests.TenantID); err != nil || len(list) != 2 {
+		t.Fatalf("expected 2 promotions listed, got %v, %v", list, err)
+	}
+
+	// 10% off 1000 leaves 900, and the coupon takes 200 more.
+	s, err := product.AddSale(ctx, db, claims, product.NewSale{Quantity: 1, Coupon: "LAUNCH"}, kite.ID, now)
+	if err != nil {
+		t.Fatalf("adding sale: %s", err)
+	}
+	if exp, got := int64(700), s.Paid.Amount; exp != got {
+		t.Fatalf("expected sale priced at %v, got %v", exp, got)
+	}
+	if exp, got := 2, len(s.Promotions); exp != got {
+		t.Fatalf("expected %v promotions applied, got %v", exp, got)
+	}
+
+	if _, err := product.AddSale(ctx, db, claims, product.NewSale{Quantity: 1, Coupon: "launch"}, kite.ID, now); err != promotion.ErrInvalidCoupon {
+		t.Fatalf("expected a used up coupon to fail with %v, got %v", promotion.ErrInvalidCoupon, err)
+	}
+
+	// The kite sale has ended by the next day.
+	s, err = product.AddSale(ctx, db, claims, product.NewSale{Quantity: 1}, kite.ID, ends)
+	if err != nil {
+		t.Fatalf("adding sale: %s", err)
+	}
+	if exp, got := int64(1000), s.Paid.Amount; exp != got {
+		t.Fatalf("expected sale priced at %v, got %v", exp, got)
+	}
+
+	sales, err := product.ListSales(ctx, db, tests.TenantID, kite.ID)
+	if err != nil {
+		t.Fatalf("listing sales: %s", err)
+	}
+	applied := 0
+	for _, s := range sales {
+		applied += len(s.Promotions)
+	}
+	if exp, got := 2, applied; exp != got {
+		t.Fatalf("expected %v recorded promotions, got %v", exp, got)
+	}
+}
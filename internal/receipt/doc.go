@@ -0,0 +1,3 @@
+// Package receipt issues invoice numbers for sales and renders their
+// receipts as HTML or plain text.
+package receipt
@@ -0,0 +1,81 @@
+package receipt
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"go.opencensus.io/trace"
+)
+
+// Issue returns the invoice of a sale of tenantID, allocating the tenant's
+// next invoice number the first time it is asked for. Allocation takes a
+// lock on the tenant's counter row, so its numbers are handed out in order
+// and a rolled back allocation never leaves a gap.
+func Issue(ctx context.Context, db *sqlx.DB, tenantID, saleID string, now time.Time) (*Invoice, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.receipt.Issue")
+	defer span.End()
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("starting invoice: %w", err)
+	}
+	defer tx.Rollback()
+
+	var last int64
+	const c = `SELECT last_number FROM invoice_counter WHERE tenant_id = $1 FOR UPDATE`
+	if err := tx.GetContext(ctx, &last, c, tenantID); err != nil {
+		return nil, fmt.Errorf("locking invoice counter: %w", err)
+	}
+
+	// Checking after taking the lock means a concurrent request for the same
+	// sale sees the invoice the first one issued.
+	var inv Invoice
+	const q = `SELECT invoice_number, sale_id, date_issued FROM invoices
+		WHERE tenant_id = $1 AND sale_id = $2`
+	err = tx.GetContext(ctx, &inv, q, tenantID, saleID)
+	switch err {
+	case nil:
+		return &inv, nil
+	case sql.ErrNoRows:
+	default:
+		return nil, fmt.Errorf("selecting invoice: %w", err)
+	}
+
+	inv = Invoice{
+		Number:     last + 1,
+		SaleID:     saleID,
+		DateIssued: now.UTC(),
+	}
+
+	const u = `UPDATE invoice_counter SET last_number = $2 WHERE tenant_id = $1`
+	if _, err := tx.ExecContext(ctx, u, tenantID, inv.Number); err != nil {
+		return nil, fmt.Errorf("advancing invoice counter: %w", err)
+	}
+
+	const i = `INSERT INTO invoices (tenant_id, invoice_number, sale_id, date_issued) VALUES ($1, $2, $3, $4)`
+	if _, err := tx.ExecContext(ctx, i, tenantID, inv.Number, inv.SaleID, inv.DateIssued); err != nil {
+		return nil, fmt.Errorf("inserting invoice: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("committing invoice: %w", err)
+	}
+
+	return &inv, nil
+}
+
+// StartNumbering gives a new tenant the counter its invoices are numbered
+// from, starting at 1.
+func StartNumbering(ctx context.Context, db sqlx.ExecerContext, tenantID string) error {
+	ctx, span := trace.StartSpan(ctx, "internal.receipt.StartNumbering")
+	defer span.End()
+
+	const q = `INSERT INTO invoice_counter (tenant_id, last_number) VALUES ($1, 0)`
+	if _, err := db.ExecContext(ctx, q, tenantID); err != nil {
+		return fmt.Errorf("inserting invoice counter: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package receipt_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/money"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/product"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/receipt"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/schema"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/tenant"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/tests"
+)
+
+func TestIssue(t *testing.T) {
+	db, teardown := tests.NewUnit(t)
+	defer teardown()
+
+	if err := schema.Seed(db); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2019, time.March, 1, 0, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+
+	sales := []string{
+		"85f6fb09-eb05-4874-ae39-82d1a30fe0d7",
+		"98b6d4b8-f04b-4c79-8c2e-a0aef46854b7",
+		"85f6fb09-eb05-4874-ae39-82d1a30fe0d7",
+		"a235be9e-ab5d-44e6-a987-fa1c749264c7",
+	}
+	want := []int64{1, 2, 1, 3}
+
+	for i, id := range sales {
+		inv, err := receipt.Issue(ctx, db, tests.TenantID, id, now)
+		if err != nil {
+			t.Fatalf("issuing invoice: %s", err)
+		}
+		if exp, got := want[i], inv.Number; exp != got {
+			t.Fatalf("expected invoice number %v for sale %v, got %v", exp, id, got)
+		}
+	}
+
+	rc, err := receipt.Build(ctx, db, tests.TenantID, sales[1], now)
+	if err != nil {
+		t.Fatalf("building receipt: %s", err)
+	}
+	if exp, got := "Comic Books", rc.Product.Name; exp != got {
+		t.Fatalf("expected receipt for %q, got %q", exp, got)
+	}
+	if exp, got := int64(2), rc.Invoice.Number; exp != got {
+		t.Fatalf("expected invoice number %v, got %v", exp, got)
+	}
+
+	// Another tenant numbers its invoices from its own counter.
+	other, err := tenant.Create(ctx, db, tenant.NewTenant{Name: "Corner Shop"}, now)
+	if err != nil {
+		t.Fatalf("creating tenant: %s", err)
+	}
+	theirs := auth.NewClaims(tests.AdminID, []string{auth.RoleAdmin}, now, time.Hour)
+	theirs.Permissions = auth.Permissions
+	theirs.Tenant = other.ID
+
+	np := product.NewProduct{
+		Name:     "Marbles",
+		Cost:     money.Money{Amount: 10, Currency: "USD"},
+		Quantity: 5,
+	}
+	p, err := product.Create(ctx, db, theirs, np, now)
+	if err != nil {
+		t.Fatalf("creating product: %s", err)
+	}
+	s, err := product.AddSale(ctx, db, theirs, product.NewSale{Quantity: 1}, p.ID, now)
+	if err != nil {
+		t.Fatalf("adding sale: %s", err)
+	}
+
+	inv, err := receipt.Issue(ctx, db, other.ID, s.ID, now)
+	if err != nil {
+		t.Fatalf("issuing invoice: %s", err)
+	}
+	if exp, got := int64(1), inv.Number; exp != got {
+		t.Fatalf("expected the other tenant's first invoice number %v, got %v", exp, got)
+	}
+}
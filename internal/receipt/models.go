@@ -0,0 +1,23 @@
+package receipt
+
+import (
+	"time"
+
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/product"
+)
+
+// Invoice ties a sale to its invoice number. Numbers start at 1 and are
+// allocated one after another without gaps.
+type Invoice struct {
+	Number     int64     `db:"invoice_number" json:"number"`
+	SaleID     string    `db:"sale_id" json:"sale_id"`
+	DateIssued time.Time `db:"date_issued" json:"date_issued"`
+}
+
+// Receipt is everything the receipt templates are executed with.
+type Receipt struct {
+	Invoice Invoice
+	Sale    product.Sale
+	Product product.Product
+	Variant product.Variant
+}
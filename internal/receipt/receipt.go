@@ -0,0 +1,46 @@
+package receipt
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/product"
+	"go.opencensus.io/trace"
+)
+
+// Build gathers the receipt of a sale of tenantID, issuing its invoice if it
+// has none.
+func Build(ctx context.Context, db *sqlx.DB, tenantID, saleID string, now time.Time) (*Receipt, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.receipt.Build")
+	defer span.End()
+
+	s, err := product.RetrieveSale(ctx, db, tenantID, saleID)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := product.Retrieve(ctx, db, tenantID, s.ProductID)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := product.RetrieveVariant(ctx, db, tenantID, s.VariantID)
+	if err != nil {
+		return nil, err
+	}
+
+	inv, err := Issue(ctx, db, tenantID, s.ID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	r := Receipt{
+		Invoice: *inv,
+		Sale:    *s,
+		Product: *p,
+		Variant: *v,
+	}
+
+	return &r, nil
+}
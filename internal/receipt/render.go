@@ -0,0 +1,114 @@
+package receipt
+
+import (
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	texttemplate "text/template"
+)
+
+// Formats a receipt can be rendered in.
+const (
+	FormatHTML = "html"
+	FormatText = "text"
+)
+
+var ErrUnknownFormat = errors.New("receipt format must be html or text")
+
+// funcs are available to every receipt template.
+var funcs = map[string]interface{}{
+	"invoice": func(n int64) string { return fmt.Sprintf("%08d", n) },
+	"percent": percent,
+}
+
+// Renderer executes the receipt templates.
+type Renderer struct {
+	html *htmltemplate.Template
+	text *texttemplate.Template
+}
+
+// NewRenderer parses the receipt templates. When dir is not empty, a
+// receipt.html or receipt.txt file in it replaces the built in template of
+// that format.
+func NewRenderer(dir string) (*Renderer, error) {
+	htmlSrc, err := source(dir, htmlName, defaultHTML)
+	if err != nil {
+		return nil, err
+	}
+	textSrc, err := source(dir, textName, defaultText)
+	if err != nil {
+		return nil, err
+	}
+
+	var r Renderer
+
+	r.html, err = htmltemplate.New(htmlName).Funcs(funcs).Parse(htmlSrc)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", htmlName, err)
+	}
+	r.text, err = texttemplate.New(textName).Funcs(funcs).Parse(textSrc)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", textName, err)
+	}
+
+	return &r, nil
+}
+
+// Render writes rc to w in format.
+func (r *Renderer) Render(w io.Writer, format string, rc Receipt) error {
+	switch format {
+	case FormatHTML:
+		return r.html.Execute(w, rc)
+	case FormatText:
+		return r.text.Execute(w, rc)
+	default:
+		return ErrUnknownFormat
+	}
+}
+
+// ContentType is the media type of a receipt rendered in format.
+func ContentType(format string) string {
+	if format == FormatHTML {
+		return "text/html; charset=utf-8"
+	}
+	return "text/plain; charset=utf-8"
+}
+
+// source returns the contents of name in dir, or def when dir is empty or
+// holds no such file.
+func source(dir, name, def string) (string, error) {
+	if dir == "" {
+		return def, nil
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return def, nil
+		}
+		return "", fmt.Errorf("reading %s: %w", name, err)
+	}
+
+	return string(b), nil
+}
+
+// percent formats a decimal fraction rate such as "0.190000" as "19%".
+func percent(rate *string) string {
+	if rate == nil {
+		return ""
+	}
+	r, ok := new(big.Rat).SetString(*rate)
+	if !ok {
+		return *rate
+	}
+	r.Mul(r, big.NewRat(100, 1))
+	if r.IsInt() {
+		return r.Num().String() + "%"
+	}
+	return r.FloatString(2) + "%"
+}
@@ -0,0 +1,100 @@
+package receipt_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/money"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/product"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/promotion"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/receipt"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/tests"
+)
+
+func TestRender(t *testing.T) {
+	now := time.Date(2019, time.March, 1, 12, 0, 0, 0, time.UTC)
+	eur := func(n int64) money.Money { return money.Money{Amount: n, Currency: "EUR"} }
+
+	rc := receipt.Receipt{
+		Invoice: receipt.Invoice{Number: 42, DateIssued: now},
+		Sale: product.Sale{
+			Quantity:     2,
+			ListPrice:    eur(2000),
+			Net:          eur(1800),
+			Tax:          eur(342),
+			Paid:         eur(2142),
+			TaxRate:      tests.StringPointer("0.190000"),
+			CustomerName: tests.StringPointer("Ada <Lovelace>"),
+			Promotions: []promotion.Applied{
+				{Name: "10% off", Discount: eur(200)},
+			},
+			DateCreated: now,
+		},
+		Product: product.Product{Name: "Kite"},
+		Variant: product.Variant{SKU: "KITE-1"},
+	}
+
+	r, err := receipt.NewRenderer("")
+	if err != nil {
+		t.Fatalf("creating renderer: %s", err)
+	}
+
+	var text bytes.Buffer
+	if err := r.Render(&text, receipt.FormatText, rc); err != nil {
+		t.Fatalf("rendering text: %s", err)
+	}
+	for _, want := range []string{"INVOICE 00000042", "Kite (KITE-1) x 2", "10% off    -EUR 2.00", "Tax 19%    EUR 3.42", "Total    EUR 21.42", "Ada <Lovelace>"} {
+		if !strings.Contains(text.String(), want) {
+			t.Errorf("expected text receipt to contain %q, got:\n%s", want, text.String())
+		}
+	}
+
+	var html bytes.Buffer
+	if err := r.Render(&html, receipt.FormatHTML, rc); err != nil {
+		t.Fatalf("rendering html: %s", err)
+	}
+	if !strings.Contains(html.String(), "Ada &lt;Lovelace&gt;") {
+		t.Errorf("expected the customer name to be escaped, got:\n%s", html.String())
+	}
+
+	if err := r.Render(&html, "pdf", rc); err != receipt.ErrUnknownFormat {
+		t.Errorf("expected rendering pdf to fail with %v, got %v", receipt.ErrUnknownFormat, err)
+	}
+
+	// A template in the directory replaces the built in one of its format only.
+	dir, err := ioutil.TempDir("", "receipts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "receipt.txt"), []byte("No. {{invoice .Invoice.Number}}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err = receipt.NewRenderer(dir)
+	if err != nil {
+		t.Fatalf("creating renderer: %s", err)
+	}
+
+	text.Reset()
+	if err := r.Render(&text, receipt.FormatText, rc); err != nil {
+		t.Fatalf("rendering text: %s", err)
+	}
+	if exp, got := "No. 00000042", text.String(); exp != got {
+		t.Errorf("expected overridden receipt %q, got %q", exp, got)
+	}
+
+	html.Reset()
+	if err := r.Render(&html, receipt.FormatHTML, rc); err != nil {
+		t.Fatalf("rendering html: %s", err)
+	}
+	if !strings.Contains(html.String(), "Invoice 00000042") {
+		t.Errorf("expected the built in html receipt, got:\n%s", html.String())
+	}
+}
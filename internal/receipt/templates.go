@@ -0,0 +1,57 @@
+package receipt
+
+// The built in templates. A file named after the template in the template
+// directory given to NewRenderer replaces it.
+const (
+	htmlName = "receipt.html"
+	textName = "receipt.txt"
+)
+
+const defaultHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Invoice {{invoice .Invoice.Number}}</title>
+</head>
+<body>
+<h1>Invoice {{invoice .Invoice.Number}}</h1>
+<p>Issued {{.Invoice.DateIssued.Format "2006-01-02"}} for the sale of {{.Sale.DateCreated.Format "2006-01-02 15:04"}}.</p>
+{{- if or .Sale.CustomerName .Sale.CustomerEmail}}
+<p>Customer:
+{{- with .Sale.CustomerName}} {{.}}{{end}}
+{{- with .Sale.CustomerEmail}} &lt;{{.}}&gt;{{end}}
+</p>
+{{- end}}
+<table>
+<tr><th>Item</th><th>SKU</th><th>Quantity</th><th>Price</th></tr>
+<tr><td>{{.Product.Name}}</td><td>{{.Variant.SKU}}</td><td>{{.Sale.Quantity}}</td><td>{{.Sale.ListPrice}}</td></tr>
+{{- range .Sale.Promotions}}
+<tr><td colspan="3">{{.Name}}</td><td>-{{.Discount}}</td></tr>
+{{- end}}
+</table>
+<p>Net: {{.Sale.Net}}</p>
+{{- if .Sale.TaxRate}}
+<p>Tax at {{percent .Sale.TaxRate}}{{if .Sale.TaxInclusive}} (included){{end}}: {{.Sale.Tax}}</p>
+{{- end}}
+<p><strong>Total: {{.Sale.Paid}}</strong></p>
+</body>
+</html>
+`
+
+const defaultText = `INVOICE {{invoice .Invoice.Number}}
+Issued {{.Invoice.DateIssued.Format "2006-01-02"}} for the sale of {{.Sale.DateCreated.Format "2006-01-02 15:04"}}
+{{- if or .Sale.CustomerName .Sale.CustomerEmail}}
+Customer:{{with .Sale.CustomerName}} {{.}}{{end}}{{with .Sale.CustomerEmail}} <{{.}}>{{end}}
+{{- end}}
+
+{{.Product.Name}} ({{.Variant.SKU}}) x {{.Sale.Quantity}}    {{.Sale.ListPrice}}
+{{- range .Sale.Promotions}}
+  {{.Name}}    -{{.Discount}}
+{{- end}}
+
+Net    {{.Sale.Net}}
+{{- if .Sale.TaxRate}}
+Tax {{percent .Sale.TaxRate}}{{if .Sale.TaxInclusive}} (included){{end}}    {{.Sale.Tax}}
+{{- end}}
+Total    {{.Sale.Paid}}
+`
@@ -0,0 +1,2 @@
+// Package report implements the read only summaries we produce from sales.
+package report
@@ -0,0 +1,27 @@
+package report
+
+import (
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/money"
+)
+
+// CategorySales totals the sales of products in a category and every
+// category below it. Revenue is converted into the report's base currency.
+type CategorySales struct {
+	CategoryID string      `json:"category_id"`
+	ParentID   *string     `json:"parent_id"`
+	Name       string      `json:"name"`
+	Quantity   int         `json:"quantity"`
+	Revenue    money.Money `json:"revenue"`
+}
+
+// TaxSummary totals the sales charged at one tax rate in one jurisdiction
+// and currency. Untaxed sales are summarised with a nil Jurisdiction or Rate.
+type TaxSummary struct {
+	Jurisdiction *string     `json:"jurisdiction"`
+	Rate         *string     `json:"rate"`
+	Inclusive    bool        `json:"inclusive"`
+	Sales        int         `json:"sales"`
+	Net          money.Money `json:"net"`
+	Tax          money.Money `json:"tax"`
+	Gross        money.Money `json:"gross"`
+}
@@ -0,0 +1,86 @@
+package report_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/exchange"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/money"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/product"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/report"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/schema"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/tests"
+)
+
+func TestSalesByCategory(t *testing.T) {
+	db, teardown := tests.NewUnit(t)
+	defer teardown()
+
+	if err := schema.Seed(db); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	from := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+
+	claims := auth.NewClaims(tests.AdminID, []string{auth.RoleAdmin}, from, time.Hour)
+	claims.Permissions = auth.Permissions
+	claims.Tenant = tests.TenantID
+
+	// A euro priced toy sold on the second of January.
+	toys := "9a7b6c5d-4e3f-4a1b-8c9d-0e1f2a3b4c30"
+	np := product.NewProduct{
+		Name:       "Yo-yo",
+		Cost:       money.Money{Amount: 100, Currency: "EUR"},
+		Quantity:   5,
+		CategoryID: &toys,
+	}
+	yoyo, err := product.Create(ctx, db, claims, np, from)
+	if err != nil {
+		t.Fatalf("creating product: %s", err)
+	}
+
+	ns := product.NewSale{Quantity: 1}
+	if _, err := product.AddSale(ctx, db, claims, ns, yoyo.ID, from.AddDate(0, 0, 1)); err != nil {
+		t.Fatalf("adding sale: %s", err)
+	}
+
+	if _, err := report.SalesByCategory(ctx, db, tests.TenantID, "USD", from, to); err == nil {
+		t.Fatal("expected reporting without a EUR rate to fail")
+	}
+
+	rates := []exchange.NewRate{
+		{Currency: "EUR", Base: "USD", Rate: "1.2", ValidFrom: from.AddDate(0, 0, -10)},
+		{Currency: "EUR", Base: "USD", Rate: "1.1", ValidFrom: from},
+		{Currency: "EUR", Base: "USD", Rate: "1.5", ValidFrom: from.AddDate(0, 0, 2)},
+	}
+	for _, nr := range rates {
+		if _, err := exchange.Set(ctx, db, nr); err != nil {
+			t.Fatalf("setting rate: %s", err)
+		}
+	}
+
+	rows, err := report.SalesByCategory(ctx, db, tests.TenantID, "USD", from, to)
+	if err != nil {
+		t.Fatalf("reporting: %s", err)
+	}
+
+	got := make(map[string]report.CategorySales)
+	for _, r := range rows {
+		got[r.Name] = r
+	}
+
+	want := map[string][2]int64{
+		"Entertainment": {11, 685},
+		"Books":         {7, 350},
+		"Toys":          {4, 335},
+	}
+	for name, w := range want {
+		if int64(got[name].Quantity) != w[0] || got[name].Revenue.Amount != w[1] {
+			t.Errorf("%s: expected quantity %v and revenue %v, got %v and %v", name, w[0], w[1], got[name].Quantity, got[name].Revenue)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package report
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/exchange"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/money"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/database"
+	"go.opencensus.io/trace"
+)
+
+// SalesByCategory totals the sales of tenantID made in [from, to) for every
+// category, rolling the sales of each category up into all of its
+// ancestors. Revenue is converted into base at the rate valid on the day of
+// each sale.
+func SalesByCategory(ctx context.Context, db *sqlx.DB, tenantID, base string, from, to time.Time) ([]CategorySales, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.report.SalesByCategory")
+	defer span.End()
+
+	conv, err := exchange.NewConverter(ctx, db, base)
+	if err != nil {
+		return nil, err
+	}
+
+	// Sales are grouped by currency and day so each group converts at a
+	// single rate.
+	var groups []struct {
+		CategoryID string     `db:"category_id"`
+		ParentID   *string    `db:"parent_id"`
+		Name       string     `db:"name"`
+		Currency   *string    `db:"currency"`
+		Day        *time.Time `db:"day"`
+		Quantity   int        `db:"quantity"`
+		Paid       int64      `db:"paid"`
+	}
+
+	const q = `WITH RECURSIVE tree AS (
+			SELECT category_id AS root_id, category_id FROM categories WHERE tenant_id = $3
+			UNION ALL
+			SELECT t.root_id, c.category_id FROM categories AS c JOIN tree AS t ON c.parent_id = t.category_id
+		)
+		SELECT
+			c.category_id,
+			c.parent_id,
+			c.name,
+			s.currency,
+			DATE_TRUNC('day', s.date_created) AS day,
+			COALESCE(SUM(s.quantity), 0) AS quantity,
+			COALESCE(SUM(s.paid), 0) AS paid
+		FROM categories AS c
+		JOIN tree AS t ON t.root_id = c.category_id
+		LEFT JOIN products AS p ON p.category_id = t.category_id AND p.tenant_id = $3
+		LEFT JOIN sales AS s ON s.product_id = p.product_id
+			AND s.date_created >= $1 AND s.date_created < $2
+		GROUP BY c.category_id, s.currency, DATE_TRUNC('day', s.date_created)
+		ORDER BY c.name, c.category_id`
+	err = database.WithTenant(ctx, db, tenantID, func(tx *sqlx.Tx) error {
+		return tx.SelectContext(ctx, &groups, q, from.UTC(), to.UTC(), tenantID)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("selecting sales by category: %w", err)
+	}
+
+	var rows []CategorySales
+	index := make(map[string]int)
+	for _, g := range groups {
+		i, ok := index[g.CategoryID]
+		if !ok {
+			i = len(rows)
+			index[g.CategoryID] = i
+			rows = append(rows, CategorySales{
+				CategoryID: g.CategoryID,
+				ParentID:   g.ParentID,
+				Name:       g.Name,
+				Revenue:    money.Money{Currency: conv.Base()},
+			})
+		}
+
+		// Categories without sales in the period come back with no currency.
+		if g.Currency == nil {
+			continue
+		}
+
+		paid, err := conv.Convert(money.Money{Amount: g.Paid, Currency: *g.Currency}, *g.Day)
+		if err != nil {
+			return nil, fmt.Errorf("converting %s sales of %s: %w", *g.Currency, g.Day.Format("2006-01-02"), err)
+		}
+
+		rows[i].Quantity += g.Quantity
+		if rows[i].Revenue, err = rows[i].Revenue.Add(paid); err != nil {
+			return nil, err
+		}
+	}
+
+	return rows, nil
+}
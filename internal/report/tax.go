@@ -0,0 +1,66 @@
+package report
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/money"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/database"
+	"go.opencensus.io/trace"
+)
+
+// Tax summarises the tax charged on the sales of tenantID made in [from, to)
+// by jurisdiction, rate and currency. Amounts are the sums of what was stored on
+// each sale, so the report agrees with the sales to the minor unit.
+func Tax(ctx context.Context, db *sqlx.DB, tenantID string, from, to time.Time) ([]TaxSummary, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.report.Tax")
+	defer span.End()
+
+	var groups []struct {
+		Jurisdiction *string `db:"jurisdiction"`
+		Rate         *string `db:"tax_rate"`
+		Inclusive    bool    `db:"tax_inclusive"`
+		Currency     string  `db:"currency"`
+		Sales        int     `db:"sales"`
+		Net          int64   `db:"net"`
+		Tax          int64   `db:"tax"`
+		Gross        int64   `db:"gross"`
+	}
+
+	const q = `SELECT
+			jurisdiction,
+			tax_rate::TEXT AS tax_rate,
+			tax_inclusive,
+			currency,
+			COUNT(*) AS sales,
+			SUM(COALESCE(net, paid)) AS net,
+			SUM(tax) AS tax,
+			SUM(paid) AS gross
+		FROM sales
+		WHERE tenant_id = $1 AND date_created >= $2 AND date_created < $3
+		GROUP BY jurisdiction, tax_rate, tax_inclusive, currency
+		ORDER BY jurisdiction NULLS LAST, tax_rate NULLS LAST, tax_inclusive, currency`
+	err := database.WithTenant(ctx, db, tenantID, func(tx *sqlx.Tx) error {
+		return tx.SelectContext(ctx, &groups, q, tenantID, from.UTC(), to.UTC())
+	})
+	if err != nil {
+		return nil, fmt.Errorf("selecting tax summary: %w", err)
+	}
+
+	rows := make([]TaxSummary, len(groups))
+	for i, g := range groups {
+		rows[i] = TaxSummary{
+			Jurisdiction: g.Jurisdiction,
+			Rate:         g.Rate,
+			Inclusive:    g.Inclusive,
+			Sales:        g.Sales,
+			Net:          money.Money{Amount: g.Net, Currency: g.Currency},
+			Tax:          money.Money{Amount: g.Tax, Currency: g.Currency},
+			Gross:        money.Money{Amount: g.Gross, Currency: g.Currency},
+		}
+	}
+
+	return rows, nil
+}
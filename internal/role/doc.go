@@ -0,0 +1,4 @@
+// Package role defines what each role may do as a set of the permissions
+// named in the auth package. Definitions live in the database and can be
+// managed through the API or loaded from a file with sales-admin.
+package role
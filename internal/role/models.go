@@ -0,0 +1,26 @@
+package role
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Role grants its Permissions to the users of its tenant holding it. When
+// RequireMFA is set, they are granted only to users enrolled in multi-factor
+// authentication.
+type Role struct {
+	TenantID    string         `db:"tenant_id" json:"tenant_id"`
+	Name        string         `db:"name" json:"name"`
+	Permissions pq.StringArray `db:"permissions" json:"permissions"`
+	RequireMFA  bool           `db:"require_mfa" json:"require_mfa"`
+	DateCreated time.Time      `db:"date_created" json:"date_created"`
+	DateUpdated time.Time      `db:"date_updated" json:"date_updated"`
+}
+
+// UpdateRole replaces the permissions of a role, creating it if need be.
+// RequireMFA is left as it was when nil, and off for a new role.
+type UpdateRole struct {
+	Permissions []string `json:"permissions" validate:"required"`
+	RequireMFA  *bool    `json:"require_mfa"`
+}
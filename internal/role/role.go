@@ -0,0 +1,242 @@
+package role
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/database"
+	"go.opencensus.io/trace"
+)
+
+var (
+	ErrNotFound          = errors.New("role not found")
+	ErrInvalidName       = errors.New("role names are upper case letters, digits and underscores")
+	ErrUnknownPermission = errors.New("unknown permission")
+	ErrInUse             = errors.New("role is held by users")
+)
+
+var validName = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+
+// Defaults are the roles every tenant starts with.
+var Defaults = map[string]UpdateRole{
+	auth.RoleAdmin: {Permissions: auth.Permissions, RequireMFA: boolPointer(true)},
+	auth.RoleUser:  {Permissions: []string{auth.PermProductsWrite}},
+}
+
+// List returns the roles defined in tenantID.
+func List(ctx context.Context, db *sqlx.DB, tenantID string) ([]Role, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.role.List")
+	defer span.End()
+
+	roles := []Role{}
+	const q = `SELECT * FROM roles WHERE tenant_id = $1 ORDER BY name`
+	if err := db.SelectContext(ctx, &roles, q, tenantID); err != nil {
+		return nil, fmt.Errorf("selecting roles: %w", err)
+	}
+
+	return roles, nil
+}
+
+// Retrieve returns the role name of tenantID.
+func Retrieve(ctx context.Context, db *sqlx.DB, tenantID, name string) (*Role, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.role.Retrieve")
+	defer span.End()
+
+	var r Role
+	const q = `SELECT * FROM roles WHERE tenant_id = $1 AND name = $2`
+	if err := db.GetContext(ctx, &r, q, tenantID, name); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("selecting role %q: %w", name, err)
+	}
+
+	return &r, nil
+}
+
+// Set defines the role name of tenantID as granting the permissions in ur.
+// Tokens already issued keep the permissions they were issued with until
+// they are refreshed.
+func Set(ctx context.Context, db *sqlx.DB, tenantID, name string, ur UpdateRole, now time.Time) (*Role, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.role.Set")
+	defer span.End()
+
+	perms, err := normalize(name, ur.Permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	return set(ctx, db, tenantID, name, perms, ur.RequireMFA, now)
+}
+
+// SetDefaults defines the Defaults in tenantID, as for a new tenant.
+func SetDefaults(ctx context.Context, db sqlx.QueryerContext, tenantID string, now time.Time) error {
+	ctx, span := trace.StartSpan(ctx, "internal.role.SetDefaults")
+	defer span.End()
+
+	for name, ur := range Defaults {
+		perms, err := normalize(name, ur.Permissions)
+		if err != nil {
+			return fmt.Errorf("role %q: %w", name, err)
+		}
+		if _, err := set(ctx, db, tenantID, name, perms, ur.RequireMFA, now); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func set(ctx context.Context, db sqlx.QueryerContext, tenantID, name string, perms pq.StringArray, requireMFA *bool, now time.Time) (*Role, error) {
+	var r Role
+	const q = `INSERT INTO roles (tenant_id, name, permissions, require_mfa, date_created, date_updated)
+		VALUES ($1, $2, $3, COALESCE($4::BOOLEAN, FALSE), $5, $5)
+		ON CONFLICT (tenant_id, name) DO UPDATE SET
+			permissions = $3, require_mfa = COALESCE($4::BOOLEAN, roles.require_mfa), date_updated = $5
+		RETURNING *`
+	if err := sqlx.GetContext(ctx, db, &r, q, tenantID, name, perms, requireMFA, now.UTC()); err != nil {
+		return nil, fmt.Errorf("setting role %q: %w", name, err)
+	}
+
+	return &r, nil
+}
+
+// Delete removes the definition of a role of tenantID no user of it holds.
+func Delete(ctx context.Context, db *sqlx.DB, tenantID, name string) error {
+	ctx, span := trace.StartSpan(ctx, "internal.role.Delete")
+	defer span.End()
+
+	return database.WithTenant(ctx, db, tenantID, func(tx *sqlx.Tx) error {
+		var held bool
+		const h = `SELECT EXISTS (SELECT 1 FROM users WHERE tenant_id = $1 AND $2 = ANY(roles))`
+		if err := tx.GetContext(ctx, &held, h, tenantID, name); err != nil {
+			return fmt.Errorf("checking role %q is unused: %w", name, err)
+		}
+		if held {
+			return ErrInUse
+		}
+
+		res, err := tx.ExecContext(ctx, `DELETE FROM roles WHERE tenant_id = $1 AND name = $2`, tenantID, name)
+		if err != nil {
+			return fmt.Errorf("deleting role %q: %w", name, err)
+		}
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
+}
+
+// Permissions returns the permissions granted by roles in tenantID, sorted.
+// Roles without a definition grant nothing.
+func Permissions(ctx context.Context, db sqlx.QueryerContext, tenantID string, roles []string) ([]string, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.role.Permissions")
+	defer span.End()
+
+	perms := []string{}
+	const q = `SELECT DISTINCT unnest(permissions) AS p FROM roles
+		WHERE tenant_id = $1 AND name = ANY($2) ORDER BY p`
+	if err := sqlx.SelectContext(ctx, db, &perms, q, tenantID, pq.StringArray(roles)); err != nil {
+		return nil, fmt.Errorf("selecting permissions: %w", err)
+	}
+
+	return perms, nil
+}
+
+// RequireMFA reports whether any of roles in tenantID requires its holders
+// to be enrolled in multi-factor authentication.
+func RequireMFA(ctx context.Context, db sqlx.QueryerContext, tenantID string, roles []string) (bool, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.role.RequireMFA")
+	defer span.End()
+
+	var required bool
+	const q = `SELECT EXISTS (SELECT 1 FROM roles WHERE tenant_id = $1 AND name = ANY($2) AND require_mfa)`
+	if err := sqlx.GetContext(ctx, db, &required, q, tenantID, pq.StringArray(roles)); err != nil {
+		return false, fmt.Errorf("checking roles require MFA: %w", err)
+	}
+
+	return required, nil
+}
+
+// Load defines the roles of tenantID in a JSON object mapping role names to
+// their permissions, e.g. {"CLERK": ["sales:create"]}. Every role is checked
+// before any is saved, and roles left out of the file are kept. Whether a
+// role requires MFA is not changed.
+func Load(ctx context.Context, db *sqlx.DB, tenantID string, r io.Reader, now time.Time) ([]Role, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.role.Load")
+	defer span.End()
+
+	var defs map[string]pq.StringArray
+	if err := json.NewDecoder(r).Decode(&defs); err != nil {
+		return nil, fmt.Errorf("decoding role definitions: %w", err)
+	}
+
+	names := make([]string, 0, len(defs))
+	for name, perms := range defs {
+		p, err := normalize(name, perms)
+		if err != nil {
+			return nil, fmt.Errorf("role %q: %w", name, err)
+		}
+		defs[name] = p
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("starting role load: %w", err)
+	}
+	defer tx.Rollback()
+
+	roles := make([]Role, 0, len(names))
+	for _, name := range names {
+		r, err := set(ctx, tx, tenantID, name, defs[name], nil, now)
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, *r)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("committing role load: %w", err)
+	}
+
+	return roles, nil
+}
+
+// normalize checks a role definition and returns its permissions sorted
+// without duplicates.
+func normalize(name string, perms []string) (pq.StringArray, error) {
+	if !validName.MatchString(name) {
+		return nil, ErrInvalidName
+	}
+
+	seen := make(map[string]bool)
+	out := pq.StringArray{}
+	for _, p := range perms {
+		if !auth.KnownPermission(p) {
+			return nil, ErrUnknownPermission
+		}
+		if !seen[p] {
+			seen[p] = true
+			out = append(out, p)
+		}
+	}
+	sort.Strings(out)
+
+	return out, nil
+}
+
+func boolPointer(b bool) *bool {
+	return &b
+}
@@ -0,0 +1,132 @@
+package role_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/role"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/schema"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/tenant"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/tests"
+)
+
+func TestRoles(t *testing.T) {
+	db, teardown := tests.NewUnit(t)
+	defer teardown()
+
+	if err := schema.Seed(db); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	now := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	// The built in roles are defined by the migrations.
+	perms, err := role.Permissions(ctx, db, tests.TenantID, []string{auth.RoleUser})
+	if err != nil {
+		t.Fatalf("reading USER permissions: %s", err)
+	}
+	if diff := cmp.Diff([]string{auth.PermProductsWrite}, perms); diff != "" {
+		t.Fatalf("USER permissions differ:\n%s", diff)
+	}
+
+	ur := role.UpdateRole{Permissions: []string{auth.PermSalesCreate, auth.PermReportsRead, auth.PermSalesCreate}}
+	clerk, err := role.Set(ctx, db, tests.TenantID, "CLERK", ur, now)
+	if err != nil {
+		t.Fatalf("setting role: %s", err)
+	}
+	if diff := cmp.Diff([]string{auth.PermReportsRead, auth.PermSalesCreate}, []string(clerk.Permissions)); diff != "" {
+		t.Fatalf("CLERK permissions differ:\n%s", diff)
+	}
+
+	// Permissions of several roles are merged, and undefined roles grant
+	// nothing.
+	perms, err = role.Permissions(ctx, db, tests.TenantID, []string{"CLERK", auth.RoleUser, "NOBODY"})
+	if err != nil {
+		t.Fatalf("reading merged permissions: %s", err)
+	}
+	want := []string{auth.PermProductsWrite, auth.PermReportsRead, auth.PermSalesCreate}
+	if diff := cmp.Diff(want, perms); diff != "" {
+		t.Fatalf("merged permissions differ:\n%s", diff)
+	}
+
+	if _, err := role.Set(ctx, db, tests.TenantID, "clerk", ur, now); err != role.ErrInvalidName {
+		t.Fatalf("setting lower case role: got %v, want %v", err, role.ErrInvalidName)
+	}
+	bad := role.UpdateRole{Permissions: []string{"sales:everything"}}
+	if _, err := role.Set(ctx, db, tests.TenantID, "CLERK", bad, now); err != role.ErrUnknownPermission {
+		t.Fatalf("setting unknown permission: got %v, want %v", err, role.ErrUnknownPermission)
+	}
+
+	if err := role.Delete(ctx, db, tests.TenantID, auth.RoleUser); err != role.ErrInUse {
+		t.Fatalf("deleting held role: got %v, want %v", err, role.ErrInUse)
+	}
+	if err := role.Delete(ctx, db, tests.TenantID, "CLERK"); err != nil {
+		t.Fatalf("deleting role: %s", err)
+	}
+	if _, err := role.Retrieve(ctx, db, tests.TenantID, "CLERK"); err != role.ErrNotFound {
+		t.Fatalf("retrieving deleted role: got %v, want %v", err, role.ErrNotFound)
+	}
+	if err := role.Delete(ctx, db, tests.TenantID, "CLERK"); err != role.ErrNotFound {
+		t.Fatalf("deleting deleted role: got %v, want %v", err, role.ErrNotFound)
+	}
+
+	// Each tenant defines its own roles, starting from the defaults.
+	other, err := tenant.Create(ctx, db, tenant.NewTenant{Name: "Corner Shop"}, now)
+	if err != nil {
+		t.Fatalf("creating tenant: %s", err)
+	}
+	ur = role.UpdateRole{Permissions: auth.Permissions}
+	if _, err := role.Set(ctx, db, tests.TenantID, auth.RoleUser, ur, now); err != nil {
+		t.Fatalf("setting USER: %s", err)
+	}
+	perms, err = role.Permissions(ctx, db, other.ID, []string{auth.RoleUser})
+	if err != nil {
+		t.Fatalf("reading other tenant's USER permissions: %s", err)
+	}
+	if diff := cmp.Diff([]string{auth.PermProductsWrite}, perms); diff != "" {
+		t.Fatalf("other tenant's USER permissions differ:\n%s", diff)
+	}
+	admin, err := role.Retrieve(ctx, db, other.ID, auth.RoleAdmin)
+	if err != nil {
+		t.Fatalf("retrieving other tenant's ADMIN: %s", err)
+	}
+	if !admin.RequireMFA || len(admin.Permissions) != len(auth.Permissions) {
+		t.Fatalf("got ADMIN %+v, want every permission behind MFA", admin)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	db, teardown := tests.NewUnit(t)
+	defer teardown()
+
+	ctx := context.Background()
+	now := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	// A bad definition saves nothing.
+	bad := `{"AUDITOR": ["reports:read"], "CLERK": ["sales:everything"]}`
+	if _, err := role.Load(ctx, db, tests.TenantID, strings.NewReader(bad), now); err == nil {
+		t.Fatal("loading a bad definition should fail")
+	}
+	if _, err := role.Retrieve(ctx, db, tests.TenantID, "AUDITOR"); err != role.ErrNotFound {
+		t.Fatalf("retrieving role from failed load: got %v, want %v", err, role.ErrNotFound)
+	}
+
+	defs := `{"CLERK": ["sales:create"], "USER": ["products:write", "reports:read"]}`
+	roles, err := role.Load(ctx, db, tests.TenantID, strings.NewReader(defs), now)
+	if err != nil {
+		t.Fatalf("loading roles: %s", err)
+	}
+	if len(roles) != 2 || roles[0].Name != "CLERK" || roles[1].Name != auth.RoleUser {
+		t.Fatalf("expected CLERK and USER, got %+v", roles)
+	}
+
+	// Roles left out of the file are kept.
+	if _, err := role.Retrieve(ctx, db, tests.TenantID, auth.RoleAdmin); err != nil {
+		t.Fatalf("retrieving ADMIN: %s", err)
+	}
+}
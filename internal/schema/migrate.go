@@ -1,6 +1,9 @@
 package schema
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/GuiaBolso/darwin"
 	"github.com/jmoiron/sqlx"
 )
@@ -55,6 +58,747 @@ CREATE TABLE users (
 		Script: `
 ALTER TABLE products
 	ADD COLUMN user_id UUID DEFAULT '00000000-0000-0000-0000-000000000000'
+`,
+	},
+	{
+		Version:     5,
+		Description: "Add warehouses and per warehouse stock",
+		Script: `
+CREATE TABLE warehouses (
+	warehouse_id UUID,
+	name         TEXT UNIQUE,
+	date_created TIMESTAMP,
+	date_updated TIMESTAMP,
+	PRIMARY KEY (warehouse_id)
+);
+
+CREATE TABLE stock (
+	warehouse_id UUID,
+	product_id   UUID,
+	quantity     INT NOT NULL CHECK (quantity >= 0),
+	PRIMARY KEY (warehouse_id, product_id),
+	FOREIGN KEY (warehouse_id) REFERENCES warehouses(warehouse_id) ON DELETE CASCADE,
+	FOREIGN KEY (product_id) REFERENCES products(product_id) ON DELETE CASCADE
+);
+
+INSERT INTO warehouses (warehouse_id, name, date_created, date_updated) VALUES
+	('d3a3e3c4-7c4e-4b8a-9f0e-6d1a0f1f2b01', 'Main', NOW(), NOW());
+
+INSERT INTO stock (warehouse_id, product_id, quantity)
+	SELECT 'd3a3e3c4-7c4e-4b8a-9f0e-6d1a0f1f2b01', product_id, COALESCE(quantity, 0) FROM products;
+
+ALTER TABLE products DROP COLUMN quantity;
+
+ALTER TABLE sales
+	ADD COLUMN warehouse_id UUID REFERENCES warehouses(warehouse_id);
+
+UPDATE sales SET warehouse_id = 'd3a3e3c4-7c4e-4b8a-9f0e-6d1a0f1f2b01';
+`,
+	},
+	{
+		Version:     6,
+		Description: "Add stock transfers",
+		Script: `
+CREATE TABLE transfers (
+	transfer_id       UUID,
+	product_id        UUID,
+	from_warehouse_id UUID,
+	to_warehouse_id   UUID,
+	quantity          INT,
+	user_id           UUID,
+	date_created      TIMESTAMP,
+	PRIMARY KEY (transfer_id),
+	FOREIGN KEY (product_id) REFERENCES products(product_id) ON DELETE CASCADE,
+	FOREIGN KEY (from_warehouse_id) REFERENCES warehouses(warehouse_id),
+	FOREIGN KEY (to_warehouse_id) REFERENCES warehouses(warehouse_id)
+);`,
+	},
+	{
+		Version:     7,
+		Description: "Add product variants",
+		Script: `
+CREATE TABLE variants (
+	variant_id   UUID,
+	product_id   UUID NOT NULL,
+	sku          TEXT NOT NULL,
+	attributes   JSONB NOT NULL DEFAULT '{}',
+	cost         INT,
+	date_created TIMESTAMP,
+	date_updated TIMESTAMP,
+	PRIMARY KEY (variant_id),
+	UNIQUE (sku),
+	FOREIGN KEY (product_id) REFERENCES products(product_id) ON DELETE CASCADE
+);
+
+-- Every existing product becomes a single variant product whose variant
+-- shares its ID, so existing stock, sales and transfers carry over as is.
+INSERT INTO variants (variant_id, product_id, sku, date_created, date_updated)
+	SELECT product_id, product_id, UPPER(REPLACE(product_id::TEXT, '-', '')), date_created, date_updated
+	FROM products;
+
+ALTER TABLE stock ADD COLUMN variant_id UUID REFERENCES variants(variant_id) ON DELETE CASCADE;
+UPDATE stock SET variant_id = product_id;
+ALTER TABLE stock ALTER COLUMN variant_id SET NOT NULL;
+ALTER TABLE stock DROP CONSTRAINT stock_pkey;
+ALTER TABLE stock ADD PRIMARY KEY (warehouse_id, variant_id);
+
+ALTER TABLE sales ADD COLUMN variant_id UUID REFERENCES variants(variant_id) ON DELETE CASCADE;
+UPDATE sales SET variant_id = product_id;
+
+ALTER TABLE transfers ADD COLUMN variant_id UUID REFERENCES variants(variant_id) ON DELETE CASCADE;
+UPDATE transfers SET variant_id = product_id;
+`,
+	},
+	{
+		Version:     8,
+		Description: "Add categories and product tags",
+		Script: `
+CREATE TABLE categories (
+	category_id  UUID,
+	parent_id    UUID,
+	name         TEXT NOT NULL,
+	date_created TIMESTAMP,
+	date_updated TIMESTAMP,
+	PRIMARY KEY (category_id),
+	FOREIGN KEY (parent_id) REFERENCES categories(category_id) ON DELETE RESTRICT
+);
+
+CREATE UNIQUE INDEX categories_parent_name_idx
+	ON categories (COALESCE(parent_id, '00000000-0000-0000-0000-000000000000'), name);
+
+ALTER TABLE products
+	ADD COLUMN category_id UUID REFERENCES categories(category_id) ON DELETE SET NULL;
+
+CREATE TABLE product_tags (
+	product_id UUID,
+	tag        TEXT,
+	PRIMARY KEY (product_id, tag),
+	FOREIGN KEY (product_id) REFERENCES products(product_id) ON DELETE CASCADE
+);
+
+CREATE INDEX product_tags_tag_idx ON product_tags (tag);
+`,
+	},
+	{
+		Version:     9,
+		Description: "Add currencies and exchange rates",
+		Script: `
+ALTER TABLE products ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD';
+ALTER TABLE sales ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD';
+
+CREATE TABLE exchange_rates (
+	currency   CHAR(3),
+	base       CHAR(3),
+	rate       NUMERIC(20, 10) NOT NULL CHECK (rate > 0),
+	valid_from DATE,
+	PRIMARY KEY (currency, base, valid_from)
+);
+`,
+	},
+	{
+		Version:     10,
+		Description: "Add promotions and price overrides on sales",
+		Script: `
+CREATE TABLE promotions (
+	promotion_id  UUID,
+	name          TEXT NOT NULL,
+	kind          TEXT NOT NULL CHECK (kind IN ('percentage', 'fixed', 'buy_x_get_y')),
+	percent       INT NOT NULL DEFAULT 0 CHECK (percent BETWEEN 0 AND 100),
+	amount        INT NOT NULL DEFAULT 0 CHECK (amount >= 0),
+	currency      CHAR(3) NOT NULL DEFAULT 'USD',
+	buy_quantity  INT NOT NULL DEFAULT 0,
+	free_quantity INT NOT NULL DEFAULT 0,
+	product_id    UUID,
+	coupon_code   TEXT UNIQUE,
+	usage_limit   INT,
+	usage_count   INT NOT NULL DEFAULT 0,
+	starts_at     TIMESTAMP,
+	ends_at       TIMESTAMP,
+	active        BOOLEAN NOT NULL DEFAULT TRUE,
+	date_created  TIMESTAMP,
+	PRIMARY KEY (promotion_id),
+	FOREIGN KEY (product_id) REFERENCES products(product_id) ON DELETE CASCADE
+);
+
+CREATE TABLE sale_promotions (
+	sale_id      UUID,
+	promotion_id UUID,
+	discount     INT NOT NULL,
+	PRIMARY KEY (sale_id, promotion_id),
+	FOREIGN KEY (sale_id) REFERENCES sales(sale_id) ON DELETE CASCADE,
+	FOREIGN KEY (promotion_id) REFERENCES promotions(promotion_id) ON DELETE CASCADE
+);
+
+ALTER TABLE sales
+	ADD COLUMN list_price INT,
+	ADD COLUMN override_reason TEXT,
+	ADD COLUMN overridden_by UUID;
+
+UPDATE sales SET list_price = paid;
+`,
+	},
+	{
+		Version:     11,
+		Description: "Add tax rates and tax on sales",
+		Script: `
+CREATE TABLE tax_rates (
+	tax_rate_id  UUID,
+	jurisdiction TEXT NOT NULL,
+	category_id  UUID,
+	name         TEXT NOT NULL,
+	rate         NUMERIC(9, 6) NOT NULL CHECK (rate >= 0 AND rate < 1),
+	inclusive    BOOLEAN NOT NULL DEFAULT FALSE,
+	date_created TIMESTAMP,
+	date_updated TIMESTAMP,
+	PRIMARY KEY (tax_rate_id),
+	FOREIGN KEY (category_id) REFERENCES categories(category_id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX tax_rates_jurisdiction_category_idx
+	ON tax_rates (jurisdiction, COALESCE(category_id, '00000000-0000-0000-0000-000000000000'));
+
+ALTER TABLE sales
+	ADD COLUMN jurisdiction TEXT,
+	ADD COLUMN tax_rate NUMERIC(9, 6),
+	ADD COLUMN tax_inclusive BOOLEAN NOT NULL DEFAULT FALSE,
+	ADD COLUMN net INT,
+	ADD COLUMN tax INT NOT NULL DEFAULT 0;
+
+UPDATE sales SET net = paid;
+`,
+	},
+	{
+		Version:     12,
+		Description: "Add customers on sales and invoice numbers",
+		Script: `
+ALTER TABLE sales
+	ADD COLUMN customer_name TEXT,
+	ADD COLUMN customer_email TEXT;
+
+CREATE TABLE invoice_counter (
+	id          BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+	last_number BIGINT NOT NULL
+);
+
+INSERT INTO invoice_counter (last_number) VALUES (0);
+
+CREATE TABLE invoices (
+	invoice_number BIGINT,
+	sale_id        UUID NOT NULL UNIQUE,
+	date_issued    TIMESTAMP,
+	PRIMARY KEY (invoice_number),
+	FOREIGN KEY (sale_id) REFERENCES sales(sale_id) ON DELETE RESTRICT
+);
+`,
+	},
+	{
+		Version:     13,
+		Description: "Add external references to sales",
+		Script: `
+ALTER TABLE sales ADD COLUMN external_ref TEXT UNIQUE;
+`,
+	},
+	{
+		Version:     14,
+		Description: "Add outbox of domain events",
+		Script: `
+CREATE TABLE outbox (
+	event_id        UUID,
+	sequence        BIGSERIAL NOT NULL UNIQUE,
+	aggregate_type  TEXT NOT NULL,
+	aggregate_id    UUID NOT NULL,
+	event_type      TEXT NOT NULL,
+	payload         JSONB NOT NULL,
+	attempts        INT NOT NULL DEFAULT 0,
+	last_error      TEXT,
+	date_created    TIMESTAMP,
+	date_dispatched TIMESTAMP,
+	PRIMARY KEY (event_id)
+);
+
+CREATE INDEX outbox_pending_idx ON outbox (sequence) WHERE date_dispatched IS NULL;
+`,
+	},
+	{
+		Version:     15,
+		Description: "Add webhook subscriptions and deliveries",
+		Script: `
+CREATE TABLE webhook_subscriptions (
+	subscription_id UUID,
+	url             TEXT NOT NULL,
+	events          TEXT[] NOT NULL DEFAULT '{}',
+	secret          TEXT NOT NULL,
+	date_created    TIMESTAMP,
+	date_updated    TIMESTAMP,
+	PRIMARY KEY (subscription_id)
+);
+
+CREATE TABLE webhook_deliveries (
+	delivery_id      UUID,
+	subscription_id  UUID NOT NULL,
+	event_id         UUID NOT NULL,
+	event_type       TEXT NOT NULL,
+	payload          JSONB NOT NULL,
+	status           TEXT NOT NULL CHECK (status IN ('pending', 'delivered', 'dead')),
+	attempts         INT NOT NULL DEFAULT 0,
+	next_attempt_at  TIMESTAMP,
+	last_status_code INT,
+	last_error       TEXT,
+	date_created     TIMESTAMP,
+	date_delivered   TIMESTAMP,
+	PRIMARY KEY (delivery_id),
+	UNIQUE (subscription_id, event_id),
+	FOREIGN KEY (subscription_id) REFERENCES webhook_subscriptions(subscription_id) ON DELETE CASCADE
+);
+
+CREATE INDEX webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+`,
+	},
+	{
+		Version:     16,
+		Description: "Add recording transaction to outbox events",
+		Script: `
+-- Sequences are handed out before transactions commit, so they can become
+-- visible out of order. The change feed reads events in the order of the
+-- transaction that recorded them instead, and only once that transaction is
+-- older than every transaction still running.
+ALTER TABLE outbox ADD COLUMN txid BIGINT NOT NULL DEFAULT txid_current();
+
+CREATE INDEX outbox_txid_sequence_idx ON outbox (txid, sequence);
+`,
+	},
+	{
+		Version:     17,
+		Description: "Add refresh tokens and revoked access tokens",
+		Script: `
+-- Each refresh is a new row in the family started by a login, with the access
+-- token issued alongside it, so reusing a spent token can revoke the family
+-- and every access token issued to it.
+CREATE TABLE refresh_tokens (
+	token_id       UUID,
+	family_id      UUID NOT NULL,
+	user_id        UUID NOT NULL,
+	token_hash     BYTEA NOT NULL UNIQUE,
+	access_jti     TEXT NOT NULL,
+	access_expires TIMESTAMP NOT NULL,
+	date_created   TIMESTAMP,
+	date_expires   TIMESTAMP NOT NULL,
+	date_used      TIMESTAMP,
+	date_revoked   TIMESTAMP,
+	PRIMARY KEY (token_id),
+	FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+CREATE INDEX refresh_tokens_family_idx ON refresh_tokens (family_id);
+CREATE INDEX refresh_tokens_access_jti_idx ON refresh_tokens (access_jti);
+
+CREATE TABLE revoked_tokens (
+	jti          TEXT,
+	date_expires TIMESTAMP NOT NULL,
+	date_revoked TIMESTAMP,
+	PRIMARY KEY (jti)
+);
+
+CREATE INDEX revoked_tokens_expires_idx ON revoked_tokens (date_expires);
+`,
+	},
+	{
+		Version:     18,
+		Description: "Add API keys",
+		Script: `
+CREATE TABLE api_keys (
+	key_id         UUID,
+	prefix         TEXT NOT NULL UNIQUE,
+	secret_hash    BYTEA NOT NULL,
+	name           TEXT NOT NULL,
+	user_id        UUID NOT NULL,
+	roles          TEXT[] NOT NULL,
+	date_created   TIMESTAMP,
+	date_expires   TIMESTAMP,
+	date_last_used TIMESTAMP,
+	date_revoked   TIMESTAMP,
+	PRIMARY KEY (key_id),
+	FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+`,
+	},
+	{
+		Version:     19,
+		Description: "Add role definitions",
+		Script: `
+CREATE TABLE roles (
+	name         TEXT,
+	permissions  TEXT[] NOT NULL DEFAULT '{}',
+	date_created TIMESTAMP,
+	date_updated TIMESTAMP,
+	PRIMARY KEY (name)
+);
+
+-- The roles that were hard coded before they could be defined.
+INSERT INTO roles (name, permissions, date_created, date_updated) VALUES
+	('ADMIN', '{products:write,products:admin,sales:create,sales:admin,inventory:admin,catalog:admin,pricing:admin,reports:read,webhooks:admin,users:admin}', now(), now()),
+	('USER', '{products:write}', now(), now());
+`,
+	},
+	{
+		Version:     20,
+		Description: "Add tenants",
+		Script: `
+CREATE TABLE tenants (
+	tenant_id    UUID,
+	name         TEXT NOT NULL UNIQUE,
+	date_created TIMESTAMP,
+	PRIMARY KEY (tenant_id)
+);
+
+-- Everything so far belongs to the shop the service was first run for.
+INSERT INTO tenants (tenant_id, name, date_created) VALUES
+	('1b7c3e5a-2d4f-4a6b-8c9d-0e1f2a3b4c5d', 'default', now());
+
+ALTER TABLE products ADD COLUMN tenant_id UUID REFERENCES tenants(tenant_id);
+ALTER TABLE sales ADD COLUMN tenant_id UUID REFERENCES tenants(tenant_id);
+ALTER TABLE users ADD COLUMN tenant_id UUID REFERENCES tenants(tenant_id);
+
+UPDATE products SET tenant_id = '1b7c3e5a-2d4f-4a6b-8c9d-0e1f2a3b4c5d';
+UPDATE sales SET tenant_id = '1b7c3e5a-2d4f-4a6b-8c9d-0e1f2a3b4c5d';
+UPDATE users SET tenant_id = '1b7c3e5a-2d4f-4a6b-8c9d-0e1f2a3b4c5d';
+
+ALTER TABLE products ALTER COLUMN tenant_id SET NOT NULL;
+ALTER TABLE sales ALTER COLUMN tenant_id SET NOT NULL;
+ALTER TABLE users ALTER COLUMN tenant_id SET NOT NULL;
+
+-- External references only need to be unique within the shop that sent them.
+ALTER TABLE sales DROP CONSTRAINT sales_external_ref_key;
+ALTER TABLE sales ADD UNIQUE (tenant_id, external_ref);
+
+CREATE INDEX products_tenant_idx ON products (tenant_id);
+CREATE INDEX sales_tenant_idx ON sales (tenant_id, product_id);
+CREATE INDEX users_tenant_idx ON users (tenant_id);
+
+-- Once enabled with RowLevelSecurity, these admit only the rows of the
+-- tenant a transaction set with database.SetTenant. Work done outside any
+-- tenant, such as logging in or dispatching the outbox, sets none and sees
+-- every row.
+CREATE POLICY tenant_isolation ON products
+	USING (COALESCE(current_setting('app.tenant_id', true), '') IN ('', tenant_id::text));
+CREATE POLICY tenant_isolation ON sales
+	USING (COALESCE(current_setting('app.tenant_id', true), '') IN ('', tenant_id::text));
+CREATE POLICY tenant_isolation ON users
+	USING (COALESCE(current_setting('app.tenant_id', true), '') IN ('', tenant_id::text));
+`,
+	},
+	{
+		Version:     21,
+		Description: "Add login throttling",
+		Script: `
+-- Failed logins are counted per account, by email whether or not a user has
+-- it, and per client address.
+CREATE TABLE login_failures (
+	kind             TEXT,
+	subject          TEXT,
+	failures         INT NOT NULL,
+	locked           BOOLEAN NOT NULL DEFAULT FALSE,
+	date_last_failed TIMESTAMP NOT NULL,
+	date_retry       TIMESTAMP NOT NULL,
+	PRIMARY KEY (kind, subject)
+);
+
+CREATE TABLE login_events (
+	event_id     UUID,
+	event        TEXT NOT NULL,
+	kind         TEXT NOT NULL,
+	subject      TEXT NOT NULL,
+	user_id      UUID,
+	actor_id     UUID,
+	failures     INT NOT NULL,
+	date_created TIMESTAMP NOT NULL,
+	PRIMARY KEY (event_id)
+);
+
+CREATE INDEX login_events_user_idx ON login_events (user_id, date_created);
+`,
+	},
+	{
+		Version:     22,
+		Description: "Add multi-factor authentication",
+		Script: `
+-- A user's TOTP secret is kept from enrolment, but only counts once a code
+-- from it has confirmed the enrolment. last_step is the time step of the
+-- last code accepted, which may not be used again.
+CREATE TABLE mfa_totp (
+	user_id        UUID,
+	secret         TEXT NOT NULL,
+	last_step      BIGINT NOT NULL DEFAULT 0,
+	date_created   TIMESTAMP NOT NULL,
+	date_confirmed TIMESTAMP,
+	PRIMARY KEY (user_id),
+	FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+CREATE TABLE mfa_recovery_codes (
+	user_id   UUID,
+	code_hash BYTEA,
+	date_used TIMESTAMP,
+	PRIMARY KEY (user_id, code_hash),
+	FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+-- A challenge is handed out for a correct password and exchanged, with a
+-- code, for tokens.
+CREATE TABLE mfa_challenges (
+	challenge_hash BYTEA,
+	user_id        UUID NOT NULL,
+	attempts       INT NOT NULL DEFAULT 0,
+	date_expires   TIMESTAMP NOT NULL,
+	date_used      TIMESTAMP,
+	PRIMARY KEY (challenge_hash),
+	FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+-- Holders of roles requiring MFA get no permissions until they enrol.
+ALTER TABLE roles ADD COLUMN require_mfa BOOLEAN NOT NULL DEFAULT FALSE;
+UPDATE roles SET require_mfa = TRUE WHERE name = 'ADMIN';
+`,
+	},
+	{
+		Version:     23,
+		Description: "Add password reset and email verification",
+		Script: `
+ALTER TABLE users ADD COLUMN date_email_verified TIMESTAMP;
+
+-- Tokens mailed to users to prove they hold email. Only their hashes are
+-- kept, and each may be used once.
+CREATE TABLE account_tokens (
+	token_hash   BYTEA,
+	user_id      UUID NOT NULL,
+	purpose      TEXT NOT NULL,
+	email        TEXT NOT NULL,
+	date_created TIMESTAMP NOT NULL,
+	date_expires TIMESTAMP NOT NULL,
+	date_used    TIMESTAMP,
+	PRIMARY KEY (token_hash),
+	FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+CREATE INDEX account_tokens_user_idx ON account_tokens (user_id, purpose, date_created);
+`,
+	},
+	{
+		Version:     24,
+		Description: "Define roles per tenant",
+		Script: `
+-- Every tenant gets its own copy of the roles defined so far, so one
+-- tenant's admins cannot change what another's roles grant.
+ALTER TABLE roles DROP CONSTRAINT roles_pkey;
+ALTER TABLE roles ADD COLUMN tenant_id UUID;
+
+INSERT INTO roles (tenant_id, name, permissions, require_mfa, date_created, date_updated)
+	SELECT t.tenant_id, r.name, r.permissions, r.require_mfa, r.date_created, r.date_updated
+	FROM tenants AS t CROSS JOIN roles AS r
+	WHERE r.tenant_id IS NULL;
+DELETE FROM roles WHERE tenant_id IS NULL;
+
+ALTER TABLE roles ALTER COLUMN tenant_id SET NOT NULL;
+ALTER TABLE roles ADD PRIMARY KEY (tenant_id, name);
+ALTER TABLE roles ADD FOREIGN KEY (tenant_id) REFERENCES tenants(tenant_id) ON DELETE CASCADE;
+`,
+	},
+	{
+		Version:     25,
+		Description: "Record the tenant of outbox events",
+		Script: `
+ALTER TABLE outbox ADD COLUMN tenant_id UUID;
+
+UPDATE outbox AS o SET tenant_id = p.tenant_id
+	FROM products AS p
+	WHERE o.aggregate_type = 'product' AND o.aggregate_id = p.product_id;
+UPDATE outbox AS o SET tenant_id = p.tenant_id
+	FROM variants AS v JOIN products AS p ON p.product_id = v.product_id
+	WHERE o.aggregate_type = 'variant' AND o.aggregate_id = v.variant_id;
+UPDATE outbox AS o SET tenant_id = s.tenant_id
+	FROM sales AS s
+	WHERE o.aggregate_type = 'sale' AND o.aggregate_id = s.sale_id;
+UPDATE outbox AS o SET tenant_id = u.tenant_id
+	FROM users AS u
+	WHERE o.aggregate_type = 'user' AND o.aggregate_id = u.user_id;
+
+-- Events of aggregates deleted since belonged to the only tenant there was
+-- before tenants were introduced.
+UPDATE outbox SET tenant_id = '1b7c3e5a-2d4f-4a6b-8c9d-0e1f2a3b4c5d' WHERE tenant_id IS NULL;
+
+ALTER TABLE outbox ALTER COLUMN tenant_id SET NOT NULL;
+CREATE INDEX outbox_tenant_txid_sequence_idx ON outbox (tenant_id, txid, sequence);
+`,
+	},
+	{
+		Version:     26,
+		Description: "Subscribe webhooks per tenant",
+		Script: `
+ALTER TABLE webhook_subscriptions ADD COLUMN tenant_id UUID REFERENCES tenants(tenant_id) ON DELETE CASCADE;
+UPDATE webhook_subscriptions SET tenant_id = '1b7c3e5a-2d4f-4a6b-8c9d-0e1f2a3b4c5d';
+ALTER TABLE webhook_subscriptions ALTER COLUMN tenant_id SET NOT NULL;
+
+CREATE INDEX webhook_subscriptions_tenant_idx ON webhook_subscriptions (tenant_id);
+`,
+	},
+	{
+		Version:     27,
+		Description: "Scope warehouses, categories, tax rates and promotions to tenants",
+		Script: `
+-- Rows referring to a product or category name its tenant too, so they can
+-- only refer to one of their own tenant's.
+ALTER TABLE products ADD UNIQUE (tenant_id, product_id);
+
+-- Every tenant gets a default warehouse that new stock goes to. Stock of
+-- another tenant's products held in the default tenant's warehouses moves
+-- to that tenant's own.
+ALTER TABLE warehouses ADD COLUMN tenant_id UUID REFERENCES tenants(tenant_id);
+ALTER TABLE warehouses ADD COLUMN is_default BOOLEAN NOT NULL DEFAULT FALSE;
+UPDATE warehouses SET tenant_id = '1b7c3e5a-2d4f-4a6b-8c9d-0e1f2a3b4c5d';
+UPDATE warehouses SET is_default = TRUE WHERE warehouse_id = 'd3a3e3c4-7c4e-4b8a-9f0e-6d1a0f1f2b01';
+
+INSERT INTO warehouses (warehouse_id, tenant_id, name, is_default, date_created, date_updated)
+	SELECT md5(random()::TEXT || tenant_id::TEXT)::UUID, tenant_id, 'Main', TRUE, NOW(), NOW()
+	FROM tenants
+	WHERE tenant_id <> '1b7c3e5a-2d4f-4a6b-8c9d-0e1f2a3b4c5d';
+
+INSERT INTO stock (warehouse_id, product_id, variant_id, quantity)
+	SELECT d.warehouse_id, s.product_id, s.variant_id, SUM(s.quantity)
+	FROM stock AS s
+	JOIN products AS p ON p.product_id = s.product_id
+	JOIN warehouses AS w ON w.warehouse_id = s.warehouse_id
+	JOIN warehouses AS d ON d.tenant_id = p.tenant_id AND d.is_default
+	WHERE w.tenant_id <> p.tenant_id
+	GROUP BY d.warehouse_id, s.product_id, s.variant_id;
+DELETE FROM stock AS s
+	USING products AS p, warehouses AS w
+	WHERE p.product_id = s.product_id AND w.warehouse_id = s.warehouse_id
+		AND w.tenant_id <> p.tenant_id;
+
+ALTER TABLE warehouses ALTER COLUMN tenant_id SET NOT NULL;
+ALTER TABLE warehouses DROP CONSTRAINT warehouses_name_key;
+ALTER TABLE warehouses ADD UNIQUE (tenant_id, name);
+CREATE UNIQUE INDEX warehouses_default_idx ON warehouses (tenant_id) WHERE is_default;
+
+-- The categories so far are the default tenant's. Products of other
+-- tenants filed under them become uncategorised.
+ALTER TABLE categories ADD COLUMN tenant_id UUID REFERENCES tenants(tenant_id);
+UPDATE categories SET tenant_id = '1b7c3e5a-2d4f-4a6b-8c9d-0e1f2a3b4c5d';
+ALTER TABLE categories ALTER COLUMN tenant_id SET NOT NULL;
+ALTER TABLE categories ADD UNIQUE (tenant_id, category_id);
+ALTER TABLE categories DROP CONSTRAINT categories_parent_id_fkey;
+ALTER TABLE categories ADD FOREIGN KEY (tenant_id, parent_id)
+	REFERENCES categories(tenant_id, category_id) ON DELETE RESTRICT;
+
+DROP INDEX categories_parent_name_idx;
+CREATE UNIQUE INDEX categories_parent_name_idx
+	ON categories (tenant_id, COALESCE(parent_id, '00000000-0000-0000-0000-000000000000'), name);
+
+UPDATE products AS p SET category_id = NULL
+	FROM categories AS c
+	WHERE c.category_id = p.category_id AND c.tenant_id <> p.tenant_id;
+
+-- Deleting a category uncategorises its products itself, as a composite
+-- key cannot set only category_id to NULL.
+ALTER TABLE products DROP CONSTRAINT products_category_id_fkey;
+ALTER TABLE products ADD FOREIGN KEY (tenant_id, category_id)
+	REFERENCES categories(tenant_id, category_id);
+
+ALTER TABLE tax_rates ADD COLUMN tenant_id UUID REFERENCES tenants(tenant_id);
+UPDATE tax_rates SET tenant_id = '1b7c3e5a-2d4f-4a6b-8c9d-0e1f2a3b4c5d';
+ALTER TABLE tax_rates ALTER COLUMN tenant_id SET NOT NULL;
+ALTER TABLE tax_rates DROP CONSTRAINT tax_rates_category_id_fkey;
+ALTER TABLE tax_rates ADD FOREIGN KEY (tenant_id, category_id)
+	REFERENCES categories(tenant_id, category_id) ON DELETE CASCADE;
+
+DROP INDEX tax_rates_jurisdiction_category_idx;
+CREATE UNIQUE INDEX tax_rates_jurisdiction_category_idx
+	ON tax_rates (tenant_id, jurisdiction, COALESCE(category_id, '00000000-0000-0000-0000-000000000000'));
+
+-- Promotions of a product belong to the product's tenant, the rest to the
+-- default tenant.
+ALTER TABLE promotions ADD COLUMN tenant_id UUID REFERENCES tenants(tenant_id);
+UPDATE promotions AS pr SET tenant_id = p.tenant_id
+	FROM products AS p
+	WHERE p.product_id = pr.product_id;
+UPDATE promotions SET tenant_id = '1b7c3e5a-2d4f-4a6b-8c9d-0e1f2a3b4c5d' WHERE tenant_id IS NULL;
+ALTER TABLE promotions ALTER COLUMN tenant_id SET NOT NULL;
+ALTER TABLE promotions DROP CONSTRAINT promotions_product_id_fkey;
+ALTER TABLE promotions ADD FOREIGN KEY (tenant_id, product_id)
+	REFERENCES products(tenant_id, product_id) ON DELETE CASCADE;
+ALTER TABLE promotions DROP CONSTRAINT promotions_coupon_code_key;
+ALTER TABLE promotions ADD UNIQUE (tenant_id, coupon_code);
+`,
+	},
+	{
+		Version:     28,
+		Description: "Hide every tenant's rows from transactions that set none",
+		Script: `
+-- A transaction sees only the rows of the tenant it set with
+-- database.SetTenant, and none when it set no tenant. The lookups that must
+-- work across tenants, such as logging in, say so with database.Bypass.
+DROP POLICY tenant_isolation ON products;
+DROP POLICY tenant_isolation ON sales;
+DROP POLICY tenant_isolation ON users;
+
+CREATE POLICY tenant_isolation ON products
+	USING (current_setting('app.bypass', true) = 'on'
+		OR tenant_id::text = current_setting('app.tenant_id', true));
+CREATE POLICY tenant_isolation ON sales
+	USING (current_setting('app.bypass', true) = 'on'
+		OR tenant_id::text = current_setting('app.tenant_id', true));
+CREATE POLICY tenant_isolation ON users
+	USING (current_setting('app.bypass', true) = 'on'
+		OR tenant_id::text = current_setting('app.tenant_id', true));
+`,
+	},
+	{
+		Version:     29,
+		Description: "Back off retrying outbox events that failed",
+		Script: `
+ALTER TABLE outbox ADD COLUMN date_retry TIMESTAMP;
+
+CREATE INDEX outbox_aggregate_pending_idx ON outbox (aggregate_type, aggregate_id, sequence)
+	WHERE date_dispatched IS NULL;
+`,
+	},
+	{
+		Version:     30,
+		Description: "Store user emails in lower case",
+		Script: `
+-- Emails are looked up as user.NormalizeEmail leaves them. This fails, for
+-- an operator to resolve, if two users' emails differ only in case.
+SELECT set_config('app.bypass', 'on', true);
+UPDATE users SET email = LOWER(TRIM(email)) WHERE email <> LOWER(TRIM(email));
+`,
+	},
+	{
+		Version:     31,
+		Description: "Number invoices per tenant",
+		Script: `
+-- Each tenant numbers its invoices from its own counter, which carries on
+-- from the highest number its invoices were given by the shared one.
+SELECT set_config('app.bypass', 'on', true);
+
+ALTER TABLE invoices ADD COLUMN tenant_id UUID REFERENCES tenants(tenant_id);
+UPDATE invoices AS i SET tenant_id = s.tenant_id
+	FROM sales AS s
+	WHERE s.sale_id = i.sale_id;
+ALTER TABLE invoices ALTER COLUMN tenant_id SET NOT NULL;
+ALTER TABLE invoices DROP CONSTRAINT invoices_pkey;
+ALTER TABLE invoices ADD PRIMARY KEY (tenant_id, invoice_number);
+
+DROP TABLE invoice_counter;
+CREATE TABLE invoice_counter (
+	tenant_id   UUID REFERENCES tenants(tenant_id) ON DELETE CASCADE,
+	last_number BIGINT NOT NULL,
+	PRIMARY KEY (tenant_id)
+);
+
+INSERT INTO invoice_counter (tenant_id, last_number)
+	SELECT t.tenant_id, COALESCE(MAX(i.invoice_number), 0)
+	FROM tenants AS t LEFT JOIN invoices AS i ON i.tenant_id = t.tenant_id
+	GROUP BY t.tenant_id;
 `,
 	},
 }
@@ -66,3 +810,25 @@ func Migrate(db *sqlx.DB) error {
 
 	return d.Migrate()
 }
+
+// tenantTables are the tables whose rows belong to a tenant.
+var tenantTables = []string{"products", "sales", "users"}
+
+// RowLevelSecurity turns the enforcement of the tenant isolation policies on
+// or off. The policies are forced on the tables' owner too, but Postgres
+// never applies them to superusers, so the service must connect as an
+// ordinary role for them to take effect.
+func RowLevelSecurity(db *sqlx.DB, enable bool) error {
+	stmt := "ALTER TABLE %s ENABLE ROW LEVEL SECURITY; ALTER TABLE %[1]s FORCE ROW LEVEL SECURITY;"
+	if !enable {
+		stmt = "ALTER TABLE %s NO FORCE ROW LEVEL SECURITY; ALTER TABLE %[1]s DISABLE ROW LEVEL SECURITY;"
+	}
+
+	var script strings.Builder
+	for _, t := range tenantTables {
+		fmt.Fprintf(&script, stmt, t)
+	}
+
+	_, err := db.Exec(script.String())
+	return err
+}
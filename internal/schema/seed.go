@@ -3,21 +3,51 @@ package schema
 import "github.com/jmoiron/sqlx"
 
 const seeds = `
-INSERT INTO products (product_id, name, cost, quantity, date_created, date_updated) VALUES
-	('a2b0639f-2cc6-44b8-b97b-15d69dbb511e', 'Comic Books', 50, 42, '2019-01-01 00:00:01.000001+00', '2019-01-01 00:00:01.000001+00'),
-	('72f8b983-3eb4-48db-9ed0-e45cc6bd716b', 'McDonalds Toys', 75, 120, '2019-01-01 00:00:02.000001+00', '2019-01-01 00:00:02.000001+00')
+INSERT INTO warehouses (warehouse_id, tenant_id, name, date_created, date_updated) VALUES
+	('3f1b6a52-0c7e-4f43-a1a8-1f0c8e3b9d11', '1b7c3e5a-2d4f-4a6b-8c9d-0e1f2a3b4c5d', 'North', '2019-01-01 00:00:00', '2019-01-01 00:00:00'),
+	('8c6d2e94-5b1a-4d7f-9e33-7a2f4c1d6e22', '1b7c3e5a-2d4f-4a6b-8c9d-0e1f2a3b4c5d', 'South', '2019-01-01 00:00:00', '2019-01-01 00:00:00')
 	ON CONFLICT DO NOTHING;
 
-INSERT INTO sales (sale_id, product_id, quantity, paid, date_created) VALUES
-	('98b6d4b8-f04b-4c79-8c2e-a0aef46854b7', 'a2b0639f-2cc6-44b8-b97b-15d69dbb511e', 2, 100, '2019-01-01 00:00:03.000001+00'),
-	('85f6fb09-eb05-4874-ae39-82d1a30fe0d7', 'a2b0639f-2cc6-44b8-b97b-15d69dbb511e', 5, 250, '2019-01-01 00:00:04.000001+00'),
-	('a235be9e-ab5d-44e6-a987-fa1c749264c7', '72f8b983-3eb4-48db-9ed0-e45cc6bd716b', 3, 225, '2019-01-01 00:00:05.000001+00')
+INSERT INTO categories (category_id, tenant_id, parent_id, name, date_created, date_updated) VALUES
+	('0b8f5a3e-6a57-4b7e-9d0b-2c6a1e4f7a10', '1b7c3e5a-2d4f-4a6b-8c9d-0e1f2a3b4c5d', NULL, 'Entertainment', '2019-01-01 00:00:00', '2019-01-01 00:00:00'),
+	('6e2c4d1a-9b3f-4a8e-b7c5-1d2e3f4a5b20', '1b7c3e5a-2d4f-4a6b-8c9d-0e1f2a3b4c5d', '0b8f5a3e-6a57-4b7e-9d0b-2c6a1e4f7a10', 'Books', '2019-01-01 00:00:00', '2019-01-01 00:00:00'),
+	('9a7b6c5d-4e3f-4a1b-8c9d-0e1f2a3b4c30', '1b7c3e5a-2d4f-4a6b-8c9d-0e1f2a3b4c5d', '0b8f5a3e-6a57-4b7e-9d0b-2c6a1e4f7a10', 'Toys', '2019-01-01 00:00:00', '2019-01-01 00:00:00')
+	ON CONFLICT DO NOTHING;
+
+INSERT INTO products (product_id, tenant_id, name, cost, category_id, date_created, date_updated) VALUES
+	('a2b0639f-2cc6-44b8-b97b-15d69dbb511e', '1b7c3e5a-2d4f-4a6b-8c9d-0e1f2a3b4c5d', 'Comic Books', 50, '6e2c4d1a-9b3f-4a8e-b7c5-1d2e3f4a5b20', '2019-01-01 00:00:01.000001+00', '2019-01-01 00:00:01.000001+00'),
+	('72f8b983-3eb4-48db-9ed0-e45cc6bd716b', '1b7c3e5a-2d4f-4a6b-8c9d-0e1f2a3b4c5d', 'McDonalds Toys', 75, '9a7b6c5d-4e3f-4a1b-8c9d-0e1f2a3b4c30', '2019-01-01 00:00:02.000001+00', '2019-01-01 00:00:02.000001+00')
+	ON CONFLICT DO NOTHING;
+
+INSERT INTO product_tags (product_id, tag) VALUES
+	('a2b0639f-2cc6-44b8-b97b-15d69dbb511e', 'collectible'),
+	('a2b0639f-2cc6-44b8-b97b-15d69dbb511e', 'paper'),
+	('72f8b983-3eb4-48db-9ed0-e45cc6bd716b', 'collectible')
+	ON CONFLICT DO NOTHING;
+
+-- Each product's first variant shares its ID. The toys come in more than one.
+INSERT INTO variants (variant_id, product_id, sku, attributes, cost, date_created, date_updated) VALUES
+	('a2b0639f-2cc6-44b8-b97b-15d69dbb511e', 'a2b0639f-2cc6-44b8-b97b-15d69dbb511e', 'COMIC-001', '{}', NULL, '2019-01-01 00:00:01.000001+00', '2019-01-01 00:00:01.000001+00'),
+	('72f8b983-3eb4-48db-9ed0-e45cc6bd716b', '72f8b983-3eb4-48db-9ed0-e45cc6bd716b', 'TOY-001', '{"size": "small"}', NULL, '2019-01-01 00:00:02.000001+00', '2019-01-01 00:00:02.000001+00'),
+	('5e0c3b7a-2f0d-4c57-8a2b-9f5e1d7c3a44', '72f8b983-3eb4-48db-9ed0-e45cc6bd716b', 'TOY-002', '{"size": "large"}', 95, '2019-01-01 00:00:02.000001+00', '2019-01-01 00:00:02.000001+00')
+	ON CONFLICT DO NOTHING;
+
+INSERT INTO stock (warehouse_id, product_id, variant_id, quantity) VALUES
+	('d3a3e3c4-7c4e-4b8a-9f0e-6d1a0f1f2b01', 'a2b0639f-2cc6-44b8-b97b-15d69dbb511e', 'a2b0639f-2cc6-44b8-b97b-15d69dbb511e', 30),
+	('3f1b6a52-0c7e-4f43-a1a8-1f0c8e3b9d11', 'a2b0639f-2cc6-44b8-b97b-15d69dbb511e', 'a2b0639f-2cc6-44b8-b97b-15d69dbb511e', 12),
+	('d3a3e3c4-7c4e-4b8a-9f0e-6d1a0f1f2b01', '72f8b983-3eb4-48db-9ed0-e45cc6bd716b', '72f8b983-3eb4-48db-9ed0-e45cc6bd716b', 120)
+	ON CONFLICT DO NOTHING;
+
+INSERT INTO sales (sale_id, tenant_id, product_id, variant_id, warehouse_id, quantity, list_price, net, paid, date_created) VALUES
+	('98b6d4b8-f04b-4c79-8c2e-a0aef46854b7', '1b7c3e5a-2d4f-4a6b-8c9d-0e1f2a3b4c5d', 'a2b0639f-2cc6-44b8-b97b-15d69dbb511e', 'a2b0639f-2cc6-44b8-b97b-15d69dbb511e', 'd3a3e3c4-7c4e-4b8a-9f0e-6d1a0f1f2b01', 2, 100, 100, 100, '2019-01-01 00:00:03.000001+00'),
+	('85f6fb09-eb05-4874-ae39-82d1a30fe0d7', '1b7c3e5a-2d4f-4a6b-8c9d-0e1f2a3b4c5d', 'a2b0639f-2cc6-44b8-b97b-15d69dbb511e', 'a2b0639f-2cc6-44b8-b97b-15d69dbb511e', 'd3a3e3c4-7c4e-4b8a-9f0e-6d1a0f1f2b01', 5, 250, 250, 250, '2019-01-01 00:00:04.000001+00'),
+	('a235be9e-ab5d-44e6-a987-fa1c749264c7', '1b7c3e5a-2d4f-4a6b-8c9d-0e1f2a3b4c5d', '72f8b983-3eb4-48db-9ed0-e45cc6bd716b', '72f8b983-3eb4-48db-9ed0-e45cc6bd716b', 'd3a3e3c4-7c4e-4b8a-9f0e-6d1a0f1f2b01', 3, 225, 225, 225, '2019-01-01 00:00:05.000001+00')
 	ON CONFLICT DO NOTHING;
 	
 -- Create admin and regular User with password "gophers"
-INSERT INTO users (user_id, name, email, roles, password_hash, date_created, date_updated) VALUES
-	('5cf37266-3473-4006-984f-9325122678b7', 'Admin Gopher', 'admin@example.com', '{ADMIN,USER}', '$2a$10$1ggfMVZV6Js0ybvJufLRUOWHS5f6KneuP0XwwHpJ8L8ipdry9f2/a', '2019-03-24 00:00:00', '2019-03-24 00:00:00'),
-	('45b5fbd3-755f-4379-8f07-a58d4a30fa2f', 'User Gopher', 'user@example.com', '{USER}', '$2a$10$9/XASPKBbJKVfCAZKDH.UuhsuALDr5vVm6VrYA9VFR8rccK86C1hW', '2019-03-24 00:00:00', '2019-03-24 00:00:00')
+INSERT INTO users (user_id, tenant_id, name, email, roles, password_hash, date_created, date_updated) VALUES
+	('5cf37266-3473-4006-984f-9325122678b7', '1b7c3e5a-2d4f-4a6b-8c9d-0e1f2a3b4c5d', 'Admin Gopher', 'admin@example.com', '{ADMIN,USER}', '$2a$10$1ggfMVZV6Js0ybvJufLRUOWHS5f6KneuP0XwwHpJ8L8ipdry9f2/a', '2019-03-24 00:00:00', '2019-03-24 00:00:00'),
+	('45b5fbd3-755f-4379-8f07-a58d4a30fa2f', '1b7c3e5a-2d4f-4a6b-8c9d-0e1f2a3b4c5d', 'User Gopher', 'user@example.com', '{USER}', '$2a$10$9/XASPKBbJKVfCAZKDH.UuhsuALDr5vVm6VrYA9VFR8rccK86C1hW', '2019-03-24 00:00:00', '2019-03-24 00:00:00')
 	ON CONFLICT DO NOTHING;
 `
 
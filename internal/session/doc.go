@@ -0,0 +1,6 @@
+// Package session keeps users signed in beyond the life of an access token.
+// A login starts a family of single-use refresh tokens, each exchanged for the
+// next along with a new access token. Presenting a spent refresh token means
+// it was stolen, so the family is ended and its access tokens are revoked.
+// Revoked access tokens are held in a list the API checks on every request.
+package session
@@ -0,0 +1,27 @@
+package session
+
+import "time"
+
+// Session is the family of refresh tokens a user's login started.
+type Session struct {
+	FamilyID string `db:"family_id"`
+	UserID   string `db:"user_id"`
+}
+
+// refreshToken is a stored refresh token. Only the hash of the token the
+// client holds is kept.
+type refreshToken struct {
+	ID          string     `db:"token_id"`
+	FamilyID    string     `db:"family_id"`
+	UserID      string     `db:"user_id"`
+	Hash        []byte     `db:"token_hash"`
+	DateExpires time.Time  `db:"date_expires"`
+	DateUsed    *time.Time `db:"date_used"`
+	DateRevoked *time.Time `db:"date_revoked"`
+}
+
+// Revocation is an access token revoked before it expired.
+type Revocation struct {
+	JTI         string    `db:"jti"`
+	DateExpires time.Time `db:"date_expires"`
+}
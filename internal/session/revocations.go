@@ -0,0 +1,108 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"go.opencensus.io/trace"
+)
+
+// Revocations caches the revoked access tokens that have yet to expire so
+// they can be checked on every request without a query. It is loaded from
+// the database when created and refreshed periodically by Run, and straight
+// away by Refresh after this instance revokes tokens. Revocations are never
+// undone, so the cache only drops a token once it would have expired anyway.
+type Revocations struct {
+	db *sqlx.DB
+
+	mu      sync.RWMutex
+	revoked map[string]time.Time
+}
+
+// NewRevocations returns the revoked tokens in db.
+func NewRevocations(ctx context.Context, db *sqlx.DB) (*Revocations, error) {
+	r := Revocations{
+		db:      db,
+		revoked: make(map[string]time.Time),
+	}
+	if err := r.Refresh(ctx, time.Now()); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// Revoked reports whether the access token with the ID jti was revoked.
+func (r *Revocations) Revoked(jti string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	_, ok := r.revoked[jti]
+	return ok
+}
+
+// Refresh reloads every revocation in the database that has yet to expire,
+// adding any the cache lacks, and drops the cached ones that have expired.
+func (r *Revocations) Refresh(ctx context.Context, now time.Time) error {
+	ctx, span := trace.StartSpan(ctx, "internal.session.Revocations.Refresh")
+	defer span.End()
+
+	var list []Revocation
+	const q = `select jti, date_expires from revoked_tokens where date_expires > $1`
+	if err := r.db.SelectContext(ctx, &list, q, now.UTC()); err != nil {
+		return fmt.Errorf("selecting revoked tokens: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for jti, expires := range r.revoked {
+		if !now.Before(expires) {
+			delete(r.revoked, jti)
+		}
+	}
+	for _, rv := range list {
+		r.revoked[rv.JTI] = rv.DateExpires
+	}
+
+	return nil
+}
+
+// Run refreshes the cache every interval until ctx is done, picking up
+// tokens revoked by other instances, and deletes the revocations that have
+// expired from the database.
+func (r *Revocations) Run(ctx context.Context, log *log.Logger, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			now := time.Now()
+			if err := r.Refresh(ctx, now); err != nil {
+				log.Printf("session: refreshing revoked tokens: %v", err)
+			}
+			if err := r.prune(ctx, now); err != nil {
+				log.Printf("session: %v", err)
+			}
+		}
+	}
+}
+
+// prune deletes the revocations of tokens that have expired, which no longer
+// need to be checked.
+func (r *Revocations) prune(ctx context.Context, now time.Time) error {
+	ctx, span := trace.StartSpan(ctx, "internal.session.Revocations.prune")
+	defer span.End()
+
+	const q = `delete from revoked_tokens where date_expires <= $1`
+	if _, err := r.db.ExecContext(ctx, q, now.UTC()); err != nil {
+		return fmt.Errorf("pruning revoked tokens: %w", err)
+	}
+	return nil
+}
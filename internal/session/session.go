@@ -0,0 +1,208 @@
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
+	"go.opencensus.io/trace"
+)
+
+// Lifetime is how long a refresh token may be used for. Each refresh issues a
+// token with a new lifetime, so a session lasts as long as it keeps being
+// refreshed.
+const Lifetime = 30 * 24 * time.Hour
+
+var (
+	// ErrInvalidToken is returned for refresh tokens that are unknown,
+	// expired or revoked.
+	ErrInvalidToken = errors.New("refresh token is invalid or expired")
+
+	// ErrTokenReused is returned when a refresh token is presented a second
+	// time. The session it belongs to has been ended.
+	ErrTokenReused = errors.New("refresh token has already been used")
+)
+
+// Issue returns a new refresh token paired with the access token of claims.
+// It continues the session familyID, or starts one when familyID is blank.
+func Issue(ctx context.Context, db *sqlx.DB, familyID string, claims auth.Claims, now time.Time) (string, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.session.Issue")
+	defer span.End()
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("generating refresh token: %w", err)
+	}
+	raw := base64.RawURLEncoding.EncodeToString(secret)
+
+	if familyID == "" {
+		familyID = uuid.New().String()
+	}
+
+	const q = `insert into refresh_tokens
+		(token_id, family_id, user_id, token_hash, access_jti, access_expires, date_created, date_expires)
+		values ($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	_, err := db.ExecContext(ctx, q,
+		uuid.New().String(), familyID, claims.Subject, hash(raw),
+		claims.Id, time.Unix(claims.ExpiresAt, 0).UTC(),
+		now.UTC(), now.Add(Lifetime).UTC())
+	if err != nil {
+		return "", fmt.Errorf("inserting refresh token: %w", err)
+	}
+
+	return raw, nil
+}
+
+// Use spends the refresh token raw and returns the session it belongs to,
+// which the next token is issued to. Spending a token twice ends its session
+// and returns ErrTokenReused.
+func Use(ctx context.Context, db *sqlx.DB, raw string, now time.Time) (Session, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.session.Use")
+	defer span.End()
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return Session{}, fmt.Errorf("starting refresh: %w", err)
+	}
+	defer tx.Rollback()
+
+	var t refreshToken
+	const q = `select token_id, family_id, user_id, token_hash, date_expires, date_used, date_revoked
+		from refresh_tokens where token_hash = $1 for update`
+	if err := tx.GetContext(ctx, &t, q, hash(raw)); err != nil {
+		if err == sql.ErrNoRows {
+			return Session{}, ErrInvalidToken
+		}
+		return Session{}, fmt.Errorf("selecting refresh token: %w", err)
+	}
+
+	switch {
+	case t.DateRevoked != nil:
+		return Session{}, ErrInvalidToken
+	case t.DateUsed != nil:
+		if err := endFamily(ctx, tx, t.FamilyID, now); err != nil {
+			return Session{}, err
+		}
+		if err := tx.Commit(); err != nil {
+			return Session{}, fmt.Errorf("committing session end: %w", err)
+		}
+		return Session{}, ErrTokenReused
+	case !now.Before(t.DateExpires):
+		return Session{}, ErrInvalidToken
+	}
+
+	const u = `update refresh_tokens set date_used = $2 where token_id = $1`
+	if _, err := tx.ExecContext(ctx, u, t.ID, now.UTC()); err != nil {
+		return Session{}, fmt.Errorf("spending refresh token: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Session{}, fmt.Errorf("committing refresh: %w", err)
+	}
+
+	return Session{FamilyID: t.FamilyID, UserID: t.UserID}, nil
+}
+
+// End signs out the holder of the access token claims: the token is revoked,
+// and so is the session it was issued to, if any, with all of its tokens.
+func End(ctx context.Context, db *sqlx.DB, claims auth.Claims, now time.Time) error {
+	ctx, span := trace.StartSpan(ctx, "internal.session.End")
+	defer span.End()
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("starting sign out: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := revoke(ctx, tx, claims.Id, time.Unix(claims.ExpiresAt, 0), now); err != nil {
+		return err
+	}
+
+	var familyID string
+	const q = `select family_id from refresh_tokens where access_jti = $1`
+	switch err := tx.GetContext(ctx, &familyID, q, claims.Id); err {
+	case nil:
+		if err := endFamily(ctx, tx, familyID, now); err != nil {
+			return err
+		}
+	case sql.ErrNoRows:
+		// The token was not issued alongside a refresh token.
+	default:
+		return fmt.Errorf("selecting session: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing sign out: %w", err)
+	}
+
+	return nil
+}
+
+// EndAll signs the user userID out everywhere by ending all of their
+// sessions, as when their password is changed. It runs in tx so it holds
+// only if the change does.
+func EndAll(ctx context.Context, tx *sqlx.Tx, userID string, now time.Time) error {
+	ctx, span := trace.StartSpan(ctx, "internal.session.EndAll")
+	defer span.End()
+
+	var families []string
+	const q = `select distinct family_id from refresh_tokens
+		where user_id = $1 and date_revoked is null`
+	if err := tx.SelectContext(ctx, &families, q, userID); err != nil {
+		return fmt.Errorf("selecting sessions: %w", err)
+	}
+
+	for _, f := range families {
+		if err := endFamily(ctx, tx, f, now); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// endFamily revokes every refresh token of a session along with the access
+// tokens issued with them that have yet to expire.
+func endFamily(ctx context.Context, tx *sqlx.Tx, familyID string, now time.Time) error {
+	const q = `update refresh_tokens set date_revoked = $2
+		where family_id = $1 and date_revoked is null`
+	if _, err := tx.ExecContext(ctx, q, familyID, now.UTC()); err != nil {
+		return fmt.Errorf("revoking refresh tokens: %w", err)
+	}
+
+	const r = `insert into revoked_tokens (jti, date_expires, date_revoked)
+		select access_jti, access_expires, $2 from refresh_tokens
+		where family_id = $1 and access_expires > $2
+		on conflict do nothing`
+	if _, err := tx.ExecContext(ctx, r, familyID, now.UTC()); err != nil {
+		return fmt.Errorf("revoking access tokens: %w", err)
+	}
+
+	return nil
+}
+
+func revoke(ctx context.Context, tx *sqlx.Tx, jti string, expires, now time.Time) error {
+	const q = `insert into revoked_tokens (jti, date_expires, date_revoked)
+		values ($1, $2, $3) on conflict do nothing`
+	if _, err := tx.ExecContext(ctx, q, jti, expires.UTC(), now.UTC()); err != nil {
+		return fmt.Errorf("revoking access token: %w", err)
+	}
+	return nil
+}
+
+// hash is how refresh tokens are stored. They are random and long enough
+// that a fast hash cannot be brute forced.
+func hash(raw string) []byte {
+	sum := sha256.Sum256([]byte(raw))
+	return sum[:]
+}
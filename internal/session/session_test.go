@@ -0,0 +1,111 @@
+package session_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/schema"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/session"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/tests"
+)
+
+func TestSession(t *testing.T) {
+	db, teardown := tests.NewUnit(t)
+	defer teardown()
+
+	if err := schema.Seed(db); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	now := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	revocations, err := session.NewRevocations(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims := auth.NewClaims(tests.UserID, []string{auth.RoleUser}, now, time.Hour)
+	first, err := session.Issue(ctx, db, "", claims, now)
+	if err != nil {
+		t.Fatalf("starting session: %s", err)
+	}
+
+	s, err := session.Use(ctx, db, first, now.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("using refresh token: %s", err)
+	}
+	if s.UserID != tests.UserID || s.FamilyID == "" {
+		t.Fatalf("got session %+v", s)
+	}
+
+	next := auth.NewClaims(tests.UserID, []string{auth.RoleUser}, now, time.Hour)
+	second, err := session.Issue(ctx, db, s.FamilyID, next, now.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("continuing session: %s", err)
+	}
+
+	if _, err := session.Use(ctx, db, "unknown", now); err != session.ErrInvalidToken {
+		t.Fatalf("unknown token: got %v, want %v", err, session.ErrInvalidToken)
+	}
+
+	if _, err := session.Use(ctx, db, first, now.Add(2*time.Minute)); err != session.ErrTokenReused {
+		t.Fatalf("reused token: got %v, want %v", err, session.ErrTokenReused)
+	}
+
+	// Reuse ended the session, so even its unspent token is refused.
+	if _, err := session.Use(ctx, db, second, now.Add(2*time.Minute)); err != session.ErrInvalidToken {
+		t.Fatalf("token of ended session: got %v, want %v", err, session.ErrInvalidToken)
+	}
+
+	if err := revocations.Refresh(ctx, now.Add(2*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if !revocations.Revoked(claims.Id) || !revocations.Revoked(next.Id) {
+		t.Fatal("access tokens of the ended session should be revoked")
+	}
+
+	// Revocations are dropped once the tokens expire.
+	if err := revocations.Refresh(ctx, now.Add(2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if revocations.Revoked(claims.Id) {
+		t.Fatal("expired token should have been dropped from the cache")
+	}
+
+	t.Run("Expired", func(t *testing.T) {
+		claims := auth.NewClaims(tests.UserID, []string{auth.RoleUser}, now, time.Hour)
+		raw, err := session.Issue(ctx, db, "", claims, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := session.Use(ctx, db, raw, now.Add(session.Lifetime)); err != session.ErrInvalidToken {
+			t.Fatalf("got %v, want %v", err, session.ErrInvalidToken)
+		}
+	})
+
+	t.Run("End", func(t *testing.T) {
+		claims := auth.NewClaims(tests.UserID, []string{auth.RoleUser}, now, time.Hour)
+		raw, err := session.Issue(ctx, db, "", claims, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := session.End(ctx, db, claims, now); err != nil {
+			t.Fatalf("ending session: %s", err)
+		}
+
+		if _, err := session.Use(ctx, db, raw, now); err != session.ErrInvalidToken {
+			t.Fatalf("refreshing ended session: got %v, want %v", err, session.ErrInvalidToken)
+		}
+
+		if err := revocations.Refresh(ctx, now); err != nil {
+			t.Fatal(err)
+		}
+		if !revocations.Revoked(claims.Id) {
+			t.Fatal("access token should be revoked")
+		}
+	})
+}
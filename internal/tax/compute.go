@@ -0,0 +1,43 @@
+package tax
+
+import (
+	"math/big"
+
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/money"
+)
+
+// Rounding is how a line's tax is rounded to whole minor units. Tax is
+// rounded once per line, never per unit, so a line always adds up.
+const Rounding = money.HalfUp
+
+// Split works out the tax on a line priced at amount. For an exclusive rate
+// the tax is added to amount; for an inclusive one it is taken out of it, so
+// Gross equals amount. Either way Net plus Tax is exactly Gross.
+func Split(amount money.Money, rate *big.Rat, inclusive bool) Line {
+	if !inclusive {
+		t := amount.Mul(rate, Rounding)
+		return Line{
+			Net:   amount,
+			Tax:   t,
+			Gross: money.Money{Amount: amount.Amount + t.Amount, Currency: amount.Currency},
+		}
+	}
+
+	// The tax inside a gross amount g at rate r is g * r / (1 + r).
+	share := new(big.Rat).Quo(rate, new(big.Rat).Add(big.NewRat(1, 1), rate))
+	t := amount.Mul(share, Rounding)
+	return Line{
+		Net:   money.Money{Amount: amount.Amount - t.Amount, Currency: amount.Currency},
+		Tax:   t,
+		Gross: amount,
+	}
+}
+
+// Untaxed is the Line of a sale no rate applies to.
+func Untaxed(amount money.Money) Line {
+	return Line{
+		Net:   amount,
+		Tax:   money.Money{Currency: amount.Currency},
+		Gross: amount,
+	}
+}
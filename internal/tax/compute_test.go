@@ -0,0 +1,55 @@
+package tax_test
+
+import (
+	"math/big"
+	"testing"
+
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/money"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/tax"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name      string
+		amount    money.Money
+		rate      string
+		inclusive bool
+		net       int64
+		tax       int64
+	}{
+		{"exclusive", money.Money{Amount: 1000, Currency: "EUR"}, "0.19", false, 1000, 190},
+		{"exclusive rounds half up", money.Money{Amount: 50, Currency: "EUR"}, "0.07", false, 50, 4},
+		{"exclusive rounds down below half", money.Money{Amount: 21, Currency: "EUR"}, "0.07", false, 21, 1},
+		{"inclusive", money.Money{Amount: 1190, Currency: "EUR"}, "0.19", true, 1000, 190},
+		{"inclusive rounds half up", money.Money{Amount: 107, Currency: "EUR"}, "0.07", true, 100, 7},
+		{"inclusive with remainder", money.Money{Amount: 999, Currency: "EUR"}, "0.19", true, 839, 160},
+		{"zero decimal currency", money.Money{Amount: 1050, Currency: "JPY"}, "0.10", false, 1050, 105},
+		{"zero rate", money.Money{Amount: 999, Currency: "USD"}, "0", true, 999, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rate, ok := new(big.Rat).SetString(tt.rate)
+			if !ok {
+				t.Fatalf("parsing rate %q", tt.rate)
+			}
+
+			l := tax.Split(tt.amount, rate, tt.inclusive)
+
+			if exp, got := tt.net, l.Net.Amount; exp != got {
+				t.Errorf("expected net %v, got %v", exp, got)
+			}
+			if exp, got := tt.tax, l.Tax.Amount; exp != got {
+				t.Errorf("expected tax %v, got %v", exp, got)
+			}
+			if exp, got := l.Net.Amount+l.Tax.Amount, l.Gross.Amount; exp != got {
+				t.Errorf("expected gross %v to equal net plus tax %v", got, exp)
+			}
+			for _, m := range []money.Money{l.Net, l.Tax, l.Gross} {
+				if m.Currency != tt.amount.Currency {
+					t.Errorf("expected currency %v, got %v", tt.amount.Currency, m.Currency)
+				}
+			}
+		})
+	}
+}
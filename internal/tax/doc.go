@@ -0,0 +1,3 @@
+// Package tax maintains the tax rates charged on sales and works out the tax
+// due on each sale line.
+package tax
@@ -0,0 +1,40 @@
+package tax
+
+import (
+	"time"
+
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/money"
+)
+
+// Rate is the tax charged in a Jurisdiction on products in a category and
+// every category below it, or on all products when CategoryID is nil. Rate
+// is a decimal fraction such as "0.19" kept as a string so it is never
+// rounded through a float. Inclusive rates are already part of the price;
+// exclusive ones are added on top of it.
+type Rate struct {
+	ID           string    `db:"tax_rate_id" json:"id"`
+	TenantID     string    `db:"tenant_id" json:"-"`
+	Jurisdiction string    `db:"jurisdiction" json:"jurisdiction"`
+	CategoryID   *string   `db:"category_id" json:"category_id"`
+	Name         string    `db:"name" json:"name"`
+	Rate         string    `db:"rate" json:"rate"`
+	Inclusive    bool      `db:"inclusive" json:"inclusive"`
+	DateCreated  time.Time `db:"date_created" json:"date_created"`
+	DateUpdated  time.Time `db:"date_updated" json:"date_updated"`
+}
+
+type NewRate struct {
+	Jurisdiction string  `json:"jurisdiction" validate:"required"`
+	CategoryID   *string `json:"category_id" validate:"omitempty,uuid"`
+	Name         string  `json:"name" validate:"required"`
+	Rate         string  `json:"rate" validate:"required"`
+	Inclusive    bool    `json:"inclusive"`
+}
+
+// Line is a sale line split into the amount before tax, the tax and the
+// total charged.
+type Line struct {
+	Net   money.Money `json:"net"`
+	Tax   money.Money `json:"tax"`
+	Gross money.Money `json:"gross"`
+}
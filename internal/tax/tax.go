@@ -0,0 +1,147 @@
+package tax
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"go.opencensus.io/trace"
+)
+
+var (
+	ErrNotFound         = errors.New("tax rate not found")
+	ErrInvalidID        = errors.New("ID is not in its proper form")
+	ErrInvalidRate      = errors.New("rate must be a decimal fraction between 0 and 1")
+	ErrCategoryNotFound = errors.New("category not found")
+)
+
+const selectRates = `SELECT tax_rate_id, tenant_id, jurisdiction, category_id, name, rate::TEXT AS rate,
+		inclusive, date_created, date_updated
+	FROM tax_rates`
+
+func List(ctx context.Context, db *sqlx.DB, tenantID string) ([]Rate, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.tax.List")
+	defer span.End()
+
+	var rates []Rate
+
+	const q = selectRates + ` WHERE tenant_id = $1 ORDER BY jurisdiction, category_id NULLS FIRST`
+	if err := db.SelectContext(ctx, &rates, q, tenantID); err != nil {
+		return nil, fmt.Errorf("selecting tax rates: %w", err)
+	}
+
+	return rates, nil
+}
+
+// Set records the rate tenantID charges for a jurisdiction and category,
+// replacing the rate already recorded for them.
+func Set(ctx context.Context, db *sqlx.DB, tenantID string, nr NewRate, now time.Time) (*Rate, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.tax.Set")
+	defer span.End()
+
+	if _, err := ParseRate(nr.Rate); err != nil {
+		return nil, err
+	}
+
+	r := Rate{
+		ID:           uuid.New().String(),
+		TenantID:     tenantID,
+		Jurisdiction: NormalizeJurisdiction(nr.Jurisdiction),
+		CategoryID:   nr.CategoryID,
+		Name:         nr.Name,
+		Inclusive:    nr.Inclusive,
+		DateCreated:  now.UTC(),
+		DateUpdated:  now.UTC(),
+	}
+
+	const q = `INSERT INTO tax_rates
+		(tax_rate_id, tenant_id, jurisdiction, category_id, name, rate, inclusive, date_created, date_updated)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (tenant_id, jurisdiction, COALESCE(category_id, '00000000-0000-0000-0000-000000000000'))
+		DO UPDATE SET name = EXCLUDED.name, rate = EXCLUDED.rate,
+			inclusive = EXCLUDED.inclusive, date_updated = EXCLUDED.date_updated
+		RETURNING tax_rate_id, rate::TEXT, date_created`
+	row := db.QueryRowContext(ctx, q,
+		r.ID, r.TenantID, r.Jurisdiction, r.CategoryID, r.Name, nr.Rate, r.Inclusive, r.DateCreated, r.DateUpdated)
+	if err := row.Scan(&r.ID, &r.Rate, &r.DateCreated); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			return nil, ErrCategoryNotFound
+		}
+		return nil, fmt.Errorf("setting tax rate: %w", err)
+	}
+
+	return &r, nil
+}
+
+func Delete(ctx context.Context, db *sqlx.DB, tenantID, id string) error {
+	ctx, span := trace.StartSpan(ctx, "internal.tax.Delete")
+	defer span.End()
+
+	if _, err := uuid.Parse(id); err != nil {
+		return ErrInvalidID
+	}
+
+	const q = `DELETE FROM tax_rates WHERE tenant_id = $1 AND tax_rate_id = $2`
+	if _, err := db.ExecContext(ctx, q, tenantID, id); err != nil {
+		return fmt.Errorf("deleting tax rate: %w", err)
+	}
+
+	return nil
+}
+
+// Lookup finds the rate tenantID charges for a product in categoryID sold in
+// jurisdiction.
+// The rate set on the nearest category up the tree wins, then the rate for
+// the whole jurisdiction. It returns ErrNotFound when neither exists.
+func Lookup(ctx context.Context, tx *sqlx.Tx, tenantID, jurisdiction string, categoryID *string) (*Rate, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.tax.Lookup")
+	defer span.End()
+
+	var r Rate
+
+	const q = `WITH RECURSIVE ancestors AS (
+			SELECT category_id, parent_id, 0 AS depth FROM categories WHERE category_id = $2::UUID
+			UNION ALL
+			SELECT c.category_id, c.parent_id, a.depth + 1
+			FROM categories AS c JOIN ancestors AS a ON c.category_id = a.parent_id
+		)
+		SELECT r.tax_rate_id, r.tenant_id, r.jurisdiction, r.category_id, r.name, r.rate::TEXT AS rate,
+			r.inclusive, r.date_created, r.date_updated
+		FROM tax_rates AS r
+		LEFT JOIN ancestors AS a ON a.category_id = r.category_id
+		WHERE r.tenant_id = $3 AND r.jurisdiction = $1
+			AND (r.category_id IS NULL OR a.category_id IS NOT NULL)
+		ORDER BY r.category_id IS NULL, a.depth
+		LIMIT 1`
+	if err := tx.GetContext(ctx, &r, q, NormalizeJurisdiction(jurisdiction), categoryID, tenantID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("looking up tax rate: %w", err)
+	}
+
+	return &r, nil
+}
+
+// ParseRate parses a decimal fraction such as "0.19".
+func ParseRate(s string) (*big.Rat, error) {
+	r, ok := new(big.Rat).SetString(s)
+	if !ok || r.Sign() < 0 || r.Cmp(big.NewRat(1, 1)) >= 0 {
+		return nil, ErrInvalidRate
+	}
+	return r, nil
+}
+
+// NormalizeJurisdiction upper cases a jurisdiction code such as "de" or
+// "us-ca".
+func NormalizeJurisdiction(j string) string {
+	return strings.ToUpper(strings.TrimSpace(j))
+}
@@ -0,0 +1,109 @@
+package tax_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/product"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/report"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/tax"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/tenant"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/tests"
+)
+
+func TestRates(t *testing.T) {
+	db, teardown := tests.NewUnit(t)
+	defer teardown()
+
+	now := time.Date(2019, time.February, 1, 0, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+
+	claims := auth.NewClaims(tests.AdminID, []string{auth.RoleAdmin}, now, time.Hour)
+	claims.Permissions = auth.Permissions
+	claims.Tenant = tests.TenantID
+
+	// Books are taxed at a reduced, inclusive rate. Everything else pays the
+	// standard rate on top of the price.
+	entertainment := "0b8f5a3e-6a57-4b7e-9d0b-2c6a1e4f7a10"
+	books := "6e2c4d1a-9b3f-4a8e-b7c5-1d2e3f4a5b20"
+	rates := []tax.NewRate{
+		{Jurisdiction: "de", Name: "Standard", Rate: "0.19"},
+		{Jurisdiction: "DE", CategoryID: &entertainment, Name: "Entertainment", Rate: "0.19"},
+		{Jurisdiction: "DE", CategoryID: &books, Name: "Reduced", Rate: "0.07", Inclusive: true},
+	}
+	for _, nr := range rates {
+		if _, err := tax.Set(ctx, db, tests.TenantID, nr, now); err != nil {
+			t.Fatalf("setting rate: %s", err)
+		}
+	}
+
+	if _, err := tax.Set(ctx, db, tests.TenantID, tax.NewRate{Jurisdiction: "DE", Name: "Bogus", Rate: "1.5"}, now); err != tax.ErrInvalidRate {
+		t.Fatalf("expected a rate over 1 to fail with %v, got %v", tax.ErrInvalidRate, err)
+	}
+
+	list, err := tax.List(ctx, db, tests.TenantID)
+	if err != nil {
+		t.Fatalf("listing rates: %s", err)
+	}
+	if exp, got := 3, len(list); exp != got {
+		t.Fatalf("expected %v rates, got %v", exp, got)
+	}
+
+	// Another tenant's rates apply to its own sales only, and it cannot set
+	// rates on this tenant's categories.
+	other, err := tenant.Create(ctx, db, tenant.NewTenant{Name: "Corner Shop"}, now)
+	if err != nil {
+		t.Fatalf("creating tenant: %s", err)
+	}
+	if _, err := tax.Set(ctx, db, other.ID, tax.NewRate{Jurisdiction: "FR", Name: "Standard", Rate: "0.2"}, now); err != nil {
+		t.Fatalf("setting another tenant's rate: %s", err)
+	}
+	if _, err := tax.Set(ctx, db, other.ID, tax.NewRate{Jurisdiction: "DE", CategoryID: &books, Name: "Books", Rate: "0.05"}, now); err != tax.ErrCategoryNotFound {
+		t.Fatalf("expected a category of another tenant to fail with %v, got %v", tax.ErrCategoryNotFound, err)
+	}
+
+	comics := "a2b0639f-2cc6-44b8-b97b-15d69dbb511e"
+	toys := "72f8b983-3eb4-48db-9ed0-e45cc6bd716b"
+
+	// Two comics at 50 include 7 of tax.
+	s, err := product.AddSale(ctx, db, claims, product.NewSale{Quantity: 2, Jurisdiction: "de"}, comics, now)
+	if err != nil {
+		t.Fatalf("adding sale: %s", err)
+	}
+	if exp, got := [3]int64{93, 7, 100}, [3]int64{s.Net.Amount, s.Tax.Amount, s.Paid.Amount}; exp != got {
+		t.Fatalf("expected net, tax and paid %v, got %v", exp, got)
+	}
+
+	// Toys inherit the Entertainment rate from their parent category.
+	s, err = product.AddSale(ctx, db, claims, product.NewSale{Quantity: 1, Jurisdiction: "DE"}, toys, now)
+	if err != nil {
+		t.Fatalf("adding sale: %s", err)
+	}
+	if exp, got := [3]int64{75, 14, 89}, [3]int64{s.Net.Amount, s.Tax.Amount, s.Paid.Amount}; exp != got {
+		t.Fatalf("expected net, tax and paid %v, got %v", exp, got)
+	}
+
+	// Nothing is charged where no rate is set.
+	s, err = product.AddSale(ctx, db, claims, product.NewSale{Quantity: 1, Jurisdiction: "FR"}, toys, now)
+	if err != nil {
+		t.Fatalf("adding sale: %s", err)
+	}
+	if s.Tax.Amount != 0 || s.TaxRate != nil {
+		t.Fatalf("expected an untaxed sale, got tax %v at %v", s.Tax, s.TaxRate)
+	}
+
+	rows, err := report.Tax(ctx, db, tests.TenantID, now, now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("reporting: %s", err)
+	}
+
+	var collected int64
+	for _, r := range rows {
+		collected += r.Tax.Amount
+	}
+	if exp, got := int64(21), collected; exp != got {
+		t.Fatalf("expected %v tax collected, got %v", exp, got)
+	}
+}
@@ -0,0 +1,5 @@
+// Package tenant manages the shops sharing one deployment. Products, sales
+// and users each belong to a tenant, and callers only ever see their own
+// tenant's. Everything created before there were tenants belongs to the
+// one with DefaultID.
+package tenant
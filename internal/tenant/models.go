@@ -0,0 +1,14 @@
+package tenant
+
+import "time"
+
+// Tenant is a shop with its own products, sales and users.
+type Tenant struct {
+	ID          string    `db:"tenant_id" json:"id"`
+	Name        string    `db:"name" json:"name"`
+	DateCreated time.Time `db:"date_created" json:"date_created"`
+}
+
+type NewTenant struct {
+	Name string `json:"name" validate:"required"`
+}
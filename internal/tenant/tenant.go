@@ -0,0 +1,91 @@
+package tenant
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/receipt"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/role"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/warehouse"
+	"go.opencensus.io/trace"
+)
+
+// DefaultID is the tenant the migrations create for existing data.
+const DefaultID = "1b7c3e5a-2d4f-4a6b-8c9d-0e1f2a3b4c5d"
+
+var (
+	ErrNotFound  = errors.New("tenant not found")
+	ErrInvalidID = errors.New("ID is not in its proper form")
+	ErrNameTaken = errors.New("tenant name is taken")
+)
+
+func Retrieve(ctx context.Context, db *sqlx.DB, id string) (*Tenant, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.tenant.Retrieve")
+	defer span.End()
+
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrInvalidID
+	}
+
+	var t Tenant
+	const q = `SELECT * FROM tenants WHERE tenant_id = $1`
+	if err := db.GetContext(ctx, &t, q, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("selecting tenant %q: %w", id, err)
+	}
+
+	return &t, nil
+}
+
+// Create adds a tenant with the default roles defined and a default
+// warehouse to stock its products in.
+func Create(ctx context.Context, db *sqlx.DB, nt NewTenant, now time.Time) (*Tenant, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.tenant.Create")
+	defer span.End()
+
+	t := Tenant{
+		ID:          uuid.New().String(),
+		Name:        nt.Name,
+		DateCreated: now.UTC(),
+	}
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("starting tenant insert: %w", err)
+	}
+	defer tx.Rollback()
+
+	const q = `INSERT INTO tenants (tenant_id, name, date_created) VALUES ($1, $2, $3)`
+	if _, err := tx.ExecContext(ctx, q, t.ID, t.Name, t.DateCreated); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return nil, ErrNameTaken
+		}
+		return nil, fmt.Errorf("inserting tenant: %w", err)
+	}
+
+	if err := role.SetDefaults(ctx, tx, t.ID, now); err != nil {
+		return nil, err
+	}
+
+	if _, err := warehouse.CreateDefault(ctx, tx, t.ID, now); err != nil {
+		return nil, err
+	}
+
+	if err := receipt.StartNumbering(ctx, tx, t.ID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("committing tenant insert: %w", err)
+	}
+	return &t, nil
+}
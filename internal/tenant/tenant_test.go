@@ -0,0 +1,272 @@
+package tenant_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/money"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/database"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/product"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/schema"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/tenant"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/tests"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/user"
+)
+
+func TestTenants(t *testing.T) {
+	db, teardown := tests.NewUnit(t)
+	defer teardown()
+
+	ctx := context.Background()
+	now := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	// The migrations make the tenant existing data belongs to.
+	def, err := tenant.Retrieve(ctx, db, tenant.DefaultID)
+	if err != nil {
+		t.Fatalf("retrieving default tenant: %s", err)
+	}
+	if def.Name != "default" {
+		t.Fatalf("default tenant is named %q", def.Name)
+	}
+
+	created, err := tenant.Create(ctx, db, tenant.NewTenant{Name: "Corner Shop"}, now)
+	if err != nil {
+		t.Fatalf("creating tenant: %s", err)
+	}
+
+	saved, err := tenant.Retrieve(ctx, db, created.ID)
+	if err != nil {
+		t.Fatalf("retrieving tenant: %s", err)
+	}
+	if saved.Name != "Corner Shop" {
+		t.Fatalf("retrieved tenant is named %q", saved.Name)
+	}
+
+	if _, err := tenant.Create(ctx, db, tenant.NewTenant{Name: "Corner Shop"}, now); err != tenant.ErrNameTaken {
+		t.Fatalf("creating tenant twice: got %v, want %v", err, tenant.ErrNameTaken)
+	}
+	if _, err := tenant.Retrieve(ctx, db, "3c7e0c5a-6f0d-4a35-9a43-5b7a2f31e8b2"); err != tenant.ErrNotFound {
+		t.Fatalf("retrieving unknown tenant: got %v, want %v", err, tenant.ErrNotFound)
+	}
+	if _, err := tenant.Retrieve(ctx, db, "shop"); err != tenant.ErrInvalidID {
+		t.Fatalf("retrieving malformed ID: got %v, want %v", err, tenant.ErrInvalidID)
+	}
+}
+
+// TestIsolation proves the products, sales and users of one tenant cannot
+// be read or changed through another.
+func TestIsolation(t *testing.T) {
+	db, teardown := tests.NewUnit(t)
+	defer teardown()
+
+	if err := schema.Seed(db); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	now := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	other, err := tenant.Create(ctx, db, tenant.NewTenant{Name: "Corner Shop"}, now)
+	if err != nil {
+		t.Fatalf("creating tenant: %s", err)
+	}
+
+	admin := func(tenantID string) auth.Claims {
+		c := auth.NewClaims(tests.AdminID, []string{auth.RoleAdmin}, now, time.Hour)
+		c.Permissions = auth.Permissions
+		c.Tenant = tenantID
+		return c
+	}
+	theirs := admin(other.ID)
+	ours := admin(tests.TenantID)
+
+	np := product.NewProduct{
+		Name:     "Marbles",
+		Cost:     money.Money{Amount: 10, Currency: "USD"},
+		Quantity: 5,
+	}
+	p, err := product.Create(ctx, db, theirs, np, now)
+	if err != nil {
+		t.Fatalf("creating product: %s", err)
+	}
+
+	s, err := product.AddSale(ctx, db, theirs, product.NewSale{Quantity: 1}, p.ID, now)
+	if err != nil {
+		t.Fatalf("adding sale: %s", err)
+	}
+
+	nu := user.NewUser{
+		Name:            "Corner Clerk",
+		Email:           "clerk@corner.example.com",
+		Roles:           []string{auth.RoleUser},
+		Password:        "gophers",
+		PasswordConfirm: "gophers",
+	}
+	u, err := user.Create(ctx, db, theirs, nu, now)
+	if err != nil {
+		t.Fatalf("creating user: %s", err)
+	}
+
+	// Their rows are visible to them.
+	if _, err := product.Retrieve(ctx, db, other.ID, p.ID); err != nil {
+		t.Fatalf("retrieving own product: %s", err)
+	}
+	if _, err := user.Retrieve(ctx, db, other.ID, u.ID); err != nil {
+		t.Fatalf("retrieving own user: %s", err)
+	}
+
+	// And to nobody else.
+	if _, err := product.Retrieve(ctx, db, tests.TenantID, p.ID); err != product.ErrNotFound {
+		t.Fatalf("retrieving product: got %v, want %v", err, product.ErrNotFound)
+	}
+	if _, err := product.RetrieveVariant(ctx, db, tests.TenantID, p.ID); err != product.ErrVariantNotFound {
+		t.Fatalf("retrieving variant: got %v, want %v", err, product.ErrVariantNotFound)
+	}
+	if _, err := product.RetrieveSale(ctx, db, tests.TenantID, s.ID); err != product.ErrSaleNotFound {
+		t.Fatalf("retrieving sale: got %v, want %v", err, product.ErrSaleNotFound)
+	}
+	if _, err := user.Retrieve(ctx, db, tests.TenantID, u.ID); err != user.ErrNotFound {
+		t.Fatalf("retrieving user: got %v, want %v", err, user.ErrNotFound)
+	}
+
+	variants, err := product.ListVariants(ctx, db, tests.TenantID, p.ID)
+	if err != nil {
+		t.Fatalf("listing variants: %s", err)
+	}
+	if len(variants) != 0 {
+		t.Fatalf("listed %d variants of another tenant's product", len(variants))
+	}
+
+	sales, err := product.ListSales(ctx, db, tests.TenantID, p.ID)
+	if err != nil {
+		t.Fatalf("listing sales: %s", err)
+	}
+	if len(sales) != 0 {
+		t.Fatalf("listed %d sales of another tenant's product", len(sales))
+	}
+
+	// Each tenant's listing holds only its own products.
+	list, err := product.List(ctx, db, tests.TenantID, product.Filter{})
+	if err != nil {
+		t.Fatalf("listing products: %s", err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("default tenant lists %d products, want the 2 seeded", len(list))
+	}
+	for _, lp := range list {
+		if lp.ID == p.ID {
+			t.Fatal("default tenant lists another tenant's product")
+		}
+	}
+
+	list, err = product.List(ctx, db, other.ID, product.Filter{})
+	if err != nil {
+		t.Fatalf("listing products: %s", err)
+	}
+	if len(list) != 1 || list[0].ID != p.ID {
+		t.Fatalf("other tenant lists %v, want only its own product", list)
+	}
+
+	// Writes cannot reach across either.
+	name := "Stolen"
+	if err := product.Update(ctx, db, ours, p.ID, product.UpdateProduct{Name: &name}, now); err != product.ErrNotFound {
+		t.Fatalf("updating product: got %v, want %v", err, product.ErrNotFound)
+	}
+	if _, err := product.AddSale(ctx, db, ours, product.NewSale{Quantity: 1}, p.ID, now); err != product.ErrVariantNotFound {
+		t.Fatalf("adding sale: got %v, want %v", err, product.ErrVariantNotFound)
+	}
+	if err := product.Delete(ctx, db, ours, p.ID, now); err != nil {
+		t.Fatalf("deleting product: %s", err)
+	}
+	saved, err := product.Retrieve(ctx, db, other.ID, p.ID)
+	if err != nil {
+		t.Fatalf("retrieving product after another tenant deleted it: %s", err)
+	}
+	if saved.Name != np.Name {
+		t.Fatalf("product is named %q after another tenant updated it", saved.Name)
+	}
+}
+
+// TestRowLevelSecurity checks the policies alone keep a tenant to its rows,
+// for a query that forgets to filter them.
+func TestRowLevelSecurity(t *testing.T) {
+	db, teardown := tests.NewUnit(t)
+	defer teardown()
+
+	if err := schema.Seed(db); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	now := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	other, err := tenant.Create(ctx, db, tenant.NewTenant{Name: "Corner Shop"}, now)
+	if err != nil {
+		t.Fatalf("creating tenant: %s", err)
+	}
+
+	if err := schema.RowLevelSecurity(db, true); err != nil {
+		t.Fatalf("enabling row level security: %s", err)
+	}
+
+	// The test database is reached as a superuser, which the policies never
+	// apply to, so the queries run as an ordinary role like the service's.
+	const setup = `CREATE ROLE shop;
+		GRANT SELECT ON products, sales, users TO shop`
+	if _, err := db.Exec(setup); err != nil {
+		t.Fatalf("creating role: %s", err)
+	}
+
+	// count counts the products a transaction sees once act has said whom it
+	// acts for.
+	count := func(act func(tx *sqlx.Tx) error) int {
+		t.Helper()
+
+		tx, err := db.Beginx()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer tx.Rollback()
+
+		if err := act(tx); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tx.ExecContext(ctx, `SET LOCAL ROLE shop`); err != nil {
+			t.Fatal(err)
+		}
+
+		var n int
+		if err := tx.GetContext(ctx, &n, `SELECT COUNT(*) FROM products`); err != nil {
+			t.Fatalf("counting products: %s", err)
+		}
+		return n
+	}
+	forTenant := func(id string) func(tx *sqlx.Tx) error {
+		return func(tx *sqlx.Tx) error { return database.SetTenant(ctx, tx, id) }
+	}
+	bypass := func(tx *sqlx.Tx) error { return database.Bypass(ctx, tx) }
+	nobody := func(tx *sqlx.Tx) error { return nil }
+
+	if n := count(forTenant(tests.TenantID)); n != 2 {
+		t.Fatalf("default tenant sees %d products, want 2", n)
+	}
+	if n := count(forTenant(other.ID)); n != 0 {
+		t.Fatalf("other tenant sees %d products, want 0", n)
+	}
+	if n := count(nobody); n != 0 {
+		t.Fatalf("a transaction without a tenant sees %d products, want 0", n)
+	}
+	if n := count(bypass); n != 2 {
+		t.Fatalf("a transaction bypassing tenants sees %d products, want 2", n)
+	}
+
+	if err := schema.RowLevelSecurity(db, false); err != nil {
+		t.Fatalf("disabling row level security: %s", err)
+	}
+	if n := count(forTenant(other.ID)); n != 2 {
+		t.Fatalf("other tenant sees %d products with the policies off, want 2", n)
+	}
+}
@@ -13,13 +13,20 @@ import (
 	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
 	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/database"
 	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/database/databasetest"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/mail"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/receipt"
 	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/schema"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/session"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/tenant"
 	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/user"
 )
 
 const (
 	AdminID = "5cf37266-3473-4006-984f-9325122678b7"
 	UserID  = "45b5fbd3-755f-4379-8f07-a58d4a30fa2f"
+
+	// TenantID is the tenant the seed data belongs to.
+	TenantID = tenant.DefaultID
 )
 
 func NewUnit(t *testing.T) (*sqlx.DB, func()) {
@@ -73,6 +80,9 @@ type Test struct {
 	DB            *sqlx.DB
 	Log           *log.Logger
 	Authenticator *auth.Authenticator
+	Revocations   *session.Revocations
+	Receipts      *receipt.Renderer
+	Mailer        *mail.Memory
 
 	t       *testing.T
 	cleanup func()
@@ -87,6 +97,12 @@ func New(t *testing.T) *Test {
 		t.Fatal(err)
 	}
 
+	// The seeded admin logs in with a password alone, as Token does. Tests
+	// of MFA require it again.
+	if _, err := db.Exec(`UPDATE roles SET require_mfa = FALSE`); err != nil {
+		t.Fatal(err)
+	}
+
 	logger := log.New(os.Stdout, "TEST : ", log.LstdFlags|log.Lmicroseconds|log.Lshortfile)
 
 	key, err := rsa.GenerateKey(rand.Reader, 2048)
@@ -101,10 +117,23 @@ func New(t *testing.T) *Test {
 		t.Fatal(err)
 	}
 
+	revocations, err := session.NewRevocations(context.Background(), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	receipts, err := receipt.NewRenderer("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	return &Test{
 		DB:            db,
 		Log:           logger,
 		Authenticator: authenticator,
+		Revocations:   revocations,
+		Receipts:      receipts,
+		Mailer:        &mail.Memory{},
 		t:             t,
 		cleanup:       cleanup,
 	}
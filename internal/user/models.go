@@ -8,12 +8,17 @@ import (
 
 type User struct {
 	ID           string         `db:"user_id" json:"id"`
+	TenantID     string         `db:"tenant_id" json:"tenant_id"`
 	Name         string         `db:"name" json:"name"`
 	Email        string         `db:"email" json:"email"`
 	Roles        pq.StringArray `db:"roles" json:"roles"`
 	PasswordHash []byte         `db:"password_hash" json:"-"`
 	DateCreated  time.Time      `db:"date_created" json:"date_created"`
 	DateUpdated  time.Time      `db:"date_updated" json:"date_updated"`
+
+	// DateEmailVerified is when the user proved they hold Email, or nil if
+	// they have yet to.
+	DateEmailVerified *time.Time `db:"date_email_verified" json:"date_email_verified"`
 }
 
 type NewUser struct {
@@ -5,23 +5,117 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/mfa"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/outbox"
 	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/authz"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/database"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/role"
 	"go.opencensus.io/trace"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// tokenLifetime is how long the access tokens issued to users are valid.
+const tokenLifetime = time.Hour
+
+// dummyHash is what passwords given for unknown emails are checked against,
+// so they take as long to refuse as wrong passwords and do not give away
+// which emails have accounts. It is of no password anyone has.
+var dummyHash = []byte("$2a$10$jKEFpFjzRFkM6S4FwyTXYubb3fgfs5GL4OUPDiD4xQniysEMe2CGi")
+
+// Permissions returns what roles of tenantID grant the user id. Roles that
+// require MFA grant nothing until the user has enrolled, so every credential
+// the user holds, tokens and API keys alike, is held to the same rule.
+func Permissions(ctx context.Context, db sqlx.QueryerContext, tenantID, id string, roles []string) ([]string, error) {
+	perms, err := role.Permissions(ctx, db, tenantID, roles)
+	if err != nil {
+		return nil, err
+	}
+
+	required, err := role.RequireMFA(ctx, db, tenantID, roles)
+	if err != nil {
+		return nil, err
+	}
+	if required {
+		enabled, err := mfa.Enabled(ctx, db, id)
+		if err != nil {
+			return nil, err
+		}
+		if !enabled {
+			return []string{}, nil
+		}
+	}
+
+	return perms, nil
+}
+
+// NormalizeEmail returns email as it is stored and looked up, so addresses
+// that differ only in case or surrounding space name the same user.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
 var (
 	ErrAuthenticationFailure = errors.New("authentication failed")
+	ErrNotFound              = errors.New("user not found")
+	ErrInvalidID             = errors.New("ID is not in its proper form")
+
+	// ErrMFARequired matches every *MFARequired.
+	ErrMFARequired = errors.New("multi-factor authentication required")
 )
 
-func Create(ctx context.Context, db *sqlx.DB, n NewUser, now time.Time) (*User, error) {
+// MFARequired is returned for the correct password of a user enrolled in
+// MFA. The login is completed by AuthenticateMFA with a code for Challenge.
+type MFARequired struct {
+	Challenge mfa.Challenge
+}
+
+func (m *MFARequired) Error() string {
+	return ErrMFARequired.Error()
+}
+
+func (m *MFARequired) Is(target error) bool {
+	return target == ErrMFARequired
+}
+
+// Retrieve returns the user id of tenantID.
+func Retrieve(ctx context.Context, db *sqlx.DB, tenantID, id string) (*User, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.user.Retrieve")
+	defer span.End()
+
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrInvalidID
+	}
+
+	var u User
+	err := database.WithTenant(ctx, db, tenantID, func(tx *sqlx.Tx) error {
+		const q = `select * from users where user_id = $1 and tenant_id = $2`
+		return tx.GetContext(ctx, &u, q, id, tenantID)
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("selecting user %q: %w", id, err)
+	}
+
+	return &u, nil
+}
+
+// Create adds a user to the tenant of claims. Only user admins may do so.
+func Create(ctx context.Context, db *sqlx.DB, claims auth.Claims, n NewUser, now time.Time) (*User, error) {
 	ctx, span := trace.StartSpan(ctx, "internal.user.Create")
 	defer span.End()
 
+	if err := authz.Check(claims, authz.Create, authz.Resource{Kind: authz.User}); err != nil {
+		return nil, err
+	}
+
 	hash, err := bcrypt.GenerateFromPassword([]byte(n.Password), bcrypt.DefaultCost)
 	if err != nil {
 		return nil, fmt.Errorf("generating password hash %w", err)
@@ -29,36 +123,100 @@ func Create(ctx context.Context, db *sqlx.DB, n NewUser, now time.Time) (*User,
 
 	u := User{
 		ID:           uuid.New().String(),
+		TenantID:     claims.Tenant,
 		Name:         n.Name,
-		Email:        n.Email,
+		Email:        NormalizeEmail(n.Email),
 		Roles:        n.Roles,
 		PasswordHash: hash,
 		DateCreated:  now.UTC(),
 		DateUpdated:  now.UTC(),
 	}
 
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("starting user insert: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := database.SetTenant(ctx, tx, u.TenantID); err != nil {
+		return nil, err
+	}
+
 	const q = `INSERT INTO users
-		(user_id, name, email, password_hash, roles, date_created, date_updated)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)`
-	_, err = db.ExecContext(
+		(user_id, tenant_id, name, email, password_hash, roles, date_created, date_updated)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	_, err = tx.ExecContext(
 		ctx, q,
-		u.ID, u.Name, u.Email, u.PasswordHash,
+		u.ID, u.TenantID, u.Name, u.Email, u.PasswordHash,
 		u.Roles, u.DateCreated, u.DateUpdated,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("inserting user %w", err)
 	}
+
+	if err := outbox.Record(ctx, tx, u.TenantID, outbox.AggregateUser, u.ID, outbox.UserCreated, u, now); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("committing user insert: %w", err)
+	}
 	return &u, nil
 }
 
+// SetPassword replaces the password of the user id.
+func SetPassword(ctx context.Context, db sqlx.ExecerContext, id, password string, now time.Time) error {
+	ctx, span := trace.StartSpan(ctx, "internal.user.SetPassword")
+	defer span.End()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("generating password hash %w", err)
+	}
+
+	const q = `UPDATE users SET password_hash = $2, date_updated = $3 WHERE user_id = $1`
+	res, err := db.ExecContext(ctx, q, id, hash, now.UTC())
+	if err != nil {
+		return fmt.Errorf("updating password of %q: %w", id, err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// VerifyEmail records that the user id holds email, unless their email has
+// changed since it was checked.
+func VerifyEmail(ctx context.Context, db sqlx.ExecerContext, id, email string, now time.Time) error {
+	ctx, span := trace.StartSpan(ctx, "internal.user.VerifyEmail")
+	defer span.End()
+
+	const q = `UPDATE users SET date_email_verified = $3
+		WHERE user_id = $1 AND email = $2 AND date_email_verified IS NULL`
+	if _, err := db.ExecContext(ctx, q, id, email, now.UTC()); err != nil {
+		return fmt.Errorf("verifying email of %q: %w", id, err)
+	}
+
+	return nil
+}
+
+// Authenticate returns the claims of the user with email and password.
+// Emails are unique across tenants, so this is the one lookup that is not
+// scoped to one; the claims carry the user's tenant from then on. Users
+// enrolled in MFA get an *MFARequired instead.
 func Authenticate(ctx context.Context, db *sqlx.DB, now time.Time, email, password string) (auth.Claims, error) {
 	ctx, span := trace.StartSpan(ctx, "internal.user.Authenticate")
 	defer span.End()
 
 	const q = `select * from users where email = $1`
 	var u User
-	if err := db.GetContext(ctx, &u, q, email); err != nil {
+	err := database.WithBypass(ctx, db, func(tx *sqlx.Tx) error {
+		return tx.GetContext(ctx, &u, q, NormalizeEmail(email))
+	})
+	if err != nil {
 		if err == sql.ErrNoRows {
+			bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
 			return auth.Claims{}, ErrAuthenticationFailure
 		}
 
@@ -69,6 +227,72 @@ func Authenticate(ctx context.Context, db *sqlx.DB, now time.Time, email, passwo
 		return auth.Claims{}, ErrAuthenticationFailure
 	}
 
-	claims := auth.NewClaims(u.ID, u.Roles, now, time.Hour)
+	enabled, err := mfa.Enabled(ctx, db, u.ID)
+	if err != nil {
+		return auth.Claims{}, err
+	}
+	if enabled {
+		c, err := mfa.Issue(ctx, db, u.ID, now)
+		if err != nil {
+			return auth.Claims{}, err
+		}
+		return auth.Claims{}, &MFARequired{Challenge: c}
+	}
+
+	return newClaims(ctx, db, u, now)
+}
+
+// AuthenticateMFA completes a login held at challenge with code, returning
+// the claims of the user it was issued to.
+func AuthenticateMFA(ctx context.Context, db *sqlx.DB, now time.Time, challenge, code string) (auth.Claims, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.user.AuthenticateMFA")
+	defer span.End()
+
+	id, err := mfa.Redeem(ctx, db, challenge, code, now)
+	if err != nil {
+		return auth.Claims{}, err
+	}
+
+	return Claims(ctx, db, id, now)
+}
+
+// Claims returns the claims of a new access token for the user id, as when
+// a session is refreshed, with the user's current roles.
+func Claims(ctx context.Context, db *sqlx.DB, id string, now time.Time) (auth.Claims, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.user.Claims")
+	defer span.End()
+
+	if _, err := uuid.Parse(id); err != nil {
+		return auth.Claims{}, ErrInvalidID
+	}
+
+	// The session knows the user but not yet the tenant.
+	var u User
+	const q = `select * from users where user_id = $1`
+	err := database.WithBypass(ctx, db, func(tx *sqlx.Tx) error {
+		return tx.GetContext(ctx, &u, q, id)
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return auth.Claims{}, ErrNotFound
+		}
+		return auth.Claims{}, fmt.Errorf("selecting user %q: %w", id, err)
+	}
+
+	return newClaims(ctx, db, u, now)
+}
+
+// newClaims returns the claims of an access token for u carrying the
+// permissions of its roles. Users holding a role that requires MFA get none
+// until they have enrolled, which they may still do.
+func newClaims(ctx context.Context, db *sqlx.DB, u User, now time.Time) (auth.Claims, error) {
+	perms, err := Permissions(ctx, db, u.TenantID, u.ID, u.Roles)
+	if err != nil {
+		return auth.Claims{}, err
+	}
+
+	claims := auth.NewClaims(u.ID, u.Roles, now, tokenLifetime)
+	claims.Permissions = perms
+	claims.Tenant = u.TenantID
 	return claims, nil
 }
@@ -0,0 +1,3 @@
+// Package warehouse implements the business logic for the locations we hold
+// stock in and the movement of stock between them.
+package warehouse
@@ -0,0 +1,51 @@
+package warehouse
+
+import (
+	"time"
+)
+
+// Warehouse is a location a tenant holds and ships stock from. New stock
+// goes to the tenant's Default warehouse unless told otherwise.
+type Warehouse struct {
+	ID          string    `db:"warehouse_id" json:"id"`
+	TenantID    string    `db:"tenant_id" json:"-"`
+	Name        string    `db:"name" json:"name"`
+	Default     bool      `db:"is_default" json:"default"`
+	DateCreated time.Time `db:"date_created" json:"date_created"`
+	DateUpdated time.Time `db:"date_updated" json:"date_updated"`
+}
+
+type NewWarehouse struct {
+	Name string `json:"name" validate:"required"`
+}
+
+// Stock is the quantity of a product variant held in a single warehouse.
+type Stock struct {
+	WarehouseID string `db:"warehouse_id" json:"warehouse_id"`
+	ProductID   string `db:"product_id" json:"product_id"`
+	VariantID   string `db:"variant_id" json:"variant_id"`
+	Quantity    int    `db:"quantity" json:"quantity"`
+}
+
+type UpdateStock struct {
+	Quantity int `json:"quantity" validate:"gte=0"`
+}
+
+// Transfer records stock moved from one warehouse to another.
+type Transfer struct {
+	ID              string    `db:"transfer_id" json:"id"`
+	ProductID       string    `db:"product_id" json:"product_id"`
+	VariantID       string    `db:"variant_id" json:"variant_id"`
+	FromWarehouseID string    `db:"from_warehouse_id" json:"from_warehouse_id"`
+	ToWarehouseID   string    `db:"to_warehouse_id" json:"to_warehouse_id"`
+	Quantity        int       `db:"quantity" json:"quantity"`
+	UserID          string    `db:"user_id" json:"user_id"`
+	DateCreated     time.Time `db:"date_created" json:"date_created"`
+}
+
+type NewTransfer struct {
+	VariantID       string `json:"variant_id" validate:"required"`
+	FromWarehouseID string `json:"from_warehouse_id" validate:"required"`
+	ToWarehouseID   string `json:"to_warehouse_id" validate:"required,nefield=FromWarehouseID"`
+	Quantity        int    `json:"quantity" validate:"gte=1"`
+}
@@ -0,0 +1,321 @@
+package warehouse
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/database"
+	"go.opencensus.io/trace"
+)
+
+// AllStock returns the stock level of every variant in every warehouse of
+// tenantID.
+func AllStock(ctx context.Context, db *sqlx.DB, tenantID string) ([]Stock, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.warehouse.AllStock")
+	defer span.End()
+
+	var stock []Stock
+
+	const q = `SELECT s.* FROM stock AS s
+		JOIN warehouses AS w ON w.warehouse_id = s.warehouse_id
+		WHERE w.tenant_id = $1
+		ORDER BY s.product_id, s.warehouse_id, s.variant_id`
+	if err := db.SelectContext(ctx, &stock, q, tenantID); err != nil {
+		return nil, fmt.Errorf("selecting stock: %w", err)
+	}
+
+	return stock, nil
+}
+
+// ProductStock returns the stock level of each variant of a product in each
+// warehouse holding it.
+func ProductStock(ctx context.Context, db sqlx.QueryerContext, productID string) ([]Stock, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.warehouse.ProductStock")
+	defer span.End()
+
+	var stock []Stock
+
+	const q = `SELECT * FROM stock WHERE product_id = $1 ORDER BY warehouse_id, variant_id`
+	if err := sqlx.SelectContext(ctx, db, &stock, q, productID); err != nil {
+		return nil, fmt.Errorf("selecting product stock: %w", err)
+	}
+
+	return stock, nil
+}
+
+// WarehouseStock returns the stock level of each variant held in a warehouse
+// of tenantID.
+func WarehouseStock(ctx context.Context, db *sqlx.DB, tenantID, warehouseID string) ([]Stock, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.warehouse.WarehouseStock")
+	defer span.End()
+
+	if _, err := uuid.Parse(warehouseID); err != nil {
+		return nil, ErrInvalidID
+	}
+
+	var stock []Stock
+
+	const q = `SELECT s.* FROM stock AS s
+		JOIN warehouses AS w ON w.warehouse_id = s.warehouse_id
+		WHERE w.tenant_id = $1 AND s.warehouse_id = $2
+		ORDER BY s.product_id, s.variant_id`
+	if err := db.SelectContext(ctx, &stock, q, tenantID, warehouseID); err != nil {
+		return nil, fmt.Errorf("selecting warehouse stock: %w", err)
+	}
+
+	return stock, nil
+}
+
+// tenantStock selects the row to store in stock for a variant and a warehouse
+// that both belong to a tenant. The product is taken from the variant so no
+// rows are written when either is unknown to the tenant.
+const tenantStock = `SELECT w.warehouse_id, v.product_id, v.variant_id, $4::INT
+	FROM variants AS v
+	JOIN products AS p ON p.product_id = v.product_id
+	JOIN warehouses AS w ON w.tenant_id = p.tenant_id
+	WHERE p.tenant_id = $1 AND w.warehouse_id = $2 AND v.variant_id = $3`
+
+// replaceStock sets the level of a variant in a warehouse.
+const replaceStock = `INSERT INTO stock (warehouse_id, product_id, variant_id, quantity)
+	` + tenantStock + `
+	ON CONFLICT (warehouse_id, variant_id) DO UPDATE SET quantity = EXCLUDED.quantity
+	RETURNING product_id`
+
+// SetStock records the quantity of a variant held in a warehouse of tenantID,
+// replacing whatever level was recorded before.
+func SetStock(ctx context.Context, db *sqlx.DB, tenantID, warehouseID, variantID string, us UpdateStock) (*Stock, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.warehouse.SetStock")
+	defer span.End()
+
+	if _, err := uuid.Parse(warehouseID); err != nil {
+		return nil, ErrInvalidID
+	}
+	if _, err := uuid.Parse(variantID); err != nil {
+		return nil, ErrInvalidID
+	}
+
+	s := Stock{
+		WarehouseID: warehouseID,
+		VariantID:   variantID,
+		Quantity:    us.Quantity,
+	}
+
+	err := database.WithTenant(ctx, db, tenantID, func(tx *sqlx.Tx) error {
+		return tx.GetContext(ctx, &s.ProductID, replaceStock, tenantID, s.WarehouseID, s.VariantID, s.Quantity)
+	})
+	if err != nil {
+		if err == sql.ErrNoRows || isForeignKeyViolation(err) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("setting stock: %w", err)
+	}
+
+	return &s, nil
+}
+
+// Replace is SetStock as part of tx.
+func Replace(ctx context.Context, tx *sqlx.Tx, tenantID, warehouseID, variantID string, quantity int) error {
+	ctx, span := trace.StartSpan(ctx, "internal.warehouse.Replace")
+	defer span.End()
+
+	var productID string
+	if err := tx.GetContext(ctx, &productID, replaceStock, tenantID, warehouseID, variantID, quantity); err != nil {
+		if err == sql.ErrNoRows || isForeignKeyViolation(err) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("replacing stock: %w", err)
+	}
+
+	return nil
+}
+
+// Pick selects the warehouse of tenantID that should fulfil an order for
+// quantity units of a variant: the one holding the most of it. The chosen
+// stock row stays locked until tx ends. It returns ErrNotFound when the tenant
+// has no such variant and ErrInsufficientStock when no warehouse holds enough.
+func Pick(ctx context.Context, tx *sqlx.Tx, tenantID, variantID string, quantity int) (string, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.warehouse.Pick")
+	defer span.End()
+
+	var warehouseID string
+
+	const q = `SELECT s.warehouse_id FROM stock AS s
+		JOIN warehouses AS w ON w.warehouse_id = s.warehouse_id
+		WHERE w.tenant_id = $1 AND s.variant_id = $2 AND s.quantity >= $3
+		ORDER BY s.quantity DESC, s.warehouse_id
+		LIMIT 1
+		FOR UPDATE OF s`
+	if err := tx.GetContext(ctx, &warehouseID, q, tenantID, variantID, quantity); err != nil {
+		if err == sql.ErrNoRows {
+			return "", stockError(ctx, tx, tenantID, "", variantID)
+		}
+		return "", fmt.Errorf("picking warehouse: %w", err)
+	}
+
+	return warehouseID, nil
+}
+
+// Withdraw removes quantity units of a variant from a warehouse of tenantID as
+// part of tx. It returns ErrNotFound when the tenant has no such variant or
+// warehouse and ErrInsufficientStock when the warehouse holds too little.
+func Withdraw(ctx context.Context, tx *sqlx.Tx, tenantID, warehouseID, variantID string, quantity int) error {
+	ctx, span := trace.StartSpan(ctx, "internal.warehouse.Withdraw")
+	defer span.End()
+
+	const q = `UPDATE stock AS s SET quantity = s.quantity - $4
+		FROM warehouses AS w
+		WHERE w.warehouse_id = s.warehouse_id AND w.tenant_id = $1
+			AND s.warehouse_id = $2 AND s.variant_id = $3 AND s.quantity >= $4`
+	res, err := tx.ExecContext(ctx, q, tenantID, warehouseID, variantID, quantity)
+	if err != nil {
+		return fmt.Errorf("withdrawing stock: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("withdrawing stock: %w", err)
+	}
+	if n == 0 {
+		return stockError(ctx, tx, tenantID, warehouseID, variantID)
+	}
+
+	return nil
+}
+
+// stockError explains why no stock of a variant could be taken from a
+// warehouse of tenantID, or from any of its warehouses when warehouseID is
+// blank: ErrNotFound when either is unknown to the tenant and
+// ErrInsufficientStock when there is simply too little.
+func stockError(ctx context.Context, tx *sqlx.Tx, tenantID, warehouseID, variantID string) error {
+	var found bool
+	const v = `SELECT EXISTS (SELECT 1 FROM variants AS v
+		JOIN products AS p ON p.product_id = v.product_id
+		WHERE p.tenant_id = $1 AND v.variant_id = $2)`
+	if err := tx.GetContext(ctx, &found, v, tenantID, variantID); err != nil {
+		return fmt.Errorf("checking stock variant: %w", err)
+	}
+	if !found {
+		return ErrNotFound
+	}
+
+	if warehouseID != "" {
+		const w = `SELECT EXISTS (SELECT 1 FROM warehouses WHERE tenant_id = $1 AND warehouse_id = $2)`
+		if err := tx.GetContext(ctx, &found, w, tenantID, warehouseID); err != nil {
+			return fmt.Errorf("checking stock warehouse: %w", err)
+		}
+		if !found {
+			return ErrNotFound
+		}
+	}
+
+	return ErrInsufficientStock
+}
+
+// Deposit adds quantity units of a variant to a warehouse of tenantID as part
+// of tx.
+func Deposit(ctx context.Context, tx *sqlx.Tx, tenantID, warehouseID, variantID string, quantity int) error {
+	ctx, span := trace.StartSpan(ctx, "internal.warehouse.Deposit")
+	defer span.End()
+
+	const q = `INSERT INTO stock (warehouse_id, product_id, variant_id, quantity)
+		` + tenantStock + `
+		ON CONFLICT (warehouse_id, variant_id) DO UPDATE SET quantity = stock.quantity + EXCLUDED.quantity`
+	res, err := tx.ExecContext(ctx, q, tenantID, warehouseID, variantID, quantity)
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("depositing stock: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("depositing stock: %w", err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// AddTransfer moves stock of a variant from one warehouse to another and
+// records the transfer.
+func AddTransfer(ctx context.Context, db *sqlx.DB, user auth.Claims, nt NewTransfer, now time.Time) (*Transfer, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.warehouse.AddTransfer")
+	defer span.End()
+
+	for _, id := range []string{nt.VariantID, nt.FromWarehouseID, nt.ToWarehouseID} {
+		if _, err := uuid.Parse(id); err != nil {
+			return nil, ErrInvalidID
+		}
+	}
+
+	t := Transfer{
+		ID:              uuid.New().String(),
+		VariantID:       nt.VariantID,
+		FromWarehouseID: nt.FromWarehouseID,
+		ToWarehouseID:   nt.ToWarehouseID,
+		Quantity:        nt.Quantity,
+		UserID:          user.Subject,
+		DateCreated:     now.UTC(),
+	}
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("starting transfer: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := database.SetTenant(ctx, tx, user.Tenant); err != nil {
+		return nil, err
+	}
+
+	const v = `SELECT v.product_id FROM variants AS v
+		JOIN products AS p ON p.product_id = v.product_id
+		WHERE p.tenant_id = $1 AND v.variant_id = $2`
+	if err := tx.GetContext(ctx, &t.ProductID, v, user.Tenant, t.VariantID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("selecting transfer variant: %w", err)
+	}
+
+	if err := Withdraw(ctx, tx, user.Tenant, t.FromWarehouseID, t.VariantID, t.Quantity); err != nil {
+		return nil, err
+	}
+
+	if err := Deposit(ctx, tx, user.Tenant, t.ToWarehouseID, t.VariantID, t.Quantity); err != nil {
+		return nil, err
+	}
+
+	const q = `INSERT INTO transfers
+		(transfer_id, product_id, variant_id, from_warehouse_id, to_warehouse_id, quantity, user_id, date_created)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	_, err = tx.ExecContext(ctx, q,
+		t.ID, t.ProductID, t.VariantID, t.FromWarehouseID, t.ToWarehouseID,
+		t.Quantity, t.UserID, t.DateCreated)
+	if err != nil {
+		return nil, fmt.Errorf("inserting transfer: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("committing transfer: %w", err)
+	}
+
+	return &t, nil
+}
+
+// isForeignKeyViolation reports whether err was caused by referencing a
+// variant or warehouse that does not exist.
+func isForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23503"
+}
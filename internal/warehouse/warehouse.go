@@ -0,0 +1,131 @@
+package warehouse
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"go.opencensus.io/trace"
+)
+
+// DefaultID identifies the default tenant's default warehouse, created by the
+// migrations. Stock that was recorded before warehouses existed lives here.
+const DefaultID = "d3a3e3c4-7c4e-4b8a-9f0e-6d1a0f1f2b01"
+
+// DefaultName is the name of the default warehouse a new tenant starts with.
+const DefaultName = "Main"
+
+var (
+	ErrNotFound          = errors.New("warehouse not found")
+	ErrInvalidID         = errors.New("ID is not in its proper form")
+	ErrInsufficientStock = errors.New("insufficient stock")
+)
+
+func List(ctx context.Context, db *sqlx.DB, tenantID string) ([]Warehouse, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.warehouse.List")
+	defer span.End()
+
+	var warehouses []Warehouse
+
+	const q = `SELECT * FROM warehouses WHERE tenant_id = $1 ORDER BY name`
+	if err := db.SelectContext(ctx, &warehouses, q, tenantID); err != nil {
+		return nil, fmt.Errorf("selecting warehouses: %w", err)
+	}
+
+	return warehouses, nil
+}
+
+func Retrieve(ctx context.Context, db *sqlx.DB, tenantID, id string) (*Warehouse, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.warehouse.Retrieve")
+	defer span.End()
+
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrInvalidID
+	}
+
+	var w Warehouse
+
+	const q = `SELECT * FROM warehouses WHERE tenant_id = $1 AND warehouse_id = $2`
+	if err := db.GetContext(ctx, &w, q, tenantID, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+
+		return nil, fmt.Errorf("selecting single warehouse: %w", err)
+	}
+
+	return &w, nil
+}
+
+func Create(ctx context.Context, db *sqlx.DB, tenantID string, nw NewWarehouse, now time.Time) (*Warehouse, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.warehouse.Create")
+	defer span.End()
+
+	w := Warehouse{
+		ID:          uuid.New().String(),
+		TenantID:    tenantID,
+		Name:        nw.Name,
+		DateCreated: now.UTC(),
+		DateUpdated: now.UTC(),
+	}
+
+	if err := insert(ctx, db, w); err != nil {
+		return nil, err
+	}
+
+	return &w, nil
+}
+
+// CreateDefault creates the default warehouse of a new tenant.
+func CreateDefault(ctx context.Context, db sqlx.ExecerContext, tenantID string, now time.Time) (*Warehouse, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.warehouse.CreateDefault")
+	defer span.End()
+
+	w := Warehouse{
+		ID:          uuid.New().String(),
+		TenantID:    tenantID,
+		Name:        DefaultName,
+		Default:     true,
+		DateCreated: now.UTC(),
+		DateUpdated: now.UTC(),
+	}
+
+	if err := insert(ctx, db, w); err != nil {
+		return nil, err
+	}
+
+	return &w, nil
+}
+
+func insert(ctx context.Context, db sqlx.ExecerContext, w Warehouse) error {
+	const q = `INSERT INTO warehouses
+		(warehouse_id, tenant_id, name, is_default, date_created, date_updated)
+		VALUES ($1, $2, $3, $4, $5, $6)`
+	if _, err := db.ExecContext(ctx, q, w.ID, w.TenantID, w.Name, w.Default, w.DateCreated, w.DateUpdated); err != nil {
+		return fmt.Errorf("inserting warehouse: %w", err)
+	}
+
+	return nil
+}
+
+// Default returns the ID of the warehouse of tenantID that new stock goes to.
+func Default(ctx context.Context, tx *sqlx.Tx, tenantID string) (string, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.warehouse.Default")
+	defer span.End()
+
+	var id string
+
+	const q = `SELECT warehouse_id FROM warehouses WHERE tenant_id = $1 AND is_default`
+	if err := tx.GetContext(ctx, &id, q, tenantID); err != nil {
+		if err == sql.ErrNoRows {
+			return "", ErrNotFound
+		}
+		return "", fmt.Errorf("selecting default warehouse: %w", err)
+	}
+
+	return id, nil
+}
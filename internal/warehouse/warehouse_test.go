@@ -0,0 +1,110 @@
+package warehouse_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/money"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/platform/auth"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/product"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/tenant"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/tests"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/warehouse"
+)
+
+func TestTransfers(t *testing.T) {
+	db, teardown := tests.NewUnit(t)
+	defer teardown()
+
+	now := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+
+	claims := auth.NewClaims(
+		"718ffbea-f4a1-4667-8ae3-b349da52675e",
+		[]string{auth.RoleAdmin, auth.RoleUser},
+		now, time.Hour,
+	)
+	claims.Permissions = auth.Permissions
+	claims.Tenant = tests.TenantID
+
+	north, err := warehouse.Create(ctx, db, tests.TenantID, warehouse.NewWarehouse{Name: "North"}, now)
+	if err != nil {
+		t.Fatalf("creating warehouse: %s", err)
+	}
+
+	p, err := product.Create(ctx, db, claims, product.NewProduct{Name: "Puzzles", Cost: money.Money{Amount: 25, Currency: "USD"}, Quantity: 10}, now)
+	if err != nil {
+		t.Fatalf("creating product: %s", err)
+	}
+
+	nt := warehouse.NewTransfer{
+		VariantID:       p.ID,
+		FromWarehouseID: warehouse.DefaultID,
+		ToWarehouseID:   north.ID,
+		Quantity:        4,
+	}
+	if _, err := warehouse.AddTransfer(ctx, db, claims, nt, now); err != nil {
+		t.Fatalf("transferring stock: %s", err)
+	}
+
+	stock, err := warehouse.ProductStock(ctx, db, p.ID)
+	if err != nil {
+		t.Fatalf("listing stock: %s", err)
+	}
+
+	levels := make(map[string]int)
+	for _, s := range stock {
+		levels[s.WarehouseID] = s.Quantity
+	}
+	if exp, got := 6, levels[warehouse.DefaultID]; exp != got {
+		t.Fatalf("expected %v in default warehouse, got %v", exp, got)
+	}
+	if exp, got := 4, levels[north.ID]; exp != got {
+		t.Fatalf("expected %v in north warehouse, got %v", exp, got)
+	}
+
+	nt.Quantity = 7
+	if _, err := warehouse.AddTransfer(ctx, db, claims, nt, now); err != warehouse.ErrInsufficientStock {
+		t.Fatalf("expected transferring more than held to fail with %v, got %v", warehouse.ErrInsufficientStock, err)
+	}
+
+	// Stock cannot be moved into another tenant's warehouse, nor can that
+	// tenant see this one's.
+	other, err := tenant.Create(ctx, db, tenant.NewTenant{Name: "Corner Shop"}, now)
+	if err != nil {
+		t.Fatalf("creating tenant: %s", err)
+	}
+	list, err := warehouse.List(ctx, db, other.ID)
+	if err != nil {
+		t.Fatalf("listing warehouses: %s", err)
+	}
+	if len(list) != 1 || !list[0].Default || list[0].Name != warehouse.DefaultName {
+		t.Fatalf("expected the new tenant to hold only its default warehouse, got %+v", list)
+	}
+	if _, err := warehouse.Retrieve(ctx, db, other.ID, north.ID); err != warehouse.ErrNotFound {
+		t.Fatalf("expected retrieving from another tenant to fail with %v, got %v", warehouse.ErrNotFound, err)
+	}
+
+	nt.Quantity = 1
+	nt.ToWarehouseID = list[0].ID
+	if _, err := warehouse.AddTransfer(ctx, db, claims, nt, now); err != warehouse.ErrNotFound {
+		t.Fatalf("expected transferring to another tenant's warehouse to fail with %v, got %v", warehouse.ErrNotFound, err)
+	}
+
+	// Taking stock out of a warehouse the tenant does not have is not a
+	// shortage.
+	from := nt
+	from.FromWarehouseID, from.ToWarehouseID = list[0].ID, north.ID
+	if _, err := warehouse.AddTransfer(ctx, db, claims, from, now); err != warehouse.ErrNotFound {
+		t.Fatalf("expected transferring from another tenant's warehouse to fail with %v, got %v", warehouse.ErrNotFound, err)
+	}
+
+	saved, err := product.Retrieve(ctx, db, tests.TenantID, p.ID)
+	if err != nil {
+		t.Fatalf("getting product: %s", err)
+	}
+	if exp, got := 10, saved.Quantity; exp != got {
+		t.Fatalf("expected total quantity %v, got %v", exp, got)
+	}
+}
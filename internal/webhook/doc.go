@@ -0,0 +1,4 @@
+// Package webhook delivers domain events to the endpoints partners subscribe
+// with. Payloads are signed with each subscription's secret and failed
+// deliveries are retried with exponential backoff before being dead lettered.
+package webhook
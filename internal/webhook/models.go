@@ -0,0 +1,62 @@
+package webhook
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Delivery statuses.
+const (
+	// StatusPending deliveries are sent once NextAttempt has passed.
+	StatusPending = "pending"
+
+	// StatusDelivered deliveries were accepted by the endpoint.
+	StatusDelivered = "delivered"
+
+	// StatusDead deliveries failed too many times and are only sent again
+	// when redelivered by hand.
+	StatusDead = "dead"
+)
+
+// Subscription is an endpoint that is sent the events named in Events, or
+// every event when Events is empty.
+type Subscription struct {
+	ID          string         `db:"subscription_id" json:"id"`
+	TenantID    string         `db:"tenant_id" json:"-"`
+	URL         string         `db:"url" json:"url"`
+	Events      pq.StringArray `db:"events" json:"events"`
+	Secret      string         `db:"secret" json:"-"`
+	DateCreated time.Time      `db:"date_created" json:"date_created"`
+	DateUpdated time.Time      `db:"date_updated" json:"date_updated"`
+}
+
+type NewSubscription struct {
+	URL    string   `json:"url" validate:"required,url"`
+	Events []string `json:"events"`
+}
+
+// CreatedSubscription is returned when a subscription is made. It is the only
+// time the subscription's secret is shown.
+type CreatedSubscription struct {
+	Subscription
+	Secret string `json:"secret"`
+}
+
+// Delivery is an attempt to send one event to one subscription. Payload is
+// the exact body sent so that redeliveries are byte for byte the same.
+type Delivery struct {
+	ID             string          `db:"delivery_id" json:"id"`
+	SubscriptionID string          `db:"subscription_id" json:"subscription_id"`
+	EventID        string          `db:"event_id" json:"event_id"`
+	EventType      string          `db:"event_type" json:"event_type"`
+	Payload        json.RawMessage `db:"payload" json:"payload"`
+	Status         string          `db:"status" json:"status"`
+	Attempts       int             `db:"attempts" json:"attempts"`
+	NextAttempt    *time.Time      `db:"next_attempt_at" json:"next_attempt_at"`
+	LastStatusCode *int            `db:"last_status_code" json:"last_status_code"`
+	LastError      *string         `db:"last_error" json:"last_error"`
+	DateCreated    time.Time       `db:"date_created" json:"date_created"`
+	DateDelivered  *time.Time      `db:"date_delivered" json:"date_delivered"`
+}
@@ -0,0 +1,201 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"go.opencensus.io/trace"
+)
+
+// Headers sent with every delivery besides SignatureHeader.
+const (
+	EventHeader    = "X-Webhook-Event"
+	DeliveryHeader = "X-Webhook-Delivery"
+)
+
+// Policy says how hard a Sender tries to deliver.
+type Policy struct {
+	// MaxAttempts is how many failed attempts dead letter a delivery.
+	MaxAttempts int
+
+	// Backoff is the wait after the first failed attempt. It doubles with
+	// each further failure up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// Next returns when to try again after the given number of failed attempts.
+func (p Policy) Next(attempts int, now time.Time) time.Time {
+	wait := p.Backoff
+	for i := 1; i < attempts && wait < p.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > p.MaxBackoff {
+		wait = p.MaxBackoff
+	}
+	return now.Add(wait)
+}
+
+// Sender posts queued deliveries to their endpoints.
+type Sender struct {
+	db        *sqlx.DB
+	log       *log.Logger
+	client    *http.Client
+	interval  time.Duration
+	batchSize int
+	policy    Policy
+}
+
+// NewSender makes a Sender that looks for due deliveries every interval and
+// sends up to batchSize at a time.
+func NewSender(db *sqlx.DB, log *log.Logger, client *http.Client, interval time.Duration, batchSize int, policy Policy) *Sender {
+	return &Sender{
+		db:        db,
+		log:       log,
+		client:    client,
+		interval:  interval,
+		batchSize: batchSize,
+		policy:    policy,
+	}
+}
+
+// Run sends deliveries until ctx is cancelled.
+func (s *Sender) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			n, err := s.Send(ctx, time.Now())
+			if err != nil && ctx.Err() == nil {
+				s.log.Printf("webhook : ERROR : %v", err)
+			}
+			if err != nil || n < s.batchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// due is a delivery joined with where it goes.
+type due struct {
+	Delivery
+	URL    string `db:"url"`
+	Secret string `db:"secret"`
+}
+
+// Send attempts one batch of the deliveries due at now and reports how many
+// it attempted. Deliveries being sent by another instance are skipped.
+func (s *Sender) Send(ctx context.Context, now time.Time) (int, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.webhook.Send")
+	defer span.End()
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("starting webhook send: %w", err)
+	}
+	defer tx.Rollback()
+
+	var batch []due
+	const q = `SELECT d.*, s.url, s.secret
+		FROM webhook_deliveries AS d
+		JOIN webhook_subscriptions AS s ON s.subscription_id = d.subscription_id
+		WHERE d.status = $1 AND d.next_attempt_at <= $2
+		ORDER BY d.next_attempt_at, d.date_created
+		LIMIT $3
+		FOR UPDATE OF d SKIP LOCKED`
+	if err := tx.SelectContext(ctx, &batch, q, StatusPending, now.UTC(), s.batchSize); err != nil {
+		return 0, fmt.Errorf("selecting due webhook deliveries: %w", err)
+	}
+
+	for _, d := range batch {
+		code, err := s.post(ctx, d, now)
+		if err := s.record(ctx, tx, d, code, err, now); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("committing webhook send: %w", err)
+	}
+
+	return len(batch), nil
+}
+
+// post sends d and returns the endpoint's status code. Any response outside
+// 2xx is an error.
+func (s *Sender) post(ctx context.Context, d due, now time.Time) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, d.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, d.EventType)
+	req.Header.Set(DeliveryHeader, d.ID)
+	req.Header.Set(SignatureHeader, Sign(d.Secret, now, d.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded %s", resp.Status)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// record stores the outcome of an attempt at d, scheduling the next attempt
+// or dead lettering it when it failed.
+func (s *Sender) record(ctx context.Context, tx *sqlx.Tx, d due, code int, sendErr error, now time.Time) error {
+	var statusCode *int
+	if code != 0 {
+		statusCode = &code
+	}
+
+	attempts := d.Attempts + 1
+
+	if sendErr == nil {
+		const q = `UPDATE webhook_deliveries
+			SET status = $2, attempts = $3, next_attempt_at = NULL, last_status_code = $4, last_error = NULL, date_delivered = $5
+			WHERE delivery_id = $1`
+		if _, err := tx.ExecContext(ctx, q, d.ID, StatusDelivered, attempts, statusCode, now.UTC()); err != nil {
+			return fmt.Errorf("recording webhook delivery: %w", err)
+		}
+		return nil
+	}
+
+	status := StatusPending
+	next := s.policy.Next(attempts, now).UTC()
+	nextAttempt := &next
+	if attempts >= s.policy.MaxAttempts {
+		status = StatusDead
+		nextAttempt = nil
+	}
+
+	const q = `UPDATE webhook_deliveries
+		SET status = $2, attempts = $3, next_attempt_at = $4, last_status_code = $5, last_error = $6
+		WHERE delivery_id = $1`
+	if _, err := tx.ExecContext(ctx, q, d.ID, status, attempts, nextAttempt, statusCode, sendErr.Error()); err != nil {
+		return fmt.Errorf("recording failed webhook delivery: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package webhook_test
+
+import (
+	"testing"
+	"time"
+
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/webhook"
+)
+
+func TestSign(t *testing.T) {
+	now := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+	body := []byte(`{"type":"SaleRecorded"}`)
+
+	header := webhook.Sign("secret", now, body)
+
+	sent, err := webhook.Verify("secret", header, body)
+	if err != nil {
+		t.Fatalf("verifying own signature: %s", err)
+	}
+	if !sent.Equal(now) {
+		t.Errorf("signature time is %v, want %v", sent, now)
+	}
+
+	tests := []struct {
+		name   string
+		secret string
+		header string
+		body   string
+	}{
+		{"wrong secret", "other", header, string(body)},
+		{"tampered body", "secret", header, `{"type":"ProductDeleted"}`},
+		{"malformed header", "secret", "v1", string(body)},
+		{"missing timestamp", "secret", "v1=abc", string(body)},
+	}
+
+	for _, tt := range tests {
+		if _, err := webhook.Verify(tt.secret, tt.header, []byte(tt.body)); err != webhook.ErrBadSignature {
+			t.Errorf("%s: got %v, want %v", tt.name, err, webhook.ErrBadSignature)
+		}
+	}
+}
+
+func TestPolicyNext(t *testing.T) {
+	now := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+	p := webhook.Policy{MaxAttempts: 10, Backoff: time.Minute, MaxBackoff: 10 * time.Minute}
+
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{3, 4 * time.Minute},
+		{4, 8 * time.Minute},
+		{5, 10 * time.Minute},
+		{9, 10 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := p.Next(tt.attempts, now).Sub(now); got != tt.want {
+			t.Errorf("after %d attempts: waits %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
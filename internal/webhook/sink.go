@@ -0,0 +1,53 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/outbox"
+)
+
+// Sink returns an outbox.Sink that queues a delivery of each event for every
+// subscription of the event's tenant interested in it. The deliveries are sent by a Sender, so a
+// slow or failing endpoint never holds up the outbox.
+func Sink(db *sqlx.DB) outbox.Sink {
+	return outbox.SinkFunc(func(ctx context.Context, e outbox.Event) error {
+		return enqueue(ctx, db, e, time.Now())
+	})
+}
+
+func enqueue(ctx context.Context, db *sqlx.DB, e outbox.Event, now time.Time) error {
+	if !knownEvent(e.Type) {
+		return nil
+	}
+
+	body, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("encoding event %d: %w", e.Sequence, err)
+	}
+
+	var subs []string
+	const s = `SELECT subscription_id FROM webhook_subscriptions
+		WHERE tenant_id = $1 AND (cardinality(events) = 0 OR $2 = ANY(events))`
+	if err := db.SelectContext(ctx, &subs, s, e.TenantID, e.Type); err != nil {
+		return fmt.Errorf("selecting webhook subscriptions: %w", err)
+	}
+
+	// The outbox may hand over an event more than once; it is queued once
+	// per subscription all the same.
+	const q = `INSERT INTO webhook_deliveries
+		(delivery_id, subscription_id, event_id, event_type, payload, status, attempts, next_attempt_at, date_created)
+		VALUES ($1, $2, $3, $4, $5, $6, 0, $7, $7)
+		ON CONFLICT (subscription_id, event_id) DO NOTHING`
+	for _, id := range subs {
+		if _, err := db.ExecContext(ctx, q, uuid.New().String(), id, e.ID, e.Type, body, StatusPending, now.UTC()); err != nil {
+			return fmt.Errorf("queueing webhook delivery: %w", err)
+		}
+	}
+
+	return nil
+}
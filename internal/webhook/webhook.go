@@ -0,0 +1,171 @@
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/outbox"
+	"go.opencensus.io/trace"
+)
+
+var (
+	ErrNotFound         = errors.New("webhook subscription not found")
+	ErrDeliveryNotFound = errors.New("webhook delivery not found")
+	ErrInvalidID        = errors.New("ID is not in its proper form")
+	ErrUnknownEvent     = errors.New("unknown event type")
+)
+
+// List returns the subscriptions of tenantID.
+func List(ctx context.Context, db *sqlx.DB, tenantID string) ([]Subscription, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.webhook.List")
+	defer span.End()
+
+	subs := []Subscription{}
+	const q = `SELECT * FROM webhook_subscriptions WHERE tenant_id = $1 ORDER BY date_created`
+	if err := db.SelectContext(ctx, &subs, q, tenantID); err != nil {
+		return nil, fmt.Errorf("selecting webhook subscriptions: %w", err)
+	}
+
+	return subs, nil
+}
+
+// Retrieve finds a subscription of tenantID. Subscriptions of other tenants
+// are reported as not found.
+func Retrieve(ctx context.Context, db *sqlx.DB, tenantID, id string) (*Subscription, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.webhook.Retrieve")
+	defer span.End()
+
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrInvalidID
+	}
+
+	var s Subscription
+	const q = `SELECT * FROM webhook_subscriptions WHERE tenant_id = $1 AND subscription_id = $2`
+	if err := db.GetContext(ctx, &s, q, tenantID, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("selecting webhook subscription %q: %w", id, err)
+	}
+
+	return &s, nil
+}
+
+// Create subscribes an endpoint to the events of tenantID and generates the
+// secret its deliveries are signed with.
+func Create(ctx context.Context, db *sqlx.DB, tenantID string, ns NewSubscription, now time.Time) (*CreatedSubscription, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.webhook.Create")
+	defer span.End()
+
+	events := []string{}
+	for _, e := range ns.Events {
+		if !knownEvent(e) {
+			return nil, ErrUnknownEvent
+		}
+		events = append(events, e)
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("generating webhook secret: %w", err)
+	}
+
+	s := Subscription{
+		ID:          uuid.New().String(),
+		TenantID:    tenantID,
+		URL:         ns.URL,
+		Events:      events,
+		Secret:      hex.EncodeToString(secret),
+		DateCreated: now.UTC(),
+		DateUpdated: now.UTC(),
+	}
+
+	const q = `INSERT INTO webhook_subscriptions
+		(subscription_id, tenant_id, url, events, secret, date_created, date_updated)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	if _, err := db.ExecContext(ctx, q, s.ID, s.TenantID, s.URL, s.Events, s.Secret, s.DateCreated, s.DateUpdated); err != nil {
+		return nil, fmt.Errorf("inserting webhook subscription: %w", err)
+	}
+
+	return &CreatedSubscription{Subscription: s, Secret: s.Secret}, nil
+}
+
+// Delete unsubscribes an endpoint of tenantID. Its delivery log goes with it.
+func Delete(ctx context.Context, db *sqlx.DB, tenantID, id string) error {
+	ctx, span := trace.StartSpan(ctx, "internal.webhook.Delete")
+	defer span.End()
+
+	if _, err := uuid.Parse(id); err != nil {
+		return ErrInvalidID
+	}
+
+	const q = `DELETE FROM webhook_subscriptions WHERE tenant_id = $1 AND subscription_id = $2`
+	if _, err := db.ExecContext(ctx, q, tenantID, id); err != nil {
+		return fmt.Errorf("deleting webhook subscription %q: %w", id, err)
+	}
+
+	return nil
+}
+
+// Deliveries returns the delivery log of a subscription of tenantID, newest
+// first.
+func Deliveries(ctx context.Context, db *sqlx.DB, tenantID, subscriptionID string) ([]Delivery, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.webhook.Deliveries")
+	defer span.End()
+
+	if _, err := Retrieve(ctx, db, tenantID, subscriptionID); err != nil {
+		return nil, err
+	}
+
+	list := []Delivery{}
+	const q = `SELECT * FROM webhook_deliveries WHERE subscription_id = $1 ORDER BY date_created DESC, delivery_id`
+	if err := db.SelectContext(ctx, &list, q, subscriptionID); err != nil {
+		return nil, fmt.Errorf("selecting webhook deliveries: %w", err)
+	}
+
+	return list, nil
+}
+
+// Redeliver queues a delivery to one of tenantID's subscriptions to be sent
+// again straight away with a fresh set of attempts, whatever its status.
+func Redeliver(ctx context.Context, db *sqlx.DB, tenantID, id string, now time.Time) (*Delivery, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.webhook.Redeliver")
+	defer span.End()
+
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrInvalidID
+	}
+
+	var d Delivery
+	const q = `UPDATE webhook_deliveries AS d
+		SET status = $3, attempts = 0, next_attempt_at = $4
+		FROM webhook_subscriptions AS s
+		WHERE d.delivery_id = $2
+			AND s.subscription_id = d.subscription_id
+			AND s.tenant_id = $1
+		RETURNING d.*`
+	if err := db.GetContext(ctx, &d, q, tenantID, id, StatusPending, now.UTC()); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrDeliveryNotFound
+		}
+		return nil, fmt.Errorf("queueing redelivery of %q: %w", id, err)
+	}
+
+	return &d, nil
+}
+
+func knownEvent(e string) bool {
+	for _, t := range outbox.Types {
+		if e == t {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,166 @@
+package webhook_test
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/outbox"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/tests"
+	"gitlab.fenbishuo.com/fenbishuo/service-training/internal/webhook"
+)
+
+// receiver is an endpoint that answers with status and remembers whether the
+// deliveries it got were correctly signed.
+type receiver struct {
+	mu       sync.Mutex
+	secret   string
+	status   int
+	received []string
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	body, _ := ioutil.ReadAll(r.Body)
+	if _, err := webhook.Verify(rc.secret, r.Header.Get(webhook.SignatureHeader), body); err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	rc.received = append(rc.received, r.Header.Get(webhook.EventHeader))
+	w.WriteHeader(rc.status)
+}
+
+func TestDeliveries(t *testing.T) {
+	db, teardown := tests.NewUnit(t)
+	defer teardown()
+
+	ctx := context.Background()
+	now := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	rc := receiver{status: http.StatusInternalServerError}
+	srv := httptest.NewServer(&rc)
+	defer srv.Close()
+
+	if _, err := webhook.Create(ctx, db, tests.TenantID, webhook.NewSubscription{URL: srv.URL, Events: []string{"Bogus"}}, now); err != webhook.ErrUnknownEvent {
+		t.Fatalf("subscribing to an unknown event: got %v, want %v", err, webhook.ErrUnknownEvent)
+	}
+
+	sub, err := webhook.Create(ctx, db, tests.TenantID, webhook.NewSubscription{URL: srv.URL, Events: []string{outbox.SaleRecorded}}, now)
+	if err != nil {
+		t.Fatalf("creating subscription: %s", err)
+	}
+	rc.secret = sub.Secret
+
+	// Another tenant can neither see the subscription nor its deliveries.
+	const other = "7f0c2b1e-9d3a-4c5b-8e6f-1a2b3c4d5e6f"
+	if _, err := webhook.Retrieve(ctx, db, other, sub.ID); err != webhook.ErrNotFound {
+		t.Fatalf("retrieving from another tenant: got %v, want %v", err, webhook.ErrNotFound)
+	}
+	if list, err := webhook.List(ctx, db, other); err != nil || len(list) != 0 {
+		t.Fatalf("listing another tenant's subscriptions: got %v, %v, want none", list, err)
+	}
+	if _, err := webhook.Deliveries(ctx, db, other, sub.ID); err != webhook.ErrNotFound {
+		t.Fatalf("listing deliveries from another tenant: got %v, want %v", err, webhook.ErrNotFound)
+	}
+
+	event := func(tenantID, eventType string) outbox.Event {
+		return outbox.Event{
+			ID:            uuid.New().String(),
+			TenantID:      tenantID,
+			AggregateType: outbox.AggregateSale,
+			AggregateID:   uuid.New().String(),
+			Type:          eventType,
+			Payload:       []byte(`{}`),
+			DateCreated:   now,
+		}
+	}
+
+	sink := webhook.Sink(db)
+	sale := event(tests.TenantID, outbox.SaleRecorded)
+
+	// Events the subscription did not ask for or of another tenant are not
+	// queued, and an event handed over twice is queued once.
+	for _, e := range []outbox.Event{sale, sale, event(tests.TenantID, outbox.ProductCreated), event(other, outbox.SaleRecorded)} {
+		if err := sink.Deliver(ctx, e); err != nil {
+			t.Fatalf("queueing event: %s", err)
+		}
+	}
+
+	policy := webhook.Policy{MaxAttempts: 2, Backoff: time.Minute, MaxBackoff: time.Hour}
+	sender := webhook.NewSender(db, log.New(os.Stderr, "", 0), srv.Client(), time.Second, 10, policy)
+
+	send := func(at time.Time) int {
+		t.Helper()
+		n, err := sender.Send(ctx, at)
+		if err != nil {
+			t.Fatalf("sending: %s", err)
+		}
+		return n
+	}
+
+	deliveries := func() []webhook.Delivery {
+		t.Helper()
+		list, err := webhook.Deliveries(ctx, db, tests.TenantID, sub.ID)
+		if err != nil {
+			t.Fatalf("listing deliveries: %s", err)
+		}
+		return list
+	}
+
+	if n := send(now); n != 1 {
+		t.Fatalf("first send attempted %d deliveries, want 1", n)
+	}
+
+	d := deliveries()
+	if len(d) != 1 || d[0].Status != webhook.StatusPending || d[0].Attempts != 1 || *d[0].LastStatusCode != http.StatusInternalServerError {
+		t.Fatalf("after a failed attempt the log is %+v", d)
+	}
+
+	// Nothing is retried before the backoff has passed.
+	if n := send(now.Add(30 * time.Second)); n != 0 {
+		t.Fatalf("sent %d deliveries before the backoff passed", n)
+	}
+
+	send(now.Add(time.Minute))
+	if d := deliveries(); d[0].Status != webhook.StatusDead || d[0].Attempts != 2 {
+		t.Fatalf("after the last attempt the delivery is %s with %d attempts, want dead with 2", d[0].Status, d[0].Attempts)
+	}
+
+	rc.status = http.StatusNoContent
+
+	later := now.Add(time.Hour)
+	if _, err := webhook.Redeliver(ctx, db, other, d[0].ID, later); err != webhook.ErrDeliveryNotFound {
+		t.Fatalf("redelivering from another tenant: got %v, want %v", err, webhook.ErrDeliveryNotFound)
+	}
+	if _, err := webhook.Redeliver(ctx, db, tests.TenantID, d[0].ID, later); err != nil {
+		t.Fatalf("redelivering: %s", err)
+	}
+	send(later)
+
+	if d := deliveries(); d[0].Status != webhook.StatusDelivered || d[0].DateDelivered == nil {
+		t.Fatalf("after redelivery the delivery is %s", d[0].Status)
+	}
+
+	if len(rc.received) != 3 {
+		t.Fatalf("receiver got %d correctly signed deliveries, want 3", len(rc.received))
+	}
+	for _, e := range rc.received {
+		if e != outbox.SaleRecorded {
+			t.Fatalf("receiver got a %s event", e)
+		}
+	}
+
+	if _, err := webhook.Redeliver(ctx, db, tests.TenantID, uuid.New().String(), later); err != webhook.ErrDeliveryNotFound {
+		t.Fatalf("redelivering an unknown delivery: got %v, want %v", err, webhook.ErrDeliveryNotFound)
+	}
+}